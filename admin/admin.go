@@ -0,0 +1,148 @@
+// Package admin serves the fault injection commands used in game days, alongside the overlay explorer.
+// Commands are POST requests and answer 202 once the fault is handed to the protocol:
+//
+//	/admin/fault/drop?peer=host:port           drops a neighbour as if it went down
+//	/admin/fault/dialfailed?peer=host:port     reports a failed dial to a peer
+//	/admin/fault/suppress-shuffles?seconds=T   stops starting shuffles for T seconds
+//
+// and the membership commands, served when an admin secret is set:
+//
+//	/admin/membership/blacklist?peer=host:port&seconds=T   blacklists a peer for T seconds, forever if 0
+//	/admin/membership/rejoin                              rejoins the overlay through the bootstraps
+//
+// Both go through a Guard, see guard.go.
+//
+// It also serves the view history of the node, for post-incident analysis:
+//
+//	GET /admin/view?at=RFC3339 time            the active view at that time
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/x-bot/protocol"
+)
+
+type FaultInjector interface {
+	DropNeighbour(p peer.Peer) error
+	FailDial(p peer.Peer) error
+	SuppressShuffles(d time.Duration) error
+}
+
+func Handler(node FaultInjector, guard *Guard) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/fault/drop", guard.guarded(peerCommand(node.DropNeighbour)))
+	mux.HandleFunc("/admin/fault/dialfailed", guard.guarded(peerCommand(node.FailDial)))
+	mux.HandleFunc("/admin/fault/suppress-shuffles", guard.guarded(func(r *http.Request) (int, error) {
+		seconds, err := strconv.Atoi(r.URL.Query().Get("seconds"))
+		if err != nil || seconds <= 0 {
+			return http.StatusBadRequest, errors.New("seconds must be a positive integer")
+		}
+		return refused(node.SuppressShuffles(time.Duration(seconds) * time.Second))
+	}))
+	return mux
+}
+
+type MembershipOperator interface {
+	Blacklist(p peer.Peer, ttl time.Duration, propagate bool) error
+	JoinOverlay(force bool)
+}
+
+func MembershipHandler(node MembershipOperator, guard *Guard) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/membership/blacklist", guard.guarded(func(r *http.Request) (int, error) {
+		p, err := parsePeer(r.URL.Query().Get("peer"))
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		seconds, err := strconv.Atoi(r.URL.Query().Get("seconds"))
+		if err != nil || seconds < 0 {
+			return http.StatusBadRequest, errors.New("seconds must be a non negative integer")
+		}
+		return refused(node.Blacklist(p, time.Duration(seconds)*time.Second, false))
+	}))
+	mux.HandleFunc("/admin/membership/rejoin", guard.guarded(func(r *http.Request) (int, error) {
+		node.JoinOverlay(true)
+		return http.StatusAccepted, nil
+	}))
+	return mux
+}
+
+type ViewHistory interface {
+	ViewAt(at time.Time) (protocol.ViewHistoryEntry, bool)
+}
+
+func HistoryHandler(node ViewHistory) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/view", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		at, err := time.Parse(time.RFC3339, r.URL.Query().Get("at"))
+		if err != nil {
+			http.Error(w, "at must be an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+		entry, ok := node.ViewAt(at)
+		if !ok {
+			http.Error(w, "no view recorded at that time", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entry)
+	})
+	return mux
+}
+
+func peerCommand(inject func(peer.Peer) error) func(r *http.Request) (int, error) {
+	return func(r *http.Request) (int, error) {
+		p, err := parsePeer(r.URL.Query().Get("peer"))
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		return refused(inject(p))
+	}
+}
+
+// parsePeer only needs the protocol port, peers are matched on it.
+func parsePeer(hostPort string) (peer.Peer, error) {
+	host, portStr, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer %q: %w", hostPort, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid peer %q: host is not an IP", hostPort)
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid peer %q: %w", hostPort, err)
+	}
+	return peer.NewPeer(ip, uint16(port), 0), nil
+}
+
+func allowed(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+// refused answers commands the node refused with 403.
+func refused(err error) (int, error) {
+	if err != nil {
+		return http.StatusForbidden, err
+	}
+	return http.StatusAccepted, nil
+}
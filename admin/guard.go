@@ -0,0 +1,176 @@
+package admin
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Commands mutating membership go through a Guard. With a secret, they must carry it as a bearer
+// token and run right away. Without one, they take two steps: the first request is answered with 428
+// and a confirm token, and the command runs once it is repeated with &confirm=<token> within
+// confirmTTL. Tokens are single use and only confirm the exact command they were issued for. Anyone
+// reaching the server can confirm, so commands without a secret are only safe on loopback addresses.
+// Every request is audited, whatever its outcome.
+
+const confirmTTL = 30 * time.Second
+
+const (
+	outcomeAccepted      = "accepted"
+	outcomeFailed        = "failed"
+	outcomeUnauthorized  = "unauthorized"
+	outcomeConfirmIssued = "confirmIssued"
+	outcomeBadConfirm    = "badConfirm"
+)
+
+// AuditRecord is written as a JSON line for every guarded request.
+type AuditRecord struct {
+	Time          time.Time `json:"time"`
+	Remote        string    `json:"remote"`
+	Command       string    `json:"command"`
+	Authenticated bool      `json:"authenticated"`
+	Confirmed     bool      `json:"confirmed"`
+	Outcome       string    `json:"outcome"`
+	Error         string    `json:"error,omitempty"`
+}
+
+type Guard struct {
+	secret  []byte
+	audit   io.Writer
+	mu      sync.Mutex
+	pending map[string]pendingCommand
+}
+
+type pendingCommand struct {
+	command string
+	expires time.Time
+}
+
+// NewGuard returns a guard requiring secret, or confirm tokens if it is empty, and writing the audit
+// records to audit, which may be nil.
+func NewGuard(secret string, audit io.Writer) *Guard {
+	g := &Guard{audit: audit, pending: map[string]pendingCommand{}}
+	if secret != "" {
+		g.secret = []byte(secret)
+	}
+	return g
+}
+
+// guarded wraps a command run by a POST request, run returns the error the command was refused with.
+func (g *Guard) guarded(run func(r *http.Request) (int, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !allowed(w, r) {
+			return
+		}
+		record := AuditRecord{Time: time.Now(), Remote: r.RemoteAddr, Command: command(r)}
+		defer g.write(&record)
+		if g.secret != nil {
+			if !g.authenticated(r) {
+				record.Outcome = outcomeUnauthorized
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "missing or invalid admin secret", http.StatusUnauthorized)
+				return
+			}
+			record.Authenticated = true
+		} else {
+			token := r.URL.Query().Get("confirm")
+			if token == "" {
+				record.Outcome = outcomeConfirmIssued
+				g.issueConfirm(w, record.Command)
+				return
+			}
+			if !g.confirm(token, record.Command) {
+				record.Outcome = outcomeBadConfirm
+				http.Error(w, "unknown, expired or mismatched confirm token", http.StatusForbidden)
+				return
+			}
+			record.Confirmed = true
+		}
+		status, err := run(r)
+		if err != nil {
+			record.Outcome = outcomeFailed
+			record.Error = err.Error()
+			http.Error(w, err.Error(), status)
+			return
+		}
+		record.Outcome = outcomeAccepted
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (g *Guard) authenticated(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), g.secret) == 1
+}
+
+// command identifies a request without its confirm token, so the token confirms the same command.
+func command(r *http.Request) string {
+	query := r.URL.Query()
+	query.Del("confirm")
+	if len(query) == 0 {
+		return r.URL.Path
+	}
+	return r.URL.Path + "?" + query.Encode()
+}
+
+func (g *Guard) issueConfirm(w http.ResponseWriter, cmd string) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		http.Error(w, "could not issue confirm token", http.StatusInternalServerError)
+		return
+	}
+	token := hex.EncodeToString(raw)
+	expires := time.Now().Add(confirmTTL)
+	g.mu.Lock()
+	for issued, pending := range g.pending {
+		if time.Now().After(pending.expires) {
+			delete(g.pending, issued)
+		}
+	}
+	g.pending[token] = pendingCommand{command: cmd, expires: expires}
+	g.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionRequired)
+	err := json.NewEncoder(w).Encode(struct {
+		Command string    `json:"command"`
+		Confirm string    `json:"confirm"`
+		Expires time.Time `json:"expires"`
+	}{cmd, token, expires})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "could not send admin confirm token:", err)
+	}
+}
+
+func (g *Guard) confirm(token, cmd string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	pending, ok := g.pending[token]
+	if !ok {
+		return false
+	}
+	delete(g.pending, token)
+	return pending.command == cmd && time.Now().Before(pending.expires)
+}
+
+func (g *Guard) write(record *AuditRecord) {
+	if g.audit == nil {
+		return
+	}
+	line, _ := json.Marshal(record)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, err := g.audit.Write(append(line, '\n')); err != nil {
+		fmt.Fprintln(os.Stderr, "could not write admin audit record:", err, string(line))
+	}
+}
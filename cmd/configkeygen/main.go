@@ -0,0 +1,25 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	privKeyPath := flag.String("out", "configAdmin.key", "file to write the private key to")
+	flag.Parse()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err = ioutil.WriteFile(*privKeyPath, []byte(base64.StdEncoding.EncodeToString(priv)), 0600); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("configAdminPublicKey: %s\n", base64.StdEncoding.EncodeToString(pub))
+}
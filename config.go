@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nm-morais/x-bot/protocol"
+)
+
+// Configuration precedence, from lowest to highest: YAML file < HYPARVIEW_* environment variables < -set flags < dedicated flags.
+
+const envPrefix = "HYPARVIEW_"
+
+type setFlags []string
+
+func (s *setFlags) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *setFlags) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+func loadConfig(path string, sets []string) (*protocol.HyparviewConfig, error) {
+	conf, err := readConfFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = applyEnvOverlay(conf); err != nil {
+		return nil, err
+	}
+	for _, set := range sets {
+		kv := strings.SplitN(set, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid -set %q, expected key=value", set)
+		}
+		if err = setConfigField(conf, kv[0], kv[1]); err != nil {
+			return nil, err
+		}
+	}
+	return conf, nil
+}
+
+// applyEnvOverlay overrides each scalar config field with the environment variable named after its
+// yaml key path, e.g. activeViewSize -> HYPARVIEW_ACTIVE_VIEW_SIZE and self.port -> HYPARVIEW_SELF_PORT.
+func applyEnvOverlay(conf *protocol.HyparviewConfig) error {
+	for _, key := range configKeys(reflect.TypeOf(*conf), "") {
+		value, ok := os.LookupEnv(envVarName(key))
+		if !ok {
+			continue
+		}
+		if err := setConfigField(conf, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func configKeys(t reflect.Type, prefix string) []string {
+	keys := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			// feature settings are grouped in inlined structs
+			keys = append(keys, configKeys(field.Type, prefix)...)
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			keys = append(keys, configKeys(field.Type, prefix+tag+".")...)
+		case reflect.Ptr:
+			if field.Type.Elem().Kind() == reflect.Struct {
+				keys = append(keys, configKeys(field.Type.Elem(), prefix+tag+".")...)
+			}
+		case reflect.String, reflect.Bool, reflect.Int:
+			keys = append(keys, prefix+tag)
+		}
+	}
+	return keys
+}
+
+func envVarName(key string) string {
+	var sb strings.Builder
+	sb.WriteString(envPrefix)
+	for i, r := range key {
+		switch {
+		case r == '.':
+			sb.WriteRune('_')
+		case unicode.IsUpper(r) && i > 0 && key[i-1] != '.':
+			sb.WriteRune('_')
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return sb.String()
+}
+
+func setConfigField(conf *protocol.HyparviewConfig, key, value string) error {
+	v := reflect.ValueOf(conf).Elem()
+	for _, part := range strings.Split(key, ".") {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return fmt.Errorf("unknown config key %s", key)
+		}
+		field, ok := yamlField(v, part)
+		if !ok {
+			return fmt.Errorf("unknown config key %s", key)
+		}
+		v = field
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("config key %s: %w", key, err)
+		}
+		v.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("config key %s: %w", key, err)
+		}
+		v.SetInt(int64(n))
+	default:
+		return fmt.Errorf("config key %s cannot be overridden", key)
+	}
+	return nil
+}
+
+// yamlField returns the field of struct v with the given yaml key, looking into the inlined structs.
+func yamlField(v reflect.Value, key string) (reflect.Value, bool) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if inlined, ok := yamlField(v.Field(i), key); ok {
+				return inlined, true
+			}
+			continue
+		}
+		if strings.Split(field.Tag.Get("yaml"), ",")[0] == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/protocolManager"
+	"github.com/nm-morais/x-bot/admin"
+	"github.com/nm-morais/x-bot/explorer"
+	"github.com/nm-morais/x-bot/metrics"
+	"github.com/nm-morais/x-bot/protocol"
+)
+
+// Exit codes, meant for supervisors (see scripts/hyparview.service): config errors are not worth restarting on.
+const (
+	exitOK          = 0
+	exitConfigError = 1
+	exitJoinFailed  = 2
+	exitPanic       = 3
+)
+
+// time given to the stream manager to flush the disconnect messages after leaving
+const leaveFlushDelay = 500 * time.Millisecond
+
+// time given to the protocol goroutine to report its state after a panic
+const panicSnapshotTimeout = time.Second
+
+func runDaemon(p protocolManager.ProtocolManager, hyparview *protocol.Hyparview, conf *protocol.HyparviewConfig) int {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+
+	p.StartAsync()
+	joinFailed := make(chan error, 1)
+	if conf.JoinCompletionTimeoutSeconds > 0 {
+		hyparview.OnJoined(func(err error) {
+			if err != nil {
+				joinFailed <- err
+			}
+		})
+	}
+	if conf.DebugPort > 0 {
+		go serveExplorer(hyparview, conf)
+	}
+	if conf.MetricsPort > 0 {
+		go serveMetrics(hyparview, conf)
+	}
+	if *peersFile != "" {
+		importPeers(hyparview, *peersFile)
+	}
+	for {
+		select {
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				fmt.Println("Got SIGHUP, reloading config")
+				hyparview.ReloadConfig()
+				continue
+			}
+			if sig == syscall.SIGUSR1 {
+				publishConfigUpdate(hyparview, conf)
+				continue
+			}
+			if sig == syscall.SIGUSR2 {
+				exportPeers(hyparview, *peersFile)
+				continue
+			}
+			fmt.Printf("Got %s, leaving overlay\n", sig)
+			return leave(hyparview)
+		case err := <-joinFailed:
+			fmt.Fprintln(os.Stderr, "could not join overlay:", err)
+			return exitJoinFailed
+		}
+	}
+}
+
+func serveExplorer(hyparview *protocol.Hyparview, conf *protocol.HyparviewConfig) {
+	addr := net.JoinHostPort(conf.SelfPeer.Host, strconv.Itoa(conf.DebugPort))
+	fmt.Println("Serving overlay explorer on", addr)
+	mux := http.NewServeMux()
+	mux.Handle("/", explorer.Handler(hyparview))
+	guard := admin.NewGuard(conf.AdminSecret, openAdminAudit(conf))
+	if conf.FaultInjection {
+		if conf.AdminSecret == "" && !isLoopback(conf.SelfPeer.Host) {
+			// confirm tokens do not authenticate anyone, anybody reaching the port could inject faults
+			fmt.Fprintln(os.Stderr, "Fault injection enabled without an admin secret, not serving admin commands on non-loopback address", addr)
+		} else {
+			fmt.Println("Fault injection enabled, serving admin commands on", addr)
+			mux.Handle("/admin/fault/", admin.Handler(hyparview, guard))
+		}
+	}
+	if conf.AdminSecret != "" {
+		fmt.Println("Admin secret set, serving membership commands on", addr)
+		mux.Handle("/admin/membership/", admin.MembershipHandler(hyparview, guard))
+	}
+	if conf.ViewHistoryRetentionMinutes > 0 {
+		mux.Handle("/admin/view", admin.HistoryHandler(hyparview))
+	}
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		fmt.Fprintln(os.Stderr, "could not serve overlay explorer:", err)
+	}
+}
+
+func isLoopback(host string) bool {
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// openAdminAudit opens the audit log of the admin commands, in the log folder.
+func openAdminAudit(conf *protocol.HyparviewConfig) io.Writer {
+	path := filepath.Join(conf.LogFolder, "admin_audit.log")
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "could not open admin audit log, auditing to stderr:", err)
+		return os.Stderr
+	}
+	return file
+}
+
+func serveMetrics(hyparview *protocol.Hyparview, conf *protocol.HyparviewConfig) {
+	addr := net.JoinHostPort(conf.SelfPeer.Host, strconv.Itoa(conf.MetricsPort))
+	fmt.Println("Serving Prometheus metrics on", addr+"/metrics")
+	if err := metrics.Serve(addr, hyparview); err != nil {
+		fmt.Fprintln(os.Stderr, "could not serve metrics:", err)
+	}
+}
+
+func publishConfigUpdate(hyparview *protocol.Hyparview, conf *protocol.HyparviewConfig) {
+	fmt.Println("Got SIGUSR1, publishing config update from", conf.ConfigUpdateFile)
+	update, err := protocol.ReadConfigUpdateFile(conf.ConfigUpdateFile)
+	if err == nil {
+		err = hyparview.InjectConfigUpdate(update)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "could not publish config update:", err)
+	}
+}
+
+func peersFileFormat(path string) string {
+	if strings.HasSuffix(path, ".json") {
+		return protocol.PeerFormatJSON
+	}
+	return protocol.PeerFormatText
+}
+
+func importPeers(hyparview *protocol.Hyparview, path string) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		defer f.Close()
+		err = hyparview.ImportPeers(f, peersFileFormat(path))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "could not import peers:", err)
+	}
+}
+
+func exportPeers(hyparview *protocol.Hyparview, path string) {
+	if path == "" {
+		hyparview.ExportPeers(os.Stdout, protocol.PeerFormatText)
+		return
+	}
+	f, err := os.Create(path)
+	if err == nil {
+		defer f.Close()
+		err = hyparview.ExportPeers(f, peersFileFormat(path))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "could not export peers:", err)
+	}
+}
+
+func leave(hyparview *protocol.Hyparview) int {
+	select {
+	case <-hyparview.Leave():
+		time.Sleep(leaveFlushDelay)
+	case <-time.After(*leaveTimeout):
+		fmt.Fprintln(os.Stderr, "timed out leaving overlay")
+	}
+	return exitOK
+}
+
+// recoverPanic only sees panics raised on the main goroutine, panics inside protocol handlers are
+// recovered by the protocol itself.
+func recoverPanic(r interface{}, hyparview *protocol.Hyparview) int {
+	fmt.Fprintf(os.Stderr, "panic: %v\n%s", r, debug.Stack())
+	if hyparview == nil {
+		return exitPanic
+	}
+	// the protocol goroutine is still running, so its state is read through it, unless it is stuck too
+	snapshotCh := make(chan protocol.NodeSnapshot, 1)
+	go func() { snapshotCh <- hyparview.Snapshot() }()
+	select {
+	case snapshot := <-snapshotCh:
+		json.NewEncoder(os.Stderr).Encode(snapshot)
+	case <-time.After(panicSnapshotTimeout):
+		fmt.Fprintln(os.Stderr, "timed out waiting for the protocol state")
+	}
+	return exitPanic
+}
@@ -0,0 +1,55 @@
+// Package explorer serves a read-only web page showing the views, counters and recent events of the
+// local node, refreshed periodically from a JSON API.
+package explorer
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+
+	"github.com/nm-morais/x-bot/protocol"
+)
+
+//go:embed index.html
+var indexPage []byte
+
+type Snapshotter interface {
+	Snapshot() protocol.NodeSnapshot
+}
+
+// HealthReporter is implemented by nodes serving /api/health, answered with 503 when critical.
+type HealthReporter interface {
+	HealthReport() protocol.HealthReport
+}
+
+func Handler(node Snapshotter) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(indexPage)
+	})
+	mux.HandleFunc("/api/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(node.Snapshot())
+	})
+	if reporter, ok := node.(HealthReporter); ok {
+		mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
+			report := reporter.HealthReport()
+			w.Header().Set("Content-Type", "application/json")
+			if report.Status == protocol.HealthCritical {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			json.NewEncoder(w).Encode(report)
+		})
+	}
+	return mux
+}
+
+// Serve blocks serving the explorer on addr.
+func Serve(addr string, node Snapshotter) error {
+	return http.ListenAndServe(addr, Handler(node))
+}
@@ -15,21 +15,50 @@ import (
 )
 
 var (
-	randomPort   *bool
-	bootstraps   *string
-	listenIP     *string
-	confFilePath *string
+	randomPort    *bool
+	bootstraps    *string
+	listenIP      *string
+	confFilePath  *string
+	failureDomain *string
+	port          *int
+	leaveTimeout  *time.Duration
+	peersFile     *string
+	sets          setFlags
 )
 
 func main() {
+	os.Exit(run())
+}
+
+func run() (exitCode int) {
+	var hyparview *protocol.Hyparview
+	defer func() {
+		if r := recover(); r != nil {
+			exitCode = recoverPanic(r, hyparview)
+		}
+	}()
+
 	randomPort = flag.Bool("rport", false, "choose random port")
 	bootstraps = flag.String("bootstraps", "", "choose custom bootstrap nodes (space-separated ip:port list)")
 	listenIP = flag.String("listenIP", "", "choose custom ip to listen to")
 	confFilePath = flag.String("conf", "config/exampleConfig.yml", "specify conf file path")
+	failureDomain = flag.String("failureDomain", "", "choose the failure domain label of this node")
+	port = flag.Int("port", 0, "choose custom port to listen to")
+	peersFile = flag.String("peersFile", "", "peer list (host:port lines, or JSON if ending in .json) imported on start and exported on SIGUSR2")
+	leaveTimeout = flag.Duration("leaveTimeout", 5*time.Second, "max time to wait for a graceful leave on SIGTERM")
+	flag.Var(&sets, "set", "override a config key (yaml key path, e.g. -set activeViewSize=4), can be repeated")
 	fmt.Println("ARGS:", os.Args)
 	flag.Parse()
 	fmt.Println(*confFilePath)
-	conf := readConfFile(*confFilePath)
+	conf, err := loadConfig(*confFilePath, sets)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid config:", err)
+		return exitConfigError
+	}
+
+	if *port != 0 {
+		conf.SelfPeer.Port = *port
+	}
 
 	if *randomPort {
 		fmt.Println("Setting custom port")
@@ -45,17 +74,26 @@ func main() {
 		conf.SelfPeer.Host = *listenIP
 	}
 
+	if failureDomain != nil && *failureDomain != "" {
+		conf.FailureDomain = *failureDomain
+	}
+
 	conf.LogFolder += fmt.Sprintf("%s:%d/", conf.SelfPeer.Host, conf.SelfPeer.Port)
 	if listenIP != nil && *listenIP != "" {
 		conf.SelfPeer.Host = *listenIP
 	}
+	// checked once the flags are applied, as they may set the self peer missing from the file
+	if err = protocol.ValidateConfig(conf); err != nil {
+		fmt.Fprintln(os.Stderr, "invalid config:", err)
+		return exitConfigError
+	}
 	protoManagerConf := babel.Config{
 		Silent:    false,
 		LogFolder: conf.LogFolder,
 		SmConf: babel.StreamManagerConf{
 			BatchMaxSizeBytes: 20000,
 			BatchTimeout:      time.Second,
-			DialTimeout:       time.Millisecond * time.Duration(conf.DialTimeoutMiliseconds),
+			DialTimeout:       protocol.BabelDialTimeout(conf),
 		},
 		Peer: peer.NewPeer(net.ParseIP(conf.SelfPeer.Host), uint16(conf.SelfPeer.Port), 0),
 	}
@@ -63,14 +101,16 @@ func main() {
 	p := babel.NewProtoManager(protoManagerConf)
 	p.RegisterListenAddr(&net.TCPAddr{IP: protoManagerConf.Peer.IP(), Port: int(protoManagerConf.Peer.ProtosPort())})
 	p.RegisterListenAddr(&net.UDPAddr{IP: protoManagerConf.Peer.IP(), Port: int(protoManagerConf.Peer.ProtosPort())})
-	p.RegisterProtocol(protocol.NewHyparviewProtocol(p, conf))
-	p.StartSync()
+	hyparview = protocol.NewHyparviewProtocol(p, conf).(*protocol.Hyparview)
+	hyparview.WatchConfigFile(*confFilePath)
+	p.RegisterProtocol(hyparview)
+	return runDaemon(p, hyparview, conf)
 }
 
-func readConfFile(path string) *protocol.HyparviewConfig {
+func readConfFile(path string) (*protocol.HyparviewConfig, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	defer f.Close()
 
@@ -78,9 +118,9 @@ func readConfFile(path string) *protocol.HyparviewConfig {
 	decoder := yaml.NewDecoder(f)
 	err = decoder.Decode(cfg)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return cfg
+	return cfg, nil
 }
 
 func GetFreePort() (port int, err error) {
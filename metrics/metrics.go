@@ -0,0 +1,98 @@
+// Package metrics serves the metrics of the local node in the Prometheus text exposition format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/nm-morais/x-bot/protocol"
+)
+
+type Source interface {
+	Metrics() protocol.Metrics
+}
+
+func Handler(node Source) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w, node.Metrics())
+	})
+}
+
+// Serve blocks serving the metrics on addr, under /metrics.
+func Serve(addr string, node Source) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler(node))
+	return http.ListenAndServe(addr, mux)
+}
+
+// Write writes m in the Prometheus text exposition format.
+func Write(w io.Writer, m protocol.Metrics) {
+	gauge(w, "hyparview_active_view_size", "Number of peers in the active view.", m.ActiveViewSize)
+	gauge(w, "hyparview_active_view_capacity", "Maximum number of peers in the active view.", m.ActiveViewCapacity)
+	gauge(w, "hyparview_passive_view_size", "Number of peers in the passive view.", m.PassiveViewSize)
+	gauge(w, "hyparview_passive_view_capacity", "Effective capacity of the passive view, which follows churn when maxPassiveViewSize is set.", m.PassiveViewCapacity)
+	gauge(w, "hyparview_connected_neighbours", "Number of active view peers with an established connection.", m.Connected)
+	counter(w, "hyparview_join_attempts_total", "Join requests sent to bootstrap nodes.", m.JoinAttempts)
+	counter(w, "hyparview_shuffles_sent_total", "Shuffle requests started by this node.", m.ShufflesSent)
+	counter(w, "hyparview_shuffles_received_total", "Shuffle requests received by this node.", m.ShufflesReceived)
+	counter(w, "hyparview_neighbour_ups_total", "Connections established to active view peers.", m.NeighbourUps)
+	counter(w, "hyparview_neighbour_downs_total", "Connected peers removed from the active view.", m.NeighbourDowns)
+	counter(w, "hyparview_dial_failures_total", "Failed dials.", m.DialFailures)
+	countersByType(w, "hyparview_messages_sent_total", "Messages sent, by message type.", m.MessagesSent)
+	countersByType(w, "hyparview_messages_received_total", "Messages received, by message type.", m.MessagesReceived)
+	latencies(w, "hyparview_message_latency_seconds", "Time from sending a message to an active view peer to its delivery, by message type.", m.MessageLatencies)
+}
+
+func header(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func gauge(w io.Writer, name, help string, value int) {
+	header(w, name, help, "gauge")
+	fmt.Fprintf(w, "%s %d\n", name, value)
+}
+
+func counter(w io.Writer, name, help string, value int) {
+	header(w, name, help, "counter")
+	fmt.Fprintf(w, "%s %d\n", name, value)
+}
+
+func sortedTypes(m map[string]int) []string {
+	types := make([]string, 0, len(m))
+	for msgType := range m {
+		types = append(types, msgType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func countersByType(w io.Writer, name, help string, values map[string]int) {
+	header(w, name, help, "counter")
+	for _, msgType := range sortedTypes(values) {
+		fmt.Fprintf(w, "%s{type=%q} %d\n", name, msgType, values[msgType])
+	}
+}
+
+func latencies(w io.Writer, name, help string, histograms map[string]*protocol.LatencyHistogram) {
+	header(w, name, help, "histogram")
+	counts := map[string]int{}
+	for msgType, hist := range histograms {
+		counts[msgType] = hist.Count
+	}
+	for _, msgType := range sortedTypes(counts) {
+		hist := histograms[msgType]
+		cumulative := 0
+		for i, bound := range hist.Bounds {
+			cumulative += hist.Buckets[i]
+			le := strconv.FormatFloat(bound.Seconds(), 'g', -1, 64)
+			fmt.Fprintf(w, "%s_bucket{type=%q,le=%q} %d\n", name, msgType, le, cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{type=%q,le=\"+Inf\"} %d\n", name, msgType, hist.Count)
+		fmt.Fprintf(w, "%s_sum{type=%q} %g\n", name, msgType, hist.Sum.Seconds())
+		fmt.Fprintf(w, "%s_count{type=%q} %d\n", name, msgType, hist.Count)
+	}
+}
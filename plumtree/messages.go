@@ -0,0 +1,126 @@
+package plumtree
+
+import (
+	"encoding/binary"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+)
+
+const GossipMessageType = 1600
+
+type GossipMessage struct {
+	ID      MessageID
+	Round   uint16
+	Payload []byte
+}
+type gossipMessageSerializer struct{}
+
+var defaultGossipMessageSerializer = gossipMessageSerializer{}
+
+func (GossipMessage) Type() message.ID                   { return GossipMessageType }
+func (GossipMessage) Serializer() message.Serializer     { return defaultGossipMessageSerializer }
+func (GossipMessage) Deserializer() message.Deserializer { return defaultGossipMessageSerializer }
+func (gossipMessageSerializer) Serialize(msg message.Message) []byte {
+	converted := msg.(GossipMessage)
+	msgBytes := make([]byte, 10, 10+len(converted.Payload))
+	binary.BigEndian.PutUint64(msgBytes, uint64(converted.ID))
+	binary.BigEndian.PutUint16(msgBytes[8:], converted.Round)
+	return append(msgBytes, converted.Payload...)
+}
+
+func (gossipMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 10 {
+		return GossipMessage{}
+	}
+	return GossipMessage{
+		ID:      MessageID(binary.BigEndian.Uint64(msgBytes)),
+		Round:   binary.BigEndian.Uint16(msgBytes[8:]),
+		Payload: append([]byte{}, msgBytes[10:]...),
+	}
+}
+
+const IHaveMessageType = 1601
+
+type IHaveMessage struct {
+	Round uint16
+	IDs   []MessageID
+}
+type iHaveMessageSerializer struct{}
+
+var defaultIHaveMessageSerializer = iHaveMessageSerializer{}
+
+func (IHaveMessage) Type() message.ID                   { return IHaveMessageType }
+func (IHaveMessage) Serializer() message.Serializer     { return defaultIHaveMessageSerializer }
+func (IHaveMessage) Deserializer() message.Deserializer { return defaultIHaveMessageSerializer }
+func (iHaveMessageSerializer) Serialize(msg message.Message) []byte {
+	converted := msg.(IHaveMessage)
+	msgBytes := make([]byte, 2+8*len(converted.IDs))
+	binary.BigEndian.PutUint16(msgBytes, converted.Round)
+	for i, id := range converted.IDs {
+		binary.BigEndian.PutUint64(msgBytes[2+8*i:], uint64(id))
+	}
+	return msgBytes
+}
+
+func (iHaveMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 2 {
+		return IHaveMessage{}
+	}
+	ids := make([]MessageID, 0, (len(msgBytes)-2)/8)
+	for i := 2; i+8 <= len(msgBytes); i += 8 {
+		ids = append(ids, MessageID(binary.BigEndian.Uint64(msgBytes[i:])))
+	}
+	return IHaveMessage{
+		Round: binary.BigEndian.Uint16(msgBytes),
+		IDs:   ids,
+	}
+}
+
+const GraftMessageType = 1602
+
+type GraftMessage struct {
+	ID    MessageID
+	Round uint16
+}
+type graftMessageSerializer struct{}
+
+var defaultGraftMessageSerializer = graftMessageSerializer{}
+
+func (GraftMessage) Type() message.ID                   { return GraftMessageType }
+func (GraftMessage) Serializer() message.Serializer     { return defaultGraftMessageSerializer }
+func (GraftMessage) Deserializer() message.Deserializer { return defaultGraftMessageSerializer }
+func (graftMessageSerializer) Serialize(msg message.Message) []byte {
+	converted := msg.(GraftMessage)
+	msgBytes := make([]byte, 10)
+	binary.BigEndian.PutUint64(msgBytes, uint64(converted.ID))
+	binary.BigEndian.PutUint16(msgBytes[8:], converted.Round)
+	return msgBytes
+}
+
+func (graftMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 10 {
+		return GraftMessage{}
+	}
+	return GraftMessage{
+		ID:    MessageID(binary.BigEndian.Uint64(msgBytes)),
+		Round: binary.BigEndian.Uint16(msgBytes[8:]),
+	}
+}
+
+const PruneMessageType = 1603
+
+type PruneMessage struct{}
+type pruneMessageSerializer struct{}
+
+var defaultPruneMessageSerializer = pruneMessageSerializer{}
+
+func (PruneMessage) Type() message.ID                   { return PruneMessageType }
+func (PruneMessage) Serializer() message.Serializer     { return defaultPruneMessageSerializer }
+func (PruneMessage) Deserializer() message.Deserializer { return defaultPruneMessageSerializer }
+func (pruneMessageSerializer) Serialize(msg message.Message) []byte {
+	return []byte{}
+}
+
+func (pruneMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	return PruneMessage{}
+}
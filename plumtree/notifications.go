@@ -0,0 +1,21 @@
+package plumtree
+
+import (
+	"github.com/nm-morais/go-babel/pkg/notification"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+const DeliverNotificationType = 10600
+
+// DeliverNotification is emitted once for every message broadcast in the overlay, including the ones
+// broadcast by this node. Sender is the neighbour the message was received from, nil for local ones.
+type DeliverNotification struct {
+	MsgID   MessageID
+	Payload []byte
+	Sender  peer.Peer
+	Round   uint16
+}
+
+func (n DeliverNotification) ID() notification.ID {
+	return DeliverNotificationType
+}
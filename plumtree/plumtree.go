@@ -0,0 +1,303 @@
+// Package plumtree implements Plumtree (Leitão et al., "Epidemic Broadcast Trees") on top of the
+// HyParView active view, as a separate babel protocol. Messages are pushed eagerly along a spanning
+// tree and announced lazily, through IHave messages, to the other neighbours. A duplicate prunes the
+// link it came from out of the tree, and a message announced but not received within IHaveTimeout is
+// requested with a Graft, which adds the link back. The tree follows the neighbours announced by the
+// HyParView NeighborUp and NeighborDown notifications and is sent over their connections.
+package plumtree
+
+import (
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/errors"
+	"github.com/nm-morais/go-babel/pkg/logs"
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/notification"
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/go-babel/pkg/protocol"
+	"github.com/nm-morais/go-babel/pkg/protocolManager"
+	"github.com/nm-morais/go-babel/pkg/timer"
+	hyparview "github.com/nm-morais/x-bot/protocol"
+	"github.com/nm-morais/x-bot/protocol/registry"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	protoID = 2000
+	name    = "Plumtree"
+
+	defaultIHaveTimeout = time.Second
+	defaultGraftTimeout = 500 * time.Millisecond
+	defaultCacheTTL     = time.Minute
+)
+
+// MessageID identifies a broadcast message, it is chosen by the broadcaster and must be unique.
+type MessageID uint64
+
+type Config struct {
+	// time waited for a message announced by an IHave before grafting its announcer
+	IHaveTimeoutMillis int `yaml:"iHaveTimeoutMillis"`
+	// time waited for a grafted message before grafting the next announcer
+	GraftTimeoutMillis int `yaml:"graftTimeoutMillis"`
+	// time received messages are kept to answer grafts and drop duplicates
+	CacheTTLSeconds int `yaml:"cacheTTLSeconds"`
+}
+
+type received struct {
+	payload []byte
+	round   uint16
+	at      time.Time
+}
+
+type announcement struct {
+	sender peer.Peer
+	round  uint16
+}
+
+type Plumtree struct {
+	babel         protocolManager.ProtocolManager
+	logger        *logrus.Logger
+	conf          Config
+	eagerPush     map[string]peer.Peer
+	lazyPush      map[string]peer.Peer
+	received      map[MessageID]*received
+	missing       map[MessageID][]announcement
+	missingTimers map[MessageID]int
+}
+
+func NewPlumtreeProtocol(babel protocolManager.ProtocolManager, conf Config) *Plumtree {
+	return &Plumtree{
+		babel:         babel,
+		logger:        logs.NewLogger(name),
+		conf:          conf,
+		eagerPush:     make(map[string]peer.Peer),
+		lazyPush:      make(map[string]peer.Peer),
+		received:      make(map[MessageID]*received),
+		missing:       make(map[MessageID][]announcement),
+		missingTimers: make(map[MessageID]int),
+	}
+}
+
+func (p *Plumtree) ID() protocol.ID {
+	return protoID
+}
+
+func (p *Plumtree) Name() string {
+	return name
+}
+
+func (p *Plumtree) Logger() *logrus.Logger {
+	return p.logger
+}
+
+func (p *Plumtree) Init() {
+	err := registry.Declare(name,
+		registry.Range{Kind: registry.Message, From: 1600, To: 1699},
+		registry.Range{Kind: registry.Timer, From: 1600, To: 1699},
+		registry.Range{Kind: registry.Notification, From: 10600, To: 10699},
+	)
+	if err != nil {
+		panic(err)
+	}
+	p.babel.RegisterTimerHandler(protoID, BroadcastTimerID, p.HandleBroadcastTimer)
+	p.babel.RegisterTimerHandler(protoID, MissingTimerID, p.HandleMissingTimer)
+	p.babel.RegisterTimerHandler(protoID, CacheTimerID, p.HandleCacheTimer)
+	p.babel.RegisterMessageHandler(protoID, GossipMessage{}, p.HandleGossipMessage)
+	p.babel.RegisterMessageHandler(protoID, IHaveMessage{}, p.HandleIHaveMessage)
+	p.babel.RegisterMessageHandler(protoID, GraftMessage{}, p.HandleGraftMessage)
+	p.babel.RegisterMessageHandler(protoID, PruneMessage{}, p.HandlePruneMessage)
+	p.babel.RegisterNotificationHandler(protoID, hyparview.NeighborUpNotification{}, p.HandleNeighborUp)
+	p.babel.RegisterNotificationHandler(protoID, hyparview.NeighborDownNotification{}, p.HandleNeighborDown)
+}
+
+func (p *Plumtree) Start() {
+	p.babel.RegisterPeriodicTimer(protoID, CacheTimer{duration: p.cacheTTL()}, false)
+}
+
+// Broadcast disseminates payload to every node of the overlay, which deliver it through a
+// DeliverNotification, this node included. It may be called from any goroutine.
+func (p *Plumtree) Broadcast(id MessageID, payload []byte) {
+	p.babel.RegisterTimer(protoID, BroadcastTimer{id: id, payload: payload})
+}
+
+func (p *Plumtree) iHaveTimeout() time.Duration {
+	if p.conf.IHaveTimeoutMillis > 0 {
+		return time.Duration(p.conf.IHaveTimeoutMillis) * time.Millisecond
+	}
+	return defaultIHaveTimeout
+}
+
+func (p *Plumtree) graftTimeout() time.Duration {
+	if p.conf.GraftTimeoutMillis > 0 {
+		return time.Duration(p.conf.GraftTimeoutMillis) * time.Millisecond
+	}
+	return defaultGraftTimeout
+}
+
+func (p *Plumtree) cacheTTL() time.Duration {
+	if p.conf.CacheTTLSeconds > 0 {
+		return time.Duration(p.conf.CacheTTLSeconds) * time.Second
+	}
+	return defaultCacheTTL
+}
+
+// ---------------- Tree ----------------
+
+func (p *Plumtree) HandleNeighborUp(n notification.Notification) {
+	up := n.(hyparview.NeighborUpNotification).PeerUp
+	p.logger.Infof("Neighbour %s up, adding it to the eager push set", up.String())
+	p.eagerPush[up.String()] = up
+	delete(p.lazyPush, up.String())
+}
+
+func (p *Plumtree) HandleNeighborDown(n notification.Notification) {
+	down := n.(hyparview.NeighborDownNotification).PeerDown
+	p.logger.Infof("Neighbour %s down, removing it from the tree", down.String())
+	delete(p.eagerPush, down.String())
+	delete(p.lazyPush, down.String())
+	for id, announcements := range p.missing {
+		kept := announcements[:0]
+		for _, a := range announcements {
+			if !peer.PeersEqual(a.sender, down) {
+				kept = append(kept, a)
+			}
+		}
+		p.missing[id] = kept
+	}
+}
+
+func (p *Plumtree) makeEager(neighbour peer.Peer) {
+	delete(p.lazyPush, neighbour.String())
+	p.eagerPush[neighbour.String()] = neighbour
+}
+
+func (p *Plumtree) makeLazy(neighbour peer.Peer) {
+	if _, ok := p.eagerPush[neighbour.String()]; !ok {
+		return
+	}
+	delete(p.eagerPush, neighbour.String())
+	p.lazyPush[neighbour.String()] = neighbour
+}
+
+// ---------------- Dissemination ----------------
+
+func (p *Plumtree) HandleBroadcastTimer(t timer.Timer) {
+	broadcast := t.(BroadcastTimer)
+	if _, ok := p.received[broadcast.id]; ok {
+		p.logger.Warnf("Not broadcasting message %d, its ID was already seen", broadcast.id)
+		return
+	}
+	p.deliver(broadcast.id, broadcast.payload, 0, nil)
+}
+
+func (p *Plumtree) HandleGossipMessage(sender peer.Peer, m message.Message) {
+	gossip := m.(GossipMessage)
+	if _, ok := p.received[gossip.ID]; ok {
+		p.logger.Infof("Duplicate of message %d from %s, pruning it", gossip.ID, sender.String())
+		p.makeLazy(sender)
+		p.sendMessage(PruneMessage{}, sender)
+		return
+	}
+	if timerID, ok := p.missingTimers[gossip.ID]; ok {
+		p.babel.CancelTimer(timerID)
+		delete(p.missingTimers, gossip.ID)
+	}
+	delete(p.missing, gossip.ID)
+	p.makeEager(sender)
+	p.deliver(gossip.ID, gossip.Payload, gossip.Round, sender)
+}
+
+// deliver delivers a message seen for the first time and pushes it on, sender is nil for local ones.
+func (p *Plumtree) deliver(id MessageID, payload []byte, round uint16, sender peer.Peer) {
+	p.received[id] = &received{payload: payload, round: round, at: time.Now()}
+	p.babel.SendNotification(DeliverNotification{MsgID: id, Payload: payload, Sender: sender, Round: round})
+	gossip := GossipMessage{ID: id, Round: round + 1, Payload: payload}
+	for _, neighbour := range p.eagerPush {
+		if sender == nil || !peer.PeersEqual(neighbour, sender) {
+			p.sendMessage(gossip, neighbour)
+		}
+	}
+	iHave := IHaveMessage{Round: round + 1, IDs: []MessageID{id}}
+	for _, neighbour := range p.lazyPush {
+		if sender == nil || !peer.PeersEqual(neighbour, sender) {
+			p.sendMessage(iHave, neighbour)
+		}
+	}
+}
+
+func (p *Plumtree) HandleIHaveMessage(sender peer.Peer, m message.Message) {
+	iHave := m.(IHaveMessage)
+	for _, id := range iHave.IDs {
+		if _, ok := p.received[id]; ok {
+			continue
+		}
+		p.missing[id] = append(p.missing[id], announcement{sender: sender, round: iHave.Round})
+		if _, ok := p.missingTimers[id]; !ok {
+			p.missingTimers[id] = p.babel.RegisterTimer(protoID, MissingTimer{duration: p.iHaveTimeout(), id: id})
+		}
+	}
+}
+
+func (p *Plumtree) HandleMissingTimer(t timer.Timer) {
+	id := t.(MissingTimer).id
+	delete(p.missingTimers, id)
+	if _, ok := p.received[id]; ok {
+		return
+	}
+	announcements := p.missing[id]
+	if len(announcements) == 0 {
+		p.logger.Warnf("Message %d missing and no neighbour left to graft it from", id)
+		delete(p.missing, id)
+		return
+	}
+	first := announcements[0]
+	p.missing[id] = announcements[1:]
+	p.missingTimers[id] = p.babel.RegisterTimer(protoID, MissingTimer{duration: p.graftTimeout(), id: id})
+	p.logger.Infof("Message %d missing, grafting %s", id, first.sender.String())
+	p.makeEager(first.sender)
+	p.sendMessage(GraftMessage{ID: id, Round: first.round}, first.sender)
+}
+
+func (p *Plumtree) HandleGraftMessage(sender peer.Peer, m message.Message) {
+	graft := m.(GraftMessage)
+	p.makeEager(sender)
+	if msg, ok := p.received[graft.ID]; ok {
+		p.sendMessage(GossipMessage{ID: graft.ID, Round: msg.round + 1, Payload: msg.payload}, sender)
+	}
+}
+
+func (p *Plumtree) HandlePruneMessage(sender peer.Peer, m message.Message) {
+	p.makeLazy(sender)
+}
+
+func (p *Plumtree) HandleCacheTimer(t timer.Timer) {
+	for id, msg := range p.received {
+		if time.Since(msg.at) > p.cacheTTL() {
+			delete(p.received, id)
+		}
+	}
+}
+
+func (p *Plumtree) sendMessage(msg message.Message, target peer.Peer) {
+	p.babel.SendMessage(msg, target, protoID, protoID, false)
+}
+
+// ---------------- Connections, owned by HyParView ----------------
+
+func (p *Plumtree) InConnRequested(dialerProto protocol.ID, peer peer.Peer) bool {
+	return false
+}
+
+func (p *Plumtree) DialSuccess(sourceProto protocol.ID, peer peer.Peer) bool {
+	return false
+}
+
+func (p *Plumtree) DialFailed(peer peer.Peer) {}
+
+func (p *Plumtree) OutConnDown(peer peer.Peer) {}
+
+func (p *Plumtree) MessageDelivered(msg message.Message, peer peer.Peer) {}
+
+func (p *Plumtree) MessageDeliveryErr(msg message.Message, peer peer.Peer, err errors.Error) {
+	p.logger.Warnf("Message %T was not sent to %s because: %s", msg, peer.String(), err.Reason())
+}
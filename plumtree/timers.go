@@ -0,0 +1,52 @@
+package plumtree
+
+import (
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+const BroadcastTimerID = 1600
+
+type BroadcastTimer struct {
+	duration time.Duration
+	id       MessageID
+	payload  []byte
+}
+
+func (BroadcastTimer) ID() timer.ID {
+	return BroadcastTimerID
+}
+
+func (s BroadcastTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const MissingTimerID = 1601
+
+type MissingTimer struct {
+	duration time.Duration
+	id       MessageID
+}
+
+func (MissingTimer) ID() timer.ID {
+	return MissingTimerID
+}
+
+func (s MissingTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const CacheTimerID = 1602
+
+type CacheTimer struct {
+	duration time.Duration
+}
+
+func (CacheTimer) ID() timer.ID {
+	return CacheTimerID
+}
+
+func (s CacheTimer) Duration() time.Duration {
+	return s.duration
+}
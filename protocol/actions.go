@@ -0,0 +1,176 @@
+package protocol
+
+import (
+	"container/heap"
+	"encoding/json"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// Dials and neighbour requests are not sent by the handlers deciding on them but queued as pending
+// actions, executed in priority order by ActionTimer. An action queued while an equivalent one (same
+// kind and peer) is still pending is coalesced into it, keeping the highest priority, so a neighbour
+// redialed by several maintenance ticks or promoted by several code paths in a row is only dialed or
+// asked once. With MaxActionsPerSecond set, at most that many actions run per second, smoothing the
+// bursts that follow mass failures; otherwise the whole queue runs on the next event loop turn.
+
+// actionState holds the pending actions.
+type actionState struct {
+	actions          *actionQueue
+	actionsScheduled bool
+	actionStats      ActionStats
+}
+
+type actionKind int
+
+const (
+	actionDial actionKind = iota
+	actionNeighbourRequest
+)
+
+func (k actionKind) String() string {
+	switch k {
+	case actionDial:
+		return "dial"
+	case actionNeighbourRequest:
+		return "neighbourRequest"
+	default:
+		return "unknown"
+	}
+}
+
+type actionPriority int
+
+const (
+	priorityNormal actionPriority = iota
+	priorityHigh
+)
+
+type pendingAction struct {
+	kind     actionKind
+	peer     peer.Peer
+	priority actionPriority
+	queuedAt time.Time
+	index    int
+}
+
+func (a *pendingAction) key() string {
+	return a.kind.String() + "/" + a.peer.String()
+}
+
+// actionQueue is a heap of pending actions, highest priority first, then oldest first.
+type actionQueue struct {
+	pending []*pendingAction
+	byKey   map[string]*pendingAction
+}
+
+func newActionQueue() *actionQueue {
+	return &actionQueue{byKey: map[string]*pendingAction{}}
+}
+
+func (q *actionQueue) Len() int { return len(q.pending) }
+
+func (q *actionQueue) Less(i, j int) bool {
+	if q.pending[i].priority != q.pending[j].priority {
+		return q.pending[i].priority > q.pending[j].priority
+	}
+	return q.pending[i].queuedAt.Before(q.pending[j].queuedAt)
+}
+
+func (q *actionQueue) Swap(i, j int) {
+	q.pending[i], q.pending[j] = q.pending[j], q.pending[i]
+	q.pending[i].index = i
+	q.pending[j].index = j
+}
+
+func (q *actionQueue) Push(x interface{}) {
+	action := x.(*pendingAction)
+	action.index = len(q.pending)
+	q.pending = append(q.pending, action)
+	q.byKey[action.key()] = action
+}
+
+func (q *actionQueue) Pop() interface{} {
+	last := q.pending[len(q.pending)-1]
+	q.pending = q.pending[:len(q.pending)-1]
+	delete(q.byKey, last.key())
+	return last
+}
+
+type ActionStats struct {
+	Queued    int `json:"queued"`
+	Coalesced int `json:"coalesced"`
+	Executed  int `json:"executed"`
+	Stale     int `json:"stale"`
+	Pending   int `json:"pending"`
+}
+
+// queueAction queues an action, or coalesces it into the pending action of the same kind for p.
+func (h *Hyparview) queueAction(kind actionKind, p peer.Peer, priority actionPriority) {
+	action := &pendingAction{kind: kind, peer: p, priority: priority, queuedAt: h.timeNow()}
+	if pending, ok := h.actions.byKey[action.key()]; ok {
+		h.actionStats.Coalesced++
+		if priority > pending.priority {
+			pending.priority = priority
+			heap.Fix(h.actions, pending.index)
+		}
+		return
+	}
+	h.actionStats.Queued++
+	heap.Push(h.actions, action)
+	if !h.actionsScheduled {
+		h.actionsScheduled = true
+		h.babel.RegisterTimer(h.ID(), ActionTimer{})
+	}
+}
+
+func (h *Hyparview) HandleActionTimer(t timer.Timer) {
+	h.actionsScheduled = false
+	if h.hasLeft() {
+		return
+	}
+	limit := h.conf.MaxActionsPerSecond
+	for executed := 0; h.actions.Len() > 0 && (limit <= 0 || executed < 1); executed++ {
+		h.runAction(heap.Pop(h.actions).(*pendingAction))
+	}
+	if h.actions.Len() > 0 {
+		h.actionsScheduled = true
+		h.babel.RegisterTimer(h.ID(), ActionTimer{duration: time.Second / time.Duration(limit)})
+	}
+}
+
+// runAction executes a pending action, unless it no longer applies.
+func (h *Hyparview) runAction(action *pendingAction) {
+	switch action.kind {
+	case actionDial:
+		p, ok := h.activeView.get(action.peer)
+		if !ok {
+			h.actionStats.Stale++
+			return
+		}
+		h.dialNow(p)
+	case actionNeighbourRequest:
+		if h.activeView.contains(action.peer) || h.decommissioning() || h.frozen() {
+			h.actionStats.Stale++
+			return
+		}
+		h.sendNeighbourRequest(action.peer)
+	}
+	h.actionStats.Executed++
+}
+
+func (h *Hyparview) actionStatsSnapshot() ActionStats {
+	stats := h.actionStats
+	stats.Pending = h.actions.Len()
+	return stats
+}
+
+func (h *Hyparview) logActionStats() {
+	res, err := json.Marshal(h.actionStatsSnapshot())
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("actions", "%s", string(res))
+}
@@ -0,0 +1,65 @@
+package protocol
+
+import (
+	"time"
+)
+
+// With MaxPassiveViewSize above PassiveViewSize, the passive view capacity follows the churn of the
+// active view: higher churn needs a larger pool of candidates to replace failed neighbours quickly.
+// Every minute, the capacity grows by a quarter of PassiveViewSize while at least
+// PassiveGrowChurnPerMinute neighbours were lost in the last minute, up to MaxPassiveViewSize, and
+// shrinks back the same way, down to PassiveViewSize, once churn falls below half of that. The gap
+// between both thresholds keeps the capacity from flapping. Every change is logged as
+// <passiveCapacity>, and the current capacity is exported as hyparview_passive_view_capacity.
+
+// AdaptivePassiveConfig lets the passive view grow with churn.
+type AdaptivePassiveConfig struct {
+	MaxPassiveViewSize        int `yaml:"maxPassiveViewSize"`
+	PassiveGrowChurnPerMinute int `yaml:"passiveGrowChurnPerMinute"`
+}
+
+const passiveResizeInterval = time.Minute
+
+func (h *Hyparview) adaptivePassiveEnabled() bool {
+	return h.conf.MaxPassiveViewSize > h.conf.PassiveViewSize
+}
+
+func (h *Hyparview) passiveGrowChurn() int {
+	if h.conf.PassiveGrowChurnPerMinute > 0 {
+		return h.conf.PassiveGrowChurnPerMinute
+	}
+	return h.conf.ActiveViewSize
+}
+
+func (h *Hyparview) adaptPassiveCapacity() {
+	if !h.adaptivePassiveEnabled() || h.elapsedSince(h.passiveResizedAt) < passiveResizeInterval {
+		return
+	}
+	step := h.conf.PassiveViewSize / 4
+	if step < 1 {
+		step = 1
+	}
+	churn := h.recentChurn()
+	capacity := h.passiveView.capacity
+	switch {
+	case churn >= h.passiveGrowChurn():
+		capacity += step
+		if capacity > h.conf.MaxPassiveViewSize {
+			capacity = h.conf.MaxPassiveViewSize
+		}
+	case churn*2 < h.passiveGrowChurn():
+		capacity -= step
+		if capacity < h.conf.PassiveViewSize {
+			capacity = h.conf.PassiveViewSize
+		}
+	}
+	if capacity == h.passiveView.capacity {
+		return
+	}
+	h.passiveResizedAt = h.timeNow()
+	h.analytics("passiveCapacity", "%d %d churn=%d", h.passiveView.capacity, capacity, churn)
+	h.passiveView.capacity = capacity
+	for h.passiveView.size() > h.passiveView.capacity {
+		h.passiveView.dropRandom()
+	}
+}
@@ -0,0 +1,77 @@
+package protocol
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Machine consumed lines (<inView>, <peerLifetimes>, ...) go through analytics. By default they are
+// logged by the protocol logger as before, so they depend on its level and format. With
+// AnalyticsLogFile set they are appended to that file instead, whatever the log level, one record per
+// line with a stable format:
+//
+//	<RFC 3339 UTC timestamp, nanoseconds> <tag> <payload>
+//
+// e.g. "2021-03-04T10:11:12.123456789Z <inView> [{"ip":"10.0.0.2"}]". Warnings and errors (injected
+// faults, watchdog stalls) are also logged by the protocol logger, so operators keep seeing them.
+
+type analyticsLog struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func (h *Hyparview) openAnalyticsLog() {
+	path := h.conf.AnalyticsLogFile
+	if path == "" {
+		return
+	}
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	var file *os.File
+	if err == nil {
+		file, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	}
+	if err != nil {
+		h.logger.Errorf("Could not open analytics log %s, logging analytics to the protocol log: %s", path, err)
+		return
+	}
+	h.analyticsLog = &analyticsLog{file: file}
+}
+
+// analytics records a machine consumed line, it may be called from any goroutine.
+func (h *Hyparview) analytics(tag string, format string, args ...interface{}) {
+	h.recordAnalytics(logrus.InfoLevel, tag, fmt.Sprintf(format, args...))
+}
+
+func (h *Hyparview) analyticsWarn(tag string, format string, args ...interface{}) {
+	h.recordAnalytics(logrus.WarnLevel, tag, fmt.Sprintf(format, args...))
+}
+
+func (h *Hyparview) analyticsError(tag string, format string, args ...interface{}) {
+	h.recordAnalytics(logrus.ErrorLevel, tag, fmt.Sprintf(format, args...))
+}
+
+func (h *Hyparview) recordAnalytics(level logrus.Level, tag string, payload string) {
+	if h.analyticsLog == nil || level <= logrus.WarnLevel {
+		switch {
+		case level <= logrus.ErrorLevel:
+			h.logger.Errorf("<%s> %s", tag, payload)
+		case level == logrus.WarnLevel:
+			h.logger.Warnf("<%s> %s", tag, payload)
+		default:
+			h.logger.Infof("<%s> %s", tag, payload)
+		}
+	}
+	if h.analyticsLog == nil {
+		return
+	}
+	h.analyticsLog.mu.Lock()
+	defer h.analyticsLog.mu.Unlock()
+	if _, err := fmt.Fprintf(h.analyticsLog.file, "%s <%s> %s\n", h.timeNow().UTC().Format(time.RFC3339Nano), tag, payload); err != nil {
+		h.logger.Errorf("Could not write to analytics log: %s", err)
+	}
+}
@@ -0,0 +1,189 @@
+package protocol
+
+import (
+	"encoding/json"
+	"math/rand"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// With BandwidthProbeSeconds set, every period the active view member probed longest ago is sent a
+// burst of bandwidthProbeBurst probe messages, BandwidthProbeKiB in total, over the HyParView managed
+// connection. The neighbour times the burst from the arrival of the first probe to the arrival of the
+// last one and replies with the measured rate, which is smoothed into an estimate kept in PeerState.
+// Estimates are exposed by Bandwidths, the neighbour connection API and the snapshot, so dissemination
+// layers can favour neighbours with more capacity (e.g. when choosing eager push children).
+
+// BandwidthConfig enables bandwidth probing.
+type BandwidthConfig struct {
+	BandwidthProbeSeconds int `yaml:"bandwidthProbeSeconds"`
+	BandwidthProbeKiB     int `yaml:"bandwidthProbeKiB"`
+}
+
+const (
+	bandwidthProbeBurst      = 8
+	defaultBandwidthProbeKiB = 64
+	bandwidthAlpha           = 0.3
+	// incomplete bursts (lost probes, neighbour gone) are forgotten after this long
+	bandwidthProbeExpiry = 30 * time.Second
+)
+
+type bandwidthStats struct {
+	pendingProbe   uint32
+	probedAt       time.Time
+	bytesPerSecond float64
+	samples        int
+	measuredAt     time.Time
+}
+
+// Bandwidth is the smoothed estimate of the bandwidth available to a neighbour.
+type Bandwidth struct {
+	BytesPerSecond uint64    `json:"bytesPerSecond"`
+	Samples        int       `json:"samples"`
+	MeasuredAt     time.Time `json:"measuredAt"`
+}
+
+type bandwidthProbeReception struct {
+	probeID   uint32
+	firstAt   time.Time
+	bytes     int
+	remaining uint8
+}
+
+func (h *Hyparview) HandleBandwidthProbeTimer(t timer.Timer) {
+	if !h.shouldRunPeriodic(t) {
+		return
+	}
+	h.expireBandwidthProbeReceptions()
+	var target *PeerState
+	for _, p := range h.activeView.asArr {
+		if !p.outConnected {
+			continue
+		}
+		if p.bandwidth == nil {
+			p.bandwidth = &bandwidthStats{}
+		}
+		// probed rather than measured, so neighbours which never reply (e.g. older releases) are not
+		// probed over and over
+		if target == nil || p.bandwidth.probedAt.Before(target.bandwidth.probedAt) {
+			target = p
+		}
+	}
+	if target == nil {
+		return
+	}
+	target.bandwidth.pendingProbe = rand.Uint32()
+	target.bandwidth.probedAt = h.timeNow()
+	kib := h.conf.BandwidthProbeKiB
+	if kib <= 0 {
+		kib = defaultBandwidthProbeKiB
+	}
+	payload := make([]byte, kib*1024/bandwidthProbeBurst)
+	for seq := uint8(0); seq < bandwidthProbeBurst; seq++ {
+		h.sendMessage(BandwidthProbeMessage{
+			ProbeID: target.bandwidth.pendingProbe,
+			Seq:     seq,
+			Count:   bandwidthProbeBurst,
+			Payload: payload,
+		}, target.Peer)
+	}
+}
+
+func (h *Hyparview) HandleBandwidthProbeMessage(sender peer.Peer, msg message.Message) {
+	probe := msg.(BandwidthProbeMessage)
+	if !h.activeView.contains(sender) || probe.Count == 0 {
+		return
+	}
+	reception, ok := h.bandwidthProbes[sender.String()]
+	if !ok || reception.probeID != probe.ProbeID {
+		// the first probe only starts the clock, its transfer time is not measured
+		h.bandwidthProbes[sender.String()] = &bandwidthProbeReception{
+			probeID:   probe.ProbeID,
+			firstAt:   h.timeNow(),
+			remaining: probe.Count - 1,
+		}
+		return
+	}
+	reception.bytes += len(probe.Payload)
+	reception.remaining--
+	if reception.remaining > 0 {
+		return
+	}
+	delete(h.bandwidthProbes, sender.String())
+	elapsed := h.timeSince(reception.firstAt)
+	if elapsed < time.Microsecond {
+		elapsed = time.Microsecond
+	}
+	h.sendMessage(BandwidthProbeReplyMessage{
+		ProbeID:        probe.ProbeID,
+		BytesPerSecond: uint64(float64(reception.bytes) / elapsed.Seconds()),
+	}, sender)
+}
+
+func (h *Hyparview) HandleBandwidthProbeReplyMessage(sender peer.Peer, msg message.Message) {
+	reply := msg.(BandwidthProbeReplyMessage)
+	p, ok := h.activeView.get(sender)
+	if !ok || p.bandwidth == nil || p.bandwidth.pendingProbe != reply.ProbeID {
+		return
+	}
+	stats := p.bandwidth
+	stats.pendingProbe = 0
+	if stats.samples == 0 {
+		stats.bytesPerSecond = float64(reply.BytesPerSecond)
+	} else {
+		stats.bytesPerSecond = bandwidthAlpha*float64(reply.BytesPerSecond) + (1-bandwidthAlpha)*stats.bytesPerSecond
+	}
+	stats.samples++
+	stats.measuredAt = h.timeNow()
+}
+
+func (h *Hyparview) expireBandwidthProbeReceptions() {
+	for sender, reception := range h.bandwidthProbes {
+		if h.elapsedSince(reception.firstAt) > bandwidthProbeExpiry {
+			delete(h.bandwidthProbes, sender)
+		}
+	}
+}
+
+func (p *PeerState) bandwidthEstimate() *Bandwidth {
+	if p.bandwidth == nil || p.bandwidth.samples == 0 {
+		return nil
+	}
+	return &Bandwidth{
+		BytesPerSecond: uint64(p.bandwidth.bytesPerSecond),
+		Samples:        p.bandwidth.samples,
+		MeasuredAt:     p.bandwidth.measuredAt,
+	}
+}
+
+func (h *Hyparview) bandwidths() map[string]Bandwidth {
+	bandwidths := map[string]Bandwidth{}
+	for _, p := range h.activeView.asArr {
+		if estimate := p.bandwidthEstimate(); estimate != nil {
+			bandwidths[p.String()] = *estimate
+		}
+	}
+	return bandwidths
+}
+
+// Bandwidths returns the bandwidth estimates of the active view members, keyed by peer, it blocks until
+// the protocol goroutine takes the snapshot.
+func (h *Hyparview) Bandwidths() map[string]Bandwidth {
+	bandwidthsCh := make(chan map[string]Bandwidth, 1)
+	h.onProtocol("Bandwidths", func() { bandwidthsCh <- h.bandwidths() })
+	return <-bandwidthsCh
+}
+
+func (h *Hyparview) logBandwidths() {
+	if h.conf.BandwidthProbeSeconds <= 0 {
+		return
+	}
+	res, err := json.Marshal(h.bandwidths())
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("bandwidths", "%s", string(res))
+}
@@ -0,0 +1,237 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Blacklisted peers are dropped from the views, kept out of them and have their joins rejected.
+// Entries come from config updates (replaced by each update), from the operator through Blacklist,
+// or from BlacklistMessages signed with the config admin key, which are flooded to the active view
+// for coordinated quarantining. Entries may expire, and are persisted to BlacklistFile if set.
+
+// blacklistState holds the entries, keyed by peer, and the blacklist messages already flooded.
+type blacklistState struct {
+	blacklist         map[string]*blacklistEntry
+	seenBlacklistMsgs map[uint64]time.Time
+}
+
+const (
+	blacklistSourceConfig   = "config"
+	blacklistSourceOperator = "operator"
+	blacklistSourceControl  = "control"
+
+	seenBlacklistMsgTTL = time.Hour
+)
+
+type blacklistEntry struct {
+	Host          string    `json:"host"`
+	Port          uint16    `json:"port"`
+	AnalyticsPort uint16    `json:"analyticsPort,omitempty"`
+	Expires       time.Time `json:"expires,omitempty"`
+	Source        string    `json:"source"`
+}
+
+func (e *blacklistEntry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && now.After(e.Expires)
+}
+
+func (m BlacklistMessage) signedBytes() []byte {
+	encoded := make([]byte, 8)
+	binary.BigEndian.PutUint64(encoded, m.ID)
+	encoded = append(encoded, peer.SerializePeerArray(m.Peers)...)
+	ttl := make([]byte, 4)
+	for _, t := range m.TTLs {
+		binary.BigEndian.PutUint32(ttl, t)
+		encoded = append(encoded, ttl...)
+	}
+	return encoded
+}
+
+// Blacklist blacklists p for ttl (forever if 0). If propagate is set the entry is signed with the config
+// admin private key and flooded to the other nodes.
+func (h *Hyparview) Blacklist(p peer.Peer, ttl time.Duration, propagate bool) error {
+	msg := BlacklistMessage{
+		ID:    rand.Uint64(),
+		Peers: []peer.Peer{p},
+		TTLs:  []uint32{uint32(ttl.Seconds())},
+	}
+	if propagate {
+		if h.configAdminPrivateKey == nil {
+			return errors.New("cannot propagate blacklist entries, configAdminPrivateKeyFile not set")
+		}
+		msg.Signature = ed25519.Sign(h.configAdminPrivateKey, msg.signedBytes())
+	}
+	h.onProtocol("Blacklist", func() { h.blacklistLocally(msg, propagate) })
+	return nil
+}
+
+func (h *Hyparview) blacklistLocally(msg BlacklistMessage, propagate bool) {
+	if !propagate {
+		h.applyBlacklistMessage(msg, blacklistSourceOperator)
+		return
+	}
+	h.seenBlacklistMsgs[msg.ID] = h.timeNow()
+	h.applyBlacklistMessage(msg, blacklistSourceOperator)
+	h.floodBlacklistMessage(msg, h.babel.SelfPeer())
+}
+
+func (h *Hyparview) HandleBlacklistMessage(sender peer.Peer, msg message.Message) {
+	blacklistMsg := msg.(BlacklistMessage)
+	if h.configAdminKey == nil {
+		return
+	}
+	for id, seen := range h.seenBlacklistMsgs {
+		if h.elapsedSince(seen) > seenBlacklistMsgTTL {
+			delete(h.seenBlacklistMsgs, id)
+		}
+	}
+	if _, seen := h.seenBlacklistMsgs[blacklistMsg.ID]; seen {
+		return
+	}
+	if len(blacklistMsg.TTLs) != len(blacklistMsg.Peers) || !ed25519.Verify(h.configAdminKey, blacklistMsg.signedBytes(), blacklistMsg.Signature) {
+		h.logger.Warnf("Discarding blacklist message from %s: invalid signature", sender.String())
+		return
+	}
+	h.seenBlacklistMsgs[blacklistMsg.ID] = h.timeNow()
+	h.applyBlacklistMessage(blacklistMsg, blacklistSourceControl)
+	h.floodBlacklistMessage(blacklistMsg, sender)
+}
+
+func (h *Hyparview) floodBlacklistMessage(msg BlacklistMessage, sender peer.Peer) {
+	for _, p := range h.activeView.asArr {
+		if peer.PeersEqual(p, sender) || !p.outConnected {
+			continue
+		}
+		h.sendMessage(msg, p)
+	}
+}
+
+func (h *Hyparview) applyBlacklistMessage(msg BlacklistMessage, source string) {
+	for i, p := range msg.Peers {
+		var ttl time.Duration
+		if i < len(msg.TTLs) {
+			ttl = time.Duration(msg.TTLs[i]) * time.Second
+		}
+		h.logger.Warnf("Blacklisting %s for %s (source=%s)", p.String(), ttl, source)
+		h.addBlacklistEntry(p, ttl, source)
+	}
+	h.saveBlacklist()
+}
+
+// setBlacklist replaces the entries which came from config updates.
+func (h *Hyparview) setBlacklist(peers []peer.Peer) {
+	for k, e := range h.blacklist {
+		if e.Source == blacklistSourceConfig {
+			delete(h.blacklist, k)
+		}
+	}
+	for _, p := range peers {
+		h.addBlacklistEntry(p, 0, blacklistSourceConfig)
+	}
+	h.saveBlacklist()
+}
+
+func (h *Hyparview) addBlacklistEntry(p peer.Peer, ttl time.Duration, source string) {
+	entry := &blacklistEntry{
+		Host:          p.IP().String(),
+		Port:          p.ProtosPort(),
+		AnalyticsPort: p.AnalyticsPort(),
+		Source:        source,
+	}
+	if ttl > 0 {
+		entry.Expires = h.timeNow().Add(ttl)
+	}
+	h.blacklist[p.String()] = entry
+	if removed := h.removeFromActiveView(p, RemovalBlacklisted); removed != nil {
+		h.logger.Warnf("Dropping blacklisted peer %s from active view", p.String())
+		delete(h.outboundOnlyPeers, p.String())
+		h.sendDisconnect(removed)
+		if removed.outConnected {
+			h.babel.SendNotification(NeighborDownNotification{
+				PeerDown:    p,
+				View:        h.getView(),
+				ViewVersion: h.nextViewVersion(),
+			})
+		}
+	}
+	h.passiveView.remove(p)
+}
+
+func (h *Hyparview) isBlacklisted(p peer.Peer) bool {
+	entry, ok := h.blacklist[p.String()]
+	if !ok {
+		return false
+	}
+	if entry.expired(h.timeNow()) {
+		h.logger.Infof("Blacklist entry of %s expired", p.String())
+		delete(h.blacklist, p.String())
+		h.saveBlacklist()
+		return false
+	}
+	return true
+}
+
+func (h *Hyparview) blacklistRejector(sender peer.Peer) (JoinRejectReason, bool) {
+	return RejectBlacklisted, h.isBlacklisted(sender)
+}
+
+func (h *Hyparview) loadBlacklist() {
+	if h.conf.BlacklistFile == "" {
+		return
+	}
+	data, err := ioutil.ReadFile(h.conf.BlacklistFile)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		h.logger.Errorf("Could not read blacklist file %s: %s", h.conf.BlacklistFile, err)
+		return
+	}
+	entries := []*blacklistEntry{}
+	if err = json.Unmarshal(data, &entries); err != nil {
+		h.logger.Errorf("Could not parse blacklist file %s: %s", h.conf.BlacklistFile, err)
+		return
+	}
+	for _, e := range entries {
+		ip := net.ParseIP(e.Host)
+		if ip == nil || e.expired(h.timeNow()) {
+			continue
+		}
+		h.blacklist[peer.NewPeer(ip, e.Port, e.AnalyticsPort).String()] = e
+	}
+	h.logger.Infof("Loaded %d blacklist entries from %s", len(h.blacklist), h.conf.BlacklistFile)
+}
+
+func (h *Hyparview) saveBlacklist() {
+	if h.conf.BlacklistFile == "" {
+		return
+	}
+	entries := make([]*blacklistEntry, 0, len(h.blacklist))
+	for _, e := range h.blacklist {
+		if !e.expired(h.timeNow()) {
+			entries = append(entries, e)
+		}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		panic(err)
+	}
+	tmp := h.conf.BlacklistFile + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err == nil {
+		err = os.Rename(tmp, h.conf.BlacklistFile)
+	}
+	if err != nil {
+		h.logger.Errorf("Could not persist blacklist to %s: %s", h.conf.BlacklistFile, err)
+	}
+}
@@ -0,0 +1,161 @@
+package protocol
+
+import (
+	"encoding/json"
+	"math/rand"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// BootstrapConfig selects how joins are sent to the bootstrap nodes, and how bootstrap nodes fill their
+// own views.
+type BootstrapConfig struct {
+	BootstrapStrategy       string `yaml:"bootstrapStrategy"`
+	BootstrapFanout         int    `yaml:"bootstrapFanout"`
+	JoinReplyTimeoutSeconds int    `yaml:"joinReplyTimeoutSeconds"`
+	BootstrapMembership     string `yaml:"bootstrapMembership"`
+	BootstrapPreload        string `yaml:"bootstrapPreload"`
+}
+
+// bootstrapState tracks the joins sent to the bootstrap nodes.
+type bootstrapState struct {
+	bootstrapIdx         int
+	joinRetries          int
+	pendingBootstrapJoin *pendingBootstrapJoin
+	bootstrapStats       *BootstrapStats
+}
+
+const defaultJoinReplyTimeout = 5 * time.Second
+
+const (
+	BootstrapFirst      = "first"
+	BootstrapRandom     = "random"
+	BootstrapRoundRobin = "roundRobin"
+	BootstrapParallel   = "parallel"
+)
+
+type BootstrapStats struct {
+	JoinAttempts    int            `json:"joinAttempts"`
+	JoinTimeouts    int            `json:"joinTimeouts"`
+	Replies         int            `json:"replies"`
+	IgnoredReplies  int            `json:"ignoredReplies"`
+	AvgReplyLatency time.Duration  `json:"avgReplyLatency"`
+	Contacted       map[string]int `json:"contacted"`
+	FirstResponder  map[string]int `json:"firstResponder"`
+}
+
+type pendingBootstrapJoin struct {
+	contacted map[string]bool
+	answered  bool
+	started   time.Time
+}
+
+func (h *Hyparview) bootstrapCandidates() []peer.Peer {
+	candidates := make([]peer.Peer, 0, len(h.bootstrapNodes))
+	for _, b := range h.bootstrapNodes {
+		if !peer.PeersEqual(b, h.babel.SelfPeer()) {
+			candidates = append(candidates, b)
+		}
+	}
+	return candidates
+}
+
+func (h *Hyparview) selectBootstrapTargets() []peer.Peer {
+	candidates := h.bootstrapCandidates()
+	if len(candidates) == 0 {
+		return candidates
+	}
+
+	switch h.conf.BootstrapStrategy {
+	case BootstrapRandom:
+		return []peer.Peer{candidates[getRandInt(len(candidates))]}
+	case BootstrapRoundRobin:
+		target := candidates[h.bootstrapIdx%len(candidates)]
+		h.bootstrapIdx++
+		return []peer.Peer{target}
+	case BootstrapParallel:
+		fanout := h.conf.BootstrapFanout
+		if fanout <= 0 || fanout > len(candidates) {
+			fanout = len(candidates)
+		}
+		targets := make([]peer.Peer, 0, fanout)
+		for _, idx := range rand.Perm(len(candidates))[:fanout] {
+			targets = append(targets, candidates[idx])
+		}
+		return targets
+	default:
+		// BootstrapFirst, which is also the default. Retries after a join reply timeout move on to the next bootstrap
+		if h.conf.BootstrapStrategy != "" && h.conf.BootstrapStrategy != BootstrapFirst {
+			h.logger.Warnf("Unknown bootstrap strategy %q, using %q", h.conf.BootstrapStrategy, BootstrapFirst)
+		}
+		return []peer.Peer{candidates[h.joinRetries%len(candidates)]}
+	}
+}
+
+// acceptBootstrapReply returns false if the reply comes from a bootstrap contacted in parallel
+// which was not the first to respond, in which case the reply is discarded. Bootstraps contacted in
+// parallel are only tracked until the join reply timer fires, later replies come from other walks.
+func (h *Hyparview) acceptBootstrapReply(sender peer.Peer) bool {
+	pending := h.pendingBootstrapJoin
+	if pending == nil || !pending.contacted[sender.String()] {
+		return true
+	}
+
+	if pending.answered {
+		if h.activeView.contains(sender) {
+			// already a neighbour, e.g. through a forward join walk, the link must be kept
+			return true
+		}
+		h.logger.Infof("Ignoring forward join reply from bootstrap %s, join already answered", sender.String())
+		h.bootstrapStats.IgnoredReplies++
+		h.sendMessageTmpTransport(DisconnectMessage{}, sender)
+		return false
+	}
+
+	pending.answered = true
+	latency := h.timeSince(pending.started)
+	stats := h.bootstrapStats
+	stats.AvgReplyLatency = (stats.AvgReplyLatency*time.Duration(stats.Replies) + latency) / time.Duration(stats.Replies+1)
+	stats.Replies++
+	stats.FirstResponder[sender.String()]++
+	if len(pending.contacted) == 1 {
+		h.pendingBootstrapJoin = nil
+	}
+	return true
+}
+
+func (h *Hyparview) joinReplyTimeout() time.Duration {
+	if h.conf.JoinReplyTimeoutSeconds <= 0 {
+		return defaultJoinReplyTimeout
+	}
+	return time.Duration(h.conf.JoinReplyTimeoutSeconds) * time.Second
+}
+
+// HandleJoinReplyTimer retries the join through the next bootstrap(s) if the join sent in the same
+// attempt got no reply and no neighbour connection was established since. Otherwise it stops tracking
+// the join, closing the window in which late replies of bootstraps contacted in parallel are discarded.
+func (h *Hyparview) HandleJoinReplyTimer(t timer.Timer) {
+	if h.hasLeft() || t.(JoinReplyTimer).attempt != h.bootstrapStats.JoinAttempts {
+		return
+	}
+	pending := h.pendingBootstrapJoin
+	if pending == nil || pending.answered || len(h.getView()) > 0 {
+		h.joinRetries = 0
+		h.pendingBootstrapJoin = nil
+		return
+	}
+	h.bootstrapStats.JoinTimeouts++
+	h.joinRetries++
+	h.logger.Warnf("No reply to join within %s, retrying (retry %d)", h.joinReplyTimeout(), h.joinRetries)
+	h.sendJoinToBootstrap()
+}
+
+func (h *Hyparview) logBootstrapStats() {
+	res, err := json.Marshal(h.bootstrapStats)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("bootstrapStats", "%s", string(res))
+}
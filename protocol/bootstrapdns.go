@@ -0,0 +1,126 @@
+package protocol
+
+import (
+	"net"
+	"strings"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Bootstrap hosts which are not IP addresses are resolved through DNS every time the node joins
+// through the bootstraps, retries and rejoins included, so that deployments on Kubernetes or with
+// dynamic DNS need not hardcode IPs. Every address of a hostname becomes a bootstrap with the
+// configured ports. Hosts starting with an underscore are SRV names (e.g.
+// _hyparview._tcp.hyparview.default.svc.cluster.local), their targets are resolved in turn and use the
+// ports of the records. Lookups block, so they run on their own goroutine and the join is sent once
+// they return. Failed lookups keep the addresses of the previous resolution.
+
+// dnsBootstrapState holds the configured bootstraps and the addresses their names resolved to.
+type dnsBootstrapState struct {
+	staticBootstraps    []peer.Peer
+	dnsBootstraps       []dnsBootstrap
+	resolvedBootstraps  []peer.Peer
+	resolvingBootstraps bool
+	bootstrapsResolved  bool
+}
+
+type dnsBootstrap struct {
+	host          string
+	port          int
+	analyticsPort int
+}
+
+// splitBootstraps returns the bootstraps configured by IP and the ones to resolve through DNS.
+func splitBootstraps(conf *HyparviewConfig) ([]peer.Peer, []dnsBootstrap) {
+	static := []peer.Peer{}
+	names := []dnsBootstrap{}
+	for _, p := range conf.BootstrapPeers {
+		if net.ParseIP(p.Host) == nil {
+			names = append(names, dnsBootstrap{host: p.Host, port: p.Port, analyticsPort: p.AnalyticsPort})
+			continue
+		}
+		static = append(static, configuredPeer(conf, p.Host, p.Port, p.AnalyticsPort))
+	}
+	return static, names
+}
+
+// resolveBootstrapsFirst returns true if the join must wait for the DNS bootstraps to be resolved.
+func (h *Hyparview) resolveBootstrapsFirst() bool {
+	if len(h.dnsBootstraps) == 0 || h.discovery != nil {
+		return false
+	}
+	if h.bootstrapsResolved {
+		h.bootstrapsResolved = false
+		return false
+	}
+	if h.resolvingBootstraps {
+		return true
+	}
+	h.resolvingBootstraps = true
+	// the goroutine must not read h.conf, which a reload may replace meanwhile
+	names := h.dnsBootstraps
+	analyticsPorts := analyticsPortsEnabled(h.conf)
+	go func() {
+		resolved := []peer.Peer{}
+		for _, name := range names {
+			if !analyticsPorts {
+				name.analyticsPort = 0
+			}
+			resolved = append(resolved, h.resolveBootstrap(name)...)
+		}
+		h.onProtocol("resolveBootstraps", func() { h.bootstrapsResolvedTo(resolved) })
+	}()
+	return true
+}
+
+func isSRVName(host string) bool {
+	return strings.HasPrefix(host, "_")
+}
+
+func (h *Hyparview) resolveBootstrap(name dnsBootstrap) []peer.Peer {
+	if !isSRVName(name.host) {
+		return h.lookupBootstrap(name.host, name.port, name.analyticsPort)
+	}
+	_, records, err := net.LookupSRV("", "", name.host)
+	if err != nil {
+		h.logger.Errorf("Could not resolve bootstrap SRV name %s: %s", name.host, err)
+		return nil
+	}
+	resolved := []peer.Peer{}
+	for _, record := range records {
+		resolved = append(resolved, h.lookupBootstrap(record.Target, int(record.Port), name.analyticsPort)...)
+	}
+	return resolved
+}
+
+func (h *Hyparview) lookupBootstrap(host string, port, analyticsPort int) []peer.Peer {
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		h.logger.Errorf("Could not resolve bootstrap host %s: %s", host, err)
+		return nil
+	}
+	resolved := []peer.Peer{}
+	for _, ip := range ips {
+		resolved = append(resolved, peer.NewPeer(ip, uint16(port), uint16(analyticsPort)))
+	}
+	return resolved
+}
+
+func (h *Hyparview) bootstrapsResolvedTo(peers []peer.Peer) {
+	h.resolvingBootstraps = false
+	resolved := []peer.Peer{}
+	for _, p := range peers {
+		if !h.isSelf(p) {
+			resolved = append(resolved, p)
+		}
+	}
+	if len(resolved) > 0 {
+		h.logger.Infof("Resolved %d bootstrap peers through DNS", len(resolved))
+		h.resolvedBootstraps = resolved
+	} else {
+		h.logger.Warn("DNS bootstrap resolution returned no peers, keeping previous bootstraps")
+	}
+	h.bootstrapNodes = append(append([]peer.Peer{}, h.staticBootstraps...), h.resolvedBootstraps...)
+	h.bootstrapsResolved = true
+	h.sendJoinToBootstrap()
+}
@@ -0,0 +1,40 @@
+package protocol
+
+import "github.com/nm-morais/go-babel/pkg/peer"
+
+// BootstrapMembership chooses whether bootstrap nodes are regular overlay members or join-only
+// contacts. Join-only bootstraps are never added to passive views nor advertised in shuffles, so no
+// node promotes them, and they do not admit joiners in their own active view but only forward their
+// joins (or hand them a passive view sample if they have no neighbours to forward to). They keep the
+// few links created by their own joins, staying low-degree dedicated entry points.
+const (
+	BootstrapMember   = "member"
+	BootstrapJoinOnly = "joinOnly"
+)
+
+func (h *Hyparview) joinOnlyBootstraps() bool {
+	return h.conf.BootstrapMembership == BootstrapJoinOnly
+}
+
+func (h *Hyparview) isBootstrapNode(p peer.Peer) bool {
+	for _, b := range h.bootstrapNodes {
+		if peer.PeersEqual(b, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// joinOnlyContact returns whether p must be kept out of the passive view and shuffles.
+func (h *Hyparview) joinOnlyContact(p peer.Peer) bool {
+	return h.joinOnlyBootstraps() && h.isBootstrapNode(p)
+}
+
+// forwardJoinOnly passes a joiner on without admitting it.
+func (h *Hyparview) forwardJoinOnly(sender peer.Peer, capabilities uint8) {
+	if h.forwardJoin(sender, capabilities) == 0 && h.passiveView.size() > 0 {
+		h.sendMessageTmpTransport(ShuffleReplyMessage{
+			Peers: h.withoutShuffleExclusions(h.passiveView.getRandomElementsFromView(h.conf.Kp, sender)),
+		}, sender)
+	}
+}
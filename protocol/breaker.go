@@ -0,0 +1,118 @@
+package protocol
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// With CircuitBreakerFailures set, a destination whose messages failed that many times in a row is
+// treated as suspect: nothing is sent to it for CircuitBreakerCooldownSeconds, and it is not promoted
+// to the active view, instead of retrying in a tight loop towards a peer which stays unreachable. Once
+// the cool-down expires the breaker is half-open and a single message goes through as a probe, closing
+// the breaker if delivered, or opening it for another cool-down if not.
+
+// CircuitBreakerConfig enables the per destination circuit breakers.
+type CircuitBreakerConfig struct {
+	CircuitBreakerFailures        int `yaml:"circuitBreakerFailures"`
+	CircuitBreakerCooldownSeconds int `yaml:"circuitBreakerCooldownSeconds"`
+}
+
+// breakerState holds a circuit breaker per destination.
+type breakerState struct {
+	breakers     map[string]*circuitBreaker
+	breakerStats CircuitBreakerStats
+}
+
+const defaultCircuitBreakerCooldown = 30 * time.Second
+
+type circuitBreaker struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+type CircuitBreakerStats struct {
+	Open    int `json:"open"`
+	Opened  int `json:"opened"`
+	Blocked int `json:"blocked"`
+}
+
+func (h *Hyparview) circuitBreakerCooldown() time.Duration {
+	if h.conf.CircuitBreakerCooldownSeconds > 0 {
+		return time.Duration(h.conf.CircuitBreakerCooldownSeconds) * time.Second
+	}
+	return defaultCircuitBreakerCooldown
+}
+
+// allowSend returns whether msg may be sent to target, letting a single probe through half-open breakers.
+func (h *Hyparview) allowSend(msg message.Message, target peer.Peer) bool {
+	b, ok := h.breakers[target.String()]
+	if !ok || b.openUntil.IsZero() {
+		return true
+	}
+	if h.timeNow().After(b.openUntil) {
+		// another probe is let through if the outcome of this one is never reported
+		h.logger.Infof("Circuit breaker to %s half-open, probing it", target.String())
+		b.probing = true
+		b.openUntil = h.timeNow().Add(h.circuitBreakerCooldown())
+		return true
+	}
+	h.breakerStats.Blocked++
+	h.logger.Debugf("Circuit breaker to %s open, not sending %T", target.String(), msg)
+	return false
+}
+
+func (h *Hyparview) sendSucceeded(target peer.Peer) {
+	if b, ok := h.breakers[target.String()]; ok {
+		if !b.openUntil.IsZero() {
+			h.logger.Infof("Circuit breaker to %s closed", target.String())
+		}
+		delete(h.breakers, target.String())
+	}
+}
+
+func (h *Hyparview) sendFailed(target peer.Peer) {
+	if h.conf.CircuitBreakerFailures <= 0 {
+		return
+	}
+	b, ok := h.breakers[target.String()]
+	if !ok {
+		b = &circuitBreaker{}
+		h.breakers[target.String()] = b
+	}
+	b.failures++
+	if b.probing || (b.openUntil.IsZero() && b.failures >= h.conf.CircuitBreakerFailures) {
+		if b.openUntil.IsZero() {
+			h.breakerStats.Opened++
+		}
+		b.probing = false
+		b.openUntil = h.timeNow().Add(h.circuitBreakerCooldown())
+		h.logger.Warnf("%d consecutive sends to %s failed, circuit breaker open for %s", b.failures, target.String(), h.circuitBreakerCooldown())
+	}
+}
+
+// suspect returns whether p has an open circuit breaker.
+func (h *Hyparview) suspect(p peer.Peer) bool {
+	b, ok := h.breakers[p.String()]
+	return ok && !b.openUntil.IsZero()
+}
+
+func (h *Hyparview) logCircuitBreakers() {
+	if h.conf.CircuitBreakerFailures <= 0 {
+		return
+	}
+	h.breakerStats.Open = 0
+	for _, b := range h.breakers {
+		if !b.openUntil.IsZero() {
+			h.breakerStats.Open++
+		}
+	}
+	res, err := json.Marshal(h.breakerStats)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("circuitBreakers", "%s", string(res))
+}
@@ -0,0 +1,53 @@
+package protocol
+
+import (
+	"math"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// The clock of the protocol measures every deadline, window and age. It defaults to the wall clock, and
+// simulations inject a virtual one through HyparviewConfig.Clock, so that hours of protocol time run in
+// seconds. The watchdog and the latency probes keep using the wall clock, they measure real stalls and
+// round trips.
+
+func (h *Hyparview) timeNow() time.Time {
+	return h.clock()
+}
+
+func (h *Hyparview) timeSince(t time.Time) time.Duration {
+	return h.timeNow().Sub(t)
+}
+
+func (h *Hyparview) timeUntil(t time.Time) time.Duration {
+	return t.Sub(h.timeNow())
+}
+
+// elapsedSince measures the time elapsed since t using the monotonic clock reading taken by time.Now,
+// so wall clock steps (NTP, VM resume) do not affect it. An unset t or a negative measurement (t
+// without a monotonic reading and a clock stepped backwards) count as "a long time ago", which never
+// suppresses an action that is waiting for time to pass.
+func (h *Hyparview) elapsedSince(t time.Time) time.Duration {
+	if t.IsZero() {
+		return math.MaxInt64
+	}
+	elapsed := h.timeSince(t)
+	if elapsed < 0 {
+		return math.MaxInt64
+	}
+	return elapsed
+}
+
+// shouldRunPeriodic drops periodic timer triggers arriving in bursts, e.g. timers catching up after
+// a clock jump, by skipping triggers less than half a period apart from the last one that ran.
+func (h *Hyparview) shouldRunPeriodic(t timer.Timer) bool {
+	now := h.timeNow()
+	last, ok := h.lastTimerRuns[t.ID()]
+	if ok && h.elapsedSince(last) < t.Duration()/2 {
+		h.logger.Warnf("Skipping burst trigger of timer %d", t.ID())
+		return false
+	}
+	h.lastTimerRuns[t.ID()] = now
+	return true
+}
@@ -0,0 +1,23 @@
+package protocol
+
+import (
+	"crypto/subtle"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// With ClusterToken set, joins and neighbour requests carry the token and nodes reject those carrying
+// a different one (or none). Unlike OverlayID, which is hashed, the token is compared as is: it is a
+// shared secret keeping nodes of other clusters from joining by accident, not authentication, as it
+// travels in clear. Nodes without a token neither send nor expect one.
+
+const maxClusterTokenLength = 255
+
+func (h *Hyparview) sameClusterToken(sender peer.Peer, token string, what string) bool {
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.conf.ClusterToken)) == 1 {
+		return true
+	}
+	h.tokenMismatches++
+	h.logger.Warnf("Dropping %s from %s: cluster token does not match ours", what, sender.String())
+	return false
+}
@@ -0,0 +1,221 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sort"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+const (
+	CapCompactPeerLists uint8 = 1 << iota
+	// CapOverlayID is set by the shuffle serializer when the overlay ID follows the capabilities byte.
+	CapOverlayID
+	// CapOptionalAnalyticsPorts is advertised by nodes decoding compact peer lists which omit zero
+	// analytics ports.
+	CapOptionalAnalyticsPorts
+	// CapShuffleFragments is advertised by nodes splitting large shuffles into ShuffleFragmentMessages.
+	CapShuffleFragments
+	// CapTimeHints is advertised by nodes with TimeSyncHints set, which read time hints in maintenance
+	// messages.
+	CapTimeHints
+	// CapVersion is advertised by every node reading the protocol version in maintenance messages.
+	CapVersion
+)
+
+const (
+	compactIPv6Flag            = 0x80
+	compactNoAnalyticsPortFlag = 0x40
+	compactSharedBitsMask      = 0x1f
+)
+
+// encodePeersCompact sorts the peers by address and prefix-encodes each address against the previous
+// one of the same family, followed by varint encoded ports. Dense deployments where peers share most
+// of their address bytes (e.g. the same /16) end up sending only the differing suffix of each address.
+// With omitZeroAnalyticsPorts, the analytics port of peers which have none is left out and flagged in
+// the entry's header.
+func encodePeersCompact(peers []peer.Peer, omitZeroAnalyticsPorts bool) []byte {
+	sorted := make([]peer.Peer, len(peers))
+	copy(sorted, peers)
+	sort.Slice(sorted, func(i, j int) bool { return peerLess(sorted[i], sorted[j]) })
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(len(sorted)))
+	encoded := append([]byte{}, buf[:n]...)
+	var prev net.IP
+	for _, p := range sorted {
+		ip := p.IP().To4()
+		header := byte(0)
+		if ip == nil {
+			ip = p.IP().To16()
+			header |= compactIPv6Flag
+		}
+		shared := 0
+		if len(prev) == len(ip) {
+			for shared < len(ip)-1 && ip[shared] == prev[shared] {
+				shared++
+			}
+		}
+		header |= byte(shared)
+		omitAnalyticsPort := omitZeroAnalyticsPorts && p.AnalyticsPort() == 0
+		if omitAnalyticsPort {
+			header |= compactNoAnalyticsPortFlag
+		}
+		encoded = append(encoded, header)
+		encoded = append(encoded, ip[shared:]...)
+		n = binary.PutUvarint(buf, uint64(p.ProtosPort()))
+		encoded = append(encoded, buf[:n]...)
+		if !omitAnalyticsPort {
+			n = binary.PutUvarint(buf, uint64(p.AnalyticsPort()))
+			encoded = append(encoded, buf[:n]...)
+		}
+		prev = ip
+	}
+	return encoded
+}
+
+// decodePeersCompact decodes a list encoded by encodePeersCompact, stopping at the first malformed entry.
+// It reports whether analytics ports were omitted from any entry and how many bytes the list took, all
+// of them if it is malformed.
+func decodePeersCompact(encoded []byte) ([]peer.Peer, bool, int) {
+	reader := bytes.NewReader(encoded)
+	amount, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return []peer.Peer{}, false, len(encoded)
+	}
+	omitted := false
+	peers := []peer.Peer{}
+	var prev net.IP
+	for i := uint64(0); i < amount; i++ {
+		header, err := reader.ReadByte()
+		if err != nil {
+			return peers, omitted, len(encoded)
+		}
+		ipLen := net.IPv4len
+		if header&compactIPv6Flag != 0 {
+			ipLen = net.IPv6len
+		}
+		shared := int(header & compactSharedBitsMask)
+		if shared >= ipLen || (shared > 0 && len(prev) != ipLen) {
+			return peers, omitted, len(encoded)
+		}
+		ip := make(net.IP, ipLen)
+		copy(ip, prev[:shared])
+		if _, err := reader.Read(ip[shared:]); err != nil {
+			return peers, omitted, len(encoded)
+		}
+		protosPort, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return peers, omitted, len(encoded)
+		}
+		var analyticsPort uint64
+		if header&compactNoAnalyticsPortFlag != 0 {
+			omitted = true
+		} else if analyticsPort, err = binary.ReadUvarint(reader); err != nil {
+			return peers, omitted, len(encoded)
+		}
+		peers = append(peers, peer.NewPeer(ip, uint16(protosPort), uint16(analyticsPort)))
+		prev = ip
+	}
+	return peers, omitted, len(encoded) - reader.Len()
+}
+
+func (h *Hyparview) localCapabilities() uint8 {
+	capabilities := CapVersion
+	if h.conf.CompactPeerLists {
+		capabilities |= CapCompactPeerLists | CapOptionalAnalyticsPorts
+	}
+	if h.conf.ShuffleFragmentBytes > 0 {
+		capabilities |= CapShuffleFragments
+	}
+	if h.conf.TimeSyncHints {
+		capabilities |= CapTimeHints
+	}
+	return capabilities
+}
+
+// learnCapabilities records the capabilities a neighbour advertised in its join or neighbour handshake,
+// so that features like time hints need not wait for its first shuffle.
+func (h *Hyparview) learnCapabilities(p peer.Peer, capabilities uint8) {
+	if neighbour, ok := h.activeView.get(p); ok {
+		neighbour.capabilities = capabilities
+	}
+}
+
+func (h *Hyparview) supportsCompactPeerLists(capabilities uint8) bool {
+	return h.conf.CompactPeerLists && capabilities&CapCompactPeerLists != 0
+}
+
+func (h *Hyparview) sendShuffleMessage(msg ShuffleMessage, target peer.Peer) {
+	msg.Capabilities = h.localCapabilities()
+	msg.ConfigUpdate = h.configUpdateToGossip()
+	msg.OverlayID = h.overlayID()
+	var toSend message.Message = msg
+	var capabilities uint8
+	if p, ok := h.activeView.get(target); ok {
+		capabilities = p.capabilities
+	}
+	// compact shuffles carry no config updates
+	if msg.ConfigUpdate == nil && h.supportsCompactPeerLists(capabilities) {
+		toSend = CompactShuffleMessage{
+			ID:                     msg.ID,
+			TTL:                    msg.TTL,
+			Peers:                  msg.Peers,
+			OmitZeroAnalyticsPorts: capabilities&CapOptionalAnalyticsPorts != 0,
+			OverlayID:              msg.OverlayID,
+		}
+	}
+	if fragments := h.fragmentShuffle(toSend, capabilities); fragments != nil {
+		for _, fragment := range fragments {
+			h.sendMessage(fragment, target)
+		}
+		return
+	}
+	h.sendMessage(toSend, target)
+}
+
+func (h *Hyparview) sendShuffleReplyMessage(reply ShuffleReplyMessage, target peer.Peer, capabilities uint8) {
+	reply.ConfigUpdate = h.configUpdateToGossip()
+	var toSend message.Message = reply
+	if reply.ConfigUpdate == nil && h.supportsCompactPeerLists(capabilities) {
+		toSend = CompactShuffleReplyMessage{
+			ID:                     reply.ID,
+			Peers:                  reply.Peers,
+			OmitZeroAnalyticsPorts: capabilities&CapOptionalAnalyticsPorts != 0,
+		}
+	}
+	if fragments := h.fragmentShuffle(toSend, capabilities); fragments != nil {
+		for _, fragment := range fragments {
+			h.sendMessageTmpTransport(fragment, target)
+		}
+		return
+	}
+	h.sendMessageTmpTransport(toSend, target)
+}
+
+func (h *Hyparview) HandleCompactShuffleMessage(sender peer.Peer, msg message.Message) {
+	compactMsg := msg.(CompactShuffleMessage)
+	capabilities := CapCompactPeerLists
+	if compactMsg.OmitZeroAnalyticsPorts {
+		capabilities |= CapOptionalAnalyticsPorts
+	}
+	if p, ok := h.activeView.get(sender); ok {
+		// compact shuffles do not carry the other capabilities, keep those of earlier shuffles
+		capabilities |= p.capabilities &^ (CapCompactPeerLists | CapOptionalAnalyticsPorts)
+	}
+	h.HandleShuffleMessage(sender, ShuffleMessage{
+		ID:           compactMsg.ID,
+		TTL:          compactMsg.TTL,
+		Peers:        compactMsg.Peers,
+		Capabilities: capabilities,
+		OverlayID:    compactMsg.OverlayID,
+	})
+}
+
+func (h *Hyparview) HandleCompactShuffleReplyMessage(sender peer.Peer, msg message.Message) {
+	compactReply := msg.(CompactShuffleReplyMessage)
+	h.HandleShuffleReplyMessage(sender, ShuffleReplyMessage{ID: compactReply.ID, Peers: compactReply.Peers})
+}
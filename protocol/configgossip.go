@@ -0,0 +1,265 @@
+package protocol
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"gopkg.in/yaml.v2"
+)
+
+// An admin node holding the private key matching ConfigAdminPublicKey can publish a signed
+// ConfigUpdate, which is piggybacked on the next ConfigGossipRounds shuffles (and shuffle replies)
+// of every node accepting it, spreading epidemically. An update is accepted if its signature is
+// valid, its version is higher than the last accepted one, and the resulting config is valid.
+// Updates carry the full desired state: a new update replaces the previous blacklist.
+
+// ConfigGossipConfig holds the admin keys and the number of shuffles each update is gossiped on.
+type ConfigGossipConfig struct {
+	ConfigAdminPublicKey      string `yaml:"configAdminPublicKey"`
+	ConfigAdminPrivateKeyFile string `yaml:"configAdminPrivateKeyFile"`
+	ConfigUpdateFile          string `yaml:"configUpdateFile"`
+	ConfigGossipRounds        int    `yaml:"configGossipRounds"`
+}
+
+// configGossipState holds the admin keys and the last accepted update.
+type configGossipState struct {
+	configAdminKey        ed25519.PublicKey
+	configAdminPrivateKey ed25519.PrivateKey
+	configUpdate          *ConfigUpdate
+	configUpdateRounds    int
+}
+
+const defaultConfigGossipRounds = 10
+
+// settings which can be changed through config updates, see applyConfigSettings
+var gossipableSettings = map[string]bool{
+	"minShuffleTimerDurationSeconds": true,
+	"debugTimerDurationSeconds":      true,
+	"activeViewSize":                 true,
+	"passiveViewSize":                true,
+	"logLevel":                       true,
+}
+
+type ConfigUpdate struct {
+	Version   uint64
+	Settings  map[string]string
+	Blacklist []peer.Peer
+	Signature []byte
+}
+
+type configUpdateFile struct {
+	Version   uint64            `yaml:"version"`
+	Settings  map[string]string `yaml:"settings"`
+	Blacklist []struct {
+		Host string `yaml:"host"`
+		Port int    `yaml:"port"`
+	} `yaml:"blacklist"`
+}
+
+func ReadConfigUpdateFile(path string) (ConfigUpdate, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ConfigUpdate{}, err
+	}
+	defer f.Close()
+
+	file := configUpdateFile{}
+	if err = yaml.NewDecoder(f).Decode(&file); err != nil {
+		return ConfigUpdate{}, err
+	}
+	update := ConfigUpdate{
+		Version:  file.Version,
+		Settings: file.Settings,
+	}
+	for _, p := range file.Blacklist {
+		ip := net.ParseIP(p.Host)
+		if ip == nil {
+			return ConfigUpdate{}, fmt.Errorf("invalid blacklisted host %s", p.Host)
+		}
+		update.Blacklist = append(update.Blacklist, peer.NewPeer(ip, uint16(p.Port), 0))
+	}
+	return update, nil
+}
+
+func (u ConfigUpdate) signedBytes() []byte {
+	keys := make([]string, 0, len(u.Settings))
+	for k := range u.Settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	encoded := make([]byte, 10)
+	binary.BigEndian.PutUint64(encoded[0:8], u.Version)
+	binary.BigEndian.PutUint16(encoded[8:10], uint16(len(keys)))
+	for _, k := range keys {
+		encoded = appendLengthPrefixed(encoded, []byte(k))
+		encoded = appendLengthPrefixed(encoded, []byte(u.Settings[k]))
+	}
+	return append(encoded, peer.SerializePeerArray(u.Blacklist)...)
+}
+
+func (u ConfigUpdate) encode() []byte {
+	return appendLengthPrefixed(u.signedBytes(), u.Signature)
+}
+
+// decodeConfigUpdate returns nil if encoded is empty or malformed.
+func decodeConfigUpdate(encoded []byte) *ConfigUpdate {
+	if len(encoded) < 10 {
+		return nil
+	}
+	u := &ConfigUpdate{
+		Version:  binary.BigEndian.Uint64(encoded[0:8]),
+		Settings: map[string]string{},
+	}
+	nrSettings := int(binary.BigEndian.Uint16(encoded[8:10]))
+	rest := encoded[10:]
+	for i := 0; i < nrSettings; i++ {
+		var k, v []byte
+		var ok bool
+		if k, rest, ok = readLengthPrefixed(rest); !ok {
+			return nil
+		}
+		if v, rest, ok = readLengthPrefixed(rest); !ok {
+			return nil
+		}
+		u.Settings[string(k)] = string(v)
+	}
+	n, blacklist, ok := readPeerArray(rest)
+	if !ok {
+		return nil
+	}
+	u.Blacklist = blacklist
+	signature, _, ok := readLengthPrefixed(rest[n:])
+	if !ok {
+		return nil
+	}
+	u.Signature = signature
+	return u
+}
+
+func appendLengthPrefixed(dst, b []byte) []byte {
+	length := make([]byte, 2)
+	binary.BigEndian.PutUint16(length, uint16(len(b)))
+	return append(append(dst, length...), b...)
+}
+
+func readLengthPrefixed(b []byte) ([]byte, []byte, bool) {
+	if len(b) < 2 {
+		return nil, nil, false
+	}
+	length := int(binary.BigEndian.Uint16(b[0:2]))
+	if len(b) < 2+length {
+		return nil, nil, false
+	}
+	return b[2 : 2+length], b[2+length:], true
+}
+
+func loadConfigAdminKeys(conf *HyparviewConfig) (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	if conf.ConfigAdminPublicKey == "" {
+		return nil, nil, nil
+	}
+	pub, err := base64.StdEncoding.DecodeString(conf.ConfigAdminPublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return nil, nil, errors.New("configAdminPublicKey must be a base64 encoded ed25519 public key")
+	}
+	if conf.ConfigAdminPrivateKeyFile == "" {
+		return pub, nil, nil
+	}
+	privFile, err := ioutil.ReadFile(conf.ConfigAdminPrivateKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	priv, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(privFile)))
+	if err != nil || len(priv) != ed25519.PrivateKeySize {
+		return nil, nil, errors.New("configAdminPrivateKeyFile must hold a base64 encoded ed25519 private key")
+	}
+	return pub, priv, nil
+}
+
+// InjectConfigUpdate signs update with the admin private key, applies it locally and starts gossiping it.
+func (h *Hyparview) InjectConfigUpdate(update ConfigUpdate) error {
+	if h.configAdminPrivateKey == nil {
+		return errors.New("not a config admin, configAdminPrivateKeyFile not set")
+	}
+	update.Signature = ed25519.Sign(h.configAdminPrivateKey, update.signedBytes())
+	h.onProtocol("InjectConfigUpdate", func() { h.acceptConfigUpdate(update, h.babel.SelfPeer()) })
+	return nil
+}
+
+func (h *Hyparview) configUpdateToGossip() *ConfigUpdate {
+	if h.configUpdate == nil || h.configUpdateRounds <= 0 {
+		return nil
+	}
+	h.configUpdateRounds--
+	return h.configUpdate
+}
+
+func (h *Hyparview) acceptConfigUpdate(update ConfigUpdate, sender peer.Peer) {
+	if h.configAdminKey == nil {
+		return
+	}
+	if h.configUpdate != nil && update.Version <= h.configUpdate.Version {
+		return
+	}
+	if !ed25519.Verify(h.configAdminKey, update.signedBytes(), update.Signature) {
+		h.logger.Warnf("Discarding config update v%d from %s: invalid signature", update.Version, sender.String())
+		return
+	}
+	prevConf := *h.conf
+	newConf := *h.conf
+	err := applyConfigSettings(&newConf, update.Settings)
+	if err == nil {
+		err = validateReloadableConfig(&newConf)
+	}
+	if err != nil {
+		h.logger.Warnf("Discarding config update v%d from %s: %s", update.Version, sender.String(), err)
+		return
+	}
+
+	h.logger.Infof("Accepting config update v%d from %s", update.Version, sender.String())
+	h.applyReloadableConfig(&prevConf, &newConf)
+	h.setBlacklist(update.Blacklist)
+	h.configUpdate = &update
+	h.configUpdateRounds = h.conf.ConfigGossipRounds
+	if h.configUpdateRounds <= 0 {
+		h.configUpdateRounds = defaultConfigGossipRounds
+	}
+	h.babel.SendNotification(ConfigReloadedNotification{Applied: true, ViewVersion: h.CurrentViewVersion()})
+}
+
+func applyConfigSettings(conf *HyparviewConfig, settings map[string]string) error {
+	for key, value := range settings {
+		if !gossipableSettings[key] {
+			return fmt.Errorf("setting %s cannot be changed through config updates", key)
+		}
+		if key == "logLevel" {
+			conf.LogLevel = value
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("setting %s: %w", key, err)
+		}
+		switch key {
+		case "minShuffleTimerDurationSeconds":
+			conf.MinShuffleTimerDurationSeconds = n
+		case "debugTimerDurationSeconds":
+			conf.DebugTimerDurationSeconds = n
+		case "activeViewSize":
+			conf.ActiveViewSize = n
+		case "passiveViewSize":
+			conf.PassiveViewSize = n
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Package conformance pins the wire format of every Hyparview message through golden binary encodings
+// and round-trip checks, so that serializer changes breaking wire compatibility are caught before deployment.
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+)
+
+const goldenExtension = ".golden"
+
+// RoundTrip serializes msg, deserializes it back and re-serializes the result,
+// failing if the message type or its encoding changes along the way.
+func RoundTrip(msg message.Message) ([]byte, error) {
+	encoded := msg.Serializer().Serialize(msg)
+	decoded := msg.Deserializer().Deserialize(encoded)
+	if decoded.Type() != msg.Type() {
+		return nil, fmt.Errorf("type mismatch after round trip: %d != %d", decoded.Type(), msg.Type())
+	}
+	if reflect.TypeOf(decoded) != reflect.TypeOf(msg) {
+		return nil, fmt.Errorf("go type mismatch after round trip: %s != %s", reflect.TypeOf(decoded), reflect.TypeOf(msg))
+	}
+	reEncoded := decoded.Serializer().Serialize(decoded)
+	if !bytes.Equal(encoded, reEncoded) {
+		return nil, fmt.Errorf("encoding changed after round trip: %x != %x", encoded, reEncoded)
+	}
+	return encoded, nil
+}
+
+// VerifyGolden round-trips every vector and compares its encoding against the golden file in dir.
+// If update is set, golden files are (re)written instead.
+func VerifyGolden(dir string, update bool) error {
+	if update {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	for _, v := range Vectors() {
+		encoded, err := RoundTrip(v.Message)
+		if err != nil {
+			return fmt.Errorf("%s: %w", v.Name, err)
+		}
+		path := filepath.Join(dir, v.Name+goldenExtension)
+		if update {
+			if err := ioutil.WriteFile(path, encoded, 0644); err != nil {
+				return err
+			}
+			continue
+		}
+		golden, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", v.Name, err)
+		}
+		if !bytes.Equal(golden, encoded) {
+			return fmt.Errorf("%s: wire format changed, golden=%x current=%x", v.Name, golden, encoded)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package conformance
+
+import (
+	"flag"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current encodings")
+
+func TestRoundTrip(t *testing.T) {
+	for _, v := range Vectors() {
+		v := v
+		t.Run(v.Name, func(t *testing.T) {
+			if _, err := RoundTrip(v.Message); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestGolden(t *testing.T) {
+	if err := VerifyGolden("testdata", *update); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestTruncated checks that deserializers cope with every prefix of a valid encoding, as sent by a
+// peer whose connection broke mid-message, without panicking.
+func TestTruncated(t *testing.T) {
+	for _, v := range Vectors() {
+		v := v
+		t.Run(v.Name, func(t *testing.T) {
+			encoded := v.Message.Serializer().Serialize(v.Message)
+			for i := 0; i < len(encoded); i++ {
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Errorf("deserializing the first %d of %d bytes panicked: %v", i, len(encoded), r)
+						}
+					}()
+					v.Message.Deserializer().Deserialize(append([]byte{}, encoded[:i]...))
+				}()
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+//go:build gofuzz
+// +build gofuzz
+
+package conformance
+
+import (
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/x-bot/protocol"
+)
+
+func fuzzDeserializer(msg message.Message, data []byte) int {
+	decoded := msg.Deserializer().Deserialize(data)
+	if decoded == nil {
+		return 0
+	}
+	if _, err := RoundTrip(decoded); err != nil {
+		return 0
+	}
+	return 1
+}
+
+func FuzzJoinDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.JoinMessage{}, data)
+}
+
+func FuzzDisconnectDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.DisconnectMessage{}, data)
+}
+
+func FuzzForwardJoinDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.ForwardJoinMessage{}, data)
+}
+
+func FuzzForwardJoinReplyDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.ForwardJoinMessageReply{}, data)
+}
+
+func FuzzNeighbourDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.NeighbourMessage{}, data)
+}
+
+func FuzzNeighbourReplyDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.NeighbourMessageReply{}, data)
+}
+
+func FuzzNeighbourMaintenanceDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.NeighbourMaintenanceMessage{}, data)
+}
+
+func FuzzShuffleDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.ShuffleMessage{}, data)
+}
+
+func FuzzShuffleReplyDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.ShuffleReplyMessage{}, data)
+}
+
+func FuzzCyclonShuffleDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.CyclonShuffleMessage{}, data)
+}
+
+func FuzzCyclonShuffleReplyDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.CyclonShuffleReplyMessage{}, data)
+}
+
+func FuzzWalkTerminatedDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.WalkTerminatedMessage{}, data)
+}
+
+func FuzzCompactShuffleDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.CompactShuffleMessage{}, data)
+}
+
+func FuzzCompactShuffleReplyDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.CompactShuffleReplyMessage{}, data)
+}
+
+func FuzzJoinRejectDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.JoinRejectMessage{}, data)
+}
+
+func FuzzViewSnapshotDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.ViewSnapshotMessage{}, data)
+}
+
+func FuzzBlacklistDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.BlacklistMessage{}, data)
+}
+
+func FuzzRedirectDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.RedirectMessage{}, data)
+}
+
+func FuzzPassiveViewRequestDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.PassiveViewRequestMessage{}, data)
+}
+
+func FuzzPassiveViewReplyDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.PassiveViewReplyMessage{}, data)
+}
+
+func FuzzBandwidthProbeDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.BandwidthProbeMessage{}, data)
+}
+
+func FuzzBandwidthProbeReplyDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.BandwidthProbeReplyMessage{}, data)
+}
+
+func FuzzLivenessProbeDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.LivenessProbeMessage{}, data)
+}
+
+func FuzzLivenessProbeReplyDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.LivenessProbeReplyMessage{}, data)
+}
+
+func FuzzShuffleFragmentDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.ShuffleFragmentMessage{}, data)
+}
+
+func FuzzMetadataDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.MetadataMessage{}, data)
+}
+
+func FuzzLatencyVectorDeserializer(data []byte) int {
+	return fuzzDeserializer(protocol.LatencyVectorMessage{}, data)
+}
@@ -0,0 +1,100 @@
+package conformance
+
+import (
+	"net"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/x-bot/protocol"
+)
+
+// Vector is a canonical instance of a message type whose binary encoding is pinned by a golden file.
+type Vector struct {
+	Name    string
+	Message message.Message
+}
+
+func vectorPeers() []peer.Peer {
+	return []peer.Peer{
+		peer.NewPeer(net.IPv4(10, 10, 0, 1), 1200, 1300),
+		peer.NewPeer(net.IPv4(10, 10, 0, 2), 1201, 1301),
+		peer.NewPeer(net.IPv4(192, 168, 1, 254), 65535, 0),
+	}
+}
+
+func Vectors() []Vector {
+	peers := vectorPeers()
+	configUpdate := protocol.ConfigUpdate{
+		Version:   3,
+		Settings:  map[string]string{"activeViewSize": "6", "logLevel": "debug"},
+		Blacklist: peers[2:],
+		Signature: []byte{0xDE, 0xAD, 0xBE, 0xEF},
+	}
+	return []Vector{
+		{Name: "join", Message: protocol.JoinMessage{}},
+		{Name: "join_outbound_only", Message: protocol.JoinMessage{OutboundOnly: true}},
+		{Name: "join_incarnation", Message: protocol.JoinMessage{Incarnation: 7}},
+		{Name: "join_trace", Message: protocol.JoinMessage{TraceID: 0xCAFEBABE}},
+		{Name: "join_overlay", Message: protocol.JoinMessage{OverlayID: 0x5EED5EED}},
+		{Name: "join_cluster_token", Message: protocol.JoinMessage{ClusterToken: "s3cr3t"}},
+		{Name: "join_capabilities", Message: protocol.JoinMessage{ClusterToken: "s3cr3t", Capabilities: protocol.CapTimeHints}},
+		{Name: "disconnect_empty", Message: protocol.DisconnectMessage{}},
+		{Name: "disconnect_peers", Message: protocol.DisconnectMessage{Peers: peers}},
+		{Name: "forward_join", Message: protocol.ForwardJoinMessage{TTL: 6, OriginalSender: peers[0]}},
+		{Name: "forward_join_walk", Message: protocol.ForwardJoinMessage{TTL: 6, WalkID: 0xCAFEBABE, OriginalSender: peers[0]}},
+		{Name: "forward_join_capabilities", Message: protocol.ForwardJoinMessage{TTL: 6, WalkID: 0xCAFEBABE, OriginalSender: peers[0], Capabilities: protocol.CapTimeHints}},
+		{Name: "forward_join_reply", Message: protocol.ForwardJoinMessageReply{}},
+		{Name: "forward_join_reply_incarnation", Message: protocol.ForwardJoinMessageReply{Incarnation: 7}},
+		{Name: "forward_join_reply_trace", Message: protocol.ForwardJoinMessageReply{Incarnation: 7, TraceID: 0xCAFEBABE}},
+		{Name: "forward_join_reply_capabilities", Message: protocol.ForwardJoinMessageReply{Capabilities: protocol.CapTimeHints}},
+		{Name: "neighbour_high_prio", Message: protocol.NeighbourMessage{HighPrio: true}},
+		{Name: "neighbour_low_prio", Message: protocol.NeighbourMessage{HighPrio: false}},
+		{Name: "neighbour_outbound_only", Message: protocol.NeighbourMessage{HighPrio: true, OutboundOnly: true}},
+		{Name: "neighbour_incarnation", Message: protocol.NeighbourMessage{HighPrio: false, Incarnation: 7}},
+		{Name: "neighbour_trace", Message: protocol.NeighbourMessage{HighPrio: true, TraceID: 0xCAFEBABE}},
+		{Name: "neighbour_overlay", Message: protocol.NeighbourMessage{HighPrio: true, OverlayID: 0x5EED5EED}},
+		{Name: "neighbour_cluster_token", Message: protocol.NeighbourMessage{HighPrio: true, OverlayID: 0x5EED5EED, ClusterToken: "s3cr3t"}},
+		{Name: "neighbour_capabilities", Message: protocol.NeighbourMessage{HighPrio: true, Capabilities: protocol.CapTimeHints}},
+		{Name: "neighbour_reply_accepted", Message: protocol.NeighbourMessageReply{Accepted: true}},
+		{Name: "neighbour_reply_rejected", Message: protocol.NeighbourMessageReply{Accepted: false}},
+		{Name: "neighbour_reply_incarnation", Message: protocol.NeighbourMessageReply{Accepted: true, Incarnation: 7}},
+		{Name: "neighbour_reply_trace", Message: protocol.NeighbourMessageReply{Accepted: false, TraceID: 0xCAFEBABE}},
+		{Name: "neighbour_reply_capabilities", Message: protocol.NeighbourMessageReply{Accepted: true, Incarnation: 7, Capabilities: protocol.CapTimeHints}},
+		{Name: "neighbour_maintenance", Message: protocol.NeighbourMaintenanceMessage{}},
+		{Name: "neighbour_maintenance_incarnation", Message: protocol.NeighbourMaintenanceMessage{FailureDomain: "rack-1", Incarnation: 7}},
+		{Name: "neighbour_maintenance_time_hint", Message: protocol.NeighbourMaintenanceMessage{FailureDomain: "rack-1", Incarnation: 7, Time: &protocol.TimeHint{SentAt: 1600000000000000000, EchoSentAt: 1599999999000000000, EchoDelay: 250000000}}},
+		{Name: "neighbour_maintenance_version", Message: protocol.NeighbourMaintenanceMessage{FailureDomain: "rack-1", Version: 1}},
+		{Name: "neighbour_maintenance_time_hint_version", Message: protocol.NeighbourMaintenanceMessage{FailureDomain: "rack-1", Incarnation: 7, Time: &protocol.TimeHint{SentAt: 1600000000000000000, EchoSentAt: 1599999999000000000, EchoDelay: 250000000}, Version: 1}},
+		{Name: "shuffle", Message: protocol.ShuffleMessage{ID: 42, TTL: 3, Peers: peers}},
+		{Name: "shuffle_capabilities", Message: protocol.ShuffleMessage{ID: 42, TTL: 3, Peers: peers, Capabilities: protocol.CapCompactPeerLists}},
+		{Name: "compact_shuffle", Message: protocol.CompactShuffleMessage{ID: 42, TTL: 3, Peers: peers}},
+		{Name: "compact_shuffle_reply", Message: protocol.CompactShuffleReplyMessage{ID: 42, Peers: peers}},
+		{Name: "compact_shuffle_optional_analytics_ports", Message: protocol.CompactShuffleMessage{ID: 42, TTL: 3, Peers: peers, OmitZeroAnalyticsPorts: true}},
+		{Name: "compact_shuffle_reply_optional_analytics_ports", Message: protocol.CompactShuffleReplyMessage{ID: 42, Peers: peers, OmitZeroAnalyticsPorts: true}},
+		{Name: "compact_shuffle_overlay", Message: protocol.CompactShuffleMessage{ID: 42, TTL: 3, Peers: peers, OverlayID: 0x5EED5EED}},
+		{Name: "shuffle_reply", Message: protocol.ShuffleReplyMessage{ID: 42, Peers: peers[:2]}},
+		{Name: "shuffle_config_update", Message: protocol.ShuffleMessage{ID: 42, TTL: 3, Peers: peers, ConfigUpdate: &configUpdate}},
+		{Name: "shuffle_overlay", Message: protocol.ShuffleMessage{ID: 42, TTL: 3, Peers: peers, OverlayID: 0x5EED5EED, ConfigUpdate: &configUpdate}},
+		{Name: "shuffle_reply_config_update", Message: protocol.ShuffleReplyMessage{ID: 42, Peers: peers[:2], ConfigUpdate: &configUpdate}},
+		{Name: "cyclon_shuffle", Message: protocol.CyclonShuffleMessage{ID: 7, Peers: peers, Ages: []uint16{0, 3, 65535}}},
+		{Name: "cyclon_shuffle_reply", Message: protocol.CyclonShuffleReplyMessage{ID: 7, Peers: peers[1:], Ages: []uint16{1, 2}}},
+		{Name: "cyclon_shuffle_overlay", Message: protocol.CyclonShuffleMessage{ID: 7, Peers: peers, Ages: []uint16{0, 3, 65535}, OverlayID: 0x5EED5EED}},
+		{Name: "cyclon_shuffle_reply_overlay", Message: protocol.CyclonShuffleReplyMessage{ID: 7, Peers: peers[1:], Ages: []uint16{1, 2}, OverlayID: 0x5EED5EED}},
+		{Name: "join_reject", Message: protocol.JoinRejectMessage{Reason: protocol.RejectRateLimited, Peers: peers}},
+		{Name: "view_snapshot", Message: protocol.ViewSnapshotMessage{Peers: peers}},
+		{Name: "redirect", Message: protocol.RedirectMessage{Peers: peers}},
+		{Name: "passive_view_request", Message: protocol.PassiveViewRequestMessage{Size: 30}},
+		{Name: "passive_view_reply", Message: protocol.PassiveViewReplyMessage{Peers: peers}},
+		{Name: "bandwidth_probe", Message: protocol.BandwidthProbeMessage{ProbeID: 0xCAFEBABE, Seq: 3, Count: 8, Payload: make([]byte, 16)}},
+		{Name: "bandwidth_probe_reply", Message: protocol.BandwidthProbeReplyMessage{ProbeID: 0xCAFEBABE, BytesPerSecond: 12500000}},
+		{Name: "liveness_probe", Message: protocol.LivenessProbeMessage{Nonce: 0xCAFEBABE}},
+		{Name: "liveness_probe_reply", Message: protocol.LivenessProbeReplyMessage{Nonce: 0xCAFEBABE}},
+		{Name: "shuffle_fragment", Message: protocol.ShuffleFragmentMessage{GroupID: 0xCAFEBABE, Index: 1, Count: 3, InnerType: protocol.ShuffleMessageType, Payload: []byte{1, 2, 3, 4}}},
+		{Name: "disconnect_px", Message: protocol.DisconnectMessage{Peers: peers[:1], PX: peers[1:]}},
+		{Name: "disconnect_eviction_nonce", Message: protocol.DisconnectMessage{Peers: peers[:1], Nonce: 0xCAFEBABE}},
+		{Name: "metadata", Message: protocol.MetadataMessage{Version: 3, Attributes: map[string]string{"zone": "eu-west-1a", "role": "storage"}}},
+		{Name: "latency_vector", Message: protocol.LatencyVectorMessage{Peers: peers, RTTs: []uint32{150, 2300, 98000}}},
+		{Name: "blacklist", Message: protocol.BlacklistMessage{ID: 11, Peers: peers[:2], TTLs: []uint32{0, 3600}, Signature: []byte{0xDE, 0xAD, 0xBE, 0xEF}}},
+		{Name: "walk_terminated", Message: protocol.WalkTerminatedMessage{WalkID: 9, Hops: 4, Accepted: true, OriginalSender: peers[2]}},
+	}
+}
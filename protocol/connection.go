@@ -0,0 +1,41 @@
+package protocol
+
+import "github.com/nm-morais/go-babel/pkg/peer"
+
+// NeighbourConnection describes an established connection to an active view member. Payload protocols
+// may send over it instead of dialing the peer themselves, until a NeighborDownNotification for the
+// peer is received.
+type NeighbourConnection struct {
+	Peer peer.Peer
+	// Capabilities are the Cap... bits the neighbour advertised in its last shuffle, zero if it sent none.
+	Capabilities uint8
+	// Version is the protocol version of the neighbour, zero until it sent a maintenance message.
+	Version uint16
+	// Bandwidth is the estimated bandwidth to the neighbour, nil unless bandwidth probing measured it.
+	Bandwidth *Bandwidth
+}
+
+// GetNeighbourConnection returns the connection to p if p is an active view member HyParView is
+// connected to. It blocks until the protocol goroutine answers.
+func (h *Hyparview) GetNeighbourConnection(p peer.Peer) (NeighbourConnection, bool) {
+	reply := make(chan *NeighbourConnection, 1)
+	h.onProtocol("GetNeighbourConnection", func() { reply <- h.neighbourConnection(p) })
+	conn := <-reply
+	if conn == nil {
+		return NeighbourConnection{}, false
+	}
+	return *conn, true
+}
+
+func (h *Hyparview) neighbourConnection(p peer.Peer) *NeighbourConnection {
+	neighbour, ok := h.activeView.get(p)
+	if !ok || !neighbour.outConnected {
+		return nil
+	}
+	return &NeighbourConnection{
+		Peer:         neighbour.Peer,
+		Capabilities: neighbour.capabilities,
+		Version:      neighbour.version,
+		Bandwidth:    neighbour.bandwidthEstimate(),
+	}
+}
@@ -0,0 +1,101 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Nodes register themselves as instances of a Consul service with a TTL check, which the
+// heartbeat keeps passing. Consul deregisters instances whose check stays critical for too long.
+
+const defaultDiscoveryTTL = 30 * time.Second
+
+type consulService struct {
+	Address string            `json:"Address"`
+	Port    int               `json:"Port"`
+	Meta    map[string]string `json:"Meta"`
+}
+
+type consulProvider struct {
+	address   string
+	service   string
+	ttl       time.Duration
+	serviceID string
+}
+
+func newConsulProvider(conf *HyparviewConfig) *consulProvider {
+	ttl := defaultDiscoveryTTL
+	if conf.ConsulDiscovery.TTLSeconds > 0 {
+		ttl = time.Duration(conf.ConsulDiscovery.TTLSeconds) * time.Second
+	}
+	address := conf.ConsulDiscovery.Address
+	if address == "" {
+		address = "http://127.0.0.1:8500"
+	}
+	return &consulProvider{
+		address: address,
+		service: conf.ConsulDiscovery.Service,
+		ttl:     ttl,
+	}
+}
+
+func (c *consulProvider) Name() string {
+	return "consul"
+}
+
+func (c *consulProvider) Register(self peer.Peer) error {
+	c.serviceID = fmt.Sprintf("%s-%s", c.service, self.String())
+	registration := map[string]interface{}{
+		"ID":      c.serviceID,
+		"Name":    c.service,
+		"Address": self.IP().String(),
+		"Port":    self.ProtosPort(),
+		"Meta":    map[string]string{"analyticsPort": strconv.Itoa(int(self.AnalyticsPort()))},
+		"Check": map[string]string{
+			"TTL":                            c.ttl.String(),
+			"DeregisterCriticalServiceAfter": (3 * c.ttl).String(),
+		},
+	}
+	if err := doJSONRequest(discoveryHTTPClient, http.MethodPut, c.address+"/v1/agent/service/register", nil, registration, nil); err != nil {
+		return err
+	}
+	return c.Heartbeat()
+}
+
+func (c *consulProvider) Heartbeat() error {
+	if c.serviceID == "" {
+		return fmt.Errorf("not registered")
+	}
+	return doJSONRequest(discoveryHTTPClient, http.MethodPut, c.address+"/v1/agent/check/pass/service:"+c.serviceID, nil, nil, nil)
+}
+
+func (c *consulProvider) Deregister() error {
+	if c.serviceID == "" {
+		return nil
+	}
+	return doJSONRequest(discoveryHTTPClient, http.MethodPut, c.address+"/v1/agent/service/deregister/"+c.serviceID, nil, nil, nil)
+}
+
+func (c *consulProvider) Peers() ([]peer.Peer, error) {
+	entries := []struct {
+		Service consulService `json:"Service"`
+	}{}
+	if err := doJSONRequest(discoveryHTTPClient, http.MethodGet, c.address+"/v1/health/service/"+c.service+"?passing=true", nil, nil, &entries); err != nil {
+		return nil, err
+	}
+	peers := make([]peer.Peer, 0, len(entries))
+	for _, entry := range entries {
+		ip := net.ParseIP(entry.Service.Address)
+		if ip == nil {
+			continue
+		}
+		analyticsPort, _ := strconv.Atoi(entry.Service.Meta["analyticsPort"])
+		peers = append(peers, peer.NewPeer(ip, uint16(entry.Service.Port), uint16(analyticsPort)))
+	}
+	return peers, nil
+}
@@ -0,0 +1,150 @@
+package protocol
+
+import (
+	"math"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Cyclon-style shuffle: every entry carries an age which is incremented on each shuffle round,
+// the oldest active view entry is chosen as the shuffle target, and the exchange is done directly
+// with it instead of through a random walk.
+
+func (h *Hyparview) cyclonShuffle() {
+	h.incrementAges()
+	target := h.activeView.oldest()
+	if target == nil {
+		h.logger.Info("No nodes to send cyclon shuffle message to")
+		return
+	}
+
+	peers := []peer.Peer{}
+	ages := []uint16{}
+	if !h.conf.OutboundOnly && !h.excludedFromShuffles(h.babel.SelfPeer()) {
+		peers = append(peers, h.babel.SelfPeer())
+		ages = append(ages, 0)
+	}
+	for _, p := range h.passiveView.getRandomStatesFromView(h.conf.Kp-1, target) {
+		if h.excludedFromShuffles(p.Peer) {
+			continue
+		}
+		peers = append(peers, p.Peer)
+		ages = append(ages, p.age)
+	}
+	for _, p := range h.activeView.getRandomStatesFromView(h.conf.Ka, target) {
+		if !h.isDialable(p) || h.excludedFromShuffles(p.Peer) {
+			continue
+		}
+		peers = append(peers, p.Peer)
+		ages = append(ages, p.age)
+	}
+	toSend := CyclonShuffleMessage{
+		ID:        h.nextShuffleID(),
+		Peers:     peers,
+		Ages:      ages,
+		OverlayID: h.overlayID(),
+	}
+	target.age = 0
+	h.lastCyclonShuffleMsg = &toSend
+	h.logger.Info("Sending cyclon shuffle message to: ", target.String())
+	h.sendMessage(toSend, target)
+}
+
+func (h *Hyparview) HandleCyclonShuffleMessage(sender peer.Peer, msg message.Message) {
+	shuffleMsg := msg.(CyclonShuffleMessage)
+	if !h.sameOverlay(sender, shuffleMsg.OverlayID, "cyclon shuffle") || h.dropIfContainsSelf(sender, "cyclon shuffle", shuffleMsg.Peers) {
+		return
+	}
+	exclusions := append([]peer.Peer{sender}, shuffleMsg.Peers...)
+	toSend := h.passiveView.getRandomStatesFromView(len(shuffleMsg.Peers), exclusions...)
+	reply := CyclonShuffleReplyMessage{
+		ID:        shuffleMsg.ID,
+		Peers:     make([]peer.Peer, 0, len(toSend)),
+		Ages:      make([]uint16, 0, len(toSend)),
+		OverlayID: h.overlayID(),
+	}
+	sentPeers := make([]peer.Peer, 0, len(toSend))
+	for _, p := range toSend {
+		if h.excludedFromShuffles(p.Peer) {
+			continue
+		}
+		reply.Peers = append(reply.Peers, p.Peer)
+		reply.Ages = append(reply.Ages, p.age)
+		sentPeers = append(sentPeers, p.Peer)
+	}
+	h.mergeCyclonEntriesWithPassiveView(shuffleMsg.Peers, shuffleMsg.Ages, sentPeers, sender)
+	h.sendMessageTmpTransport(reply, sender)
+}
+
+func (h *Hyparview) HandleCyclonShuffleReplyMessage(sender peer.Peer, msg message.Message) {
+	shuffleReplyMsg := msg.(CyclonShuffleReplyMessage)
+	if !h.sameOverlay(sender, shuffleReplyMsg.OverlayID, "cyclon shuffle reply") || h.dropIfContainsSelf(sender, "cyclon shuffle reply", shuffleReplyMsg.Peers) {
+		return
+	}
+	h.logger.Infof("Received cyclon shuffle reply message %+v", shuffleReplyMsg)
+	peersToDiscardFirst := []peer.Peer{}
+	if h.lastCyclonShuffleMsg != nil {
+		peersToDiscardFirst = append(peersToDiscardFirst, h.lastCyclonShuffleMsg.Peers...)
+	}
+	h.lastCyclonShuffleMsg = nil
+	h.mergeCyclonEntriesWithPassiveView(shuffleReplyMsg.Peers, shuffleReplyMsg.Ages, peersToDiscardFirst, sender)
+}
+
+func (h *Hyparview) mergeCyclonEntriesWithPassiveView(peers []peer.Peer, ages []uint16, peersToKickFirst []peer.Peer, origin peer.Peer) {
+	for i, receivedHost := range peers {
+		if h.isSelf(receivedHost) {
+			continue
+		}
+
+		if !h.learnablePeer(receivedHost) {
+			continue
+		}
+
+		var age uint16
+		if i < len(ages) {
+			age = ages[i]
+		}
+
+		if existing, ok := h.passiveView.get(receivedHost); ok {
+			if age < existing.age {
+				existing.age = age
+			}
+			existing.lastHeard = h.timeNow()
+			continue
+		}
+
+		if h.passiveOriginFull(origin) || h.deferForVerification(receivedHost, age, origin) {
+			continue
+		}
+
+		if h.passiveView.isFull() {
+			removed := false
+			for _, firstToKick := range peersToKickFirst {
+				if h.passiveView.remove(firstToKick) != nil {
+					removed = true
+					break
+				}
+			}
+			if !removed {
+				if oldest := h.passiveView.oldest(); oldest != nil {
+					h.passiveView.remove(oldest)
+				}
+			}
+		}
+		h.addPeerToPassiveViewWithAge(receivedHost, age, origin)
+	}
+}
+
+func (h *Hyparview) incrementAges() {
+	for _, p := range h.activeView.asArr {
+		if p.age < math.MaxUint16 {
+			p.age++
+		}
+	}
+	for _, p := range h.passiveView.asArr {
+		if p.age < math.MaxUint16 {
+			p.age++
+		}
+	}
+}
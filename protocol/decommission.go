@@ -0,0 +1,73 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// Decommissioning drains a node before it leaves, for rolling maintenance of large fleets: the node
+// stops accepting joins, neighbour requests and promotions, stops advertising itself in shuffles, and
+// disconnects from its neighbours one at a time, spread over the drain window, so that they do not
+// all look for a replacement at once. Once the last neighbour is gone, or the window is over, it
+// leaves like Leave does.
+
+type decommissionState struct {
+	deadline time.Time
+}
+
+// Decommission drains the node over drain and then leaves the overlay. The returned channel is closed
+// once the node left, as the one returned by Leave.
+func (h *Hyparview) Decommission(drain time.Duration) <-chan struct{} {
+	h.onProtocol("Decommission", func() { h.decommissionOver(drain) })
+	return h.leaveFlushed
+}
+
+// decommissioning returns whether the node is draining or already left, either way it takes no new neighbours.
+func (h *Hyparview) decommissioning() bool {
+	return h.decommission != nil || h.hasLeft()
+}
+
+func (h *Hyparview) decommissionOver(drain time.Duration) {
+	if h.hasLeft() || h.decommissioning() {
+		return
+	}
+	h.decommission = &decommissionState{deadline: h.timeNow().Add(drain)}
+	h.logger.Warnf("Decommissioning, draining %d neighbours over %s", h.activeView.size(), drain)
+	h.scheduleNextDrain()
+}
+
+// scheduleNextDrain spreads the remaining neighbours evenly over the rest of the drain window, the
+// last one being disconnected at the deadline.
+func (h *Hyparview) scheduleNextDrain() {
+	if h.activeView.size() == 0 {
+		h.leave()
+		return
+	}
+	remaining := h.timeUntil(h.decommission.deadline)
+	if remaining < 0 {
+		remaining = 0
+	}
+	h.babel.RegisterTimer(h.ID(), DrainTimer{duration: remaining / time.Duration(h.activeView.size())})
+}
+
+func (h *Hyparview) HandleDrainTimer(t timer.Timer) {
+	if h.hasLeft() {
+		return
+	}
+	for _, p := range h.activeView.getRandomStatesFromView(1) {
+		h.logger.Warnf("Decommissioning, disconnecting from %s (%d neighbours left)", p.String(), h.activeView.size()-1)
+		h.removeFromActiveView(p.Peer, RemovalDecommissioned)
+		delete(h.outboundOnlyPeers, p.String())
+		h.sendPrune(p)
+		if p.outConnected {
+			h.babel.SendNotification(NeighborDownNotification{
+				PeerDown:    p.Peer,
+				View:        h.getView(),
+				ViewVersion: h.nextViewVersion(),
+			})
+		}
+		h.viewsChanged()
+	}
+	h.scheduleNextDrain()
+}
@@ -0,0 +1,59 @@
+package protocol
+
+import (
+	"net"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Joiners may connect from an ephemeral source port while advertising a fixed listen port. With
+// DialBackJoiners set, the advertised address is dialed before admitting the joiner, so that view
+// entries never point at addresses nobody can connect to. Outbound-only joiners are not checked,
+// they are never dialed.
+
+// DialBackConfig enables dialing joiners back.
+type DialBackConfig struct {
+	DialBackJoiners       bool `yaml:"dialBackJoiners"`
+	DialBackTimeoutMillis int  `yaml:"dialBackTimeoutMillis"`
+}
+
+const defaultDialBackTimeout = 2 * time.Second
+
+func (h *Hyparview) dialBackTimeout() time.Duration {
+	if h.conf.DialBackTimeoutMillis > 0 {
+		return time.Duration(h.conf.DialBackTimeoutMillis) * time.Millisecond
+	}
+	return defaultDialBackTimeout
+}
+
+func (h *Hyparview) needsDialBack(joinMsg JoinMessage) bool {
+	return h.conf.DialBackJoiners && !joinMsg.OutboundOnly
+}
+
+func (h *Hyparview) dialBackJoiner(joiner peer.Peer, joinMsg JoinMessage) {
+	addr := joiner.ToTCPAddr().String()
+	timeout := h.dialBackTimeout()
+	h.logger.Infof("Dialing back joiner %s before admitting it", joiner.String())
+	go func() {
+		err := probeTCP(addr, timeout)
+		h.onProtocol("dialBackJoiner", func() { h.dialedBack(joiner, joinMsg, err) })
+	}()
+}
+
+func probeTCP(addr string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func (h *Hyparview) dialedBack(joiner peer.Peer, joinMsg JoinMessage, err error) {
+	if err != nil {
+		h.logger.Warnf("Advertised address of joiner %s is not reachable: %s", joiner.String(), err)
+		h.rejectJoin(joiner, RejectUnreachable)
+		return
+	}
+	h.admitJoiner(joiner, joinMsg)
+}
@@ -0,0 +1,72 @@
+package protocol
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// DialTimeoutMiliseconds is the timeout of babel's stream manager, which applies to every dial made by
+// babel, side streams included. DialTimeoutOverrides set other timeouts for destinations matching an
+// address or CIDR, e.g. a short one inside the local zone for fast failover and a long one for a far
+// away region. babel can only be given one timeout, so it is given the longest one (BabelDialTimeout)
+// and the protocol fails dials to the active view members which take longer than their own timeout.
+
+// BabelDialTimeout returns the dial timeout babel's stream manager must be configured with.
+func BabelDialTimeout(conf *HyparviewConfig) time.Duration {
+	longest := conf.DialTimeoutMiliseconds
+	for _, override := range conf.DialTimeoutOverrides {
+		if override.Milliseconds > longest {
+			longest = override.Milliseconds
+		}
+	}
+	return time.Duration(longest) * time.Millisecond
+}
+
+func parseDialDestination(destination string) (*net.IPNet, error) {
+	if ip := net.ParseIP(destination); ip != nil {
+		bits := 8 * net.IPv4len
+		if ip.To4() == nil {
+			bits = 8 * net.IPv6len
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, subnet, err := net.ParseCIDR(destination)
+	return subnet, err
+}
+
+func validateDialTimeoutOverrides(conf *HyparviewConfig) error {
+	for _, override := range conf.DialTimeoutOverrides {
+		if _, err := parseDialDestination(override.Destination); err != nil {
+			return fmt.Errorf("invalid dial timeout override destination %q: %w", override.Destination, err)
+		}
+		if override.Milliseconds <= 0 {
+			return fmt.Errorf("dial timeout override for %s must be positive", override.Destination)
+		}
+	}
+	return nil
+}
+
+// dialTimeout returns the timeout of the first override matching p, the global one otherwise.
+func (h *Hyparview) dialTimeout(p peer.Peer) time.Duration {
+	for _, override := range h.conf.DialTimeoutOverrides {
+		subnet, err := parseDialDestination(override.Destination)
+		if err == nil && override.Milliseconds > 0 && subnet.Contains(p.IP()) {
+			return time.Duration(override.Milliseconds) * time.Millisecond
+		}
+	}
+	return time.Duration(h.conf.DialTimeoutMiliseconds) * time.Millisecond
+}
+
+func (h *Hyparview) HandleDialTimeoutTimer(t timer.Timer) {
+	dialTimeout := t.(DialTimeoutTimer)
+	p, ok := h.activeView.get(dialTimeout.peer)
+	if !ok || !p.dialing || !p.dialStartedAt.Equal(dialTimeout.startedAt) {
+		return
+	}
+	h.logger.Warnf("Dial to %s timed out after %s", p.String(), dialTimeout.duration)
+	h.DialFailed(p.Peer)
+}
@@ -0,0 +1,167 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// DiscoveryConfig selects the registry bootstrap peers are fetched from, the first one set is used.
+type DiscoveryConfig struct {
+	KubernetesBootstrap *struct {
+		Service        string `yaml:"service"`
+		Namespace      string `yaml:"namespace"`
+		Mode           string `yaml:"mode"`
+		Port           int    `yaml:"port"`
+		RefreshSeconds int    `yaml:"refreshSeconds"`
+	} `yaml:"kubernetesBootstrap"`
+	ConsulDiscovery *struct {
+		Address        string `yaml:"address"`
+		Service        string `yaml:"service"`
+		TTLSeconds     int    `yaml:"ttlSeconds"`
+		RefreshSeconds int    `yaml:"refreshSeconds"`
+	} `yaml:"consulDiscovery"`
+	EtcdDiscovery *struct {
+		Endpoint       string `yaml:"endpoint"`
+		Prefix         string `yaml:"prefix"`
+		TTLSeconds     int    `yaml:"ttlSeconds"`
+		RefreshSeconds int    `yaml:"refreshSeconds"`
+	} `yaml:"etcdDiscovery"`
+}
+
+// discoveryState holds the discovery provider, nil unless one is configured or set.
+type discoveryState struct {
+	discovery        DiscoveryProvider
+	discoveryRefresh time.Duration
+}
+
+// A DiscoveryProvider replaces the static bootstrap list with peers fetched from an external
+// registry. Its methods may block on the network, so they are only called from the discovery
+// goroutine, except for the initial Register and Peers done on Start and Deregister done on Leave.
+type DiscoveryProvider interface {
+	Name() string
+	// Register announces the local node, Heartbeat keeps that registration alive and is called once per refresh
+	Register(self peer.Peer) error
+	Heartbeat() error
+	Deregister() error
+	Peers() ([]peer.Peer, error)
+}
+
+const defaultDiscoveryRefresh = 30 * time.Second
+
+var discoveryHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+func usesDiscovery(conf *HyparviewConfig) bool {
+	return conf.KubernetesBootstrap != nil || conf.ConsulDiscovery != nil || conf.EtcdDiscovery != nil
+}
+
+func newDiscoveryProvider(conf *HyparviewConfig, self peer.Peer) (DiscoveryProvider, time.Duration) {
+	var provider DiscoveryProvider
+	refreshSeconds := 0
+	switch {
+	case conf.KubernetesBootstrap != nil:
+		provider = newKubernetesProvider(conf, self)
+		refreshSeconds = conf.KubernetesBootstrap.RefreshSeconds
+	case conf.ConsulDiscovery != nil:
+		provider = newConsulProvider(conf)
+		refreshSeconds = conf.ConsulDiscovery.RefreshSeconds
+	case conf.EtcdDiscovery != nil:
+		provider = newEtcdProvider(conf)
+		refreshSeconds = conf.EtcdDiscovery.RefreshSeconds
+	default:
+		return nil, 0
+	}
+	if refreshSeconds <= 0 {
+		return provider, defaultDiscoveryRefresh
+	}
+	return provider, time.Duration(refreshSeconds) * time.Second
+}
+
+// SetDiscoveryProvider plugs a custom discovery provider, it must be called before the protocol starts.
+func (h *Hyparview) SetDiscoveryProvider(provider DiscoveryProvider, refresh time.Duration) {
+	h.discovery = provider
+	h.discoveryRefresh = refresh
+}
+
+func (h *Hyparview) startDiscovery() {
+	// the first lookup is synchronous so that the initial join already uses the discovered peers
+	if err := h.discovery.Register(h.babel.SelfPeer()); err != nil {
+		h.logger.Errorf("Could not register in %s: %s", h.discovery.Name(), err)
+	}
+	if peers, err := h.discovery.Peers(); err != nil {
+		h.logger.Errorf("%s bootstrap discovery failed: %s", h.discovery.Name(), err)
+	} else {
+		h.setDiscoveredBootstraps(peers)
+	}
+
+	go func() {
+		ticker := time.NewTicker(h.discoveryRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-h.left:
+				return
+			case <-ticker.C:
+			}
+			if err := h.discovery.Heartbeat(); err != nil {
+				h.logger.Errorf("Could not refresh %s registration: %s", h.discovery.Name(), err)
+			}
+			peers, err := h.discovery.Peers()
+			if err != nil {
+				h.logger.Errorf("%s bootstrap discovery failed: %s", h.discovery.Name(), err)
+				continue
+			}
+			h.onProtocol("discovery", func() { h.setDiscoveredBootstraps(peers) })
+		}
+	}()
+}
+
+func (h *Hyparview) setDiscoveredBootstraps(peers []peer.Peer) {
+	if len(peers) == 0 {
+		h.logger.Warnf("%s bootstrap discovery returned no peers, keeping previous bootstraps", h.discovery.Name())
+		return
+	}
+	h.logger.Infof("Discovered %d bootstrap peers from %s", len(peers), h.discovery.Name())
+	h.bootstrapNodes = peers
+}
+
+func (h *Hyparview) deregisterDiscovery() {
+	if h.discovery == nil {
+		return
+	}
+	if err := h.discovery.Deregister(); err != nil {
+		h.logger.Errorf("Could not deregister from %s: %s", h.discovery.Name(), err)
+	}
+}
+
+func doJSONRequest(client *http.Client, method, url string, header http.Header, body, out interface{}) error {
+	var reqBody bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequest(method, url, &reqBody)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: %s", method, url, resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,78 @@
+package protocol
+
+import (
+	"net"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// DiversityConfig spreads the active view over subnets and favours recently seen peers on promotion.
+type DiversityConfig struct {
+	MaxActivePerSubnet   int `yaml:"maxActivePerSubnet"`
+	SubnetPrefixLength   int `yaml:"subnetPrefixLength"`
+	PromotionRecencyBias int `yaml:"promotionRecencyBias"`
+}
+
+const (
+	defaultSubnetPrefixLength = 24
+	ipv6SubnetPrefixLength    = 64
+)
+
+func (h *Hyparview) subnetOf(p peer.Peer) string {
+	if ip4 := p.IP().To4(); ip4 != nil {
+		prefixLen := h.conf.SubnetPrefixLength
+		if prefixLen <= 0 {
+			prefixLen = defaultSubnetPrefixLength
+		}
+		return ip4.Mask(net.CIDRMask(prefixLen, 8*net.IPv4len)).String()
+	}
+	return p.IP().Mask(net.CIDRMask(ipv6SubnetPrefixLength, 8*net.IPv6len)).String()
+}
+
+// subnetAllows returns false if adding p to the active view would exceed MaxActivePerSubnet members sharing its subnet.
+func (h *Hyparview) subnetAllows(p peer.Peer) bool {
+	if h.conf.MaxActivePerSubnet <= 0 {
+		return true
+	}
+	subnet := h.subnetOf(p)
+	sameSubnet := 0
+	for _, neigh := range h.activeView.asArr {
+		if h.subnetOf(neigh) == subnet {
+			sameSubnet++
+		}
+	}
+	return sameSubnet < h.conf.MaxActivePerSubnet
+}
+
+func (h *Hyparview) subnetDiversityHook(view ViewID, p peer.Peer) bool {
+	return h.subnetAllows(p)
+}
+
+// pickPromotionCandidate returns a random passive view member, biased towards recently heard ones,
+// preferring the ones whose subnet is not yet saturated in the active view. Members vetoed by the
+// promotion hooks are skipped, nil is returned if all of them are.
+func (h *Hyparview) pickPromotionCandidate() peer.Peer {
+	return h.pickPromotionCandidateExcept(nil)
+}
+
+func (h *Hyparview) pickPromotionCandidateExcept(excluded map[string]bool) peer.Peer {
+	chosen := h.pickCandidate(h.conf.PromotionRecencyBias, excluded)
+	h.shadowPromotion(excluded, chosen)
+	return chosen
+}
+
+func (h *Hyparview) pickCandidate(recencyBias int, excluded map[string]bool) peer.Peer {
+	var fallback peer.Peer
+	for _, c := range h.passiveView.getRecencyWeightedElements(recencyBias) {
+		if excluded[c.String()] || !h.promotionAllowed(c) {
+			continue
+		}
+		if h.subnetAllows(c) {
+			return c
+		}
+		if fallback == nil {
+			fallback = c
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,138 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Nodes register themselves as keys under a prefix in etcd, attached to a lease which the heartbeat
+// keeps alive, so keys of crashed nodes expire with the lease. The v3 JSON gateway is used to avoid
+// depending on the etcd client.
+
+type etcdPeer struct {
+	Host          string `json:"host"`
+	Port          uint16 `json:"port"`
+	AnalyticsPort uint16 `json:"analyticsPort"`
+}
+
+type etcdProvider struct {
+	endpoint string
+	prefix   string
+	ttl      time.Duration
+	self     peer.Peer
+	leaseID  string
+}
+
+func newEtcdProvider(conf *HyparviewConfig) *etcdProvider {
+	ttl := defaultDiscoveryTTL
+	if conf.EtcdDiscovery.TTLSeconds > 0 {
+		ttl = time.Duration(conf.EtcdDiscovery.TTLSeconds) * time.Second
+	}
+	endpoint := conf.EtcdDiscovery.Endpoint
+	if endpoint == "" {
+		endpoint = "http://127.0.0.1:2379"
+	}
+	prefix := conf.EtcdDiscovery.Prefix
+	if prefix == "" {
+		prefix = "/hyparview/peers/"
+	}
+	return &etcdProvider{
+		endpoint: endpoint,
+		prefix:   prefix,
+		ttl:      ttl,
+	}
+}
+
+func (e *etcdProvider) Name() string {
+	return "etcd"
+}
+
+func (e *etcdProvider) Register(self peer.Peer) error {
+	e.self = self
+	lease := struct {
+		ID string `json:"ID"`
+	}{}
+	if err := doJSONRequest(discoveryHTTPClient, http.MethodPost, e.endpoint+"/v3/lease/grant", nil, map[string]int64{"TTL": int64(e.ttl.Seconds())}, &lease); err != nil {
+		return err
+	}
+	value, err := json.Marshal(etcdPeer{
+		Host:          self.IP().String(),
+		Port:          self.ProtosPort(),
+		AnalyticsPort: self.AnalyticsPort(),
+	})
+	if err != nil {
+		return err
+	}
+	put := map[string]interface{}{
+		"key":   []byte(e.prefix + self.String()),
+		"value": value,
+		"lease": lease.ID,
+	}
+	if err = doJSONRequest(discoveryHTTPClient, http.MethodPost, e.endpoint+"/v3/kv/put", nil, put, nil); err != nil {
+		return err
+	}
+	e.leaseID = lease.ID
+	return nil
+}
+
+func (e *etcdProvider) Heartbeat() error {
+	if e.leaseID == "" {
+		if e.self == nil {
+			return fmt.Errorf("not registered")
+		}
+		return e.Register(e.self)
+	}
+	keepAlive := struct {
+		Result struct {
+			TTL string `json:"TTL"`
+		} `json:"result"`
+	}{}
+	if err := doJSONRequest(discoveryHTTPClient, http.MethodPost, e.endpoint+"/v3/lease/keepalive", nil, map[string]string{"ID": e.leaseID}, &keepAlive); err != nil {
+		return err
+	}
+	if keepAlive.Result.TTL == "" || keepAlive.Result.TTL == "0" {
+		// the lease expired and took our key with it
+		return e.Register(e.self)
+	}
+	return nil
+}
+
+func (e *etcdProvider) Deregister() error {
+	if e.leaseID == "" {
+		return nil
+	}
+	err := doJSONRequest(discoveryHTTPClient, http.MethodPost, e.endpoint+"/v3/lease/revoke", nil, map[string]string{"ID": e.leaseID}, nil)
+	e.leaseID = ""
+	return err
+}
+
+func (e *etcdProvider) Peers() ([]peer.Peer, error) {
+	rangeEnd := []byte(e.prefix)
+	rangeEnd[len(rangeEnd)-1]++
+	resp := struct {
+		Kvs []struct {
+			Value []byte `json:"value"`
+		} `json:"kvs"`
+	}{}
+	if err := doJSONRequest(discoveryHTTPClient, http.MethodPost, e.endpoint+"/v3/kv/range", nil, map[string][]byte{"key": []byte(e.prefix), "range_end": rangeEnd}, &resp); err != nil {
+		return nil, err
+	}
+	peers := make([]peer.Peer, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		p := etcdPeer{}
+		if err := json.Unmarshal(kv.Value, &p); err != nil {
+			continue
+		}
+		ip := net.ParseIP(p.Host)
+		if ip == nil {
+			continue
+		}
+		peers = append(peers, peer.NewPeer(ip, p.Port, p.AnalyticsPort))
+	}
+	return peers, nil
+}
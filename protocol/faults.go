@@ -0,0 +1,63 @@
+package protocol
+
+import (
+	"errors"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// With FaultInjection set, operators can simulate failures on a node during game days, to check that
+// alerts fire and the overlay heals: dropping a neighbour as if it went down, failing a dial as if the
+// peer was unreachable, and suppressing the shuffles the node starts for a while. Faults go through the
+// same code paths as real failures, and every injected fault is logged as <faultInjected>.
+
+const (
+	FaultDropNeighbour    = "dropNeighbour"
+	FaultDialFailed       = "dialFailed"
+	FaultSuppressShuffles = "suppressShuffles"
+)
+
+var ErrFaultInjectionDisabled = errors.New("fault injection is disabled")
+
+// DropNeighbour removes p from the active view as if it went down.
+func (h *Hyparview) DropNeighbour(p peer.Peer) error {
+	return h.injectFault(FaultDropNeighbour, func() {
+		neighbour, ok := h.activeView.get(p)
+		if !ok {
+			h.logger.Warnf("Not injecting %s, %s is not a neighbour", FaultDropNeighbour, p.String())
+			return
+		}
+		h.analyticsWarn("faultInjected", "%s %s", FaultDropNeighbour, p.String())
+		h.handleNodeDown(neighbour.Peer, RemovalInjected)
+	})
+}
+
+// FailDial reports a failed dial to p, as babel does when a neighbour cannot be reached.
+func (h *Hyparview) FailDial(p peer.Peer) error {
+	return h.injectFault(FaultDialFailed, func() {
+		h.analyticsWarn("faultInjected", "%s %s", FaultDialFailed, p.String())
+		h.DialFailed(p)
+	})
+}
+
+// SuppressShuffles stops the node from starting shuffles for d, shuffles of other nodes are still
+// forwarded and answered.
+func (h *Hyparview) SuppressShuffles(d time.Duration) error {
+	return h.injectFault(FaultSuppressShuffles, func() {
+		h.analyticsWarn("faultInjected", "%s %s", FaultSuppressShuffles, d)
+		h.noShufflesUntil = h.timeNow().Add(d)
+	})
+}
+
+func (h *Hyparview) injectFault(fault string, inject func()) error {
+	if !h.conf.FaultInjection {
+		return ErrFaultInjectionDisabled
+	}
+	h.onProtocol(fault, inject)
+	return nil
+}
+
+func (h *Hyparview) shufflesSuppressed() bool {
+	return h.timeNow().Before(h.noShufflesUntil)
+}
@@ -0,0 +1,51 @@
+package protocol
+
+import (
+	"encoding/json"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/go-babel/pkg/protocol"
+)
+
+// Connections dialed by other protocols are denied unless the dialing protocol is listed in
+// AllowedForeignProtocols or a ForeignConnPolicy allows it, so that protocols co-hosted on the same
+// babel instance can share connections with HyParView. Denials are counted per dialing protocol and
+// logged as <deniedForeignConns>. InConnRequested runs outside of the protocol goroutine, so denials
+// are counted on it through onProtocol.
+
+// foreignConnState holds the policies and the denials per dialing protocol.
+type foreignConnState struct {
+	foreignConnPolicies []ForeignConnPolicy
+	deniedForeignConns  map[protocol.ID]int
+}
+
+// ForeignConnPolicy decides whether an inbound connection from p, dialed by protocol dialerProto, is
+// accepted. Policies run outside of the protocol goroutine and must be added before Start.
+type ForeignConnPolicy func(dialerProto protocol.ID, p peer.Peer) bool
+
+func (h *Hyparview) AddForeignConnPolicy(policy ForeignConnPolicy) {
+	h.foreignConnPolicies = append(h.foreignConnPolicies, policy)
+}
+
+func (h *Hyparview) foreignConnAllowed(dialerProto protocol.ID, p peer.Peer) bool {
+	for _, allowed := range h.conf.AllowedForeignProtocols {
+		if protocol.ID(allowed) == dialerProto {
+			return true
+		}
+	}
+	for _, policy := range h.foreignConnPolicies {
+		if policy(dialerProto, p) {
+			return true
+		}
+	}
+	h.onProtocol("foreignConnDenied", func() { h.deniedForeignConns[dialerProto]++ })
+	return false
+}
+
+func (h *Hyparview) logDeniedForeignConns() {
+	res, err := json.Marshal(h.deniedForeignConns)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("deniedForeignConns", "%s", string(res))
+}
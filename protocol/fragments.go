@@ -0,0 +1,185 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// With ShuffleFragmentBytes set, nodes advertise CapShuffleFragments, and shuffles and shuffle replies
+// to peers advertising it are split into ShuffleFragmentMessages of at most that many payload bytes
+// when their encoding is larger, instead of relying on the transport to accept arbitrarily large
+// frames (large Ka/Kp, config updates attached). The receiver reassembles the fragments and handles the
+// original message, fragments of a message not completed within ShuffleFragmentTimeoutMillis are
+// dropped. Reassembly works whatever the local config, so a cluster can enable it node by node.
+
+// FragmentConfig enables splitting large shuffles.
+type FragmentConfig struct {
+	ShuffleFragmentBytes         int `yaml:"shuffleFragmentBytes"`
+	ShuffleFragmentTimeoutMillis int `yaml:"shuffleFragmentTimeoutMillis"`
+}
+
+// fragmentState holds the shuffles being reassembled.
+type fragmentState struct {
+	shuffleAssemblies map[string]*shuffleAssembly
+	fragmentStats     ShuffleFragmentStats
+}
+
+const (
+	maxShuffleFragments           = 255
+	maxPendingShuffleAssemblies   = 64
+	defaultShuffleFragmentTimeout = 5 * time.Second
+)
+
+type shuffleAssembly struct {
+	innerType message.ID
+	fragments [][]byte
+	received  int
+	startedAt time.Time
+}
+
+type ShuffleFragmentStats struct {
+	Fragmented  int `json:"fragmented"`
+	Reassembled int `json:"reassembled"`
+	TimedOut    int `json:"timedOut"`
+	Dropped     int `json:"dropped"`
+}
+
+func (h *Hyparview) shuffleFragmentTimeout() time.Duration {
+	if h.conf.ShuffleFragmentTimeoutMillis > 0 {
+		return time.Duration(h.conf.ShuffleFragmentTimeoutMillis) * time.Millisecond
+	}
+	return defaultShuffleFragmentTimeout
+}
+
+// fragmentShuffle returns the fragments msg must be sent as, or nil if it must be sent whole.
+func (h *Hyparview) fragmentShuffle(msg message.Message, capabilities uint8) []ShuffleFragmentMessage {
+	limit := h.conf.ShuffleFragmentBytes
+	if limit <= 0 || capabilities&CapShuffleFragments == 0 {
+		return nil
+	}
+	msgBytes := msg.Serializer().Serialize(msg)
+	if len(msgBytes) <= limit {
+		return nil
+	}
+	count := (len(msgBytes) + limit - 1) / limit
+	if count > maxShuffleFragments {
+		h.logger.Warnf("%d bytes %T needs more than %d fragments, sending it whole", len(msgBytes), msg, maxShuffleFragments)
+		return nil
+	}
+	groupID := rand.Uint32()
+	fragments := make([]ShuffleFragmentMessage, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * limit
+		if end > len(msgBytes) {
+			end = len(msgBytes)
+		}
+		fragments = append(fragments, ShuffleFragmentMessage{
+			GroupID:   groupID,
+			Index:     uint8(i),
+			Count:     uint8(count),
+			InnerType: uint16(msg.Type()),
+			Payload:   msgBytes[i*limit : end],
+		})
+	}
+	h.fragmentStats.Fragmented++
+	return fragments
+}
+
+func (h *Hyparview) HandleShuffleFragmentMessage(sender peer.Peer, msg message.Message) {
+	fragment := msg.(ShuffleFragmentMessage)
+	if fragment.Count == 0 || fragment.Index >= fragment.Count || shuffleDeserializer(message.ID(fragment.InnerType)) == nil {
+		return
+	}
+	key := shuffleAssemblyKey(sender, fragment.GroupID)
+	assembly, ok := h.shuffleAssemblies[key]
+	if !ok {
+		if len(h.shuffleAssemblies) >= maxPendingShuffleAssemblies {
+			h.fragmentStats.Dropped++
+			h.logger.Warnf("Too many shuffles being reassembled, dropping fragment from %s", sender.String())
+			return
+		}
+		assembly = &shuffleAssembly{
+			innerType: message.ID(fragment.InnerType),
+			fragments: make([][]byte, fragment.Count),
+			startedAt: h.timeNow(),
+		}
+		h.shuffleAssemblies[key] = assembly
+		h.babel.RegisterTimer(h.ID(), ShuffleFragmentTimer{duration: h.shuffleFragmentTimeout(), key: key, startedAt: assembly.startedAt})
+	}
+	if int(fragment.Count) != len(assembly.fragments) || assembly.fragments[fragment.Index] != nil {
+		return
+	}
+	assembly.fragments[fragment.Index] = append([]byte(nil), fragment.Payload...)
+	assembly.received++
+	if assembly.received < len(assembly.fragments) {
+		return
+	}
+	delete(h.shuffleAssemblies, key)
+	h.fragmentStats.Reassembled++
+	var msgBytes []byte
+	for _, payload := range assembly.fragments {
+		msgBytes = append(msgBytes, payload...)
+	}
+	inner := shuffleDeserializer(assembly.innerType).Deserialize(msgBytes)
+	if h.shedMessage(inner) {
+		return
+	}
+	switch inner := inner.(type) {
+	case ShuffleMessage:
+		h.HandleShuffleMessage(sender, inner)
+	case ShuffleReplyMessage:
+		h.HandleShuffleReplyMessage(sender, inner)
+	case CompactShuffleMessage:
+		h.HandleCompactShuffleMessage(sender, inner)
+	case CompactShuffleReplyMessage:
+		h.HandleCompactShuffleReplyMessage(sender, inner)
+	}
+}
+
+func (h *Hyparview) HandleShuffleFragmentTimer(t timer.Timer) {
+	fragmentTimer := t.(ShuffleFragmentTimer)
+	assembly, ok := h.shuffleAssemblies[fragmentTimer.key]
+	if !ok || !assembly.startedAt.Equal(fragmentTimer.startedAt) {
+		return
+	}
+	delete(h.shuffleAssemblies, fragmentTimer.key)
+	h.fragmentStats.TimedOut++
+	h.logger.Warnf("Dropping partially received shuffle %s, got %d/%d fragments", fragmentTimer.key, assembly.received, len(assembly.fragments))
+}
+
+func shuffleAssemblyKey(sender peer.Peer, groupID uint32) string {
+	return fmt.Sprintf("%s/%08x", sender.String(), groupID)
+}
+
+// shuffleDeserializer returns the deserializer of the messages which may be fragmented.
+func shuffleDeserializer(msgType message.ID) message.Deserializer {
+	switch msgType {
+	case ShuffleMessageType:
+		return defaultShuffleMessageSerializer
+	case ShuffleReplyMessageType:
+		return defaultShuffleReplyMessageSerializer
+	case CompactShuffleMessageType:
+		return defaultCompactShuffleMessageSerializer
+	case CompactShuffleReplyMessageType:
+		return defaultCompactShuffleReplyMessageSerializer
+	default:
+		return nil
+	}
+}
+
+func (h *Hyparview) logShuffleFragments() {
+	if h.conf.ShuffleFragmentBytes <= 0 && h.fragmentStats == (ShuffleFragmentStats{}) {
+		return
+	}
+	res, err := json.Marshal(h.fragmentStats)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("shuffleFragments", "%s", string(res))
+}
@@ -0,0 +1,35 @@
+package protocol
+
+import "time"
+
+// Freezing keeps the neighbour set stable for a bounded window, for applications running sensitive
+// operations such as coordinated snapshots. While frozen, the node does not shuffle, does not send
+// neighbour requests (so it neither promotes passive view members nor replaces slow or outdated
+// neighbours) and does not evict neighbours to make room for others: joiners reaching a full active
+// view are redirected and high priority neighbour requests are refused. Failure detection keeps
+// running, failed neighbours are removed but only replaced once the window is over.
+
+// Freeze pauses membership changes for d, or until Unfreeze is called. Calling it again while frozen
+// moves the end of the window.
+func (h *Hyparview) Freeze(d time.Duration) {
+	until := h.timeNow().Add(d)
+	h.onProtocol("Freeze", func() { h.freezeUntil(until) })
+}
+
+// Unfreeze resumes membership changes.
+func (h *Hyparview) Unfreeze() {
+	h.onProtocol("Unfreeze", func() { h.freezeUntil(time.Time{}) })
+}
+
+func (h *Hyparview) frozen() bool {
+	return h.timeNow().Before(h.frozenUntil)
+}
+
+func (h *Hyparview) freezeUntil(until time.Time) {
+	h.frozenUntil = until
+	if h.frozen() {
+		h.logger.Warnf("Membership frozen until %s", h.frozenUntil.Format(time.RFC3339))
+		return
+	}
+	h.logger.Warn("Membership unfrozen")
+}
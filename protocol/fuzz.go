@@ -0,0 +1,136 @@
+//go:build gofuzz
+// +build gofuzz
+
+package protocol
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/x-bot/testutil/babeltest"
+	"github.com/sirupsen/logrus"
+)
+
+func newFuzzHyparview() *Hyparview {
+	self := peer.NewPeer(net.IPv4(10, 0, 0, 1), 1200, 1300)
+	conf := &HyparviewConfig{
+		ActiveViewSize:  5,
+		PassiveViewSize: 25,
+		ARWL:            6,
+		PRWL:            3,
+		Ka:              2,
+		Kp:              3,
+		BootstrapPeers: []struct {
+			Port          int    `yaml:"port"`
+			Host          string `yaml:"host"`
+			AnalyticsPort int    `yaml:"analyticsPort"`
+		}{{Port: 1200, Host: "10.0.0.2"}},
+	}
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	// the network is never stepped and the protocol never registered, so messages, dials and
+	// notifications go nowhere and timers never fire: the handlers only ever see the messages decoded
+	// from the fuzzer's input
+	babel := babeltest.NewNetwork(babeltest.Config{}, time.Now()).NewNode(self, logger)
+	h := NewHyparviewProtocol(babel, conf).(*Hyparview)
+	h.logger = logger
+	return h
+}
+
+type fuzzHandler struct {
+	msg     message.Message
+	handler func(h *Hyparview) func(peer.Peer, message.Message)
+}
+
+var fuzzHandlers = []fuzzHandler{
+	{JoinMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleJoinMessage }},
+	{ForwardJoinMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleForwardJoinMessage }},
+	{ForwardJoinMessageReply{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleForwardJoinMessageReply }},
+	{ShuffleMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleShuffleMessage }},
+	{ShuffleReplyMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleShuffleReplyMessage }},
+	{NeighbourMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleNeighbourMessage }},
+	{NeighbourMessageReply{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleNeighbourReplyMessage }},
+	{NeighbourMaintenanceMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleNeighbourMaintenanceMessage }},
+	{DisconnectMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleDisconnectMessage }},
+	{CyclonShuffleMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleCyclonShuffleMessage }},
+	{CyclonShuffleReplyMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleCyclonShuffleReplyMessage }},
+	{CompactShuffleMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleCompactShuffleMessage }},
+	{CompactShuffleReplyMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleCompactShuffleReplyMessage }},
+	{JoinRejectMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleJoinRejectMessage }},
+	{ViewSnapshotMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleViewSnapshotMessage }},
+	{BlacklistMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleBlacklistMessage }},
+	{RedirectMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleRedirectMessage }},
+	{PassiveViewRequestMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandlePassiveViewRequestMessage }},
+	{PassiveViewReplyMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandlePassiveViewReplyMessage }},
+	{WalkTerminatedMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleWalkTerminatedMessage }},
+	{BandwidthProbeMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleBandwidthProbeMessage }},
+	{BandwidthProbeReplyMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleBandwidthProbeReplyMessage }},
+	{LivenessProbeMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleLivenessProbeMessage }},
+	{LivenessProbeReplyMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleLivenessProbeReplyMessage }},
+	{ShuffleFragmentMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleShuffleFragmentMessage }},
+	{LatencyVectorMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleLatencyVectorMessage }},
+	{MetadataMessage{}, func(h *Hyparview) func(peer.Peer, message.Message) { return h.HandleMetadataMessage }},
+}
+
+// FuzzHandlers interprets data as a sequence of (handler selector, sender selector, length, payload)
+// records, delivers each deserialized message to its handler and checks the view invariants after each one.
+func FuzzHandlers(data []byte) int {
+	h := newFuzzHyparview()
+	senders := []peer.Peer{
+		peer.NewPeer(net.IPv4(10, 0, 0, 2), 1200, 1300),
+		peer.NewPeer(net.IPv4(10, 0, 0, 3), 1200, 1300),
+		peer.NewPeer(net.IPv4(10, 0, 0, 4), 1200, 1300),
+		h.babel.SelfPeer(),
+	}
+	delivered := 0
+	for len(data) >= 3 {
+		handler := fuzzHandlers[int(data[0])%len(fuzzHandlers)]
+		sender := senders[int(data[1])%len(senders)]
+		payloadLen := int(data[2])
+		data = data[3:]
+		if payloadLen > len(data) {
+			payloadLen = len(data)
+		}
+		payload := data[:payloadLen]
+		data = data[payloadLen:]
+		if peer.PeersEqual(sender, h.babel.SelfPeer()) {
+			continue
+		}
+		msg := handler.msg.Deserializer().Deserialize(payload)
+		handler.handler(h)(sender, msg)
+		h.checkInvariants()
+		delivered++
+	}
+	if delivered == 0 {
+		return 0
+	}
+	return 1
+}
+
+func (h *Hyparview) checkInvariants() {
+	for _, v := range []*View{h.activeView, h.passiveView} {
+		if v.size() > v.capacity {
+			panic(fmt.Sprintf("%s view over capacity: %d > %d", v.id, v.size(), v.capacity))
+		}
+		if len(v.asArr) != len(v.asMap) {
+			panic(fmt.Sprintf("%s view array and map out of sync", v.id))
+		}
+		for _, p := range v.asArr {
+			if _, ok := v.asMap[p.String()]; !ok {
+				panic(fmt.Sprintf("%s view entry %s missing from map", v.id, p.String()))
+			}
+			if peer.PeersEqual(p, h.babel.SelfPeer()) {
+				panic(fmt.Sprintf("self in %s view", v.id))
+			}
+		}
+	}
+	for _, p := range h.activeView.asArr {
+		if h.passiveView.contains(p) {
+			panic(fmt.Sprintf("peer %s in both views", p.String()))
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package protocol
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// HealthReport combines the view sizes, the state of every active view link, the recent churn, the
+// shuffle success rate and the join times into a single report with an overall status, for fleet
+// dashboards which cannot interpret the individual analytics lines. A node is critical when it did not
+// join yet or has no connected neighbour, and degraded when any of the checks below fails, each
+// failing check adding a reason to the report.
+
+type HealthStatus string
+
+const (
+	HealthOK       HealthStatus = "ok"
+	HealthDegraded HealthStatus = "degraded"
+	HealthCritical HealthStatus = "critical"
+
+	healthChurnWindow = time.Minute
+	// shuffles sent before the shuffle success rate is checked
+	healthMinShuffles = 5
+	// below this percentage of answered shuffles the node is degraded
+	healthMinShuffleSuccess = 50
+)
+
+type NeighbourHealth struct {
+	Peer               string        `json:"peer"`
+	Connected          bool          `json:"connected"`
+	Dialing            bool          `json:"dialing"`
+	SinceInbound       time.Duration `json:"sinceInbound,omitempty"`
+	MissedMaintenance  int           `json:"missedMaintenance"`
+	LivenessProbing    bool          `json:"livenessProbing"`
+	LinkLatency        time.Duration `json:"linkLatency,omitempty"`
+	LinkFailurePercent int           `json:"linkFailurePercent"`
+}
+
+type HealthReport struct {
+	Status          HealthStatus      `json:"status"`
+	Reasons         []string          `json:"reasons"`
+	Joined          bool              `json:"joined"`
+	JoinedAt        time.Time         `json:"joinedAt,omitempty"`
+	LastJoinAttempt time.Time         `json:"lastJoinAttempt,omitempty"`
+	ActiveSize      int               `json:"activeSize"`
+	ActiveCapacity  int               `json:"activeCapacity"`
+	Connected       int               `json:"connected"`
+	PassiveSize     int               `json:"passiveSize"`
+	PassiveCapacity int               `json:"passiveCapacity"`
+	ChurnPerMinute  int               `json:"churnPerMinute"`
+	ShuffleSuccess  float64           `json:"shuffleSuccessPercent"`
+	Neighbours      []NeighbourHealth `json:"neighbours"`
+}
+
+// HealthReport returns the health of the node, it blocks until the protocol goroutine builds the report.
+func (h *Hyparview) HealthReport() HealthReport {
+	reply := make(chan HealthReport, 1)
+	h.onProtocol("HealthReport", func() { reply <- h.healthReport() })
+	return <-reply
+}
+
+func (h *Hyparview) trackChurn() {
+	h.onBeforeRemove(ActiveView, func(_ ViewID, _ peer.Peer) {
+		h.churn = append(h.churn, h.timeNow())
+	})
+}
+
+func (h *Hyparview) recentChurn() int {
+	for len(h.churn) > 0 && h.elapsedSince(h.churn[0]) > healthChurnWindow {
+		h.churn = h.churn[1:]
+	}
+	return len(h.churn)
+}
+
+func (h *Hyparview) healthReport() HealthReport {
+	report := HealthReport{
+		Status:          HealthOK,
+		Reasons:         []string{},
+		Joined:          h.isJoinDone() && h.joinErr == nil,
+		JoinedAt:        h.joinedAt,
+		LastJoinAttempt: h.lastJoinAttempt,
+		ActiveSize:      h.activeView.size(),
+		ActiveCapacity:  h.activeView.capacity,
+		PassiveSize:     h.passiveView.size(),
+		PassiveCapacity: h.passiveView.capacity,
+		ChurnPerMinute:  h.recentChurn(),
+		ShuffleSuccess:  100,
+		Neighbours:      []NeighbourHealth{},
+	}
+	unhealthyLinks := 0
+	for _, p := range h.activeView.asArr {
+		neighbour := NeighbourHealth{
+			Peer:            p.String(),
+			Connected:       p.outConnected,
+			Dialing:         p.dialing,
+			LivenessProbing: p.liveness != nil,
+		}
+		if !p.lastInbound.IsZero() {
+			neighbour.SinceInbound = h.elapsedSince(p.lastInbound)
+		}
+		if p.maintenance != nil {
+			neighbour.MissedMaintenance = p.maintenance.missed
+		}
+		if p.link != nil {
+			neighbour.LinkLatency = p.link.latency
+			if total := p.link.delivered + p.link.failed; total > 0 {
+				neighbour.LinkFailurePercent = 100 * p.link.failed / total
+			}
+		}
+		if p.outConnected {
+			report.Connected++
+		}
+		if neighbour.MissedMaintenance > 0 || neighbour.LivenessProbing {
+			unhealthyLinks++
+		}
+		report.Neighbours = append(report.Neighbours, neighbour)
+	}
+	if sent := h.shuffleReplyStats.Sent; sent > 0 {
+		report.ShuffleSuccess = 100 * float64(h.shuffleReplyStats.Answered) / float64(sent)
+	}
+
+	critical := func(reason string) {
+		report.Status = HealthCritical
+		report.Reasons = append(report.Reasons, reason)
+	}
+	degraded := func(reason string) {
+		if report.Status == HealthOK {
+			report.Status = HealthDegraded
+		}
+		report.Reasons = append(report.Reasons, reason)
+	}
+	if !report.Joined {
+		critical("not joined")
+	}
+	if report.Connected == 0 {
+		critical("no connected neighbour")
+	} else if report.Connected < (report.ActiveCapacity+1)/2 {
+		degraded(fmt.Sprintf("%d of %d neighbours connected", report.Connected, report.ActiveCapacity))
+	}
+	if report.PassiveSize == 0 {
+		degraded("passive view empty")
+	}
+	if report.ChurnPerMinute > report.ActiveCapacity {
+		degraded(fmt.Sprintf("%d neighbours lost in the last minute", report.ChurnPerMinute))
+	}
+	if h.shuffleReplyStats.Sent >= healthMinShuffles && report.ShuffleSuccess < healthMinShuffleSuccess {
+		degraded(fmt.Sprintf("%.0f%% of shuffles answered", report.ShuffleSuccess))
+	}
+	if unhealthyLinks > 0 {
+		degraded(fmt.Sprintf("%d neighbours missing maintenance or liveness replies", unhealthyLinks))
+	}
+	return report
+}
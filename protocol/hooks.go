@@ -0,0 +1,178 @@
+package protocol
+
+import "github.com/nm-morais/go-babel/pkg/peer"
+
+// hookState holds the hooks registered by the application.
+type hookState struct {
+	promotionHooks        []PromotionCandidateHook
+	shuffleExclusionHooks []ShuffleExclusionHook
+}
+
+type ViewID int
+
+const (
+	ActiveView ViewID = iota
+	PassiveView
+)
+
+func (v ViewID) String() string {
+	switch v {
+	case ActiveView:
+		return "active"
+	case PassiveView:
+		return "passive"
+	default:
+		return "unknown"
+	}
+}
+
+// BeforeAddHook is called before a peer is added to a view, returning false vetoes the addition.
+type BeforeAddHook func(view ViewID, p peer.Peer) bool
+
+// AfterAddHook is called after a peer was added to a view.
+type AfterAddHook func(view ViewID, p peer.Peer)
+
+// BeforeRemoveHook is called before a peer is removed (or dropped) from a view.
+type BeforeRemoveHook func(view ViewID, p peer.Peer)
+
+// PromotionCandidateHook is called before sending a neighbour request to a passive view member,
+// returning false vetoes its promotion (e.g. peers running a wrong version or failing health checks).
+type PromotionCandidateHook func(p peer.Peer) bool
+
+// ShuffleExclusionHook is called for every peer, self included, about to be advertised in a shuffle or
+// shuffle reply, returning true leaves it out (e.g. peers in maintenance mode). Excluded peers stay in
+// the views.
+type ShuffleExclusionHook func(p peer.Peer) bool
+
+type viewHooks struct {
+	beforeAdd    []BeforeAddHook
+	afterAdd     []AfterAddHook
+	beforeRemove []BeforeRemoveHook
+}
+
+// OnBeforeAdd registers a hook run before peers are added to view. Like the other hook registrations,
+// it takes effect on the protocol goroutine, so it may be called at any time.
+func (h *Hyparview) OnBeforeAdd(view ViewID, hook BeforeAddHook) {
+	h.onProtocol("OnBeforeAdd", func() { h.onBeforeAdd(view, hook) })
+}
+
+func (h *Hyparview) onBeforeAdd(view ViewID, hook BeforeAddHook) {
+	v := h.viewByID(view)
+	v.hooks.beforeAdd = append(v.hooks.beforeAdd, hook)
+}
+
+// OnAfterAdd registers a hook run after peers were added to view.
+func (h *Hyparview) OnAfterAdd(view ViewID, hook AfterAddHook) {
+	h.onProtocol("OnAfterAdd", func() { h.onAfterAdd(view, hook) })
+}
+
+func (h *Hyparview) onAfterAdd(view ViewID, hook AfterAddHook) {
+	v := h.viewByID(view)
+	v.hooks.afterAdd = append(v.hooks.afterAdd, hook)
+}
+
+// OnBeforeRemove registers a hook run before peers are removed from view.
+func (h *Hyparview) OnBeforeRemove(view ViewID, hook BeforeRemoveHook) {
+	h.onProtocol("OnBeforeRemove", func() { h.onBeforeRemove(view, hook) })
+}
+
+func (h *Hyparview) onBeforeRemove(view ViewID, hook BeforeRemoveHook) {
+	v := h.viewByID(view)
+	v.hooks.beforeRemove = append(v.hooks.beforeRemove, hook)
+}
+
+// OnPromotionCandidate registers a hook able to veto the promotion of passive view members.
+func (h *Hyparview) OnPromotionCandidate(hook PromotionCandidateHook) {
+	h.onProtocol("OnPromotionCandidate", func() { h.promotionHooks = append(h.promotionHooks, hook) })
+}
+
+// OnShuffleExclusion registers a hook able to leave peers out of shuffles.
+func (h *Hyparview) OnShuffleExclusion(hook ShuffleExclusionHook) {
+	h.onProtocol("OnShuffleExclusion", func() { h.shuffleExclusionHooks = append(h.shuffleExclusionHooks, hook) })
+}
+
+func (h *Hyparview) excludedFromShuffles(p peer.Peer) bool {
+	if (h.decommissioning() && h.isSelf(p)) || h.joinOnlyContact(p) {
+		return true
+	}
+	for _, hook := range h.shuffleExclusionHooks {
+		if hook(p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Hyparview) withoutShuffleExclusions(peers []peer.Peer) []peer.Peer {
+	if len(h.shuffleExclusionHooks) == 0 && !h.decommissioning() && !h.joinOnlyBootstraps() {
+		return peers
+	}
+	advertised := make([]peer.Peer, 0, len(peers))
+	for _, p := range peers {
+		if !h.excludedFromShuffles(p) {
+			advertised = append(advertised, p)
+		}
+	}
+	return advertised
+}
+
+func (h *Hyparview) promotionAllowed(p peer.Peer) bool {
+	if h.suspect(p) {
+		h.logger.Infof("Not promoting %s, circuit breaker open", p.String())
+		return false
+	}
+	if h.evictionHeldDown(p) {
+		h.logger.Infof("Not promoting %s, held down after a mutual eviction", p.String())
+		return false
+	}
+	for _, hook := range h.promotionHooks {
+		if !hook(p) {
+			h.logger.Infof("Promotion of %s was vetoed", p.String())
+			return false
+		}
+	}
+	return true
+}
+
+func (h *Hyparview) filterPromotable(peers []peer.Peer) []peer.Peer {
+	promotable := []peer.Peer{}
+	for _, p := range peers {
+		if h.promotionAllowed(p) {
+			promotable = append(promotable, p)
+		}
+	}
+	return promotable
+}
+
+func (h *Hyparview) viewByID(view ViewID) *View {
+	switch view {
+	case ActiveView:
+		return h.activeView
+	case PassiveView:
+		return h.passiveView
+	default:
+		h.logger.Panicf("unknown view %d", view)
+		return nil
+	}
+}
+
+func (v *View) runBeforeAdd(p peer.Peer) bool {
+	for _, hook := range v.hooks.beforeAdd {
+		if !hook(v.id, p) {
+			return false
+		}
+	}
+	return true
+}
+
+func (v *View) runAfterAdd(p peer.Peer) {
+	for _, hook := range v.hooks.afterAdd {
+		hook(v.id, p)
+	}
+}
+
+func (v *View) runBeforeRemove(p peer.Peer) {
+	for _, hook := range v.hooks.beforeRemove {
+		hook(v.id, p)
+	}
+}
@@ -0,0 +1,30 @@
+package protocol
+
+import "github.com/nm-morais/go-babel/pkg/peer"
+
+// With LearnInboundPeers set, peers unknown to both views whose inbound connections are accepted (e.g.
+// joiners whose join got lost) are added to the passive view, if it has room, so that peers discovered
+// organically are not wasted. InConnRequested runs outside of the protocol goroutine, so the peer is
+// handed over through onProtocol. Self, blacklisted and outbound-only peers are ignored, and with
+// VerifyPassivePeers set the peer is verified first like any other.
+
+func (h *Hyparview) learnInboundPeer(p peer.Peer) {
+	if !h.conf.LearnInboundPeers {
+		return
+	}
+	h.onProtocol("learnInboundPeer", func() { h.learnPeer(p) })
+}
+
+func (h *Hyparview) learnPeer(p peer.Peer) {
+	if p.IP() == nil || p.ProtosPort() == 0 || h.isSelf(p) || !h.isDialable(p) || h.isBlacklisted(p) {
+		return
+	}
+	if h.activeView.contains(p) || h.passiveView.contains(p) || h.passiveView.isFull() {
+		return
+	}
+	if h.deferForVerification(p, 0, nil) {
+		return
+	}
+	h.logger.Infof("Learned peer %s from its inbound connection", p.String())
+	h.addPeerToPassiveView(p)
+}
@@ -0,0 +1,142 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Incarnation fencing: with IncarnationFile set, a counter persisted in that file is incremented on
+// every start and sent along the join, neighbour, forward join reply and maintenance messages. A
+// neighbour whose incarnation increases has restarted, its state (connection, counters, ...) belongs
+// to the previous process, so it is dropped and the handshake runs again instead of mixing pre- and
+// post-restart state. An incarnation of 0 is not sent, keeping the original encodings.
+
+// appendHandshakeTrailer appends the incarnation, the trace ID, the overlay ID, the length prefixed
+// cluster token and the capabilities carried by handshake messages. Trailing unset fields are omitted,
+// and the whole trailer is omitted if none is set.
+func appendHandshakeTrailer(msgBytes []byte, incarnation uint64, traceID uint32, overlayID uint32, clusterToken string, capabilities uint8) []byte {
+	if incarnation == 0 && traceID == 0 && overlayID == 0 && clusterToken == "" && capabilities == 0 {
+		return msgBytes
+	}
+	trailer := make([]byte, 8, 18+len(clusterToken))
+	binary.BigEndian.PutUint64(trailer, incarnation)
+	if traceID != 0 || overlayID != 0 || clusterToken != "" || capabilities != 0 {
+		trailer = trailer[:12]
+		binary.BigEndian.PutUint32(trailer[8:], traceID)
+	}
+	if overlayID != 0 || clusterToken != "" || capabilities != 0 {
+		trailer = trailer[:16]
+		binary.BigEndian.PutUint32(trailer[12:], overlayID)
+	}
+	if clusterToken != "" || capabilities != 0 {
+		trailer = append(trailer, byte(len(clusterToken)))
+		trailer = append(trailer, clusterToken...)
+	}
+	if capabilities != 0 {
+		trailer = append(trailer, capabilities)
+	}
+	return append(msgBytes, trailer...)
+}
+
+func readIncarnation(msgBytes []byte, offset int) uint64 {
+	if len(msgBytes) < offset+8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(msgBytes[offset : offset+8])
+}
+
+func readTraceID(msgBytes []byte, offset int) uint32 {
+	if len(msgBytes) < offset+12 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(msgBytes[offset+8 : offset+12])
+}
+
+func readOverlayID(msgBytes []byte, offset int) uint32 {
+	if len(msgBytes) < offset+16 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(msgBytes[offset+12 : offset+16])
+}
+
+func readClusterToken(msgBytes []byte, offset int) string {
+	if len(msgBytes) < offset+17 {
+		return ""
+	}
+	length := int(msgBytes[offset+16])
+	if len(msgBytes) < offset+17+length {
+		return ""
+	}
+	return string(msgBytes[offset+17 : offset+17+length])
+}
+
+func readHandshakeCapabilities(msgBytes []byte, offset int) uint8 {
+	if len(msgBytes) < offset+17 {
+		return 0
+	}
+	length := int(msgBytes[offset+16])
+	if len(msgBytes) < offset+18+length {
+		return 0
+	}
+	return msgBytes[offset+17+length]
+}
+
+func (h *Hyparview) loadIncarnation() {
+	if h.conf.IncarnationFile == "" {
+		return
+	}
+	var previous uint64
+	data, err := ioutil.ReadFile(h.conf.IncarnationFile)
+	if err == nil {
+		previous, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	if err == nil {
+		tmp := h.conf.IncarnationFile + ".tmp"
+		if err = ioutil.WriteFile(tmp, []byte(strconv.FormatUint(previous+1, 10)+"\n"), 0644); err == nil {
+			err = os.Rename(tmp, h.conf.IncarnationFile)
+		}
+	}
+	if err != nil {
+		// without a persisted counter a later restart could reuse the incarnation, do not send any
+		h.logger.Errorf("Could not update incarnation file %s, disabling incarnation fencing: %s", h.conf.IncarnationFile, err)
+		return
+	}
+	h.incarnation = previous + 1
+	h.logger.Infof("Starting with incarnation %d", h.incarnation)
+}
+
+// fenceIncarnation records the incarnation of an active view member, if it increased the member's
+// state is dropped and true is returned, callers then handle the message as coming from a new peer.
+func (h *Hyparview) fenceIncarnation(sender peer.Peer, incarnation uint64) bool {
+	if incarnation == 0 {
+		return false
+	}
+	p, ok := h.activeView.get(sender)
+	if !ok {
+		return false
+	}
+	if p.incarnation == 0 || incarnation <= p.incarnation {
+		if incarnation > p.incarnation {
+			p.incarnation = incarnation
+		}
+		return false
+	}
+	h.logger.Warnf("Neighbour %s restarted (incarnation %d -> %d), resetting its state", sender.String(), p.incarnation, incarnation)
+	h.removeFromActiveView(sender, RemovalRestarted)
+	if p.outConnected {
+		h.babel.Disconnect(h.ID(), sender)
+		h.babel.SendNotification(NeighborDownNotification{
+			PeerDown:    sender,
+			View:        h.getView(),
+			ViewVersion: h.nextViewVersion(),
+		})
+	}
+	return true
+}
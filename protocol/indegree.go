@@ -0,0 +1,78 @@
+package protocol
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Nodes send maintenance messages to every member of their active view, so the distinct senders of
+// recent maintenance messages approximate how many nodes hold this one as a neighbour (its overlay
+// in-degree), including those it does not hold back. With MaxInDegree set, once that many nodes
+// maintain links to it, joins and neighbour requests from other nodes are redirected to a passive
+// view sample instead of being admitted, keeping popular nodes such as long-lived bootstraps from
+// becoming overloaded hubs. Joins are still forwarded.
+
+// inDegreeState holds the recent senders of maintenance messages.
+type inDegreeState struct {
+	maintainers      map[string]time.Time
+	inDegreeRejected int
+}
+
+const inDegreeWindow = 5 * time.Second
+
+type InDegreeStats struct {
+	InDegree int `json:"inDegree"`
+	Rejected int `json:"rejected"`
+}
+
+func (h *Hyparview) recordMaintainer(sender peer.Peer) {
+	h.maintainers[sender.String()] = h.timeNow()
+}
+
+func (h *Hyparview) inDegree() int {
+	for maintainer, lastSeen := range h.maintainers {
+		if h.elapsedSince(lastSeen) > inDegreeWindow {
+			delete(h.maintainers, maintainer)
+		}
+	}
+	return len(h.maintainers)
+}
+
+// inDegreeExceeded returns true, counting it, if a new link from sender would exceed MaxInDegree.
+func (h *Hyparview) inDegreeExceeded(sender peer.Peer) bool {
+	if h.conf.MaxInDegree <= 0 {
+		return false
+	}
+	if _, maintaining := h.maintainers[sender.String()]; maintaining || h.activeView.contains(sender) {
+		return false
+	}
+	if h.inDegree() < h.conf.MaxInDegree {
+		return false
+	}
+	h.inDegreeRejected++
+	h.logger.Infof("In-degree %d reached the cap, redirecting %s", h.inDegree(), sender.String())
+	return true
+}
+
+func (h *Hyparview) redirectNeighbourRequest(sender peer.Peer, neighborMsg NeighbourMessage) {
+	h.sendMessageTmpTransport(NeighbourMessageReply{
+		Accepted:    false,
+		Incarnation: h.incarnation,
+		TraceID:     neighborMsg.TraceID,
+	}, sender)
+	h.sendMessageTmpTransport(RedirectMessage{Peers: h.passiveView.getRandomElementsFromView(h.conf.Kp, sender)}, sender)
+}
+
+func (h *Hyparview) inDegreeStats() InDegreeStats {
+	return InDegreeStats{InDegree: h.inDegree(), Rejected: h.inDegreeRejected}
+}
+
+func (h *Hyparview) logInDegree() {
+	res, err := json.Marshal(h.inDegreeStats())
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("inDegree", "%s", string(res))
+}
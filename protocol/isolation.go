@@ -0,0 +1,169 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// A node whose active and passive views are both empty is isolated and can only recover by joining
+// through the bootstrap nodes again. IsolationPolicy decides how often it tries:
+//   - immediate (default) rejoins every time isolation is noticed, on a node going down or on the
+//     promote timer, as long as JoinTimeSeconds passed since the last join;
+//   - retry waits IsolationRetrySeconds between attempts;
+//   - backoff doubles the wait after every attempt, from IsolationRetrySeconds up to
+//     IsolationMaxBackoffSeconds, so that isolated nodes do not hammer bootstrap nodes which are down.
+// Under every policy, a node still isolated after IsolationAlertAttempts attempts logs an error and
+// emits an IsolatedNotification, once per isolation period.
+//
+// With PassiveRejoinFanout set, a node whose active view is empty is treated as isolated even if its
+// passive view is not: instead of promoting one passive view member per promote period, attempts
+// alternate between sending high priority neighbour requests to PassiveRejoinFanout passive view
+// members at once and joining through the bootstrap nodes, starting with the passive view unless
+// IsolationRecoveryOrder is bootstrapFirst. This spares the bootstrap nodes when a whole rack or zone
+// goes down and the passive view still knows live peers.
+
+// IsolationConfig decides how an isolated node recovers.
+type IsolationConfig struct {
+	IsolationPolicy            string `yaml:"isolationPolicy"`
+	IsolationRetrySeconds      int    `yaml:"isolationRetrySeconds"`
+	IsolationMaxBackoffSeconds int    `yaml:"isolationMaxBackoffSeconds"`
+	IsolationAlertAttempts     int    `yaml:"isolationAlertAttempts"`
+	PassiveRejoinFanout        int    `yaml:"passiveRejoinFanout"`
+	IsolationRecoveryOrder     string `yaml:"isolationRecoveryOrder"`
+}
+
+const (
+	IsolationImmediate = "immediate"
+	IsolationRetry     = "retry"
+	IsolationBackoff   = "backoff"
+
+	defaultIsolationRetry      = 10 * time.Second
+	defaultIsolationMaxBackoff = 5 * time.Minute
+
+	RecoverPassiveFirst   = "passiveFirst"
+	RecoverBootstrapFirst = "bootstrapFirst"
+)
+
+type isolationState struct {
+	since       time.Time
+	attempts    int
+	nextAttempt time.Time
+	alerted     bool
+}
+
+func (h *Hyparview) isIsolated() bool {
+	return h.activeView.size() == 0 && h.passiveView.size() == 0
+}
+
+func (h *Hyparview) needsRecovery() bool {
+	return h.isIsolated() || (h.activeView.size() == 0 && h.conf.PassiveRejoinFanout > 0)
+}
+
+func (h *Hyparview) isolationRetryDelay() time.Duration {
+	if h.conf.IsolationRetrySeconds > 0 {
+		return time.Duration(h.conf.IsolationRetrySeconds) * time.Second
+	}
+	return defaultIsolationRetry
+}
+
+func (h *Hyparview) isolationBackoff(attempts int) time.Duration {
+	maxBackoff := defaultIsolationMaxBackoff
+	if h.conf.IsolationMaxBackoffSeconds > 0 {
+		maxBackoff = time.Duration(h.conf.IsolationMaxBackoffSeconds) * time.Second
+	}
+	delay := h.isolationRetryDelay()
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// handleIsolation is the single place deciding whether an isolated node rejoins the overlay now.
+func (h *Hyparview) handleIsolation() {
+	if h.conf.StrictPaper || h.decommissioning() || !h.needsRecovery() {
+		return
+	}
+	if h.isolation == nil {
+		h.isolation = &isolationState{since: h.timeNow()}
+		h.setPhase(PhaseIsolated)
+		h.logger.Warnf("Node is isolated (%d passive view members), recovering with policy %s", h.passiveView.size(), h.isolationPolicy())
+	}
+	if h.timeNow().Before(h.isolation.nextAttempt) {
+		return
+	}
+	if !h.recoverFromIsolation() {
+		return
+	}
+	h.isolation.attempts++
+	switch h.isolationPolicy() {
+	case IsolationRetry:
+		h.isolation.nextAttempt = h.timeNow().Add(h.isolationRetryDelay())
+	case IsolationBackoff:
+		h.isolation.nextAttempt = h.timeNow().Add(h.isolationBackoff(h.isolation.attempts))
+	}
+	if h.conf.IsolationAlertAttempts > 0 && h.isolation.attempts >= h.conf.IsolationAlertAttempts && !h.isolation.alerted {
+		h.isolation.alerted = true
+		h.logger.Errorf("Node still isolated after %d rejoin attempts (%s)", h.isolation.attempts, h.elapsedSince(h.isolation.since))
+		h.babel.SendNotification(IsolatedNotification{
+			Since:       h.isolation.since,
+			Attempts:    h.isolation.attempts,
+			ViewVersion: h.CurrentViewVersion(),
+		})
+	}
+}
+
+func (h *Hyparview) recoverFromIsolation() bool {
+	if h.isIsolated() {
+		return h.rejoinOverlay()
+	}
+	passiveTurn := h.isolation.attempts%2 == 0
+	if h.conf.IsolationRecoveryOrder == RecoverBootstrapFirst {
+		passiveTurn = !passiveTurn
+	}
+	if passiveTurn && h.rejoinThroughPassiveView() {
+		return true
+	}
+	return h.rejoinOverlay()
+}
+
+// rejoinThroughPassiveView sends high priority neighbour requests to up to PassiveRejoinFanout passive
+// view members, it returns false if every candidate was vetoed.
+func (h *Hyparview) rejoinThroughPassiveView() bool {
+	tried := map[string]bool{}
+	for len(tried) < h.conf.PassiveRejoinFanout {
+		candidate := h.pickPromotionCandidateExcept(tried)
+		if candidate == nil {
+			break
+		}
+		tried[candidate.String()] = true
+		h.sendNeighbourMessage(candidate)
+	}
+	if len(tried) == 0 {
+		return false
+	}
+	h.logger.Warnf("Rejoining through %d passive view members", len(tried))
+	return true
+}
+
+func (h *Hyparview) isolationPolicy() string {
+	switch h.conf.IsolationPolicy {
+	case IsolationRetry, IsolationBackoff:
+		return h.conf.IsolationPolicy
+	default:
+		return IsolationImmediate
+	}
+}
+
+func (h *Hyparview) trackIsolationRecovery() {
+	h.onAfterAdd(ActiveView, func(_ ViewID, _ peer.Peer) {
+		if h.isolation == nil {
+			return
+		}
+		h.logger.Warnf("Recovered from isolation after %s and %d rejoin attempts", h.elapsedSince(h.isolation.since), h.isolation.attempts)
+		h.isolation = nil
+	})
+}
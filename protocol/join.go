@@ -0,0 +1,119 @@
+package protocol
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// JoinConfig decides when the node counts as joined.
+type JoinConfig struct {
+	JoinedMinActiveViewSize      int `yaml:"joinedMinActiveViewSize"`
+	JoinCompletionTimeoutSeconds int `yaml:"joinCompletionTimeoutSeconds"`
+}
+
+// joinState tracks the first join, see WaitForJoin and OnJoined.
+type joinState struct {
+	joined          chan struct{}
+	joinErr         error
+	onJoined        []func(err error)
+	joinedAt        time.Time
+	lastJoinAttempt time.Time
+}
+
+var ErrJoinTimeout = errors.New("timed out waiting for overlay join")
+
+// WaitForJoin blocks until the active view first holds JoinedMinActiveViewSize connected neighbours,
+// the join completion timeout expires, or ctx is done.
+func (h *Hyparview) WaitForJoin(ctx context.Context) error {
+	select {
+	case <-h.joined:
+		return h.joinErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// OnJoined registers a callback fired once, with a nil error when the node first joins the overlay
+// or with ErrJoinTimeout if the join completion timeout expires first. Callbacks registered once
+// that happened fire right away. They run on the protocol goroutine, so they must not block.
+func (h *Hyparview) OnJoined(callback func(err error)) {
+	h.onProtocol("OnJoined", func() { h.onJoin(callback) })
+}
+
+func (h *Hyparview) onJoin(callback func(err error)) {
+	if h.isJoinDone() {
+		callback(h.joinErr)
+		return
+	}
+	h.onJoined = append(h.onJoined, callback)
+}
+
+func (h *Hyparview) isJoinDone() bool {
+	select {
+	case <-h.joined:
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *Hyparview) checkJoined() {
+	if h.isJoinDone() {
+		return
+	}
+	minSize := h.conf.JoinedMinActiveViewSize
+	if minSize <= 0 {
+		minSize = 1
+	}
+	if len(h.getView()) < minSize {
+		return
+	}
+	h.logger.Infof("Joined overlay with %d connected neighbours", len(h.getView()))
+	h.completeJoin(nil)
+}
+
+func (h *Hyparview) completeJoin(err error) {
+	h.joinErr = err
+	if err == nil {
+		h.joinedAt = h.timeNow()
+	}
+	close(h.joined)
+	for _, callback := range h.onJoined {
+		callback(err)
+	}
+}
+
+func (h *Hyparview) HandleJoinCompletionTimer(t timer.Timer) {
+	if h.isJoinDone() {
+		return
+	}
+	h.logger.Errorf("Did not join overlay within %d seconds", h.conf.JoinCompletionTimeoutSeconds)
+	h.completeJoin(ErrJoinTimeout)
+}
+
+// JoinOverlay (re)joins the overlay through the bootstrap nodes, force ignores the JoinTimeSeconds guard.
+// The current neighbours are kept.
+func (h *Hyparview) JoinOverlay(force bool) {
+	h.onProtocol("JoinOverlay", func() { h.rejoin(force) })
+}
+
+func (h *Hyparview) rejoin(force bool) {
+	if h.decommissioning() {
+		h.logger.Warn("Not rejoining, the node is leaving the overlay")
+		return
+	}
+	if !force {
+		h.joinOverlay()
+		return
+	}
+	h.logger.Warn("Forcing overlay rejoin")
+	if h.lifecycle.phase == PhaseIdle || h.lifecycle.phase == PhaseJoining {
+		h.joinSent(PhaseJoining)
+	} else {
+		h.joinSent(PhaseRejoining)
+	}
+	h.sendJoinToBootstrap()
+}
@@ -0,0 +1,140 @@
+package protocol
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Bootstrap peers can be discovered from the pods behind a Kubernetes headless service, either by
+// resolving the service DNS name or by listing its endpoints through the API with the pod's service
+// account. Pods are registered by Kubernetes itself, so registration is a no-op.
+
+const (
+	KubernetesDNS = "dns"
+	KubernetesAPI = "api"
+
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+type kubernetesEndpoints struct {
+	Subsets []struct {
+		Addresses []struct {
+			IP string `json:"ip"`
+		} `json:"addresses"`
+	} `json:"subsets"`
+}
+
+type kubernetesProvider struct {
+	service       string
+	namespace     string
+	mode          string
+	port          uint16
+	analyticsPort uint16
+}
+
+func newKubernetesProvider(conf *HyparviewConfig, self peer.Peer) *kubernetesProvider {
+	port := uint16(conf.KubernetesBootstrap.Port)
+	if port == 0 {
+		port = self.ProtosPort()
+	}
+	return &kubernetesProvider{
+		service:       conf.KubernetesBootstrap.Service,
+		namespace:     conf.KubernetesBootstrap.Namespace,
+		mode:          conf.KubernetesBootstrap.Mode,
+		port:          port,
+		analyticsPort: self.AnalyticsPort(),
+	}
+}
+
+func (k *kubernetesProvider) Name() string {
+	return "kubernetes"
+}
+
+func (k *kubernetesProvider) Register(self peer.Peer) error {
+	return nil
+}
+
+func (k *kubernetesProvider) Heartbeat() error {
+	return nil
+}
+
+func (k *kubernetesProvider) Deregister() error {
+	return nil
+}
+
+func (k *kubernetesProvider) Peers() ([]peer.Peer, error) {
+	var ips []string
+	var err error
+	switch k.mode {
+	case KubernetesDNS, "":
+		ips, err = net.LookupHost(k.service)
+	case KubernetesAPI:
+		ips, err = k.listEndpoints()
+	default:
+		return nil, fmt.Errorf("unknown kubernetes discovery mode %s", k.mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make([]peer.Peer, 0, len(ips))
+	for _, ip := range ips {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			continue
+		}
+		peers = append(peers, peer.NewPeer(parsed, k.port, k.analyticsPort))
+	}
+	return peers, nil
+}
+
+func (k *kubernetesProvider) listEndpoints() ([]string, error) {
+	token, err := ioutil.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, err
+	}
+	caCert, err := ioutil.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	namespace := k.namespace
+	if namespace == "" {
+		ns, err := ioutil.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, err
+		}
+		namespace = strings.TrimSpace(string(ns))
+	}
+
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caCert)
+	client := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+	}
+	url := fmt.Sprintf("https://%s/api/v1/namespaces/%s/endpoints/%s",
+		net.JoinHostPort(os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")), namespace, k.service)
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+
+	endpoints := kubernetesEndpoints{}
+	if err = doJSONRequest(client, http.MethodGet, url, header, nil, &endpoints); err != nil {
+		return nil, err
+	}
+	ips := []string{}
+	for _, subset := range endpoints.Subsets {
+		for _, addr := range subset.Addresses {
+			ips = append(ips, addr.IP)
+		}
+	}
+	return ips, nil
+}
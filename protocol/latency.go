@@ -0,0 +1,174 @@
+package protocol
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// With LatencyCollector set, nodes measure the round trip time to every peer they learn about, by
+// timing a TCP connection to it, at most LatencyProbesPerSecond (2 by default) at a time and at most
+// once every latencyRemeasure per peer. Every LatencyReportSeconds (60 by default) they send their
+// latency vector to the collector node, which logs it as <latencyVector>, producing the latency
+// matrix needed to evaluate latency-aware variants like X-BOT on real deployments.
+
+// LatencyConfig enables the latency measurements reported to LatencyCollector.
+type LatencyConfig struct {
+	LatencyCollector *struct {
+		Port          int    `yaml:"port"`
+		Host          string `yaml:"host"`
+		AnalyticsPort int    `yaml:"analyticsPort"`
+	} `yaml:"latencyCollector"`
+	LatencyProbesPerSecond int `yaml:"latencyProbesPerSecond"`
+	LatencyReportSeconds   int `yaml:"latencyReportSeconds"`
+}
+
+const (
+	defaultLatencyProbesPerSecond = 2
+	defaultLatencyReportPeriod    = time.Minute
+	latencyRemeasure              = 10 * time.Minute
+	maxLatencyTargets             = 1024
+)
+
+type latencySample struct {
+	peer       peer.Peer
+	rtt        time.Duration
+	measuredAt time.Time
+}
+
+type latencyMatrix struct {
+	pending []peer.Peer
+	queued  map[string]bool
+	samples map[string]*latencySample
+}
+
+func (h *Hyparview) latencyCollectionEnabled() bool {
+	return h.conf.LatencyCollector != nil
+}
+
+func (h *Hyparview) startLatencyCollection() {
+	h.latency = &latencyMatrix{queued: map[string]bool{}, samples: map[string]*latencySample{}}
+	for _, view := range []ViewID{ActiveView, PassiveView} {
+		h.onAfterAdd(view, func(view ViewID, p peer.Peer) {
+			h.queueLatencyProbe(p)
+		})
+	}
+	rate := h.conf.LatencyProbesPerSecond
+	if rate <= 0 {
+		rate = defaultLatencyProbesPerSecond
+	}
+	h.schedulePeriodicTimer(LatencyProbeTimer{time.Second / time.Duration(rate)}, false)
+	reportPeriod := defaultLatencyReportPeriod
+	if h.conf.LatencyReportSeconds > 0 {
+		reportPeriod = time.Duration(h.conf.LatencyReportSeconds) * time.Second
+	}
+	h.schedulePeriodicTimer(LatencyReportTimer{reportPeriod}, false)
+}
+
+func (h *Hyparview) queueLatencyProbe(p peer.Peer) {
+	matrix := h.latency
+	if matrix.queued[p.String()] || len(matrix.pending) >= maxLatencyTargets || !h.isDialable(p) {
+		return
+	}
+	if sample, ok := matrix.samples[p.String()]; ok && h.elapsedSince(sample.measuredAt) < latencyRemeasure {
+		return
+	}
+	matrix.queued[p.String()] = true
+	matrix.pending = append(matrix.pending, p)
+}
+
+func (h *Hyparview) HandleLatencyProbeTimer(t timer.Timer) {
+	if !h.shouldRunPeriodic(t) {
+		return
+	}
+	matrix := h.latency
+	if len(matrix.pending) == 0 {
+		// peers learned long ago are measured again once their sample is stale
+		for _, v := range []*View{h.activeView, h.passiveView} {
+			for _, p := range v.asArr {
+				h.queueLatencyProbe(p.Peer)
+			}
+		}
+		if len(matrix.pending) == 0 {
+			return
+		}
+	}
+	target := matrix.pending[0]
+	matrix.pending = matrix.pending[1:]
+	addr := target.ToTCPAddr().String()
+	timeout := h.dialBackTimeout()
+	go func() {
+		start := time.Now()
+		err := probeTCP(addr, timeout)
+		rtt := time.Since(start)
+		h.onProtocol("latencyProbe", func() { h.latencyMeasured(target, rtt, err) })
+	}()
+}
+
+func (h *Hyparview) latencyMeasured(target peer.Peer, rtt time.Duration, err error) {
+	matrix := h.latency
+	delete(matrix.queued, target.String())
+	if err != nil {
+		h.logger.Infof("Could not measure latency to %s: %s", target.String(), err)
+		delete(matrix.samples, target.String())
+		return
+	}
+	if _, ok := matrix.samples[target.String()]; !ok && len(matrix.samples) >= maxLatencyTargets {
+		var oldest *latencySample
+		for _, sample := range matrix.samples {
+			if oldest == nil || sample.measuredAt.Before(oldest.measuredAt) {
+				oldest = sample
+			}
+		}
+		delete(matrix.samples, oldest.peer.String())
+	}
+	matrix.samples[target.String()] = &latencySample{peer: target, rtt: rtt, measuredAt: h.timeNow()}
+}
+
+func (h *Hyparview) HandleLatencyReportTimer(t timer.Timer) {
+	if !h.shouldRunPeriodic(t) {
+		return
+	}
+	report := LatencyVectorMessage{}
+	for _, sample := range h.latency.samples {
+		report.Peers = append(report.Peers, sample.peer)
+		report.RTTs = append(report.RTTs, uint32(sample.rtt/time.Microsecond))
+	}
+	if len(report.Peers) == 0 {
+		return
+	}
+	c := h.conf.LatencyCollector
+	collector := configuredPeer(h.conf, c.Host, c.Port, c.AnalyticsPort)
+	if peer.PeersEqual(collector, h.babel.SelfPeer()) {
+		h.logLatencyVector(h.babel.SelfPeer(), report)
+		return
+	}
+	h.sendMessageTmpTransport(report, collector)
+}
+
+func (h *Hyparview) HandleLatencyVectorMessage(sender peer.Peer, msg message.Message) {
+	h.logLatencyVector(sender, msg.(LatencyVectorMessage))
+}
+
+func (h *Hyparview) logLatencyVector(from peer.Peer, report LatencyVectorMessage) {
+	toPrint := struct {
+		From string            `json:"from"`
+		RTTs map[string]uint32 `json:"rttMicros"`
+	}{
+		From: from.String(),
+		RTTs: make(map[string]uint32, len(report.Peers)),
+	}
+	for i, p := range report.Peers {
+		if i < len(report.RTTs) {
+			toPrint.RTTs[p.String()] = report.RTTs[i]
+		}
+	}
+	res, err := json.Marshal(toPrint)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("latencyVector", "%s", string(res))
+}
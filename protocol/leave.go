@@ -0,0 +1,102 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Leave gracefully leaves the overlay, sending a disconnect message to every active view neighbour,
+// emptying the active view and stopping every timer: periodic ones are cancelled and self re-arming
+// one-shot ones (shuffles, queued actions, join retries) stop re-arming. Messages received afterwards
+// are dropped. With LeaveHandoff set, every neighbour is handed a different
+// passive view member as replacement in the disconnect's PX list, which receivers with PeerExchange
+// or LeaveHandoff set try first when replacing us, so the overlay repairs itself right away. The
+// returned channel is closed once all disconnects have been handed to the stream manager, after the
+// side stream workers sent what was queued.
+func (h *Hyparview) Leave() <-chan struct{} {
+	h.onProtocol("Leave", h.leave)
+	return h.leaveFlushed
+}
+
+func (h *Hyparview) hasLeft() bool {
+	select {
+	case <-h.left:
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *Hyparview) leave() {
+	if h.hasLeft() {
+		return
+	}
+	h.logger.Warnf("Leaving overlay, disconnecting from %d neighbours", h.activeView.size())
+	replacements := h.leaveReplacements()
+	for _, p := range append([]*PeerState{}, h.activeView.asArr...) {
+		toSend := DisconnectMessage{Peers: h.passiveView.getRandomElementsFromView(h.conf.Kp, p.Peer)}
+		if replacement := replacements[p.String()]; replacement != nil {
+			toSend.PX = []peer.Peer{replacement}
+		}
+		h.deliverDisconnect(p, toSend)
+		h.removeFromActiveView(p.Peer, RemovalLeft)
+		delete(h.outboundOnlyPeers, p.String())
+		if p.outConnected {
+			h.babel.SendNotification(NeighborDownNotification{
+				PeerDown:    p.Peer,
+				View:        h.getView(),
+				ViewVersion: h.nextViewVersion(),
+			})
+		}
+	}
+	h.viewsChanged()
+	h.deregisterDiscovery()
+	close(h.left)
+	h.stopSideStreamWorkers()
+	h.stopPeriodicTimers()
+	go func() {
+		h.sideStreamWorkers.Wait()
+		close(h.leaveFlushed)
+	}()
+}
+
+// leaveReplacements assigns distinct passive view members to the neighbours, as far as there are enough.
+func (h *Hyparview) leaveReplacements() map[string]peer.Peer {
+	replacements := map[string]peer.Peer{}
+	if !h.conf.LeaveHandoff {
+		return replacements
+	}
+	candidates := []peer.Peer{}
+	for _, p := range h.passiveView.getRandomStatesFromView(h.passiveView.size()) {
+		if !h.excludedFromShuffles(p.Peer) && !h.suspect(p.Peer) {
+			candidates = append(candidates, p.Peer)
+		}
+	}
+	for i, neighbour := range h.activeView.asArr {
+		if len(candidates) == 0 {
+			break
+		}
+		replacements[neighbour.String()] = candidates[i%len(candidates)]
+	}
+	return replacements
+}
+
+// DumpState returns a human readable snapshot of the views, meant for crash reports. It does not
+// synchronize with the protocol goroutine, so it must only be called from it (e.g. when recovering
+// handler panics) or once it is stopped. Other goroutines use Snapshot.
+func (h *Hyparview) DumpState() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "self: %s, epoch: %d, joined: %t, left: %t\n", h.babel.SelfPeer().String(), h.epoch, h.isJoinDone(), h.hasLeft())
+	sb.WriteString("active view:")
+	for _, p := range h.activeView.asArr {
+		fmt.Fprintf(sb, " %s(outConnected=%t)", p.String(), p.outConnected)
+	}
+	sb.WriteString("\npassive view:")
+	for _, p := range h.passiveView.asArr {
+		fmt.Fprintf(sb, " %s", p.String())
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
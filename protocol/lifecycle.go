@@ -0,0 +1,107 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// The node lifecycle is an explicit state machine:
+//
+//	idle -> joining -> stabilizing -> joined -> isolated -> rejoining -> stabilizing -> ...
+//
+// A node is joining from the moment it sends its first join until a neighbour connects, and
+// rejoining after a join sent to recover from isolation. Every join opens a window of
+// JoinTimeSeconds during which the node is stabilizing: it neither promotes passive view members
+// nor joins again, giving the join random walks time to fill its active view. A node connected when
+// the window closes is joined, and becomes isolated once it loses every neighbour and needs to
+// recover. Bootstrap nodes starting as such are stabilizing right away. Every transition is logged as
+// <phase>, counted in Snapshot, and emitted as a PhaseChangedNotification.
+
+type Phase string
+
+const (
+	PhaseIdle        Phase = "idle"
+	PhaseJoining     Phase = "joining"
+	PhaseStabilizing Phase = "stabilizing"
+	PhaseJoined      Phase = "joined"
+	PhaseIsolated    Phase = "isolated"
+	PhaseRejoining   Phase = "rejoining"
+)
+
+type lifecycle struct {
+	phase       Phase
+	since       time.Time
+	windowOpen  bool
+	transitions map[string]int
+}
+
+type PhaseStats struct {
+	Phase       Phase          `json:"phase"`
+	Since       time.Time      `json:"since"`
+	Transitions map[string]int `json:"transitions"`
+}
+
+func newLifecycle(now time.Time) *lifecycle {
+	return &lifecycle{phase: PhaseIdle, since: now, transitions: map[string]int{}}
+}
+
+func (h *Hyparview) setPhase(to Phase) {
+	from := h.lifecycle.phase
+	if from == to {
+		return
+	}
+	h.lifecycle.phase = to
+	h.lifecycle.since = h.timeNow()
+	h.lifecycle.transitions[string(from)+"->"+string(to)]++
+	h.logger.Infof("Lifecycle phase %s -> %s", from, to)
+	h.analytics("phase", "%s %s", from, to)
+	h.babel.SendNotification(PhaseChangedNotification{
+		From:        from,
+		To:          to,
+		ViewVersion: h.CurrentViewVersion(),
+	})
+}
+
+// joinSent is called whenever the node joins through the bootstraps, opening the join window.
+func (h *Hyparview) joinSent(phase Phase) {
+	h.setPhase(phase)
+	h.openJoinWindow()
+}
+
+func (h *Hyparview) openJoinWindow() {
+	h.lifecycle.windowOpen = true
+	h.babel.RegisterTimer(h.ID(), JoinWindowTimer{duration: time.Duration(h.conf.JoinTimeSeconds) * time.Second})
+}
+
+// stabilizing returns whether the last join is too recent to promote passive view members or join again.
+func (h *Hyparview) stabilizing() bool {
+	return h.lifecycle.windowOpen
+}
+
+func (h *Hyparview) HandleJoinWindowTimer(t timer.Timer) {
+	h.lifecycle.windowOpen = false
+	if h.lifecycle.phase == PhaseStabilizing {
+		h.setPhase(PhaseJoined)
+	}
+}
+
+// neighbourConnected is called when a connection to an active view member is established.
+func (h *Hyparview) neighbourConnected() {
+	switch h.lifecycle.phase {
+	case PhaseJoining, PhaseRejoining, PhaseIsolated:
+		if h.stabilizing() {
+			h.setPhase(PhaseStabilizing)
+		} else {
+			h.setPhase(PhaseJoined)
+		}
+	}
+}
+
+func (h *Hyparview) phaseStats() PhaseStats {
+	transitions := make(map[string]int, len(h.lifecycle.transitions))
+	for transition, count := range h.lifecycle.transitions {
+		transitions[transition] = count
+	}
+	return PhaseStats{Phase: h.lifecycle.phase, Since: h.lifecycle.since, Transitions: transitions}
+}
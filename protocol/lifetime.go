@@ -0,0 +1,143 @@
+package protocol
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// How long peers stay in the active view, and why they leave it, characterizes the churn of a
+// deployment and shows whether a protocol change actually made neighbours more stable. Every removal
+// from the active view is counted in a histogram of lifetimes, overall and per removal reason, logged
+// as <peerLifetimes> and included in the snapshot.
+
+// lifetimeState holds the lifetime statistics, removalReason is the reason of the removal in progress.
+type lifetimeState struct {
+	peerLifetimes *PeerLifetimeStats
+	removalReason string
+}
+
+const (
+	RemovalUnspecified    = "unspecified"
+	RemovalConnectionDown = "connectionDown"
+	RemovalDialFailed     = "dialFailed"
+	RemovalDisconnected   = "disconnected"
+	RemovalDroppedRandom  = "droppedRandom"
+	RemovalBlacklisted    = "blacklisted"
+	RemovalRestarted      = "restarted"
+	RemovalDemoted        = "demoted"
+	RemovalHandlerPanic   = "handlerPanic"
+
+	RemovalVersionComposition = "versionComposition"
+	RemovalInjected           = "injected"
+	RemovalSilent             = "silent"
+	RemovalDecommissioned     = "decommissioned"
+	RemovalLeft               = "left"
+)
+
+var lifetimeBucketBounds = []time.Duration{
+	10 * time.Second,
+	time.Minute,
+	10 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+}
+
+// LifetimeHistogram counts lifetimes up to each of the bucket bounds, the last bucket counts the
+// lifetimes longer than every bound.
+type LifetimeHistogram struct {
+	Removals int           `json:"removals"`
+	Mean     time.Duration `json:"mean"`
+	Buckets  []int         `json:"buckets"`
+	total    time.Duration
+}
+
+type PeerLifetimeStats struct {
+	BucketBounds []string                      `json:"bucketBounds"`
+	All          *LifetimeHistogram            `json:"all"`
+	ByReason     map[string]*LifetimeHistogram `json:"byReason"`
+}
+
+func newLifetimeHistogram() *LifetimeHistogram {
+	return &LifetimeHistogram{Buckets: make([]int, len(lifetimeBucketBounds)+1)}
+}
+
+func newPeerLifetimeStats() *PeerLifetimeStats {
+	bounds := make([]string, 0, len(lifetimeBucketBounds))
+	for _, bound := range lifetimeBucketBounds {
+		bounds = append(bounds, bound.String())
+	}
+	return &PeerLifetimeStats{
+		BucketBounds: bounds,
+		All:          newLifetimeHistogram(),
+		ByReason:     map[string]*LifetimeHistogram{},
+	}
+}
+
+func (hist *LifetimeHistogram) record(lifetime time.Duration) {
+	bucket := len(lifetimeBucketBounds)
+	for i, bound := range lifetimeBucketBounds {
+		if lifetime <= bound {
+			bucket = i
+			break
+		}
+	}
+	hist.Buckets[bucket]++
+	hist.Removals++
+	hist.total += lifetime
+	hist.Mean = hist.total / time.Duration(hist.Removals)
+}
+
+func (hist *LifetimeHistogram) clone() *LifetimeHistogram {
+	cloned := *hist
+	cloned.Buckets = append([]int{}, hist.Buckets...)
+	return &cloned
+}
+
+// removeFromActiveView removes p from the active view, recording reason as the cause of the removal.
+func (h *Hyparview) removeFromActiveView(p peer.Peer, reason string) *PeerState {
+	h.removalReason = reason
+	defer func() { h.removalReason = "" }()
+	return h.activeView.remove(p)
+}
+
+func (h *Hyparview) recordPeerLifetimes() {
+	h.onBeforeRemove(ActiveView, func(_ ViewID, p peer.Peer) {
+		reason := h.removalReason
+		if reason == "" {
+			reason = RemovalUnspecified
+		}
+		state, ok := h.activeView.get(p)
+		if !ok || state.addedAt.IsZero() {
+			return
+		}
+		lifetime := h.timeSince(state.addedAt)
+		h.peerLifetimes.All.record(lifetime)
+		if _, ok := h.peerLifetimes.ByReason[reason]; !ok {
+			h.peerLifetimes.ByReason[reason] = newLifetimeHistogram()
+		}
+		h.peerLifetimes.ByReason[reason].record(lifetime)
+	})
+}
+
+func (h *Hyparview) peerLifetimesSnapshot() PeerLifetimeStats {
+	stats := PeerLifetimeStats{
+		BucketBounds: h.peerLifetimes.BucketBounds,
+		All:          h.peerLifetimes.All.clone(),
+		ByReason:     make(map[string]*LifetimeHistogram, len(h.peerLifetimes.ByReason)),
+	}
+	for reason, hist := range h.peerLifetimes.ByReason {
+		stats.ByReason[reason] = hist.clone()
+	}
+	return stats
+}
+
+func (h *Hyparview) logPeerLifetimes() {
+	toPrint, err := json.Marshal(h.peerLifetimes)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("peerLifetimes", "%s", string(toPrint))
+}
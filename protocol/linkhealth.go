@@ -0,0 +1,181 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Active view links are scored from the MessageDelivered/MessageDeliveryErr callbacks: the delivery
+// latency of a message is the time since the oldest pending message of the same type sent to that
+// neighbour. Once enough samples are gathered a link is evaluated, and a link found slow or lossy in
+// SlowPeerStrikes consecutive evaluations is demoted to the passive view and replaced.
+
+// LinkHealthConfig sets when a link counts as slow or lossy.
+type LinkHealthConfig struct {
+	SlowPeerLatencyMillis  int `yaml:"slowPeerLatencyMillis"`
+	SlowPeerFailurePercent int `yaml:"slowPeerFailurePercent"`
+	SlowPeerMinSamples     int `yaml:"slowPeerMinSamples"`
+	SlowPeerStrikes        int `yaml:"slowPeerStrikes"`
+}
+
+const (
+	defaultSlowPeerMinSamples = 10
+	defaultSlowPeerStrikes    = 3
+	linkLatencyAlpha          = 0.2
+	maxPendingPerType         = 64
+)
+
+type linkStats struct {
+	pending   map[message.ID][]time.Time
+	latency   time.Duration
+	delivered int
+	failed    int
+	strikes   int
+}
+
+func (p *PeerState) linkStats() *linkStats {
+	if p.link == nil {
+		p.link = &linkStats{pending: map[message.ID][]time.Time{}}
+	}
+	return p.link
+}
+
+func (h *Hyparview) slowPeerDetectionEnabled() bool {
+	return h.conf.SlowPeerLatencyMillis > 0 || h.conf.SlowPeerFailurePercent > 0
+}
+
+// linkTrackingEnabled returns whether send outcomes are matched to sends, for slow peer detection or
+// for the message latency metrics.
+func (h *Hyparview) linkTrackingEnabled() bool {
+	return h.slowPeerDetectionEnabled() || h.conf.MetricsPort > 0
+}
+
+func (h *Hyparview) recordSend(msg message.Message, target peer.Peer) {
+	if !h.linkTrackingEnabled() {
+		return
+	}
+	p, ok := h.activeView.get(target)
+	if !ok {
+		return
+	}
+	link := p.linkStats()
+	pending := append(link.pending[msg.Type()], h.timeNow())
+	if len(pending) > maxPendingPerType {
+		// outcomes of the oldest sends were never reported
+		pending = pending[1:]
+	}
+	link.pending[msg.Type()] = pending
+}
+
+// recordDelivery returns the link stats of target if the outcome was matched to a pending message
+func (h *Hyparview) recordDelivery(msg message.Message, target peer.Peer) (*linkStats, time.Duration, bool) {
+	if !h.linkTrackingEnabled() {
+		return nil, 0, false
+	}
+	p, ok := h.activeView.get(target)
+	if !ok || p.link == nil || len(p.link.pending[msg.Type()]) == 0 {
+		return nil, 0, false
+	}
+	sentAt := p.link.pending[msg.Type()][0]
+	p.link.pending[msg.Type()] = p.link.pending[msg.Type()][1:]
+	return p.link, h.elapsedSince(sentAt), true
+}
+
+func (h *Hyparview) recordDelivered(msg message.Message, target peer.Peer) {
+	link, latency, ok := h.recordDelivery(msg, target)
+	if !ok {
+		return
+	}
+	h.recordMessageLatency(msg, latency)
+	link.delivered++
+	if link.latency == 0 {
+		link.latency = latency
+		return
+	}
+	link.latency = time.Duration(linkLatencyAlpha*float64(latency) + (1-linkLatencyAlpha)*float64(link.latency))
+}
+
+func (h *Hyparview) recordDeliveryErr(msg message.Message, target peer.Peer) {
+	if link, _, ok := h.recordDelivery(msg, target); ok {
+		link.failed++
+	}
+}
+
+func (h *Hyparview) isLinkHealthy(link *linkStats) bool {
+	if h.conf.SlowPeerLatencyMillis > 0 && link.latency > time.Duration(h.conf.SlowPeerLatencyMillis)*time.Millisecond {
+		return false
+	}
+	total := link.delivered + link.failed
+	if h.conf.SlowPeerFailurePercent > 0 && link.failed*100 > h.conf.SlowPeerFailurePercent*total {
+		return false
+	}
+	return true
+}
+
+func (h *Hyparview) demoteSlowPeers() {
+	if !h.slowPeerDetectionEnabled() || h.frozen() {
+		return
+	}
+	minSamples := h.conf.SlowPeerMinSamples
+	if minSamples <= 0 {
+		minSamples = defaultSlowPeerMinSamples
+	}
+	maxStrikes := h.conf.SlowPeerStrikes
+	if maxStrikes <= 0 {
+		maxStrikes = defaultSlowPeerStrikes
+	}
+
+	toDemote := []*PeerState{}
+	for _, p := range h.activeView.asArr {
+		if p.link == nil || p.link.delivered+p.link.failed < minSamples {
+			continue
+		}
+		if h.isLinkHealthy(p.link) {
+			p.link.strikes = 0
+		} else {
+			p.link.strikes++
+			h.logger.Warnf("Link to %s unhealthy (latency=%s, delivered=%d, failed=%d), strike %d",
+				p.String(), p.link.latency, p.link.delivered, p.link.failed, p.link.strikes)
+		}
+		p.link.delivered = 0
+		p.link.failed = 0
+		if p.link.strikes >= maxStrikes {
+			toDemote = append(toDemote, p)
+		}
+	}
+	for _, p := range toDemote {
+		h.demoteFromActiveView(p)
+	}
+}
+
+// demoteFromActiveView moves p to the passive view and asks a passive view member to replace it,
+// p is kept if there is no replacement.
+func (h *Hyparview) demoteFromActiveView(p *PeerState) {
+	if h.passiveView.size() == 0 {
+		return
+	}
+	replacement := h.pickPromotionCandidate()
+	if replacement == nil {
+		return
+	}
+	h.logger.Warnf("Demoting slow neighbour %s, replacing it with %s", p.String(), replacement.String())
+	h.replaceNeighbour(p, replacement, RemovalDemoted)
+}
+
+// replaceNeighbour moves p to the passive view and sends a neighbour request to replacement.
+func (h *Hyparview) replaceNeighbour(p *PeerState, replacement peer.Peer, reason string) {
+	h.removeFromActiveView(p.Peer, reason)
+	delete(h.outboundOnlyPeers, p.String())
+	h.sendDisconnect(p)
+	if p.outConnected {
+		h.babel.SendNotification(NeighborDownNotification{
+			PeerDown:    p.Peer,
+			View:        h.getView(),
+			ViewVersion: h.nextViewVersion(),
+		})
+	}
+	h.addPeerToPassiveView(p.Peer)
+	h.sendNeighbourMessage(replacement)
+}
@@ -0,0 +1,117 @@
+package protocol
+
+import (
+	"encoding/json"
+	"math/rand"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// A connection can die silently (e.g. a NAT or firewall dropping its state) long before TCP keepalive
+// notices. With SilentNeighbourSeconds set, an active view member which sent nothing for that long, not
+// even maintenance messages, is sent a liveness probe, and is handled as down if nothing at all arrives
+// from it within LivenessProbeTimeoutMillis. The probe is answered over the neighbour's own connection
+// to us when it has one, so a dead link in either direction is caught.
+
+// LivenessConfig enables probing silent neighbours.
+type LivenessConfig struct {
+	SilentNeighbourSeconds     int `yaml:"silentNeighbourSeconds"`
+	LivenessProbeTimeoutMillis int `yaml:"livenessProbeTimeoutMillis"`
+}
+
+const defaultLivenessProbeTimeout = 3 * time.Second
+
+type livenessProbe struct {
+	nonce  uint32
+	sentAt time.Time
+}
+
+type LivenessStats struct {
+	Sent     int `json:"sent"`
+	Answered int `json:"answered"`
+	Down     int `json:"down"`
+}
+
+// receivedFrom is called by the message handler wrapper for every message received.
+func (h *Hyparview) receivedFrom(sender peer.Peer) {
+	if p, ok := h.activeView.get(sender); ok {
+		p.lastInbound = h.timeNow()
+	}
+}
+
+func (h *Hyparview) livenessProbeTimeout() time.Duration {
+	if h.conf.LivenessProbeTimeoutMillis > 0 {
+		return time.Duration(h.conf.LivenessProbeTimeoutMillis) * time.Millisecond
+	}
+	return defaultLivenessProbeTimeout
+}
+
+// checkSilentNeighbours runs on the maintenance timer.
+func (h *Hyparview) checkSilentNeighbours() {
+	if h.conf.SilentNeighbourSeconds <= 0 {
+		return
+	}
+	threshold := time.Duration(h.conf.SilentNeighbourSeconds) * time.Second
+	var down []peer.Peer
+	for _, p := range h.activeView.asArr {
+		if !p.outConnected {
+			continue
+		}
+		lastInbound := p.lastInbound
+		if lastInbound.IsZero() {
+			lastInbound = p.addedAt
+		}
+		if p.liveness != nil {
+			if lastInbound.After(p.liveness.sentAt) {
+				p.liveness = nil
+				h.livenessStats.Answered++
+			} else if h.elapsedSince(p.liveness.sentAt) > h.livenessProbeTimeout() {
+				down = append(down, p.Peer)
+			}
+			continue
+		}
+		if h.elapsedSince(lastInbound) > threshold {
+			p.liveness = &livenessProbe{nonce: rand.Uint32(), sentAt: h.timeNow()}
+			h.livenessStats.Sent++
+			h.logger.Warnf("Nothing received from %s for %s, probing it", p.String(), h.elapsedSince(lastInbound))
+			h.sendMessage(LivenessProbeMessage{Nonce: p.liveness.nonce}, p.Peer)
+		}
+	}
+	for _, p := range down {
+		h.livenessStats.Down++
+		h.logger.Errorf("Liveness probe to %s unanswered, handling it as down", p.String())
+		h.handleNodeDown(p, RemovalSilent)
+	}
+}
+
+func (h *Hyparview) HandleLivenessProbeMessage(sender peer.Peer, msg message.Message) {
+	reply := LivenessProbeReplyMessage{Nonce: msg.(LivenessProbeMessage).Nonce}
+	if p, ok := h.activeView.get(sender); ok && p.outConnected {
+		h.sendMessage(reply, sender)
+		return
+	}
+	h.sendMessageTmpTransport(reply, sender)
+}
+
+func (h *Hyparview) HandleLivenessProbeReplyMessage(sender peer.Peer, msg message.Message) {
+	p, ok := h.activeView.get(sender)
+	if !ok || p.liveness == nil || p.liveness.nonce != msg.(LivenessProbeReplyMessage).Nonce {
+		return
+	}
+	p.maintenanceStats().probeRTT = h.elapsedSince(p.liveness.sentAt)
+	p.liveness = nil
+	h.livenessStats.Answered++
+}
+
+func (h *Hyparview) logLivenessProbes() {
+	if h.conf.SilentNeighbourSeconds <= 0 {
+		return
+	}
+	res, err := json.Marshal(h.livenessStats)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("livenessProbes", "%s", string(res))
+}
@@ -0,0 +1,102 @@
+package protocol
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Every maintenance period each node sends a maintenance message to its neighbours, which otherwise
+// only act on it when they are not connected back. To make the subsystem observable, the receiver
+// keeps, per neighbour, when its last maintenance message arrived and how many maintenance periods
+// in a row went by without one. Together with the round trip time (from time sync hints, or else from
+// the last answered liveness probe) and the dangling neighbour counters, they are logged as
+// <maintenance> and returned by Snapshot.
+
+type maintenanceStats struct {
+	lastSeen time.Time
+	missed   int
+	probeRTT time.Duration
+}
+
+// MaintenanceStats describes the maintenance messages received from a neighbour.
+type MaintenanceStats struct {
+	LastSeen time.Time     `json:"lastSeen"`
+	Missed   int           `json:"missed"`
+	RTT      time.Duration `json:"rtt,omitempty"`
+}
+
+type MaintenanceDump struct {
+	Neighbours map[string]MaintenanceStats `json:"neighbours"`
+	Dangling   map[string]int              `json:"dangling"`
+}
+
+func (p *PeerState) maintenanceStats() *maintenanceStats {
+	if p.maintenance == nil {
+		p.maintenance = &maintenanceStats{}
+	}
+	return p.maintenance
+}
+
+func (h *Hyparview) maintenanceReceived(p *PeerState) {
+	stats := p.maintenanceStats()
+	stats.lastSeen = h.timeNow()
+	stats.missed = 0
+}
+
+// countMissedMaintenance runs on the maintenance timer, neighbours added since the previous period
+// are not expected to have sent anything yet.
+func (h *Hyparview) countMissedMaintenance() {
+	previous := h.lastMaintenanceTick
+	h.lastMaintenanceTick = h.timeNow()
+	if previous.IsZero() {
+		return
+	}
+	for _, p := range h.activeView.asArr {
+		if p.addedAt.After(previous) {
+			continue
+		}
+		stats := p.maintenanceStats()
+		if stats.lastSeen.Before(previous) {
+			stats.missed++
+		}
+	}
+}
+
+func (p *PeerState) maintenanceSnapshot() *MaintenanceStats {
+	if p.maintenance == nil {
+		return nil
+	}
+	stats := &MaintenanceStats{
+		LastSeen: p.maintenance.lastSeen,
+		Missed:   p.maintenance.missed,
+		RTT:      p.maintenance.probeRTT,
+	}
+	if p.clock != nil && p.clock.samples > 0 {
+		stats.RTT = p.clock.rtt
+	}
+	return stats
+}
+
+func (h *Hyparview) maintenanceDump() MaintenanceDump {
+	dump := MaintenanceDump{
+		Neighbours: map[string]MaintenanceStats{},
+		Dangling:   map[string]int{},
+	}
+	for _, p := range h.activeView.asArr {
+		if stats := p.maintenanceSnapshot(); stats != nil {
+			dump.Neighbours[p.String()] = *stats
+		}
+	}
+	for neigh, count := range h.danglingNeighCounters {
+		dump.Dangling[neigh] = count
+	}
+	return dump
+}
+
+func (h *Hyparview) logMaintenance() {
+	res, err := json.Marshal(h.maintenanceDump())
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("maintenance", "%s", string(res))
+}
@@ -1,7 +1,10 @@
 package protocol
 
 import (
+	"bytes"
 	"encoding/binary"
+	"math"
+	"sort"
 
 	"github.com/nm-morais/go-babel/pkg/message"
 	"github.com/nm-morais/go-babel/pkg/peer"
@@ -9,20 +12,54 @@ import (
 
 const JoinMessageType = 1500
 
-type JoinMessage struct{}
+type JoinMessage struct {
+	OutboundOnly bool
+	Incarnation  uint64
+	TraceID      uint32
+	OverlayID    uint32
+	ClusterToken string
+	Capabilities uint8
+}
 type joinMessageSerializer struct{}
 
 var defaultJoinMessageSerializer = joinMessageSerializer{}
 
-func (JoinMessage) Type() message.ID                                      { return JoinMessageType }
-func (JoinMessage) Serializer() message.Serializer                        { return defaultJoinMessageSerializer }
-func (JoinMessage) Deserializer() message.Deserializer                    { return defaultJoinMessageSerializer }
-func (joinMessageSerializer) Serialize(msg message.Message) []byte        { return []byte{} }
-func (joinMessageSerializer) Deserialize(msgBytes []byte) message.Message { return JoinMessage{} }
+func (JoinMessage) Type() message.ID                   { return JoinMessageType }
+func (JoinMessage) Serializer() message.Serializer     { return defaultJoinMessageSerializer }
+func (JoinMessage) Deserializer() message.Deserializer { return defaultJoinMessageSerializer }
+func (joinMessageSerializer) Serialize(msg message.Message) []byte {
+	converted := msg.(JoinMessage)
+	if converted.Incarnation != 0 || converted.TraceID != 0 || converted.OverlayID != 0 || converted.ClusterToken != "" || converted.Capabilities != 0 {
+		msgBytes := []byte{0}
+		if converted.OutboundOnly {
+			msgBytes[0] = 1
+		}
+		return appendHandshakeTrailer(msgBytes, converted.Incarnation, converted.TraceID, converted.OverlayID, converted.ClusterToken, converted.Capabilities)
+	}
+	if converted.OutboundOnly {
+		return []byte{1}
+	}
+	return []byte{}
+}
+
+func (joinMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	return JoinMessage{
+		OutboundOnly: len(msgBytes) > 0 && msgBytes[0] == 1,
+		Incarnation:  readIncarnation(msgBytes, 1),
+		TraceID:      readTraceID(msgBytes, 1),
+		OverlayID:    readOverlayID(msgBytes, 1),
+		ClusterToken: readClusterToken(msgBytes, 1),
+		Capabilities: readHandshakeCapabilities(msgBytes, 1),
+	}
+}
 
 const DisconnectMessageType = 1501
 
-type DisconnectMessage struct{}
+type DisconnectMessage struct {
+	Peers []peer.Peer
+	PX    []peer.Peer // peer exchange list, optional
+	Nonce uint32      // capacity eviction nonce, optional
+}
 type disconnectMessageSerializer struct{}
 
 var defaultDisconnectMessageSerializer = disconnectMessageSerializer{}
@@ -32,16 +69,55 @@ func (DisconnectMessage) Serializer() message.Serializer { return defaultDisconn
 func (DisconnectMessage) Deserializer() message.Deserializer {
 	return defaultDisconnectMessageSerializer
 }
-func (disconnectMessageSerializer) Serialize(msg message.Message) []byte { return []byte{} }
+func (disconnectMessageSerializer) Serialize(msg message.Message) []byte {
+	converted := msg.(DisconnectMessage)
+	if len(converted.Peers) == 0 && len(converted.PX) == 0 && converted.Nonce == 0 {
+		return []byte{}
+	}
+	msgBytes := peer.SerializePeerArray(converted.Peers)
+	if len(converted.PX) > 0 || converted.Nonce != 0 {
+		msgBytes = append(msgBytes, peer.SerializePeerArray(converted.PX)...)
+	}
+	if converted.Nonce != 0 {
+		nonceBytes := make([]byte, 4)
+		binary.BigEndian.PutUint32(nonceBytes, converted.Nonce)
+		msgBytes = append(msgBytes, nonceBytes...)
+	}
+	return msgBytes
+}
+
 func (disconnectMessageSerializer) Deserialize(msgBytes []byte) message.Message {
-	return DisconnectMessage{}
+	if len(msgBytes) == 0 {
+		return DisconnectMessage{}
+	}
+	n, hosts, ok := readPeerArray(msgBytes)
+	if !ok {
+		return DisconnectMessage{}
+	}
+	var px []peer.Peer
+	var nonce uint32
+	if n < len(msgBytes) {
+		m, pxHosts, ok := readPeerArray(msgBytes[n:])
+		px = pxHosts
+		if ok && n+m+4 <= len(msgBytes) {
+			nonce = binary.BigEndian.Uint32(msgBytes[n+m:])
+		}
+	}
+	return DisconnectMessage{
+		Peers: hosts,
+		PX:    px,
+		Nonce: nonce,
+	}
 }
 
 const ForwardJoinMessageType = 1502
 
 type ForwardJoinMessage struct {
 	TTL            uint32
+	WalkID         uint32
 	OriginalSender peer.Peer
+	// capabilities the original sender advertised in its join
+	Capabilities uint8
 }
 type forwardJoinMessageSerializer struct{}
 
@@ -52,26 +128,57 @@ func (ForwardJoinMessage) Serializer() message.Serializer { return defaultForwar
 func (ForwardJoinMessage) Deserializer() message.Deserializer {
 	return defaultForwardJoinMessageSerializer
 }
+
+// The walk ID and the capabilities trail the original sender, so that nodes predating them, which read
+// the TTL and the peer only, still parse forward joins, and their forward joins, which lack them, parse
+// with a zero walk ID and no capabilities.
 func (forwardJoinMessageSerializer) Serialize(msg message.Message) []byte {
 	converted := msg.(ForwardJoinMessage)
-	ttlBytes := make([]byte, 4)
-	binary.BigEndian.PutUint32(ttlBytes, converted.TTL)
-	return append(ttlBytes, converted.OriginalSender.Marshal()...)
+	msgBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(msgBytes[0:4], converted.TTL)
+	msgBytes = append(msgBytes, converted.OriginalSender.Marshal()...)
+	if converted.WalkID != 0 || converted.Capabilities != 0 {
+		walkID := make([]byte, 4)
+		binary.BigEndian.PutUint32(walkID, converted.WalkID)
+		msgBytes = append(msgBytes, walkID...)
+	}
+	if converted.Capabilities != 0 {
+		msgBytes = append(msgBytes, converted.Capabilities)
+	}
+	return msgBytes
 }
 
 func (forwardJoinMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 4 {
+		return ForwardJoinMessage{}
+	}
 	ttl := binary.BigEndian.Uint32(msgBytes[0:4])
-	p := &peer.IPeer{}
-	p.Unmarshal(msgBytes[4:])
+	p, ok := readPeer(msgBytes[4:])
+	if !ok {
+		return ForwardJoinMessage{}
+	}
+	var walkID uint32
+	if len(msgBytes) >= 4+peerWireSize+4 {
+		walkID = binary.BigEndian.Uint32(msgBytes[4+peerWireSize:])
+	}
+	var capabilities uint8
+	if len(msgBytes) > 4+peerWireSize+4 {
+		capabilities = msgBytes[4+peerWireSize+4]
+	}
 	return ForwardJoinMessage{
 		TTL:            ttl,
+		WalkID:         walkID,
 		OriginalSender: p,
+		Capabilities:   capabilities,
 	}
 }
 
 const ForwardJoinMessageReplyType = 1503
 
 type ForwardJoinMessageReply struct {
+	Incarnation  uint64
+	TraceID      uint32
+	Capabilities uint8
 }
 type forwardJoinMessageReplySerializer struct{}
 
@@ -85,17 +192,28 @@ func (ForwardJoinMessageReply) Deserializer() message.Deserializer {
 	return defaultForwardJoinMessageReplySerializer
 }
 func (forwardJoinMessageReplySerializer) Serialize(msg message.Message) []byte {
-	return []byte{}
+	converted := msg.(ForwardJoinMessageReply)
+	return appendHandshakeTrailer([]byte{}, converted.Incarnation, converted.TraceID, 0, "", converted.Capabilities)
 }
 
 func (forwardJoinMessageReplySerializer) Deserialize(msgBytes []byte) message.Message {
-	return ForwardJoinMessageReply{}
+	return ForwardJoinMessageReply{
+		Incarnation:  readIncarnation(msgBytes, 0),
+		TraceID:      readTraceID(msgBytes, 0),
+		Capabilities: readHandshakeCapabilities(msgBytes, 0),
+	}
 }
 
 const NeighbourMessageType = 1504
 
 type NeighbourMessage struct {
-	HighPrio bool
+	HighPrio     bool
+	OutboundOnly bool
+	Incarnation  uint64
+	TraceID      uint32
+	OverlayID    uint32
+	ClusterToken string
+	Capabilities uint8
 }
 type neighbourMessageSerializer struct{}
 
@@ -114,20 +232,38 @@ func (neighbourMessageSerializer) Serialize(msg message.Message) []byte {
 	} else {
 		msgBytes = []byte{0}
 	}
-	return msgBytes
+	if converted.OutboundOnly {
+		msgBytes = append(msgBytes, 1)
+	} else if converted.Incarnation != 0 || converted.TraceID != 0 || converted.OverlayID != 0 || converted.ClusterToken != "" || converted.Capabilities != 0 {
+		msgBytes = append(msgBytes, 0)
+	}
+	return appendHandshakeTrailer(msgBytes, converted.Incarnation, converted.TraceID, converted.OverlayID, converted.ClusterToken, converted.Capabilities)
 }
 
 func (neighbourMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) == 0 {
+		return NeighbourMessage{}
+	}
 	highPrio := msgBytes[0] == 1
+	outboundOnly := len(msgBytes) > 1 && msgBytes[1] == 1
 	return NeighbourMessage{
-		HighPrio: highPrio,
+		HighPrio:     highPrio,
+		OutboundOnly: outboundOnly,
+		Incarnation:  readIncarnation(msgBytes, 2),
+		TraceID:      readTraceID(msgBytes, 2),
+		OverlayID:    readOverlayID(msgBytes, 2),
+		ClusterToken: readClusterToken(msgBytes, 2),
+		Capabilities: readHandshakeCapabilities(msgBytes, 2),
 	}
 }
 
 const NeighbourMessageReplyType = 1505
 
 type NeighbourMessageReply struct {
-	Accepted bool
+	Accepted     bool
+	Incarnation  uint64
+	TraceID      uint32
+	Capabilities uint8
 }
 type neighbourMessageReplySerializer struct{}
 
@@ -148,19 +284,29 @@ func (neighbourMessageReplySerializer) Serialize(msg message.Message) []byte {
 	} else {
 		msgBytes = []byte{0}
 	}
-	return msgBytes
+	return appendHandshakeTrailer(msgBytes, converted.Incarnation, converted.TraceID, 0, "", converted.Capabilities)
 }
 
 func (neighbourMessageReplySerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) == 0 {
+		return NeighbourMessageReply{}
+	}
 	accepted := msgBytes[0] == 1
 	return NeighbourMessageReply{
-		Accepted: accepted,
+		Accepted:     accepted,
+		Incarnation:  readIncarnation(msgBytes, 1),
+		TraceID:      readTraceID(msgBytes, 1),
+		Capabilities: readHandshakeCapabilities(msgBytes, 1),
 	}
 }
 
 const NeighbourMaintenanceMessageType = 1506
 
 type NeighbourMaintenanceMessage struct {
+	FailureDomain string
+	Incarnation   uint64
+	Time          *TimeHint
+	Version       uint16
 }
 type neighbourMaintenanceMessageSerializer struct{}
 
@@ -174,19 +320,60 @@ func (NeighbourMaintenanceMessage) Deserializer() message.Deserializer {
 	return defaultNeighbourMaintenanceMessageSerializer
 }
 func (neighbourMaintenanceMessageSerializer) Serialize(msg message.Message) []byte {
-	return []byte{}
+	maintenanceMsg := msg.(NeighbourMaintenanceMessage)
+	msgBytes := []byte(maintenanceMsg.FailureDomain)
+	if maintenanceMsg.Incarnation != 0 || maintenanceMsg.Time != nil || maintenanceMsg.Version != 0 {
+		// failure domains never contain a NUL byte, the incarnation, time hint and version follow one
+		msgBytes = append(msgBytes, 0)
+		msgBytes = append(msgBytes, make([]byte, 8)...)
+		binary.BigEndian.PutUint64(msgBytes[len(msgBytes)-8:], maintenanceMsg.Incarnation)
+	}
+	if maintenanceMsg.Time != nil {
+		msgBytes = append(msgBytes, maintenanceMsg.Time.encode()...)
+	}
+	if maintenanceMsg.Version != 0 {
+		// 2 bytes, never mistaken for the 24 bytes of a time hint
+		msgBytes = append(msgBytes, 0, 0)
+		binary.BigEndian.PutUint16(msgBytes[len(msgBytes)-2:], maintenanceMsg.Version)
+	}
+	return msgBytes
 }
 
 func (neighbourMaintenanceMessageSerializer) Deserialize(msgBytes []byte) message.Message {
-	return NeighbourMaintenanceMessage{}
+	idx := bytes.IndexByte(msgBytes, 0)
+	if idx < 0 {
+		return NeighbourMaintenanceMessage{
+			FailureDomain: string(msgBytes),
+		}
+	}
+	var hint *TimeHint
+	var version uint16
+	if len(msgBytes) > idx+9 {
+		rest := msgBytes[idx+9:]
+		if hint = decodeTimeHint(rest); hint != nil {
+			rest = rest[24:]
+		}
+		if len(rest) >= 2 {
+			version = binary.BigEndian.Uint16(rest[0:2])
+		}
+	}
+	return NeighbourMaintenanceMessage{
+		FailureDomain: string(msgBytes[:idx]),
+		Incarnation:   readIncarnation(msgBytes, idx+1),
+		Time:          hint,
+		Version:       version,
+	}
 }
 
 const ShuffleMessageType = 1507
 
 type ShuffleMessage struct {
-	ID    uint32
-	TTL   uint32
-	Peers []peer.Peer
+	ID           uint32
+	TTL          uint32
+	Peers        []peer.Peer
+	Capabilities uint8
+	ConfigUpdate *ConfigUpdate
+	OverlayID    uint32
 }
 type ShuffleMessageSerializer struct{}
 
@@ -204,25 +391,64 @@ func (ShuffleMessageSerializer) Serialize(msg message.Message) []byte {
 	shuffleMsg := msg.(ShuffleMessage)
 	binary.BigEndian.PutUint32(msgBytes[0:4], shuffleMsg.ID)
 	binary.BigEndian.PutUint32(msgBytes[4:8], shuffleMsg.TTL)
-	return append(msgBytes, peer.SerializePeerArray(shuffleMsg.Peers)...)
+	msgBytes = append(msgBytes, peer.SerializePeerArray(shuffleMsg.Peers)...)
+	capabilities := shuffleMsg.Capabilities
+	if shuffleMsg.OverlayID != 0 {
+		capabilities |= CapOverlayID
+	}
+	if capabilities != 0 || shuffleMsg.ConfigUpdate != nil {
+		// trailing byte, ignored by nodes which do not know about capabilities
+		msgBytes = append(msgBytes, capabilities)
+	}
+	if shuffleMsg.OverlayID != 0 {
+		msgBytes = append(msgBytes, make([]byte, 4)...)
+		binary.BigEndian.PutUint32(msgBytes[len(msgBytes)-4:], shuffleMsg.OverlayID)
+	}
+	if shuffleMsg.ConfigUpdate != nil {
+		msgBytes = append(msgBytes, shuffleMsg.ConfigUpdate.encode()...)
+	}
+	return msgBytes
 }
 
 func (ShuffleMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 8 {
+		return ShuffleMessage{}
+	}
 	id := binary.BigEndian.Uint32(msgBytes[0:4])
 	ttl := binary.BigEndian.Uint32(msgBytes[4:8])
-	_, hosts := peer.DeserializePeerArray(msgBytes[8:])
+	n, hosts, ok := readPeerArray(msgBytes[8:])
+	if !ok {
+		return ShuffleMessage{}
+	}
+	var capabilities uint8
+	var overlayID uint32
+	var configUpdate *ConfigUpdate
+	if len(msgBytes) > 8+n {
+		capabilities = msgBytes[8+n]
+		rest := msgBytes[9+n:]
+		if capabilities&CapOverlayID != 0 && len(rest) >= 4 {
+			overlayID = binary.BigEndian.Uint32(rest[0:4])
+			rest = rest[4:]
+		}
+		capabilities &^= CapOverlayID
+		configUpdate = decodeConfigUpdate(rest)
+	}
 	return ShuffleMessage{
-		ID:    id,
-		TTL:   ttl,
-		Peers: hosts,
+		ID:           id,
+		TTL:          ttl,
+		Peers:        hosts,
+		Capabilities: capabilities,
+		ConfigUpdate: configUpdate,
+		OverlayID:    overlayID,
 	}
 }
 
 const ShuffleReplyMessageType = 1508
 
 type ShuffleReplyMessage struct {
-	ID    uint32
-	Peers []peer.Peer
+	ID           uint32
+	Peers        []peer.Peer
+	ConfigUpdate *ConfigUpdate
 }
 type ShuffleReplyMessageSerializer struct{}
 
@@ -239,14 +465,788 @@ func (ShuffleReplyMessageSerializer) Serialize(msg message.Message) []byte {
 	msgBytes := make([]byte, 4)
 	shuffleMsg := msg.(ShuffleReplyMessage)
 	binary.BigEndian.PutUint32(msgBytes[0:4], shuffleMsg.ID)
-	return append(msgBytes, peer.SerializePeerArray(shuffleMsg.Peers)...)
+	msgBytes = append(msgBytes, peer.SerializePeerArray(shuffleMsg.Peers)...)
+	if shuffleMsg.ConfigUpdate != nil {
+		msgBytes = append(msgBytes, shuffleMsg.ConfigUpdate.encode()...)
+	}
+	return msgBytes
 }
 
 func (ShuffleReplyMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 4 {
+		return ShuffleReplyMessage{}
+	}
 	id := binary.BigEndian.Uint32(msgBytes[0:4])
-	_, hosts := peer.DeserializePeerArray(msgBytes[4:])
+	n, hosts, ok := readPeerArray(msgBytes[4:])
+	if !ok {
+		return ShuffleReplyMessage{}
+	}
 	return ShuffleReplyMessage{
-		ID:    id,
+		ID:           id,
+		Peers:        hosts,
+		ConfigUpdate: decodeConfigUpdate(msgBytes[4+n:]),
+	}
+}
+
+const CyclonShuffleMessageType = 1509
+
+type CyclonShuffleMessage struct {
+	ID        uint32
+	Peers     []peer.Peer
+	Ages      []uint16
+	OverlayID uint32
+}
+type cyclonShuffleMessageSerializer struct{}
+
+var defaultCyclonShuffleMessageSerializer = cyclonShuffleMessageSerializer{}
+
+func (CyclonShuffleMessage) Type() message.ID { return CyclonShuffleMessageType }
+func (CyclonShuffleMessage) Serializer() message.Serializer {
+	return defaultCyclonShuffleMessageSerializer
+}
+func (CyclonShuffleMessage) Deserializer() message.Deserializer {
+	return defaultCyclonShuffleMessageSerializer
+}
+func (cyclonShuffleMessageSerializer) Serialize(msg message.Message) []byte {
+	msgBytes := make([]byte, 4)
+	shuffleMsg := msg.(CyclonShuffleMessage)
+	binary.BigEndian.PutUint32(msgBytes[0:4], shuffleMsg.ID)
+	msgBytes = append(msgBytes, peer.SerializePeerArray(shuffleMsg.Peers)...)
+	msgBytes = append(msgBytes, serializeAges(shuffleMsg.Ages)...)
+	return appendOverlayID(msgBytes, shuffleMsg.OverlayID)
+}
+
+func (cyclonShuffleMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 4 {
+		return CyclonShuffleMessage{}
+	}
+	id := binary.BigEndian.Uint32(msgBytes[0:4])
+	n, hosts, ok := readPeerArray(msgBytes[4:])
+	if !ok {
+		return CyclonShuffleMessage{}
+	}
+	rest := msgBytes[4+n:]
+	return CyclonShuffleMessage{
+		ID:        id,
+		Peers:     hosts,
+		Ages:      deserializeAges(rest, len(hosts)),
+		OverlayID: readTrailingOverlayID(rest, 2*len(hosts)),
+	}
+}
+
+const CyclonShuffleReplyMessageType = 1510
+
+type CyclonShuffleReplyMessage struct {
+	ID        uint32
+	Peers     []peer.Peer
+	Ages      []uint16
+	OverlayID uint32
+}
+type cyclonShuffleReplyMessageSerializer struct{}
+
+var defaultCyclonShuffleReplyMessageSerializer = cyclonShuffleReplyMessageSerializer{}
+
+func (CyclonShuffleReplyMessage) Type() message.ID { return CyclonShuffleReplyMessageType }
+func (CyclonShuffleReplyMessage) Serializer() message.Serializer {
+	return defaultCyclonShuffleReplyMessageSerializer
+}
+func (CyclonShuffleReplyMessage) Deserializer() message.Deserializer {
+	return defaultCyclonShuffleReplyMessageSerializer
+}
+func (cyclonShuffleReplyMessageSerializer) Serialize(msg message.Message) []byte {
+	msgBytes := make([]byte, 4)
+	shuffleMsg := msg.(CyclonShuffleReplyMessage)
+	binary.BigEndian.PutUint32(msgBytes[0:4], shuffleMsg.ID)
+	msgBytes = append(msgBytes, peer.SerializePeerArray(shuffleMsg.Peers)...)
+	msgBytes = append(msgBytes, serializeAges(shuffleMsg.Ages)...)
+	return appendOverlayID(msgBytes, shuffleMsg.OverlayID)
+}
+
+func (cyclonShuffleReplyMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 4 {
+		return CyclonShuffleReplyMessage{}
+	}
+	id := binary.BigEndian.Uint32(msgBytes[0:4])
+	n, hosts, ok := readPeerArray(msgBytes[4:])
+	if !ok {
+		return CyclonShuffleReplyMessage{}
+	}
+	rest := msgBytes[4+n:]
+	return CyclonShuffleReplyMessage{
+		ID:        id,
+		Peers:     hosts,
+		Ages:      deserializeAges(rest, len(hosts)),
+		OverlayID: readTrailingOverlayID(rest, 2*len(hosts)),
+	}
+}
+
+// appendOverlayID appends the overlay ID as a trailer of messages whose older decoders ignore trailing
+// bytes, nothing if it is unset.
+func appendOverlayID(msgBytes []byte, overlayID uint32) []byte {
+	if overlayID == 0 {
+		return msgBytes
+	}
+	msgBytes = append(msgBytes, make([]byte, 4)...)
+	binary.BigEndian.PutUint32(msgBytes[len(msgBytes)-4:], overlayID)
+	return msgBytes
+}
+
+// readTrailingOverlayID reads the overlay ID appended by appendOverlayID at offset, 0 if there is none.
+func readTrailingOverlayID(msgBytes []byte, offset int) uint32 {
+	if len(msgBytes) < offset+4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(msgBytes[offset : offset+4])
+}
+
+func serializeAges(ages []uint16) []byte {
+	agesBytes := make([]byte, 2*len(ages))
+	for i, age := range ages {
+		binary.BigEndian.PutUint16(agesBytes[2*i:], age)
+	}
+	return agesBytes
+}
+
+func deserializeAges(agesBytes []byte, amount int) []uint16 {
+	ages := make([]uint16, amount)
+	for i := 0; i < amount && 2*i+2 <= len(agesBytes); i++ {
+		ages[i] = binary.BigEndian.Uint16(agesBytes[2*i:])
+	}
+	return ages
+}
+
+const WalkTerminatedMessageType = 1511
+
+type WalkTerminatedMessage struct {
+	WalkID         uint32
+	Hops           uint32
+	Accepted       bool
+	OriginalSender peer.Peer
+}
+type walkTerminatedMessageSerializer struct{}
+
+var defaultWalkTerminatedMessageSerializer = walkTerminatedMessageSerializer{}
+
+func (WalkTerminatedMessage) Type() message.ID { return WalkTerminatedMessageType }
+func (WalkTerminatedMessage) Serializer() message.Serializer {
+	return defaultWalkTerminatedMessageSerializer
+}
+func (WalkTerminatedMessage) Deserializer() message.Deserializer {
+	return defaultWalkTerminatedMessageSerializer
+}
+func (walkTerminatedMessageSerializer) Serialize(msg message.Message) []byte {
+	converted := msg.(WalkTerminatedMessage)
+	msgBytes := make([]byte, 9)
+	binary.BigEndian.PutUint32(msgBytes[0:4], converted.WalkID)
+	binary.BigEndian.PutUint32(msgBytes[4:8], converted.Hops)
+	if converted.Accepted {
+		msgBytes[8] = 1
+	}
+	return append(msgBytes, converted.OriginalSender.Marshal()...)
+}
+
+func (walkTerminatedMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 9 {
+		return WalkTerminatedMessage{}
+	}
+	p, ok := readPeer(msgBytes[9:])
+	if !ok {
+		return WalkTerminatedMessage{}
+	}
+	return WalkTerminatedMessage{
+		WalkID:         binary.BigEndian.Uint32(msgBytes[0:4]),
+		Hops:           binary.BigEndian.Uint32(msgBytes[4:8]),
+		Accepted:       msgBytes[8] == 1,
+		OriginalSender: p,
+	}
+}
+
+const CompactShuffleMessageType = 1512
+
+type CompactShuffleMessage struct {
+	ID                     uint32
+	TTL                    uint32
+	Peers                  []peer.Peer
+	OmitZeroAnalyticsPorts bool
+	OverlayID              uint32
+}
+type compactShuffleMessageSerializer struct{}
+
+var defaultCompactShuffleMessageSerializer = compactShuffleMessageSerializer{}
+
+func (CompactShuffleMessage) Type() message.ID { return CompactShuffleMessageType }
+func (CompactShuffleMessage) Serializer() message.Serializer {
+	return defaultCompactShuffleMessageSerializer
+}
+func (CompactShuffleMessage) Deserializer() message.Deserializer {
+	return defaultCompactShuffleMessageSerializer
+}
+func (compactShuffleMessageSerializer) Serialize(msg message.Message) []byte {
+	msgBytes := make([]byte, 8)
+	shuffleMsg := msg.(CompactShuffleMessage)
+	binary.BigEndian.PutUint32(msgBytes[0:4], shuffleMsg.ID)
+	binary.BigEndian.PutUint32(msgBytes[4:8], shuffleMsg.TTL)
+	msgBytes = append(msgBytes, encodePeersCompact(shuffleMsg.Peers, shuffleMsg.OmitZeroAnalyticsPorts)...)
+	return appendOverlayID(msgBytes, shuffleMsg.OverlayID)
+}
+
+func (compactShuffleMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 8 {
+		return CompactShuffleMessage{}
+	}
+	peers, omitted, n := decodePeersCompact(msgBytes[8:])
+	return CompactShuffleMessage{
+		ID:                     binary.BigEndian.Uint32(msgBytes[0:4]),
+		TTL:                    binary.BigEndian.Uint32(msgBytes[4:8]),
+		Peers:                  peers,
+		OmitZeroAnalyticsPorts: omitted,
+		OverlayID:              readTrailingOverlayID(msgBytes[8:], n),
+	}
+}
+
+const CompactShuffleReplyMessageType = 1513
+
+type CompactShuffleReplyMessage struct {
+	ID                     uint32
+	Peers                  []peer.Peer
+	OmitZeroAnalyticsPorts bool
+}
+type compactShuffleReplyMessageSerializer struct{}
+
+var defaultCompactShuffleReplyMessageSerializer = compactShuffleReplyMessageSerializer{}
+
+func (CompactShuffleReplyMessage) Type() message.ID { return CompactShuffleReplyMessageType }
+func (CompactShuffleReplyMessage) Serializer() message.Serializer {
+	return defaultCompactShuffleReplyMessageSerializer
+}
+func (CompactShuffleReplyMessage) Deserializer() message.Deserializer {
+	return defaultCompactShuffleReplyMessageSerializer
+}
+func (compactShuffleReplyMessageSerializer) Serialize(msg message.Message) []byte {
+	msgBytes := make([]byte, 4)
+	shuffleMsg := msg.(CompactShuffleReplyMessage)
+	binary.BigEndian.PutUint32(msgBytes[0:4], shuffleMsg.ID)
+	return append(msgBytes, encodePeersCompact(shuffleMsg.Peers, shuffleMsg.OmitZeroAnalyticsPorts)...)
+}
+
+func (compactShuffleReplyMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 4 {
+		return CompactShuffleReplyMessage{}
+	}
+	peers, omitted, _ := decodePeersCompact(msgBytes[4:])
+	return CompactShuffleReplyMessage{
+		ID:                     binary.BigEndian.Uint32(msgBytes[0:4]),
+		Peers:                  peers,
+		OmitZeroAnalyticsPorts: omitted,
+	}
+}
+
+const JoinRejectMessageType = 1514
+
+type JoinRejectMessage struct {
+	Reason JoinRejectReason
+	Peers  []peer.Peer
+}
+type joinRejectMessageSerializer struct{}
+
+var defaultJoinRejectMessageSerializer = joinRejectMessageSerializer{}
+
+func (JoinRejectMessage) Type() message.ID { return JoinRejectMessageType }
+func (JoinRejectMessage) Serializer() message.Serializer {
+	return defaultJoinRejectMessageSerializer
+}
+func (JoinRejectMessage) Deserializer() message.Deserializer {
+	return defaultJoinRejectMessageSerializer
+}
+func (joinRejectMessageSerializer) Serialize(msg message.Message) []byte {
+	converted := msg.(JoinRejectMessage)
+	return append([]byte{byte(converted.Reason)}, peer.SerializePeerArray(converted.Peers)...)
+}
+
+func (joinRejectMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) == 0 {
+		return JoinRejectMessage{}
+	}
+	_, hosts, _ := readPeerArray(msgBytes[1:])
+	return JoinRejectMessage{
+		Reason: JoinRejectReason(msgBytes[0]),
+		Peers:  hosts,
+	}
+}
+
+const ViewSnapshotMessageType = 1515
+
+type ViewSnapshotMessage struct {
+	Peers []peer.Peer
+}
+type viewSnapshotMessageSerializer struct{}
+
+var defaultViewSnapshotMessageSerializer = viewSnapshotMessageSerializer{}
+
+func (ViewSnapshotMessage) Type() message.ID { return ViewSnapshotMessageType }
+func (ViewSnapshotMessage) Serializer() message.Serializer {
+	return defaultViewSnapshotMessageSerializer
+}
+func (ViewSnapshotMessage) Deserializer() message.Deserializer {
+	return defaultViewSnapshotMessageSerializer
+}
+func (viewSnapshotMessageSerializer) Serialize(msg message.Message) []byte {
+	return peer.SerializePeerArray(msg.(ViewSnapshotMessage).Peers)
+}
+
+func (viewSnapshotMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	_, hosts, _ := readPeerArray(msgBytes)
+	return ViewSnapshotMessage{
+		Peers: hosts,
+	}
+}
+
+const BlacklistMessageType = 1516
+
+type BlacklistMessage struct {
+	ID        uint64
+	Peers     []peer.Peer
+	TTLs      []uint32
+	Signature []byte
+}
+type blacklistMessageSerializer struct{}
+
+var defaultBlacklistMessageSerializer = blacklistMessageSerializer{}
+
+func (BlacklistMessage) Type() message.ID { return BlacklistMessageType }
+func (BlacklistMessage) Serializer() message.Serializer {
+	return defaultBlacklistMessageSerializer
+}
+func (BlacklistMessage) Deserializer() message.Deserializer {
+	return defaultBlacklistMessageSerializer
+}
+func (blacklistMessageSerializer) Serialize(msg message.Message) []byte {
+	converted := msg.(BlacklistMessage)
+	return appendLengthPrefixed(converted.signedBytes(), converted.Signature)
+}
+
+func (blacklistMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 8 {
+		return BlacklistMessage{}
+	}
+	id := binary.BigEndian.Uint64(msgBytes[0:8])
+	n, hosts, ok := readPeerArray(msgBytes[8:])
+	if !ok {
+		return BlacklistMessage{}
+	}
+	rest := msgBytes[8+n:]
+	ttls := make([]uint32, 0, len(hosts))
+	for range hosts {
+		if len(rest) < 4 {
+			break
+		}
+		ttls = append(ttls, binary.BigEndian.Uint32(rest[0:4]))
+		rest = rest[4:]
+	}
+	signature, _, _ := readLengthPrefixed(rest)
+	return BlacklistMessage{
+		ID:        id,
+		Peers:     hosts,
+		TTLs:      ttls,
+		Signature: signature,
+	}
+}
+
+const RedirectMessageType = 1517
+
+type RedirectMessage struct {
+	Peers []peer.Peer
+}
+type redirectMessageSerializer struct{}
+
+var defaultRedirectMessageSerializer = redirectMessageSerializer{}
+
+func (RedirectMessage) Type() message.ID { return RedirectMessageType }
+func (RedirectMessage) Serializer() message.Serializer {
+	return defaultRedirectMessageSerializer
+}
+func (RedirectMessage) Deserializer() message.Deserializer {
+	return defaultRedirectMessageSerializer
+}
+func (redirectMessageSerializer) Serialize(msg message.Message) []byte {
+	return peer.SerializePeerArray(msg.(RedirectMessage).Peers)
+}
+
+func (redirectMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	_, hosts, _ := readPeerArray(msgBytes)
+	return RedirectMessage{
+		Peers: hosts,
+	}
+}
+
+const PassiveViewRequestMessageType = 1518
+
+type PassiveViewRequestMessage struct {
+	Size uint16
+}
+type passiveViewRequestMessageSerializer struct{}
+
+var defaultPassiveViewRequestMessageSerializer = passiveViewRequestMessageSerializer{}
+
+func (PassiveViewRequestMessage) Type() message.ID { return PassiveViewRequestMessageType }
+func (PassiveViewRequestMessage) Serializer() message.Serializer {
+	return defaultPassiveViewRequestMessageSerializer
+}
+func (PassiveViewRequestMessage) Deserializer() message.Deserializer {
+	return defaultPassiveViewRequestMessageSerializer
+}
+func (passiveViewRequestMessageSerializer) Serialize(msg message.Message) []byte {
+	msgBytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(msgBytes, msg.(PassiveViewRequestMessage).Size)
+	return msgBytes
+}
+
+func (passiveViewRequestMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 2 {
+		return PassiveViewRequestMessage{}
+	}
+	return PassiveViewRequestMessage{Size: binary.BigEndian.Uint16(msgBytes)}
+}
+
+const PassiveViewReplyMessageType = 1519
+
+type PassiveViewReplyMessage struct {
+	Peers []peer.Peer
+}
+type passiveViewReplyMessageSerializer struct{}
+
+var defaultPassiveViewReplyMessageSerializer = passiveViewReplyMessageSerializer{}
+
+func (PassiveViewReplyMessage) Type() message.ID { return PassiveViewReplyMessageType }
+func (PassiveViewReplyMessage) Serializer() message.Serializer {
+	return defaultPassiveViewReplyMessageSerializer
+}
+func (PassiveViewReplyMessage) Deserializer() message.Deserializer {
+	return defaultPassiveViewReplyMessageSerializer
+}
+func (passiveViewReplyMessageSerializer) Serialize(msg message.Message) []byte {
+	return peer.SerializePeerArray(msg.(PassiveViewReplyMessage).Peers)
+}
+
+func (passiveViewReplyMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	_, hosts, _ := readPeerArray(msgBytes)
+	return PassiveViewReplyMessage{
+		Peers: hosts,
+	}
+}
+
+const BandwidthProbeMessageType = 1520
+
+type BandwidthProbeMessage struct {
+	ProbeID uint32
+	Seq     uint8
+	Count   uint8
+	Payload []byte
+}
+type bandwidthProbeMessageSerializer struct{}
+
+var defaultBandwidthProbeMessageSerializer = bandwidthProbeMessageSerializer{}
+
+func (BandwidthProbeMessage) Type() message.ID { return BandwidthProbeMessageType }
+func (BandwidthProbeMessage) Serializer() message.Serializer {
+	return defaultBandwidthProbeMessageSerializer
+}
+func (BandwidthProbeMessage) Deserializer() message.Deserializer {
+	return defaultBandwidthProbeMessageSerializer
+}
+func (bandwidthProbeMessageSerializer) Serialize(msg message.Message) []byte {
+	converted := msg.(BandwidthProbeMessage)
+	msgBytes := make([]byte, 6, 6+len(converted.Payload))
+	binary.BigEndian.PutUint32(msgBytes, converted.ProbeID)
+	msgBytes[4] = converted.Seq
+	msgBytes[5] = converted.Count
+	return append(msgBytes, converted.Payload...)
+}
+
+func (bandwidthProbeMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 6 {
+		return BandwidthProbeMessage{}
+	}
+	return BandwidthProbeMessage{
+		ProbeID: binary.BigEndian.Uint32(msgBytes),
+		Seq:     msgBytes[4],
+		Count:   msgBytes[5],
+		Payload: msgBytes[6:],
+	}
+}
+
+const BandwidthProbeReplyMessageType = 1521
+
+type BandwidthProbeReplyMessage struct {
+	ProbeID        uint32
+	BytesPerSecond uint64
+}
+type bandwidthProbeReplyMessageSerializer struct{}
+
+var defaultBandwidthProbeReplyMessageSerializer = bandwidthProbeReplyMessageSerializer{}
+
+func (BandwidthProbeReplyMessage) Type() message.ID { return BandwidthProbeReplyMessageType }
+func (BandwidthProbeReplyMessage) Serializer() message.Serializer {
+	return defaultBandwidthProbeReplyMessageSerializer
+}
+func (BandwidthProbeReplyMessage) Deserializer() message.Deserializer {
+	return defaultBandwidthProbeReplyMessageSerializer
+}
+func (bandwidthProbeReplyMessageSerializer) Serialize(msg message.Message) []byte {
+	converted := msg.(BandwidthProbeReplyMessage)
+	msgBytes := make([]byte, 12)
+	binary.BigEndian.PutUint32(msgBytes, converted.ProbeID)
+	binary.BigEndian.PutUint64(msgBytes[4:], converted.BytesPerSecond)
+	return msgBytes
+}
+
+func (bandwidthProbeReplyMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 12 {
+		return BandwidthProbeReplyMessage{}
+	}
+	return BandwidthProbeReplyMessage{
+		ProbeID:        binary.BigEndian.Uint32(msgBytes),
+		BytesPerSecond: binary.BigEndian.Uint64(msgBytes[4:]),
+	}
+}
+
+const LivenessProbeMessageType = 1522
+
+type LivenessProbeMessage struct {
+	Nonce uint32
+}
+type livenessProbeMessageSerializer struct{}
+
+var defaultLivenessProbeMessageSerializer = livenessProbeMessageSerializer{}
+
+func (LivenessProbeMessage) Type() message.ID { return LivenessProbeMessageType }
+func (LivenessProbeMessage) Serializer() message.Serializer {
+	return defaultLivenessProbeMessageSerializer
+}
+func (LivenessProbeMessage) Deserializer() message.Deserializer {
+	return defaultLivenessProbeMessageSerializer
+}
+func (livenessProbeMessageSerializer) Serialize(msg message.Message) []byte {
+	msgBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(msgBytes, msg.(LivenessProbeMessage).Nonce)
+	return msgBytes
+}
+
+func (livenessProbeMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 4 {
+		return LivenessProbeMessage{}
+	}
+	return LivenessProbeMessage{Nonce: binary.BigEndian.Uint32(msgBytes)}
+}
+
+const LivenessProbeReplyMessageType = 1523
+
+type LivenessProbeReplyMessage struct {
+	Nonce uint32
+}
+type livenessProbeReplyMessageSerializer struct{}
+
+var defaultLivenessProbeReplyMessageSerializer = livenessProbeReplyMessageSerializer{}
+
+func (LivenessProbeReplyMessage) Type() message.ID { return LivenessProbeReplyMessageType }
+func (LivenessProbeReplyMessage) Serializer() message.Serializer {
+	return defaultLivenessProbeReplyMessageSerializer
+}
+func (LivenessProbeReplyMessage) Deserializer() message.Deserializer {
+	return defaultLivenessProbeReplyMessageSerializer
+}
+func (livenessProbeReplyMessageSerializer) Serialize(msg message.Message) []byte {
+	msgBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(msgBytes, msg.(LivenessProbeReplyMessage).Nonce)
+	return msgBytes
+}
+
+func (livenessProbeReplyMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 4 {
+		return LivenessProbeReplyMessage{}
+	}
+	return LivenessProbeReplyMessage{Nonce: binary.BigEndian.Uint32(msgBytes)}
+}
+
+const ShuffleFragmentMessageType = 1524
+
+type ShuffleFragmentMessage struct {
+	GroupID   uint32
+	Index     uint8
+	Count     uint8
+	InnerType uint16
+	Payload   []byte
+}
+type shuffleFragmentMessageSerializer struct{}
+
+var defaultShuffleFragmentMessageSerializer = shuffleFragmentMessageSerializer{}
+
+func (ShuffleFragmentMessage) Type() message.ID { return ShuffleFragmentMessageType }
+func (ShuffleFragmentMessage) Serializer() message.Serializer {
+	return defaultShuffleFragmentMessageSerializer
+}
+func (ShuffleFragmentMessage) Deserializer() message.Deserializer {
+	return defaultShuffleFragmentMessageSerializer
+}
+func (shuffleFragmentMessageSerializer) Serialize(msg message.Message) []byte {
+	converted := msg.(ShuffleFragmentMessage)
+	msgBytes := make([]byte, 8, 8+len(converted.Payload))
+	binary.BigEndian.PutUint32(msgBytes, converted.GroupID)
+	msgBytes[4] = converted.Index
+	msgBytes[5] = converted.Count
+	binary.BigEndian.PutUint16(msgBytes[6:], converted.InnerType)
+	return append(msgBytes, converted.Payload...)
+}
+
+func (shuffleFragmentMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 8 {
+		return ShuffleFragmentMessage{}
+	}
+	return ShuffleFragmentMessage{
+		GroupID:   binary.BigEndian.Uint32(msgBytes),
+		Index:     msgBytes[4],
+		Count:     msgBytes[5],
+		InnerType: binary.BigEndian.Uint16(msgBytes[6:]),
+		Payload:   msgBytes[8:],
+	}
+}
+
+const LatencyVectorMessageType = 1525
+
+type LatencyVectorMessage struct {
+	Peers []peer.Peer
+	RTTs  []uint32 // microseconds
+}
+type latencyVectorMessageSerializer struct{}
+
+var defaultLatencyVectorMessageSerializer = latencyVectorMessageSerializer{}
+
+func (LatencyVectorMessage) Type() message.ID { return LatencyVectorMessageType }
+func (LatencyVectorMessage) Serializer() message.Serializer {
+	return defaultLatencyVectorMessageSerializer
+}
+func (LatencyVectorMessage) Deserializer() message.Deserializer {
+	return defaultLatencyVectorMessageSerializer
+}
+func (latencyVectorMessageSerializer) Serialize(msg message.Message) []byte {
+	converted := msg.(LatencyVectorMessage)
+	msgBytes := peer.SerializePeerArray(converted.Peers)
+	rttBytes := make([]byte, 4*len(converted.RTTs))
+	for i, rtt := range converted.RTTs {
+		binary.BigEndian.PutUint32(rttBytes[4*i:], rtt)
+	}
+	return append(msgBytes, rttBytes...)
+}
+
+func (latencyVectorMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	n, hosts, ok := readPeerArray(msgBytes)
+	if !ok {
+		return LatencyVectorMessage{}
+	}
+	rtts := make([]uint32, len(hosts))
+	for i := range rtts {
+		if n+4*i+4 > len(msgBytes) {
+			break
+		}
+		rtts[i] = binary.BigEndian.Uint32(msgBytes[n+4*i:])
+	}
+	return LatencyVectorMessage{
 		Peers: hosts,
+		RTTs:  rtts,
+	}
+}
+
+const MetadataMessageType = 1526
+
+type MetadataMessage struct {
+	Version    uint32
+	Attributes map[string]string
+}
+type metadataMessageSerializer struct{}
+
+var defaultMetadataMessageSerializer = metadataMessageSerializer{}
+
+func (MetadataMessage) Type() message.ID                   { return MetadataMessageType }
+func (MetadataMessage) Serializer() message.Serializer     { return defaultMetadataMessageSerializer }
+func (MetadataMessage) Deserializer() message.Deserializer { return defaultMetadataMessageSerializer }
+func (metadataMessageSerializer) Serialize(msg message.Message) []byte {
+	converted := msg.(MetadataMessage)
+	msgBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(msgBytes, converted.Version)
+	keys := make([]string, 0, len(converted.Attributes))
+	for k := range converted.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		msgBytes = appendString(msgBytes, k)
+		msgBytes = appendString(msgBytes, converted.Attributes[k])
 	}
+	return msgBytes
+}
+
+func (metadataMessageSerializer) Deserialize(msgBytes []byte) message.Message {
+	if len(msgBytes) < 4 {
+		return MetadataMessage{}
+	}
+	attributes := map[string]string{}
+	rest := msgBytes[4:]
+	for len(rest) > 0 {
+		k, afterKey, ok := readString(rest)
+		if !ok {
+			break
+		}
+		v, afterValue, ok := readString(afterKey)
+		if !ok {
+			break
+		}
+		attributes[k] = v
+		rest = afterValue
+	}
+	return MetadataMessage{
+		Version:    binary.BigEndian.Uint32(msgBytes),
+		Attributes: attributes,
+	}
+}
+
+// appendString appends s prefixed by its length, strings longer than 64KiB are truncated.
+func appendString(msgBytes []byte, s string) []byte {
+	if len(s) > math.MaxUint16 {
+		s = s[:math.MaxUint16]
+	}
+	msgBytes = append(msgBytes, 0, 0)
+	binary.BigEndian.PutUint16(msgBytes[len(msgBytes)-2:], uint16(len(s)))
+	return append(msgBytes, s...)
+}
+
+func readString(msgBytes []byte) (string, []byte, bool) {
+	if len(msgBytes) < 2 {
+		return "", nil, false
+	}
+	n := int(binary.BigEndian.Uint16(msgBytes))
+	if len(msgBytes) < 2+n {
+		return "", nil, false
+	}
+	return string(msgBytes[2 : 2+n]), msgBytes[2+n:], true
+}
+
+// peerWireSize is the length of a peer marshalled by babel: IPv4 address, protos port and analytics port.
+const peerWireSize = 8
+
+// readPeerArray deserializes a peer array, reporting false instead of panicking if msgBytes is too short to hold it.
+func readPeerArray(msgBytes []byte) (int, []peer.Peer, bool) {
+	if len(msgBytes) < 2 || len(msgBytes) < 2+peerWireSize*int(binary.BigEndian.Uint16(msgBytes)) {
+		return 0, nil, false
+	}
+	n, hosts := peer.DeserializePeerArray(msgBytes)
+	return n, hosts, true
+}
+
+func readPeer(msgBytes []byte) (peer.Peer, bool) {
+	if len(msgBytes) < peerWireSize {
+		return nil, false
+	}
+	p := &peer.IPeer{}
+	p.Unmarshal(msgBytes)
+	return p, true
 }
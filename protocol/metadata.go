@@ -0,0 +1,173 @@
+package protocol
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Nodes may advertise metadata, string attributes set through the Metadata config key or
+// SetMetadata, to their neighbours. Every change increments the metadata version. The attributes are
+// sent along with the maintenance messages to every neighbour which did not get the current version
+// yet, and sent again every third of MetadataTTLSeconds so neighbours know they are still current.
+// Metadata not refreshed within MetadataTTLSeconds is marked stale, and a MetadataChangedNotification
+// is emitted whenever the attributes of a neighbour change or go stale, so applications relying on
+// them never act on stale data. Metadata is kept for active view members only.
+
+// MetadataConfig sets the attributes advertised on start and how long they stay current.
+type MetadataConfig struct {
+	// attributes advertised to the neighbours until SetMetadata replaces them
+	Metadata           map[string]string `yaml:"metadata"`
+	MetadataTTLSeconds int               `yaml:"metadataTTLSeconds"`
+}
+
+// metadataState holds the local metadata.
+type metadataState struct {
+	metadata        map[string]string
+	metadataVersion uint32
+}
+
+const defaultMetadataTTL = 30 * time.Second
+
+type peerMetadata struct {
+	version     uint32
+	attributes  map[string]string
+	refreshedAt time.Time
+	stale       bool
+	// version last sent to the peer, and when
+	sentVersion uint32
+	sentAt      time.Time
+}
+
+// PeerMetadata is the metadata advertised by a neighbour.
+type PeerMetadata struct {
+	Version     uint32            `json:"version"`
+	Attributes  map[string]string `json:"attributes"`
+	RefreshedAt time.Time         `json:"refreshedAt"`
+	Stale       bool              `json:"stale"`
+}
+
+func (p *PeerState) peerMetadata() *peerMetadata {
+	if p.metadata == nil {
+		p.metadata = &peerMetadata{}
+	}
+	return p.metadata
+}
+
+func (h *Hyparview) metadataTTL() time.Duration {
+	if h.conf.MetadataTTLSeconds > 0 {
+		return time.Duration(h.conf.MetadataTTLSeconds) * time.Second
+	}
+	return defaultMetadataTTL
+}
+
+func copyAttributes(attributes map[string]string) map[string]string {
+	copied := make(map[string]string, len(attributes))
+	for k, v := range attributes {
+		copied[k] = v
+	}
+	return copied
+}
+
+// SetMetadata replaces the metadata advertised to the neighbours.
+func (h *Hyparview) SetMetadata(attributes map[string]string) {
+	attributes = copyAttributes(attributes)
+	h.onProtocol("SetMetadata", func() { h.setMetadata(attributes) })
+}
+
+func (h *Hyparview) setMetadata(attributes map[string]string) {
+	if reflect.DeepEqual(attributes, h.metadata) {
+		return
+	}
+	h.metadata = attributes
+	h.metadataVersion++
+	h.logger.Infof("Metadata changed to %v, version %d", attributes, h.metadataVersion)
+}
+
+// GetPeerMetadata returns the metadata advertised by neighbour p, it blocks until the protocol
+// goroutine answers.
+func (h *Hyparview) GetPeerMetadata(p peer.Peer) (PeerMetadata, bool) {
+	reply := make(chan *PeerMetadata, 1)
+	h.onProtocol("GetPeerMetadata", func() { reply <- h.peerMetadata(p) })
+	metadata := <-reply
+	if metadata == nil {
+		return PeerMetadata{}, false
+	}
+	return *metadata, true
+}
+
+func (h *Hyparview) peerMetadata(p peer.Peer) *PeerMetadata {
+	neighbour, ok := h.activeView.get(p)
+	if !ok || neighbour.metadata == nil || neighbour.metadata.version == 0 {
+		return nil
+	}
+	metadata := neighbour.metadata.export()
+	return &metadata
+}
+
+func (m *peerMetadata) export() PeerMetadata {
+	return PeerMetadata{
+		Version:     m.version,
+		Attributes:  copyAttributes(m.attributes),
+		RefreshedAt: m.refreshedAt,
+		Stale:       m.stale,
+	}
+}
+
+// sendMetadata runs on the maintenance timer for every neighbour.
+func (h *Hyparview) sendMetadata(p *PeerState) {
+	if h.metadataVersion == 0 || !p.outConnected {
+		return
+	}
+	m := p.peerMetadata()
+	if m.sentVersion == h.metadataVersion && h.elapsedSince(m.sentAt) < h.metadataTTL()/3 {
+		return
+	}
+	m.sentVersion = h.metadataVersion
+	m.sentAt = h.timeNow()
+	h.sendMessage(MetadataMessage{Version: h.metadataVersion, Attributes: h.metadata}, p)
+}
+
+func (h *Hyparview) HandleMetadataMessage(sender peer.Peer, msg message.Message) {
+	metadataMsg := msg.(MetadataMessage)
+	p, ok := h.activeView.get(sender)
+	if !ok {
+		return
+	}
+	m := p.peerMetadata()
+	if metadataMsg.Version < m.version {
+		return
+	}
+	changed := m.stale || !reflect.DeepEqual(m.attributes, metadataMsg.Attributes)
+	m.version = metadataMsg.Version
+	m.attributes = metadataMsg.Attributes
+	m.refreshedAt = h.timeNow()
+	m.stale = false
+	if changed {
+		h.logger.Infof("Metadata of %s changed to %v, version %d", sender.String(), m.attributes, m.version)
+		h.notifyMetadataChanged(p)
+	}
+}
+
+// markStaleMetadata runs on the maintenance timer.
+func (h *Hyparview) markStaleMetadata() {
+	for _, p := range h.activeView.asArr {
+		m := p.metadata
+		if m == nil || m.version == 0 || m.stale || h.elapsedSince(m.refreshedAt) < h.metadataTTL() {
+			continue
+		}
+		m.stale = true
+		h.logger.Warnf("Metadata of %s not refreshed for %s, marking it stale", p.String(), h.elapsedSince(m.refreshedAt))
+		h.notifyMetadataChanged(p)
+	}
+}
+
+func (h *Hyparview) notifyMetadataChanged(p *PeerState) {
+	h.babel.SendNotification(MetadataChangedNotification{
+		Peer:        p.Peer,
+		Metadata:    p.metadata.export(),
+		ViewVersion: h.CurrentViewVersion(),
+	})
+}
@@ -0,0 +1,165 @@
+package protocol
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// With MetricsPort set, the node keeps machine consumable metrics, exposed in the Prometheus text
+// format on /metrics by the metrics package: view sizes, join attempts, shuffles, neighbour churn,
+// dial failures, and per message type counts and delivery latencies. Messages are counted by a
+// message tap, and delivery latencies are measured as for slow peer detection, from the send of a
+// message to the report of its delivery, for active view members only.
+
+var messageLatencyBounds = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	5 * time.Second,
+}
+
+// LatencyHistogram counts latencies up to each of the bounds, the last bucket counts the latencies
+// longer than every bound.
+type LatencyHistogram struct {
+	Bounds  []time.Duration `json:"bounds"`
+	Buckets []int           `json:"buckets"`
+	Sum     time.Duration   `json:"sum"`
+	Count   int             `json:"count"`
+}
+
+func (hist *LatencyHistogram) record(latency time.Duration) {
+	bucket := len(hist.Bounds)
+	for i, bound := range hist.Bounds {
+		if latency <= bound {
+			bucket = i
+			break
+		}
+	}
+	hist.Buckets[bucket]++
+	hist.Sum += latency
+	hist.Count++
+}
+
+type Metrics struct {
+	ActiveViewSize      int                          `json:"activeViewSize"`
+	ActiveViewCapacity  int                          `json:"activeViewCapacity"`
+	PassiveViewSize     int                          `json:"passiveViewSize"`
+	PassiveViewCapacity int                          `json:"passiveViewCapacity"`
+	Connected           int                          `json:"connected"`
+	JoinAttempts        int                          `json:"joinAttempts"`
+	ShufflesSent        int                          `json:"shufflesSent"`
+	ShufflesReceived    int                          `json:"shufflesReceived"`
+	NeighbourUps        int                          `json:"neighbourUps"`
+	NeighbourDowns      int                          `json:"neighbourDowns"`
+	DialFailures        int                          `json:"dialFailures"`
+	MessagesSent        map[string]int               `json:"messagesSent"`
+	MessagesReceived    map[string]int               `json:"messagesReceived"`
+	MessageLatencies    map[string]*LatencyHistogram `json:"messageLatencies"`
+}
+
+type metrics struct {
+	shufflesReceived int
+	neighbourUps     int
+	neighbourDowns   int
+	dialFailures     int
+	sent             map[string]int
+	received         map[string]int
+	latencies        map[string]*LatencyHistogram
+}
+
+func (h *Hyparview) metricsEnabled() bool {
+	return h.metrics != nil
+}
+
+func (h *Hyparview) startMetrics() {
+	if h.conf.MetricsPort <= 0 {
+		return
+	}
+	h.metrics = &metrics{
+		sent:      map[string]int{},
+		received:  map[string]int{},
+		latencies: map[string]*LatencyHistogram{},
+	}
+	h.AddMessageTap(h.metrics)
+	h.onBeforeRemove(ActiveView, func(_ ViewID, p peer.Peer) {
+		if state, ok := h.activeView.get(p); ok && state.outConnected {
+			h.metrics.neighbourDowns++
+		}
+	})
+}
+
+func messageTypeName(msg message.Message) string {
+	return reflect.TypeOf(msg).Name()
+}
+
+func (m *metrics) OnMessage(direction MessageDirection, _ peer.Peer, _ time.Time, msg message.Message) {
+	if direction == Outbound {
+		m.sent[messageTypeName(msg)]++
+		return
+	}
+	m.received[messageTypeName(msg)]++
+	switch msg.(type) {
+	case ShuffleMessage, CompactShuffleMessage, CyclonShuffleMessage:
+		m.shufflesReceived++
+	}
+}
+
+func (h *Hyparview) recordMessageLatency(msg message.Message, latency time.Duration) {
+	if !h.metricsEnabled() {
+		return
+	}
+	hist, ok := h.metrics.latencies[messageTypeName(msg)]
+	if !ok {
+		hist = &LatencyHistogram{Bounds: messageLatencyBounds, Buckets: make([]int, len(messageLatencyBounds)+1)}
+		h.metrics.latencies[messageTypeName(msg)] = hist
+	}
+	hist.record(latency)
+}
+
+// Metrics returns the current metrics, it blocks until the protocol goroutine collects them. The
+// counters are zero unless MetricsPort is set.
+func (h *Hyparview) Metrics() Metrics {
+	reply := make(chan Metrics, 1)
+	h.onProtocol("Metrics", func() { reply <- h.collectMetrics() })
+	return <-reply
+}
+
+func (h *Hyparview) collectMetrics() Metrics {
+	res := Metrics{
+		ActiveViewSize:      h.activeView.size(),
+		ActiveViewCapacity:  h.activeView.capacity,
+		PassiveViewSize:     h.passiveView.size(),
+		PassiveViewCapacity: h.passiveView.capacity,
+		Connected:           len(h.getView()),
+		JoinAttempts:        h.bootstrapStats.JoinAttempts,
+		ShufflesSent:        h.shuffleReplyStats.Sent,
+		MessagesSent:        map[string]int{},
+		MessagesReceived:    map[string]int{},
+		MessageLatencies:    map[string]*LatencyHistogram{},
+	}
+	if m := h.metrics; m != nil {
+		res.ShufflesReceived = m.shufflesReceived
+		res.NeighbourUps = m.neighbourUps
+		res.NeighbourDowns = m.neighbourDowns
+		res.DialFailures = m.dialFailures
+		for msgType, count := range m.sent {
+			res.MessagesSent[msgType] = count
+		}
+		for msgType, count := range m.received {
+			res.MessagesReceived[msgType] = count
+		}
+		for msgType, hist := range m.latencies {
+			copied := *hist
+			copied.Buckets = append([]int{}, hist.Buckets...)
+			res.MessageLatencies[msgType] = &copied
+		}
+	}
+	return res
+}
@@ -0,0 +1,77 @@
+package protocol
+
+import (
+	"math"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Two full neighbours admitting joiners at the same time may pick each other for eviction, both then
+// keep the other in their passive view and may promote it back, and be evicted again, in a storm of
+// disconnects and reconnects between the same pair. Disconnects sent for capacity reasons carry a
+// random nonce, and a node receiving one from a peer it evicted itself within mutualEvictionWindow
+// knows both sides evicted each other. Both sides break the tie the same way: if the xor of both
+// nonces is even the side with the lowest address performed the eviction, otherwise the highest.
+// The evicting side does not promote the other back for evictionHoldDown, the other side is free to
+// re-establish the link later through the usual promotions.
+
+// evictionState remembers the recent evictions and the peers held down after a mutual one.
+type evictionState struct {
+	evictions       map[string]eviction
+	heldDown        map[string]time.Time
+	mutualEvictions int
+}
+
+const (
+	mutualEvictionWindow = 5 * time.Second
+	evictionHoldDown     = time.Minute
+)
+
+type eviction struct {
+	nonce uint32
+	at    time.Time
+}
+
+// evictionNonce records the capacity eviction of p, returning the nonce to send along with it.
+func (h *Hyparview) evictionNonce(p peer.Peer) uint32 {
+	for evicted, e := range h.evictions {
+		if h.elapsedSince(e.at) > mutualEvictionWindow {
+			delete(h.evictions, evicted)
+		}
+	}
+	nonce := uint32(1 + getRandInt(math.MaxUint32-1))
+	h.evictions[p.String()] = eviction{nonce: nonce, at: h.timeNow()}
+	return nonce
+}
+
+// checkMutualEviction is called on disconnects carrying an eviction nonce.
+func (h *Hyparview) checkMutualEviction(sender peer.Peer, theirNonce uint32) {
+	mine, ok := h.evictions[sender.String()]
+	if !ok || h.elapsedSince(mine.at) > mutualEvictionWindow {
+		return
+	}
+	delete(h.evictions, sender.String())
+	h.mutualEvictions++
+	lowestWins := (mine.nonce^theirNonce)%2 == 0
+	selfLowest := h.babel.SelfPeer().String() < sender.String()
+	if lowestWins != selfLowest {
+		h.logger.Infof("Mutual eviction with %s, it performed the eviction", sender.String())
+		return
+	}
+	h.logger.Infof("Mutual eviction with %s, holding it down for %s", sender.String(), evictionHoldDown)
+	h.heldDown[sender.String()] = h.timeNow().Add(evictionHoldDown)
+}
+
+// evictionHeldDown returns whether p must not be promoted after a mutual eviction.
+func (h *Hyparview) evictionHeldDown(p peer.Peer) bool {
+	until, ok := h.heldDown[p.String()]
+	if !ok {
+		return false
+	}
+	if h.timeNow().After(until) {
+		delete(h.heldDown, p.String())
+		return false
+	}
+	return true
+}
@@ -1,15 +1,23 @@
 package protocol
 
 import (
+	"time"
+
 	"github.com/nm-morais/go-babel/pkg/notification"
 	"github.com/nm-morais/go-babel/pkg/peer"
 )
 
+// Every notification carries the ViewVersion of the active view when it was emitted. The version is
+// incremented by each NeighborUpNotification and NeighborDownNotification, so a subscriber seeing a
+// neighbour notification whose version is not the previous one plus one missed a change, and can
+// resync from Snapshot, which carries the version of the views it returns.
+
 const NeighborUpNotificationType = 10501
 
 type NeighborUpNotification struct {
-	PeerUp peer.Peer
-	View   map[string]peer.Peer
+	PeerUp      peer.Peer
+	View        map[string]peer.Peer
+	ViewVersion uint64
 }
 
 func (n NeighborUpNotification) ID() notification.ID {
@@ -19,10 +27,72 @@ func (n NeighborUpNotification) ID() notification.ID {
 const NeighborDownNotificationType = 10502
 
 type NeighborDownNotification struct {
-	PeerDown peer.Peer
-	View     map[string]peer.Peer
+	PeerDown    peer.Peer
+	View        map[string]peer.Peer
+	ViewVersion uint64
 }
 
 func (n NeighborDownNotification) ID() notification.ID {
 	return NeighborDownNotificationType
 }
+
+const OverlayRejoinedNotificationType = 10503
+
+type OverlayRejoinedNotification struct {
+	Epoch       uint64
+	ViewVersion uint64
+}
+
+func (n OverlayRejoinedNotification) ID() notification.ID {
+	return OverlayRejoinedNotificationType
+}
+
+const ConfigReloadedNotificationType = 10504
+
+type ConfigReloadedNotification struct {
+	Applied     bool
+	Err         error
+	ViewVersion uint64
+}
+
+func (n ConfigReloadedNotification) ID() notification.ID {
+	return ConfigReloadedNotificationType
+}
+
+const IsolatedNotificationType = 10505
+
+type IsolatedNotification struct {
+	Since       time.Time
+	Attempts    int
+	ViewVersion uint64
+}
+
+func (n IsolatedNotification) ID() notification.ID {
+	return IsolatedNotificationType
+}
+
+const PhaseChangedNotificationType = 10506
+
+// PhaseChangedNotification is emitted on every lifecycle phase transition, see lifecycle.go.
+type PhaseChangedNotification struct {
+	From        Phase
+	To          Phase
+	ViewVersion uint64
+}
+
+func (n PhaseChangedNotification) ID() notification.ID {
+	return PhaseChangedNotificationType
+}
+
+const MetadataChangedNotificationType = 10507
+
+// MetadataChangedNotification is emitted when the metadata of a neighbour changes or goes stale.
+type MetadataChangedNotification struct {
+	Peer        peer.Peer
+	Metadata    PeerMetadata
+	ViewVersion uint64
+}
+
+func (n MetadataChangedNotification) ID() notification.ID {
+	return MetadataChangedNotificationType
+}
@@ -0,0 +1,217 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Go applications embedding the protocol can build their configuration with NewConfig and functional
+// options instead of filling the YAML tagged HyparviewConfig. The address of the node is required by
+// NewConfig's signature, every other setting defaults to the values of config/exampleConfig.yml, and
+// the result is validated. Options are plain functions over HyparviewConfig, so settings without a
+// dedicated option can be set with a custom one.
+
+type Option func(conf *HyparviewConfig)
+
+// Timers groups the protocol's time settings, zero durations keep the defaults.
+type Timers struct {
+	Join        time.Duration
+	MinShuffle  time.Duration
+	Debug       time.Duration
+	DialTimeout time.Duration
+}
+
+func NewConfig(host string, port int, opts ...Option) (*HyparviewConfig, error) {
+	conf := &HyparviewConfig{
+		DialTimeoutMiliseconds:         7000,
+		LogFolder:                      "/tmp/logs/",
+		JoinTimeSeconds:                5,
+		ActiveViewSize:                 5,
+		PassiveViewSize:                25,
+		ARWL:                           3,
+		PRWL:                           6,
+		Ka:                             2,
+		Kp:                             3,
+		MinShuffleTimerDurationSeconds: 8,
+		DebugTimerDurationSeconds:      5,
+		BootstrapConfig:                BootstrapConfig{BootstrapStrategy: BootstrapFirst},
+	}
+	conf.SelfPeer.Host = host
+	conf.SelfPeer.Port = port
+	for _, opt := range opts {
+		opt(conf)
+	}
+	if err := ValidateConfig(conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// ValidateConfig checks a configuration. NewConfig calls it, and so do the daemon on startup and
+// WatchConfigFile's reloads, which keep the running config if the reloaded one is invalid.
+func ValidateConfig(conf *HyparviewConfig) error {
+	if net.ParseIP(conf.SelfPeer.Host) == nil {
+		return fmt.Errorf("invalid self host %s", conf.SelfPeer.Host)
+	}
+	if conf.SelfPeer.Port <= 0 || conf.SelfPeer.Port > 65535 {
+		return fmt.Errorf("invalid self port %d", conf.SelfPeer.Port)
+	}
+	if err := validateReloadableConfig(conf); err != nil {
+		return err
+	}
+	if conf.ARWL <= 0 || conf.PRWL <= 0 {
+		return errors.New("random walk lengths must be positive")
+	}
+	if conf.Ka < 0 || conf.Ka > conf.ActiveViewSize {
+		return errors.New("ka must be between 0 and activeViewSize")
+	}
+	if conf.Kp < 0 || conf.Kp > conf.PassiveViewSize {
+		return errors.New("kp must be between 0 and passiveViewSize")
+	}
+	if conf.JoinTimeSeconds < 0 || conf.DialTimeoutMiliseconds <= 0 {
+		return errors.New("joinTimeSeconds must not be negative and dialTimeoutMiliseconds must be positive")
+	}
+	if err := validateDialTimeoutOverrides(conf); err != nil {
+		return err
+	}
+	if err := validateShadowPolicy(conf); err != nil {
+		return err
+	}
+	switch conf.BootstrapStrategy {
+	case "", BootstrapFirst, BootstrapRandom, BootstrapRoundRobin, BootstrapParallel:
+	default:
+		return fmt.Errorf("unknown bootstrapStrategy %q", conf.BootstrapStrategy)
+	}
+	switch conf.BootstrapMembership {
+	case "", BootstrapMember, BootstrapJoinOnly:
+	default:
+		return fmt.Errorf("unknown bootstrapMembership %q", conf.BootstrapMembership)
+	}
+	if len(conf.ClusterToken) > maxClusterTokenLength {
+		return fmt.Errorf("clusterToken must not be longer than %d bytes", maxClusterTokenLength)
+	}
+	if conf.MaxPassiveOriginPercent < 0 || conf.MaxPassiveOriginPercent > 100 {
+		return errors.New("maxPassiveOriginPercent must be between 0 and 100")
+	}
+	if conf.MaxActionsPerSecond < 0 {
+		return errors.New("maxActionsPerSecond must not be negative")
+	}
+	if conf.CircuitBreakerFailures < 0 || conf.CircuitBreakerCooldownSeconds < 0 {
+		return errors.New("circuitBreakerFailures and circuitBreakerCooldownSeconds must not be negative")
+	}
+	if conf.MaxPassiveViewSize != 0 && conf.MaxPassiveViewSize < conf.PassiveViewSize {
+		return errors.New("maxPassiveViewSize must be 0 or at least passiveViewSize")
+	}
+	if conf.PassiveGrowChurnPerMinute < 0 {
+		return errors.New("passiveGrowChurnPerMinute must not be negative")
+	}
+	if conf.MetricsPort < 0 || conf.MetricsPort > 65535 {
+		return fmt.Errorf("invalid metricsPort %d", conf.MetricsPort)
+	}
+	return nil
+}
+
+func WithActiveViewSize(size int) Option {
+	return func(conf *HyparviewConfig) { conf.ActiveViewSize = size }
+}
+
+func WithPassiveViewSize(size int) Option {
+	return func(conf *HyparviewConfig) { conf.PassiveViewSize = size }
+}
+
+// WithRandomWalks sets the active and passive random walk lengths.
+func WithRandomWalks(arwl, prwl int) Option {
+	return func(conf *HyparviewConfig) {
+		conf.ARWL = arwl
+		conf.PRWL = prwl
+	}
+}
+
+// WithShuffleSample sets the number of active and passive view members sent in each shuffle.
+func WithShuffleSample(ka, kp int) Option {
+	return func(conf *HyparviewConfig) {
+		conf.Ka = ka
+		conf.Kp = kp
+	}
+}
+
+// WithBootstrap adds a bootstrap node, it can be repeated.
+func WithBootstrap(host string, port int) Option {
+	return func(conf *HyparviewConfig) {
+		conf.BootstrapPeers = append(conf.BootstrapPeers, struct {
+			Port          int    `yaml:"port"`
+			Host          string `yaml:"host"`
+			AnalyticsPort int    `yaml:"analyticsPort"`
+		}{Port: port, Host: host})
+	}
+}
+
+// WithStandbyBootstrap adds a standby bootstrap node, it can be repeated.
+func WithStandbyBootstrap(host string, port int) Option {
+	return func(conf *HyparviewConfig) {
+		conf.StandbyBootstrapPeers = append(conf.StandbyBootstrapPeers, struct {
+			Port          int    `yaml:"port"`
+			Host          string `yaml:"host"`
+			AnalyticsPort int    `yaml:"analyticsPort"`
+		}{Port: port, Host: host})
+	}
+}
+
+func WithBootstrapStrategy(strategy string, fanout int) Option {
+	return func(conf *HyparviewConfig) {
+		conf.BootstrapStrategy = strategy
+		conf.BootstrapFanout = fanout
+	}
+}
+
+func WithTimers(timers Timers) Option {
+	return func(conf *HyparviewConfig) {
+		if timers.Join != 0 {
+			conf.JoinTimeSeconds = int(timers.Join / time.Second)
+		}
+		if timers.MinShuffle != 0 {
+			conf.MinShuffleTimerDurationSeconds = int(timers.MinShuffle / time.Second)
+		}
+		if timers.Debug != 0 {
+			conf.DebugTimerDurationSeconds = int(timers.Debug / time.Second)
+		}
+		if timers.DialTimeout != 0 {
+			conf.DialTimeoutMiliseconds = int(timers.DialTimeout / time.Millisecond)
+		}
+	}
+}
+
+func WithLogFolder(folder string) Option {
+	return func(conf *HyparviewConfig) { conf.LogFolder = folder }
+}
+
+func WithLogLevel(level string) Option {
+	return func(conf *HyparviewConfig) { conf.LogLevel = level }
+}
+
+func WithFailureDomain(domain string) Option {
+	return func(conf *HyparviewConfig) { conf.FailureDomain = domain }
+}
+
+func WithOverlayID(overlayID string) Option {
+	return func(conf *HyparviewConfig) { conf.OverlayID = overlayID }
+}
+
+func WithClusterToken(token string) Option {
+	return func(conf *HyparviewConfig) { conf.ClusterToken = token }
+}
+
+func WithCyclonShuffle() Option {
+	return func(conf *HyparviewConfig) { conf.CyclonShuffle = true }
+}
+
+func WithStrictPaper() Option {
+	return func(conf *HyparviewConfig) { conf.StrictPaper = true }
+}
+
+// WithClock replaces the wall clock the node measures its deadlines, windows and ages with.
+func WithClock(now func() time.Time) Option {
+	return func(conf *HyparviewConfig) { conf.Clock = now }
+}
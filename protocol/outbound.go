@@ -0,0 +1,28 @@
+package protocol
+
+import "github.com/nm-morais/go-babel/pkg/peer"
+
+// Nodes in outbound-only mode cannot accept inbound connections, they advertise it in Join and Neighbour
+// messages and are kept only in active views, where they maintain the links by dialing out themselves.
+
+func (h *Hyparview) setOutboundOnly(p peer.Peer, outboundOnly bool) {
+	if outboundOnly {
+		h.outboundOnlyPeers[p.String()] = true
+		return
+	}
+	delete(h.outboundOnlyPeers, p.String())
+}
+
+func (h *Hyparview) isDialable(p peer.Peer) bool {
+	return !h.outboundOnlyPeers[p.String()]
+}
+
+func (h *Hyparview) dialableOnly(peers []peer.Peer) []peer.Peer {
+	dialable := make([]peer.Peer, 0, len(peers))
+	for _, p := range peers {
+		if h.isDialable(p) {
+			dialable = append(dialable, p)
+		}
+	}
+	return dialable
+}
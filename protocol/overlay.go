@@ -0,0 +1,38 @@
+package protocol
+
+import (
+	"hash/fnv"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// With OverlayID set, joins, neighbour requests, shuffles and Cyclon shuffle replies carry a hash of it, and nodes only accept
+// those coming from nodes configured with the same OverlayID. A node pointed at the bootstrap nodes of
+// another environment (e.g. staging nodes given the production bootstrap list) is rejected instead of
+// silently merging both overlays. Nodes without an OverlayID neither send nor expect one.
+
+func overlayIDHash(overlayID string) uint32 {
+	if overlayID == "" {
+		return 0
+	}
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(overlayID))
+	// 0 means no overlay ID
+	if sum := hash.Sum32(); sum != 0 {
+		return sum
+	}
+	return 1
+}
+
+func (h *Hyparview) overlayID() uint32 {
+	return overlayIDHash(h.conf.OverlayID)
+}
+
+func (h *Hyparview) sameOverlay(sender peer.Peer, overlayID uint32, what string) bool {
+	if overlayID == h.overlayID() {
+		return true
+	}
+	h.overlayMismatches++
+	h.logger.Warnf("Dropping %s from %s: overlay ID %08x does not match ours (%08x)", what, sender.String(), overlayID, h.overlayID())
+	return false
+}
@@ -0,0 +1,121 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// babel does not expose the length of the protocol's event queue, so it is estimated from the lag of
+// a probe timer, which waits in the same queue as messages: by Little's law the depth of the queue
+// is the rate at which events are handled times the time they spend waiting. With ShedPolicy set to
+// shufflesFirst, a node whose lag exceeds OverloadLagMillis stops forwarding shuffles, answering them
+// with its passive view instead, and past twice that lag drops shuffle and passive view exchanges
+// altogether. Joins, neighbour requests, disconnects and every other message are never shed, so an
+// overloaded node loses passive view freshness before it loses active view links.
+
+// OverloadConfig decides what an overloaded node sheds.
+type OverloadConfig struct {
+	ShedPolicy        string `yaml:"shedPolicy"`
+	OverloadLagMillis int    `yaml:"overloadLagMillis"`
+}
+
+// overloadState holds the event queue estimate.
+type overloadState struct {
+	eventQueue    EventQueueStats
+	eventsHandled int
+	lastLoadProbe time.Time
+}
+
+const (
+	ShedNone          = "none"
+	ShedShufflesFirst = "shufflesFirst"
+
+	loadProbePeriod          = 100 * time.Millisecond
+	defaultOverloadLagMillis = 200
+)
+
+const (
+	shedLevelNone = iota
+	shedLevelShuffleForwards
+	shedLevelExchanges
+)
+
+type EventQueueStats struct {
+	Lag            time.Duration  `json:"lag"`
+	EstimatedDepth int            `json:"estimatedDepth"`
+	ShedLevel      int            `json:"shedLevel"`
+	Shed           map[string]int `json:"shed"`
+}
+
+func (h *Hyparview) overloadLag() time.Duration {
+	if h.conf.OverloadLagMillis > 0 {
+		return time.Duration(h.conf.OverloadLagMillis) * time.Millisecond
+	}
+	return defaultOverloadLagMillis * time.Millisecond
+}
+
+func (h *Hyparview) HandleLoadProbeTimer(t timer.Timer) {
+	now := h.timeNow()
+	if tracked, ok := h.scheduledTimers[LoadProbeTimerID]; ok {
+		h.eventQueue.Lag = tracked.Lag
+	}
+	if !h.lastLoadProbe.IsZero() {
+		rate := float64(h.eventsHandled) / now.Sub(h.lastLoadProbe).Seconds()
+		h.eventQueue.EstimatedDepth = int(rate * h.eventQueue.Lag.Seconds())
+	}
+	h.lastLoadProbe = now
+	h.eventsHandled = 0
+
+	h.eventQueue.ShedLevel = shedLevelNone
+	if h.conf.ShedPolicy == ShedShufflesFirst {
+		switch {
+		case h.eventQueue.Lag >= 2*h.overloadLag():
+			h.eventQueue.ShedLevel = shedLevelExchanges
+		case h.eventQueue.Lag >= h.overloadLag():
+			h.eventQueue.ShedLevel = shedLevelShuffleForwards
+		}
+	}
+}
+
+func (h *Hyparview) shedShuffleForward() bool {
+	if h.eventQueue.ShedLevel < shedLevelShuffleForwards {
+		return false
+	}
+	h.eventQueue.Shed["ShuffleForward"]++
+	return true
+}
+
+func (h *Hyparview) shedMessage(m message.Message) bool {
+	if h.eventQueue.ShedLevel < shedLevelExchanges {
+		return false
+	}
+	switch m.(type) {
+	case ShuffleMessage, ShuffleReplyMessage, CompactShuffleMessage, CompactShuffleReplyMessage,
+		CyclonShuffleMessage, CyclonShuffleReplyMessage, PassiveViewRequestMessage, PassiveViewReplyMessage:
+		h.eventQueue.Shed[reflect.TypeOf(m).Name()]++
+		return true
+	default:
+		return false
+	}
+}
+
+func (h *Hyparview) eventQueueSnapshot() EventQueueStats {
+	stats := h.eventQueue
+	stats.Shed = make(map[string]int, len(h.eventQueue.Shed))
+	for k, v := range h.eventQueue.Shed {
+		stats.Shed[k] = v
+	}
+	return stats
+}
+
+func (h *Hyparview) logEventQueue() {
+	toPrint, err := json.Marshal(h.eventQueue)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("eventQueue", "%s", string(toPrint))
+}
@@ -0,0 +1,50 @@
+package protocol
+
+import "github.com/nm-morais/go-babel/pkg/peer"
+
+// Passive view members learned from another node (shuffles, forward joins, disconnect and reject
+// samples, ...) remember the neighbour which relayed them. With MaxPassiveOriginPercent set, no
+// origin may account for more than that share of the passive view, so a single neighbour relaying
+// many addresses, honest or not, cannot dominate future promotions. Peers past the share are not
+// added, and existing members are not evicted to make room for them. Peers the node learned about
+// directly (demoted neighbours, inbound connections, imports) have no origin and are never capped.
+
+// passiveOriginLimit returns how many passive view members a single origin may account for, at least
+// one, or 0 if unlimited.
+func (h *Hyparview) passiveOriginLimit() int {
+	if h.conf.MaxPassiveOriginPercent <= 0 || h.conf.MaxPassiveOriginPercent >= 100 {
+		return 0
+	}
+	limit := h.passiveView.capacity * h.conf.MaxPassiveOriginPercent / 100
+	if limit < 1 {
+		limit = 1
+	}
+	return limit
+}
+
+// passiveOriginFull returns true, counting it, if origin already accounts for its whole share of
+// the passive view.
+func (h *Hyparview) passiveOriginFull(origin peer.Peer) bool {
+	limit := h.passiveOriginLimit()
+	if limit == 0 || origin == nil {
+		return false
+	}
+	learned := 0
+	for _, p := range h.passiveView.asArr {
+		if p.origin == origin.String() {
+			learned++
+		}
+	}
+	if learned < limit {
+		return false
+	}
+	h.passiveOriginCapped++
+	return true
+}
+
+func originString(origin peer.Peer) string {
+	if origin == nil {
+		return ""
+	}
+	return origin.String()
+}
@@ -0,0 +1,117 @@
+package protocol
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+const (
+	PeerFormatJSON = "json"
+	PeerFormatText = "text" // one host:port per line, blank lines and lines starting with # are skipped
+)
+
+type ExportedPeer struct {
+	Host          string `json:"host"`
+	Port          uint16 `json:"port"`
+	AnalyticsPort uint16 `json:"analyticsPort,omitempty"`
+	View          string `json:"view,omitempty"`
+}
+
+// ImportPeers reads a peer list in the given format and adds the peers to the passive view.
+func (h *Hyparview) ImportPeers(r io.Reader, format string) error {
+	var exported []ExportedPeer
+	switch format {
+	case PeerFormatJSON:
+		if err := json.NewDecoder(r).Decode(&exported); err != nil {
+			return err
+		}
+	case PeerFormatText:
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			host, portStr, err := net.SplitHostPort(line)
+			if err != nil {
+				return err
+			}
+			port, err := strconv.ParseUint(portStr, 10, 16)
+			if err != nil {
+				return fmt.Errorf("invalid port in %s: %w", line, err)
+			}
+			exported = append(exported, ExportedPeer{Host: host, Port: uint16(port)})
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown peer list format %s", format)
+	}
+
+	peers := make([]peer.Peer, 0, len(exported))
+	for _, p := range exported {
+		ip := net.ParseIP(p.Host)
+		if ip == nil {
+			return fmt.Errorf("invalid host %s", p.Host)
+		}
+		peers = append(peers, peer.NewPeer(ip, p.Port, p.AnalyticsPort))
+	}
+	h.onProtocol("ImportPeers", func() { h.importPeers(peers) })
+	return nil
+}
+
+// ExportPeers writes the active and passive views in the given format, it blocks until the protocol
+// goroutine takes the snapshot.
+func (h *Hyparview) ExportPeers(w io.Writer, format string) error {
+	if format != PeerFormatJSON && format != PeerFormatText {
+		return fmt.Errorf("unknown peer list format %s", format)
+	}
+	exportedCh := make(chan []ExportedPeer, 1)
+	h.onProtocol("ExportPeers", func() { exportedCh <- h.exportPeers() })
+	exported := <-exportedCh
+
+	if format == PeerFormatJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(exported)
+	}
+	for _, p := range exported {
+		if _, err := fmt.Fprintln(w, net.JoinHostPort(p.Host, strconv.Itoa(int(p.Port)))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Hyparview) importPeers(peers []peer.Peer) {
+	h.logger.Infof("Importing %d peers into passive view", len(peers))
+	for _, p := range peers {
+		if h.isSelf(p) || h.isBlacklisted(p) {
+			continue
+		}
+		h.addPeerToPassiveView(p)
+	}
+}
+
+func (h *Hyparview) exportPeers() []ExportedPeer {
+	exported := make([]ExportedPeer, 0, h.activeView.size()+h.passiveView.size())
+	for _, view := range []*View{h.activeView, h.passiveView} {
+		for _, p := range view.asArr {
+			exported = append(exported, ExportedPeer{
+				Host:          p.IP().String(),
+				Port:          p.ProtosPort(),
+				AnalyticsPort: p.AnalyticsPort(),
+				View:          view.id.String(),
+			})
+		}
+	}
+	return exported
+}
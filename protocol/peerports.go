@@ -0,0 +1,30 @@
+package protocol
+
+import (
+	"net"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Analytics ports only mean something to deployments running the analytics sidecar next to each
+// node. With PeerPorts set to protocolOnly, the analyticsPort fields of the config are ignored and
+// not logged, so deployments without the sidecar need not configure fake ones. babel itself still
+// encodes an analytics port (0) with every peer, but compact peer lists leave zero analytics ports
+// out when sent to nodes advertising CapOptionalAnalyticsPorts.
+
+const (
+	PeerPortsAnalytics    = "analytics"
+	PeerPortsProtocolOnly = "protocolOnly"
+)
+
+func analyticsPortsEnabled(conf *HyparviewConfig) bool {
+	return conf.PeerPorts != PeerPortsProtocolOnly
+}
+
+// configuredPeer builds the peer described by a config entry.
+func configuredPeer(conf *HyparviewConfig, host string, port, analyticsPort int) peer.Peer {
+	if !analyticsPortsEnabled(conf) {
+		analyticsPort = 0
+	}
+	return peer.NewPeer(net.ParseIP(host), uint16(port), uint16(analyticsPort))
+}
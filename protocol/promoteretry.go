@@ -0,0 +1,39 @@
+package protocol
+
+import "github.com/nm-morais/go-babel/pkg/peer"
+
+// A promotion cycle starts when the promote timer or a neighbour going down sends a neighbour request
+// to a passive view member. With NeighbourRetries set, a rejected request is immediately retried with
+// another candidate not yet tried in the cycle, up to NeighbourRetries times, instead of waiting for the
+// next promote period per attempt.
+
+type promotionCycle struct {
+	tried   map[string]bool
+	retries int
+}
+
+func (h *Hyparview) promote(candidate peer.Peer) {
+	h.promotionCycle = &promotionCycle{tried: map[string]bool{candidate.String(): true}}
+	h.sendNeighbourMessage(candidate)
+}
+
+func (h *Hyparview) retryPromotion(rejectedBy peer.Peer) {
+	cycle := h.promotionCycle
+	if cycle == nil || !cycle.tried[rejectedBy.String()] || h.activeView.isFull() {
+		return
+	}
+	if cycle.retries >= h.conf.NeighbourRetries {
+		h.logger.Warnf("Neighbour request rejected by %s, no retries left in this promotion cycle", rejectedBy.String())
+		h.promotionCycle = nil
+		return
+	}
+	candidate := h.pickPromotionCandidateExcept(cycle.tried)
+	if candidate == nil {
+		h.promotionCycle = nil
+		return
+	}
+	cycle.retries++
+	cycle.tried[candidate.String()] = true
+	h.logger.Infof("Neighbour request rejected by %s, retrying with %s (%d/%d)", rejectedBy.String(), candidate.String(), cycle.retries, h.conf.NeighbourRetries)
+	h.sendNeighbourMessage(candidate)
+}
@@ -2,11 +2,10 @@ package protocol
 
 import (
 	"encoding/json"
-	"fmt"
 	"math"
 	"math/rand"
-	"net"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/nm-morais/go-babel/pkg/errors"
@@ -16,6 +15,7 @@ import (
 	"github.com/nm-morais/go-babel/pkg/protocol"
 	"github.com/nm-morais/go-babel/pkg/protocolManager"
 	"github.com/nm-morais/go-babel/pkg/timer"
+	"github.com/nm-morais/x-bot/protocol/registry"
 	"github.com/sirupsen/logrus"
 )
 
@@ -35,6 +35,14 @@ type HyparviewConfig struct {
 		Host          string `yaml:"host"`
 		AnalyticsPort int    `yaml:"analyticsPort"`
 	} `yaml:"bootstrapPeers"`
+	DialTimeoutOverrides []struct {
+		Destination  string `yaml:"destination"`
+		Milliseconds int    `yaml:"milliseconds"`
+	} `yaml:"dialTimeoutOverrides"`
+	ShadowPolicy *struct {
+		PromotionRecencyBias int    `yaml:"promotionRecencyBias"`
+		DropPolicy           string `yaml:"dropPolicy"`
+	} `yaml:"shadowPolicy"`
 
 	DialTimeoutMiliseconds         int    `yaml:"dialTimeoutMiliseconds"`
 	LogFolder                      string `yaml:"logFolder"`
@@ -47,55 +55,252 @@ type HyparviewConfig struct {
 	Kp                             int    `yaml:"kp"`
 	MinShuffleTimerDurationSeconds int    `yaml:"minShuffleTimerDurationSeconds"`
 	DebugTimerDurationSeconds      int    `yaml:"debugTimerDurationSeconds"`
+	CyclonShuffle                  bool   `yaml:"cyclonShuffle"`
+	MaxForwardJoinTTL              int    `yaml:"maxForwardJoinTTL"`
+	MaxShuffleTTL                  int    `yaml:"maxShuffleTTL"`
+	OutboundOnly                   bool   `yaml:"outboundOnly"`
+	FailureDomain                  string `yaml:"failureDomain"`
+	CompactPeerLists               bool   `yaml:"compactPeerLists"`
+	MaxJoinsPerSecond              int    `yaml:"maxJoinsPerSecond"`
+	LogLevel                       string `yaml:"logLevel"`
+	RemovePeerOnHandlerPanic       bool   `yaml:"removePeerOnHandlerPanic"`
+	StrictPaper                    bool   `yaml:"strictPaper"`
+	SelfAddressPolicy              string `yaml:"selfAddressPolicy"`
+	BlacklistFile                  string `yaml:"blacklistFile"`
+	JoinFullPolicy                 string `yaml:"joinFullPolicy"`
+	TimeSyncHints                  bool   `yaml:"timeSyncHints"`
+	DebugPort                      int    `yaml:"debugPort"`
+	IncarnationFile                string `yaml:"incarnationFile"`
+	MaxShuffleForwardsPerSecond    int    `yaml:"maxShuffleForwardsPerSecond"`
+	MessageTracing                 bool   `yaml:"messageTracing"`
+	OverlayID                      string `yaml:"overlayID"`
+	PeerPorts                      string `yaml:"peerPorts"`
+	NeighbourRetries               int    `yaml:"neighbourRetries"`
+	LearnInboundPeers              bool   `yaml:"learnInboundPeers"`
+	FaultInjection                 bool   `yaml:"faultInjection"`
+	WatchdogSeconds                int    `yaml:"watchdogSeconds"`
+	ClusterToken                   string `yaml:"clusterToken"`
+	AnalyticsLogFile               string `yaml:"analyticsLogFile"`
+	MaxPassiveOriginPercent        int    `yaml:"maxPassiveOriginPercent"`
+	MaxActionsPerSecond            int    `yaml:"maxActionsPerSecond"`
+	MaxInDegree                    int    `yaml:"maxInDegree"`
+	LeaveHandoff                   bool   `yaml:"leaveHandoff"`
+	MetricsPort                    int    `yaml:"metricsPort"`
+	AdminSecret                    string `yaml:"adminSecret"`
+
+	// IDs of co-hosted protocols whose connections to this node are accepted
+	AllowedForeignProtocols []uint16 `yaml:"allowedForeignProtocols"`
+
+	// clock of the node, the wall clock if nil, see clock.go
+	Clock func() time.Time `yaml:"-"`
+
+	// settings of the larger features, inlined so that their YAML keys stay at the top level
+	BootstrapConfig       `yaml:",inline"`
+	StandbyConfig         `yaml:",inline"`
+	DiscoveryConfig       `yaml:",inline"`
+	JoinConfig            `yaml:",inline"`
+	TelemetryConfig       `yaml:",inline"`
+	LatencyConfig         `yaml:",inline"`
+	DiversityConfig       `yaml:",inline"`
+	ConfigGossipConfig    `yaml:",inline"`
+	LinkHealthConfig      `yaml:",inline"`
+	DialBackConfig        `yaml:",inline"`
+	VerifyConfig          `yaml:",inline"`
+	SideStreamConfig      `yaml:",inline"`
+	OverloadConfig        `yaml:",inline"`
+	IsolationConfig       `yaml:",inline"`
+	VersionConfig         `yaml:",inline"`
+	BandwidthConfig       `yaml:",inline"`
+	LivenessConfig        `yaml:",inline"`
+	FragmentConfig        `yaml:",inline"`
+	ViewHistoryConfig     `yaml:",inline"`
+	PeerExchangeConfig    `yaml:",inline"`
+	CircuitBreakerConfig  `yaml:",inline"`
+	AdaptivePassiveConfig `yaml:",inline"`
+	MetadataConfig        `yaml:",inline"`
 }
 type Hyparview struct {
 	babel                 protocolManager.ProtocolManager
 	lastShuffleMsg        *ShuffleMessage
+	lastCyclonShuffleMsg  *CyclonShuffleMessage
 	timeStart             time.Time
 	logger                *logrus.Logger
 	conf                  *HyparviewConfig
+	clock                 func() time.Time
 	selfIsBootstrap       bool
 	bootstrapNodes        []peer.Peer
 	danglingNeighCounters map[string]int
+	outboundOnlyPeers     map[string]bool
+	epoch                 uint64
+	incarnation           uint64
+	lastTimerRuns         map[timer.ID]time.Time
+	messageTaps           []MessageTap
+	isolation             *isolationState
+	viewChanges           int
+	promotionCycle        *promotionCycle
+	knownVersions         map[string]uint16
+	noShufflesUntil       time.Time
+	overlayMismatches     int
+	tokenMismatches       int
+	passiveOriginCapped   int
+	lastMaintenanceTick   time.Time
+	shadowStats           ShadowStats
+	frozenUntil           time.Time
+	viewHistory           []ViewHistoryEntry
+	latency               *latencyMatrix
+	exchangedPeers        []peer.Peer
+	churn                 []time.Time
+	lifecycle             *lifecycle
+	metrics               *metrics
+	passiveResizedAt      time.Time
+	pendingTraces         map[uint32]pendingTrace
+	standbyBootstraps     []peer.Peer
+	left                  chan struct{}
+	leaveFlushed          chan struct{}
+	handlerPanics         map[string]int
+	bandwidthProbes       map[string]*bandwidthProbeReception
+	livenessStats         LivenessStats
+	viewVersion           uint64
+	analyticsLog          *analyticsLog
+	decommission          *decommissionState
+	selfAddressSeen       int
+	events                []Event
+
+	// state of the larger features, declared in their own files
+	bootstrapState
+	dnsBootstrapState
+	discoveryState
+	joinState
+	shuffleSeqState
+	rejectState
+	hookState
+	verifyState
+	sideStreamState
+	watchdogState
+	shapingState
+	actionState
+	evictionState
+	inDegreeState
+	subscriptionState
+	metadataState
+	breakerState
+	overloadState
+	lifetimeState
+	reloadState
+	configGossipState
+	blacklistState
+	foreignConnState
+	fragmentState
+	scheduleState
 	*HyparviewState
 }
 
 func NewHyparviewProtocol(babel protocolManager.ProtocolManager, conf *HyparviewConfig) protocol.Protocol {
 	logger := logs.NewLogger(name)
+	if conf.StrictPaper {
+		applyStrictPaper(conf)
+	}
+	if conf.LogLevel != "" {
+		level, err := logrus.ParseLevel(conf.LogLevel)
+		if err != nil {
+			panic(err)
+		}
+		logger.SetLevel(level)
+	}
 	selfIsBootstrap := false
-	bootstrapNodes := []peer.Peer{}
-	for _, p := range conf.BootstrapPeers {
-		boostrapNode := peer.NewPeer(net.ParseIP(p.Host), uint16(p.Port), uint16(p.AnalyticsPort))
-		bootstrapNodes = append(bootstrapNodes, boostrapNode)
+	bootstrapNodes, dnsBootstraps := splitBootstraps(conf)
+	for _, boostrapNode := range bootstrapNodes {
 		if peer.PeersEqual(babel.SelfPeer(), boostrapNode) {
 			selfIsBootstrap = true
-			break
 		}
 	}
 	logger.Infof("Starting with selfPeer:= %+v", babel.SelfPeer())
-	logger.Infof("%+v", babel.SelfPeer().AnalyticsPort())
-	for _, b := range bootstrapNodes {
-		logger.Infof("%+v", b.AnalyticsPort())
+	if analyticsPortsEnabled(conf) {
+		logger.Infof("%+v", babel.SelfPeer().AnalyticsPort())
+		for _, b := range bootstrapNodes {
+			logger.Infof("%+v", b.AnalyticsPort())
+		}
+	}
+	standbyBootstraps := []peer.Peer{}
+	for _, p := range conf.StandbyBootstrapPeers {
+		standbyBootstraps = append(standbyBootstraps, configuredPeer(conf, p.Host, p.Port, p.AnalyticsPort))
 	}
 	logger.Infof("Starting with bootstraps:= %+v", bootstrapNodes)
+	logger.Infof("Starting with standby bootstraps:= %+v", standbyBootstraps)
 	logger.Infof("Starting with selfIsBootstrap:= %+v", selfIsBootstrap)
+	discovery, discoveryRefresh := newDiscoveryProvider(conf, babel.SelfPeer())
+	configAdminKey, configAdminPrivateKey, err := loadConfigAdminKeys(conf)
+	if err != nil {
+		panic(err)
+	}
+	clock := conf.Clock
+	if clock == nil {
+		clock = time.Now
+	}
 	return &Hyparview{
 		babel:          babel,
 		lastShuffleMsg: nil,
 		timeStart:      time.Time{},
 		logger:         logger,
 		conf:           conf,
+		clock:          clock,
 
 		bootstrapNodes:        bootstrapNodes,
+		standbyBootstraps:     standbyBootstraps,
 		selfIsBootstrap:       selfIsBootstrap,
 		danglingNeighCounters: make(map[string]int),
+		lifecycle:             newLifecycle(clock()),
+		outboundOnlyPeers:     make(map[string]bool),
+		handlerPanics:         make(map[string]int),
+		bandwidthProbes:       make(map[string]*bandwidthProbeReception),
+		pendingTraces:         make(map[uint32]pendingTrace),
+		left:                  make(chan struct{}),
+		leaveFlushed:          make(chan struct{}),
+		lastTimerRuns:         make(map[timer.ID]time.Time),
+		knownVersions:         make(map[string]uint16),
+		bootstrapState: bootstrapState{
+			bootstrapStats: &BootstrapStats{
+				Contacted:      map[string]int{},
+				FirstResponder: map[string]int{},
+			},
+		},
+		dnsBootstrapState: dnsBootstrapState{
+			staticBootstraps: bootstrapNodes,
+			dnsBootstraps:    dnsBootstraps,
+		},
+		discoveryState: discoveryState{
+			discovery:        discovery,
+			discoveryRefresh: discoveryRefresh,
+		},
+		joinState:         joinState{joined: make(chan struct{})},
+		verifyState:       verifyState{verifyingPeers: make(map[string]bool), verifiedPeers: make(map[string]time.Time)},
+		actionState:       actionState{actions: newActionQueue()},
+		evictionState:     evictionState{evictions: make(map[string]eviction), heldDown: make(map[string]time.Time)},
+		inDegreeState:     inDegreeState{maintainers: make(map[string]time.Time)},
+		subscriptionState: subscriptionState{subscriptions: make(map[*subscription]struct{})},
+		overloadState:     overloadState{eventQueue: EventQueueStats{Shed: map[string]int{}}},
+		lifetimeState:     lifetimeState{peerLifetimes: newPeerLifetimeStats()},
+		breakerState:      breakerState{breakers: make(map[string]*circuitBreaker)},
+		configGossipState: configGossipState{
+			configAdminKey:        configAdminKey,
+			configAdminPrivateKey: configAdminPrivateKey,
+		},
+		blacklistState: blacklistState{
+			blacklist:         make(map[string]*blacklistEntry),
+			seenBlacklistMsgs: make(map[uint64]time.Time),
+		},
+		foreignConnState: foreignConnState{deniedForeignConns: make(map[protocol.ID]int)},
+		scheduleState:    scheduleState{scheduledTimers: make(map[timer.ID]*ScheduledTimer)},
+		fragmentState:    fragmentState{shuffleAssemblies: make(map[string]*shuffleAssembly)},
 		HyparviewState: &HyparviewState{
 			activeView: &View{
+				id:       ActiveView,
 				capacity: conf.ActiveViewSize,
 				asArr:    []*PeerState{},
 				asMap:    map[string]*PeerState{},
 			},
 			passiveView: &View{
+				id:       PassiveView,
 				capacity: conf.PassiveViewSize,
 				asArr:    []*PeerState{},
 				asMap:    map[string]*PeerState{},
@@ -117,103 +322,308 @@ func (h *Hyparview) Logger() *logrus.Logger {
 }
 
 func (h *Hyparview) Init() {
-	h.babel.RegisterTimerHandler(protoID, ShuffleTimerID, h.HandleShuffleTimer)
-	h.babel.RegisterTimerHandler(protoID, PromoteTimerID, h.HandlePromoteTimer)
-	h.babel.RegisterTimerHandler(protoID, DebugTimerID, h.HandleDebugTimer)
-	h.babel.RegisterTimerHandler(protoID, MaintenanceTimerID, h.HandleMaintenanceTimer)
-
-	h.babel.RegisterMessageHandler(protoID, JoinMessage{}, h.HandleJoinMessage)
-	h.babel.RegisterMessageHandler(protoID, ForwardJoinMessage{}, h.HandleForwardJoinMessage)
-	h.babel.RegisterMessageHandler(protoID, ForwardJoinMessageReply{}, h.HandleForwardJoinMessageReply)
-	h.babel.RegisterMessageHandler(protoID, ShuffleMessage{}, h.HandleShuffleMessage)
-	h.babel.RegisterMessageHandler(protoID, ShuffleReplyMessage{}, h.HandleShuffleReplyMessage)
-	h.babel.RegisterMessageHandler(protoID, NeighbourMessage{}, h.HandleNeighbourMessage)
-	h.babel.RegisterMessageHandler(protoID, NeighbourMaintenanceMessage{}, h.HandleNeighbourMaintenanceMessage)
-	h.babel.RegisterMessageHandler(protoID, NeighbourMessageReply{}, h.HandleNeighbourReplyMessage)
-	h.babel.RegisterMessageHandler(protoID, DisconnectMessage{}, h.HandleDisconnectMessage)
+	err := registry.Declare(name,
+		registry.Range{Kind: registry.Message, From: 1500, To: 1599},
+		registry.Range{Kind: registry.Timer, From: 1500, To: 1599},
+		registry.Range{Kind: registry.Notification, From: 10500, To: 10599},
+	)
+	if err != nil {
+		panic(err)
+	}
+
+	h.registerTimerHandler(ShuffleTimerID, h.HandleShuffleTimer)
+	h.registerTimerHandler(PromoteTimerID, h.HandlePromoteTimer)
+	h.registerTimerHandler(DebugTimerID, h.HandleDebugTimer)
+	h.registerTimerHandler(MaintenanceTimerID, h.HandleMaintenanceTimer)
+	h.registerTimerHandler(JoinCompletionTimerID, h.HandleJoinCompletionTimer)
+	h.registerTimerHandler(MirrorTimerID, h.HandleMirrorTimer)
+	h.registerTimerHandler(ConfigReloadTimerID, h.HandleConfigReloadTimer)
+	h.registerTimerHandler(DrainTimerID, h.HandleDrainTimer)
+	h.registerTimerHandler(ActionTimerID, h.HandleActionTimer)
+	h.registerTimerHandler(ViewHistoryTimerID, h.HandleViewHistoryTimer)
+	h.registerTimerHandler(JoinReplyTimerID, h.HandleJoinReplyTimer)
+	h.registerTimerHandler(LoadProbeTimerID, h.HandleLoadProbeTimer)
+	h.registerRequestHandler(ViewsRequestType, h.HandleViewsRequest)
+	h.registerTimerHandler(JoinWindowTimerID, h.HandleJoinWindowTimer)
+	h.registerTimerHandler(BandwidthProbeTimerID, h.HandleBandwidthProbeTimer)
+	h.registerTimerHandler(DialTimeoutTimerID, h.HandleDialTimeoutTimer)
+	h.registerTimerHandler(ShuffleFragmentTimerID, h.HandleShuffleFragmentTimer)
+	h.registerTimerHandler(LatencyProbeTimerID, h.HandleLatencyProbeTimer)
+	h.registerTimerHandler(LatencyReportTimerID, h.HandleLatencyReportTimer)
+	h.registerTimerHandler(RunTimerID, h.HandleRunTimer)
+
+	h.registerMessageHandler(JoinMessage{}, h.HandleJoinMessage)
+	h.registerMessageHandler(ForwardJoinMessage{}, h.HandleForwardJoinMessage)
+	h.registerMessageHandler(ForwardJoinMessageReply{}, h.HandleForwardJoinMessageReply)
+	h.registerMessageHandler(ShuffleMessage{}, h.HandleShuffleMessage)
+	h.registerMessageHandler(ShuffleReplyMessage{}, h.HandleShuffleReplyMessage)
+	h.registerMessageHandler(NeighbourMessage{}, h.HandleNeighbourMessage)
+	h.registerMessageHandler(NeighbourMaintenanceMessage{}, h.HandleNeighbourMaintenanceMessage)
+	h.registerMessageHandler(NeighbourMessageReply{}, h.HandleNeighbourReplyMessage)
+	h.registerMessageHandler(DisconnectMessage{}, h.HandleDisconnectMessage)
+	h.registerMessageHandler(CyclonShuffleMessage{}, h.HandleCyclonShuffleMessage)
+	h.registerMessageHandler(CyclonShuffleReplyMessage{}, h.HandleCyclonShuffleReplyMessage)
+	h.registerMessageHandler(WalkTerminatedMessage{}, h.HandleWalkTerminatedMessage)
+	h.registerMessageHandler(CompactShuffleMessage{}, h.HandleCompactShuffleMessage)
+	h.registerMessageHandler(CompactShuffleReplyMessage{}, h.HandleCompactShuffleReplyMessage)
+	h.registerMessageHandler(JoinRejectMessage{}, h.HandleJoinRejectMessage)
+	h.registerMessageHandler(ViewSnapshotMessage{}, h.HandleViewSnapshotMessage)
+	h.registerMessageHandler(BlacklistMessage{}, h.HandleBlacklistMessage)
+	h.registerMessageHandler(RedirectMessage{}, h.HandleRedirectMessage)
+	h.registerMessageHandler(PassiveViewRequestMessage{}, h.HandlePassiveViewRequestMessage)
+	h.registerMessageHandler(PassiveViewReplyMessage{}, h.HandlePassiveViewReplyMessage)
+	h.registerMessageHandler(BandwidthProbeMessage{}, h.HandleBandwidthProbeMessage)
+	h.registerMessageHandler(BandwidthProbeReplyMessage{}, h.HandleBandwidthProbeReplyMessage)
+	h.registerMessageHandler(LivenessProbeMessage{}, h.HandleLivenessProbeMessage)
+	h.registerMessageHandler(LivenessProbeReplyMessage{}, h.HandleLivenessProbeReplyMessage)
+	h.registerMessageHandler(ShuffleFragmentMessage{}, h.HandleShuffleFragmentMessage)
+	h.registerMessageHandler(LatencyVectorMessage{}, h.HandleLatencyVectorMessage)
+	h.registerMessageHandler(MetadataMessage{}, h.HandleMetadataMessage)
+
+	if h.conf.MaxActivePerSubnet > 0 {
+		h.onBeforeAdd(ActiveView, h.subnetDiversityHook)
+	}
+	h.addJoinRejector(h.blacklistRejector)
+	h.recordViewEvents()
+	h.publishViewEvents()
+	h.trackChurn()
+	h.recordPeerLifetimes()
+	h.trackIsolationRecovery()
+	h.startMetrics()
 }
 
 func (h *Hyparview) Start() {
 	h.logger.Infof("Starting with confs: %+v", h.conf)
-	h.babel.RegisterTimer(h.ID(), ShuffleTimer{duration: 3 * time.Second})
-	h.babel.RegisterPeriodicTimer(h.ID(), PromoteTimer{duration: 7 * time.Second}, true)
-	h.babel.RegisterPeriodicTimer(h.ID(), DebugTimer{time.Duration(h.conf.DebugTimerDurationSeconds) * time.Second}, true)
-	h.babel.RegisterPeriodicTimer(h.ID(), MaintenanceTimer{1 * time.Second}, false)
+	h.openAnalyticsLog()
+	h.loadBlacklist()
+	h.loadIncarnation()
+	if len(h.conf.Metadata) > 0 {
+		h.setMetadata(copyAttributes(h.conf.Metadata))
+	}
+	h.initShuffleEpoch()
+	h.startSideStreamWorkers()
+	h.startWatchdog()
+	h.scheduleTimer(ShuffleTimer{duration: 3 * time.Second})
+	if !h.conf.StrictPaper {
+		h.schedulePeriodicTimer(PromoteTimer{duration: 7 * time.Second}, true)
+		h.schedulePeriodicTimer(MaintenanceTimer{1 * time.Second}, false)
+	}
+	h.debugTimerID = h.schedulePeriodicTimer(DebugTimer{time.Duration(h.conf.DebugTimerDurationSeconds) * time.Second}, true)
+	h.schedulePeriodicTimer(LoadProbeTimer{loadProbePeriod}, false)
+	if h.conf.BandwidthProbeSeconds > 0 {
+		h.schedulePeriodicTimer(BandwidthProbeTimer{time.Duration(h.conf.BandwidthProbeSeconds) * time.Second}, false)
+	}
+	if h.conf.ViewHistoryRetentionMinutes > 0 {
+		h.schedulePeriodicTimer(ViewHistoryTimer{h.viewHistoryPeriod()}, true)
+	}
+	if h.latencyCollectionEnabled() {
+		h.startLatencyCollection()
+	}
+	if h.selfIsBootstrap && len(h.standbyBootstraps) > 0 {
+		h.schedulePeriodicTimer(MirrorTimer{h.mirrorTimerDuration()}, false)
+	}
+	if h.confFilePath != "" {
+		h.schedulePeriodicTimer(ConfigReloadTimer{duration: configReloadTimerDuration}, false)
+	}
+	if h.discovery != nil {
+		h.startDiscovery()
+	}
+	if h.selfIsBootstrap {
+		h.timeStart = h.timeNow()
+		h.setPhase(PhaseStabilizing)
+		h.openJoinWindow()
+		h.startAsBootstrap()
+		return
+	}
+	if h.conf.JoinCompletionTimeoutSeconds > 0 {
+		h.babel.RegisterTimer(h.ID(), JoinCompletionTimer{time.Duration(h.conf.JoinCompletionTimeoutSeconds) * time.Second})
+	}
 	h.joinOverlay()
-	h.timeStart = time.Now()
+	h.timeStart = h.timeNow()
 }
 
-func (h *Hyparview) joinOverlay() {
-	if time.Since(h.timeStart) < time.Duration(h.conf.JoinTimeSeconds)*time.Second {
+func (h *Hyparview) joinOverlay() bool {
+	if h.stabilizing() {
 		h.logger.Infof("Not rejoining since not enough time has passed: %+v", h.conf.JoinTimeSeconds)
-		return
+		return false
 	}
+	if h.lifecycle.phase == PhaseIdle || h.lifecycle.phase == PhaseJoining {
+		h.joinSent(PhaseJoining)
+	} else {
+		h.joinSent(PhaseRejoining)
+	}
+	h.sendJoinToBootstrap()
+	return true
+}
 
+// rejoinOverlay is used when the node became totally isolated, on rejoining it starts a new epoch
+// so that upper layers know to discard state built upon pre-isolation neighbours.
+func (h *Hyparview) rejoinOverlay() bool {
+	if !h.joinOverlay() {
+		return false
+	}
+	h.epoch++
+	h.logger.Warnf("Rejoining overlay after isolation, epoch=%d", h.epoch)
+	h.babel.SendNotification(OverlayRejoinedNotification{
+		Epoch:       h.epoch,
+		ViewVersion: h.CurrentViewVersion(),
+	})
+	return true
+}
+
+func (h *Hyparview) sendJoinToBootstrap() {
+	if h.resolveBootstrapsFirst() {
+		return
+	}
 	if len(h.bootstrapNodes) == 0 {
+		if h.discovery != nil {
+			h.logger.Warn("No bootstrap nodes discovered yet, not joining")
+			return
+		}
+		if len(h.dnsBootstraps) > 0 {
+			h.logger.Warn("No bootstrap nodes resolved yet, not joining")
+			return
+		}
 		h.logger.Panic("No nodes to join overlay...")
 	}
-	for _, b := range h.bootstrapNodes {
-		if peer.PeersEqual(b, h.babel.SelfPeer()) {
-			continue
+	targets := h.selectBootstrapTargets()
+	h.bootstrapStats.JoinAttempts++
+	h.lastJoinAttempt = h.timeNow()
+	h.pendingBootstrapJoin = &pendingBootstrapJoin{
+		contacted: make(map[string]bool, len(targets)),
+		started:   h.timeNow(),
+	}
+	for _, b := range targets {
+		toSend := JoinMessage{
+			OutboundOnly: h.conf.OutboundOnly,
+			Incarnation:  h.incarnation,
+			TraceID:      h.newTraceID(),
+			OverlayID:    h.overlayID(),
+			ClusterToken: h.conf.ClusterToken,
+			Capabilities: h.localCapabilities(),
 		}
-		toSend := JoinMessage{}
-		h.logger.Info("Joining overlay...")
-		h.babel.SendMessageSideStream(toSend, b, b.ToTCPAddr(), protoID, protoID)
-		return
+		h.traceSent(traceJoin, b, toSend.TraceID)
+		h.logger.Infof("Joining overlay through %s (strategy=%s)...", b.String(), h.conf.BootstrapStrategy)
+		h.pendingBootstrapJoin.contacted[b.String()] = true
+		h.bootstrapStats.Contacted[b.String()]++
+		h.sendSideStream(toSend, b)
+		if h.conf.OutboundOnly {
+			// the forward join reply cannot reach us, keep the contact node as neighbour right away
+			h.addPeerToActiveView(b)
+		}
+	}
+	if len(targets) > 0 && !h.conf.StrictPaper {
+		h.babel.RegisterTimer(h.ID(), JoinReplyTimer{duration: h.joinReplyTimeout(), attempt: h.bootstrapStats.JoinAttempts})
 	}
 }
 
 func (h *Hyparview) InConnRequested(dialerProto protocol.ID, p peer.Peer) bool {
-	if dialerProto != h.ID() {
-		h.logger.Warnf("Denying connection  from peer %+v", p)
+	if h.conf.OutboundOnly {
+		h.logger.Warnf("Denying connection from peer %+v, running in outbound-only mode", p)
 		return false
 	}
 
+	if dialerProto != h.ID() {
+		if !h.foreignConnAllowed(dialerProto, p) {
+			h.logger.Warnf("Denying connection from peer %+v dialed by protocol %d", p, dialerProto)
+			return false
+		}
+		h.logger.Infof("Accepting connection from peer %+v dialed by protocol %d", p, dialerProto)
+		return true
+	}
+	if h.hasLeft() {
+		h.logger.Warnf("Denying connection from peer %+v, the node left the overlay", p)
+		return false
+	}
+	h.learnInboundPeer(p)
 	return true
 }
 
 func (h *Hyparview) OutConnDown(p peer.Peer) {
-	h.handleNodeDown(p)
+	h.handleNodeDown(p, RemovalConnectionDown)
 	h.logger.Errorf("Peer %s out connection went down", p.String())
 }
 
 func (h *Hyparview) DialFailed(p peer.Peer) {
 	h.logger.Errorf("Failed to dial peer %s", p.String())
-	h.handleNodeDown(p)
+	if h.metricsEnabled() {
+		h.metrics.dialFailures++
+	}
+	h.handleNodeDown(p, RemovalDialFailed)
 }
 
-func (h *Hyparview) handleNodeDown(p peer.Peer) {
+func (h *Hyparview) handleNodeDown(p peer.Peer, reason string) {
 	h.logger.Errorf("Node %s DOWN", p.String())
-	defer h.logHyparviewState()
-	defer h.babel.Disconnect(h.ID(), p)
-	if removed := h.activeView.remove(p); removed != nil {
+	defer h.viewsChanged()
+	if removed := h.removeFromActiveView(p, reason); removed != nil {
+		delete(h.outboundOnlyPeers, p.String())
 		if removed.outConnected {
+			h.babel.Disconnect(h.ID(), p)
 			h.logger.Infof("Emitting Neigh down notification...")
 			h.babel.SendNotification(NeighborDownNotification{
-				PeerDown: p,
-				View:     h.getView(),
+				PeerDown:    p,
+				View:        h.getView(),
+				ViewVersion: h.nextViewVersion(),
 			})
 		} else {
 			h.logger.Warnf("Peer in active view but was not connected")
 		}
-		if !h.activeView.isFull() {
-			if h.passiveView.size() == 0 {
-				if h.activeView.size() == 0 {
-					h.joinOverlay()
-				}
+		if !h.activeView.isFull() && !h.decommissioning() {
+			if h.needsRecovery() {
+				h.handleIsolation()
 				return
 			}
-			newNeighbor := h.passiveView.getRandomElementsFromView(1)
-			h.logger.Warnf("replacing downed with node %s from passive view", newNeighbor[0].String())
-			h.sendMessageTmpTransport(NeighbourMessage{
-				HighPrio: h.activeView.size() <= 1, // TODO review this
-			}, newNeighbor[0])
+			newNeighbor := h.pickExchangedPeer()
+			if newNeighbor == nil {
+				newNeighbor = h.pickPromotionCandidate()
+			}
+			if newNeighbor == nil {
+				h.logger.Warn("All passive view members were vetoed as replacements")
+				return
+			}
+			h.logger.Warnf("replacing downed with node %s from passive view", newNeighbor.String())
+			h.promote(newNeighbor)
 		}
 	} else {
 		h.logger.Warnf("Peer down was not in view")
 	}
 }
 
+// sendNeighbourMessage queues a neighbour request to target, see actions.go. Requests of isolated
+// nodes go first.
+func (h *Hyparview) sendNeighbourMessage(target peer.Peer) {
+	priority := priorityNormal
+	if h.activeView.size() == 0 {
+		priority = priorityHigh
+	}
+	if h.frozen() {
+		h.logger.Infof("Not sending neighbour request to %s, membership is frozen", target.String())
+		return
+	}
+	h.queueAction(actionNeighbourRequest, target, priority)
+}
+
+func (h *Hyparview) sendNeighbourRequest(target peer.Peer) {
+	toSend := NeighbourMessage{
+		HighPrio:     h.activeView.size() <= 1 || h.conf.OutboundOnly, // TODO review this
+		OutboundOnly: h.conf.OutboundOnly,
+		Incarnation:  h.incarnation,
+		TraceID:      h.newTraceID(),
+		OverlayID:    h.overlayID(),
+		ClusterToken: h.conf.ClusterToken,
+		Capabilities: h.localCapabilities(),
+	}
+	if h.conf.StrictPaper {
+		toSend.HighPrio = h.activeView.size() == 0
+	}
+	h.traceSent(traceNeighbour, target, toSend.TraceID)
+	h.sendMessageTmpTransport(toSend, target)
+	if h.conf.OutboundOnly {
+		// replies cannot reach us, assume the high priority request is accepted
+		h.addPeerToActiveView(target)
+	}
+}
+
 func (h *Hyparview) logInView() {
 	type viewWithLatencies []struct {
 		IP      string `json:"ip,omitempty"`
@@ -233,7 +643,7 @@ func (h *Hyparview) logInView() {
 	if err != nil {
 		panic(err)
 	}
-	h.logger.Infof("<inView> %s", string(res))
+	h.analytics("inView", "%s", string(res))
 }
 
 func (h *Hyparview) DialSuccess(sourceProto protocol.ID, p peer.Peer) bool {
@@ -243,12 +653,20 @@ func (h *Hyparview) DialSuccess(sourceProto protocol.ID, p peer.Peer) bool {
 	foundPeer, found := h.activeView.get(p)
 	if found {
 		foundPeer.outConnected = true
+		foundPeer.dialing = false
 		h.logger.Info("Dialed node in active view")
-		h.activeView.asMap[p.String()] = foundPeer
+		if h.metricsEnabled() {
+			h.metrics.neighbourUps++
+		}
+		defer h.checkJoined()
 		h.babel.SendNotification(NeighborUpNotification{
-			PeerUp: foundPeer,
-			View:   h.getView(),
+			PeerUp:      foundPeer,
+			View:        h.getView(),
+			ViewVersion: h.nextViewVersion(),
 		})
+		h.publishEvent(EventNeighborUp, foundPeer.Peer)
+		h.neighbourConnected()
+		h.warmPassiveView(p)
 		return true
 	}
 
@@ -259,10 +677,14 @@ func (h *Hyparview) DialSuccess(sourceProto protocol.ID, p peer.Peer) bool {
 
 func (h *Hyparview) MessageDelivered(msg message.Message, p peer.Peer) {
 	h.logger.Infof("Message of type [%s] body: %+v was sent to %s", reflect.TypeOf(msg), msg, p.String())
+	h.recordDelivered(msg, p)
+	h.sendSucceeded(p)
 }
 
 func (h *Hyparview) MessageDeliveryErr(msg message.Message, p peer.Peer, err errors.Error) {
 	h.logger.Warnf("Message %s was not sent to %s because: %s", reflect.TypeOf(msg), p.String(), err.Reason())
+	h.recordDeliveryErr(msg, p)
+	h.sendFailed(p)
 	_, isNeighMsg := msg.(NeighbourMessage)
 	if isNeighMsg {
 		h.passiveView.remove(p)
@@ -282,37 +704,110 @@ func (h *Hyparview) getView() map[string]peer.Peer {
 // ---------------- Protocol handlers (messages) ----------------
 
 func (h *Hyparview) HandleJoinMessage(sender peer.Peer, msg message.Message) {
+	joinMsg := msg.(JoinMessage)
 	h.logger.Infof("Received join message from %s", sender)
-	if h.activeView.isFull() {
-		h.dropRandomElemFromActiveView()
+	h.traceReceived(traceJoin, sender, joinMsg.TraceID)
+	if !h.sameOverlay(sender, joinMsg.OverlayID, "join") {
+		h.rejectJoin(sender, RejectOverlayMismatch)
+		return
 	}
-	toSend := ForwardJoinMessage{
-		TTL:            uint32(h.conf.ARWL),
-		OriginalSender: sender,
+	if !h.sameClusterToken(sender, joinMsg.ClusterToken, "join") {
+		h.rejectJoin(sender, RejectClusterTokenMismatch)
+		return
+	}
+	h.fenceIncarnation(sender, joinMsg.Incarnation)
+	h.setOutboundOnly(sender, joinMsg.OutboundOnly)
+	if reason, rejected := h.shouldRejectJoin(sender); rejected {
+		h.rejectJoin(sender, reason)
+		return
+	}
+	if h.inDegreeExceeded(sender) {
+		h.redirectJoin(sender, joinMsg)
+		return
+	}
+	if !h.subnetAllows(sender) {
+		h.logger.Infof("Not accepting joiner %s in active view due to subnet diversity, forwarding join only", sender.String())
+		h.forwardJoin(sender, joinMsg.Capabilities)
+		return
+	}
+	if h.selfIsBootstrap && h.joinOnlyBootstraps() {
+		h.logger.Infof("Join-only bootstrap, forwarding join of %s", sender.String())
+		h.forwardJoinOnly(sender, joinMsg.Capabilities)
+		return
+	}
+	if h.needsDialBack(joinMsg) {
+		h.dialBackJoiner(sender, joinMsg)
+		return
+	}
+	h.admitJoiner(sender, joinMsg)
+}
+
+func (h *Hyparview) admitJoiner(sender peer.Peer, joinMsg JoinMessage) {
+	if h.activeView.isFull() && h.conf.JoinFullPolicy == JoinFullRedirect {
+		h.redirectJoin(sender, joinMsg)
+		return
+	}
+	if !h.addPeerToActiveView(sender) {
+		if h.activeView.contains(sender) {
+			// a retried join, the joiner was already admitted and the join forwarded
+			return
+		}
+		// vetoed, or the view is full and frozen: the joiner must find its neighbours elsewhere
+		h.redirectJoin(sender, joinMsg)
+		return
 	}
-	h.addPeerToActiveView(sender)
-	h.sendMessageTmpTransport(ForwardJoinMessageReply{}, sender)
+	h.learnCapabilities(sender, joinMsg.Capabilities)
+	if joinMsg.OutboundOnly {
+		// other nodes cannot dial the joiner, so there is no point in forwarding the join
+		return
+	}
+	h.sendMessageTmpTransport(ForwardJoinMessageReply{Incarnation: h.incarnation, TraceID: joinMsg.TraceID, Capabilities: h.localCapabilities()}, sender)
+	if h.forwardJoin(sender, joinMsg.Capabilities) == 0 && h.passiveView.size() > 0 && !h.conf.StrictPaper {
+		// nobody to forward the join to (e.g. a standby bootstrap), hand the joiner a passive view sample instead
+		h.sendMessageTmpTransport(ShuffleReplyMessage{
+			Peers: h.passiveView.getRandomElementsFromView(h.conf.Kp, sender),
+		}, sender)
+	}
+}
+
+func (h *Hyparview) forwardJoin(sender peer.Peer, capabilities uint8) int {
+	forwarded := 0
 	for _, neigh := range h.activeView.asArr {
 		if peer.PeersEqual(neigh, sender) {
 			continue
 		}
 
 		if neigh.outConnected {
+			toSend := ForwardJoinMessage{
+				TTL:            uint32(h.conf.ARWL),
+				WalkID:         uint32(getRandInt(math.MaxUint32)),
+				OriginalSender: sender,
+				Capabilities:   capabilities,
+			}
 			h.logger.Infof("Sending ForwardJoin (original=%s) message to: %s", sender.String(), neigh.String())
 			h.sendMessage(toSend, neigh)
+			forwarded++
 		}
 	}
+	return forwarded
 }
 
 func (h *Hyparview) HandleForwardJoinMessage(sender peer.Peer, msg message.Message) {
 	fwdJoinMsg := msg.(ForwardJoinMessage)
+	if fwdJoinMsg.OriginalSender == nil {
+		h.logger.Warnf("Dropping malformed forward join message from %s", sender.String())
+		return
+	}
+	fwdJoinMsg.TTL = h.clampTTL(fwdJoinMsg.TTL, h.conf.MaxForwardJoinTTL, h.conf.ARWL, sender)
 	h.logger.Infof("Received forward join message with ttl = %d, originalSender=%s from %s",
 		fwdJoinMsg.TTL,
 		fwdJoinMsg.OriginalSender.String(),
 		sender.String())
 
-	if fwdJoinMsg.OriginalSender == h.babel.SelfPeer() {
-		h.logger.Panic("Received forward join message sent by myself")
+	if h.isSelf(fwdJoinMsg.OriginalSender) {
+		// there is nothing left to process without the original sender, the walk ends here
+		h.dropForSelfAddress(sender, "forward join")
+		return
 	}
 
 	if fwdJoinMsg.TTL == 0 || h.activeView.size() == 1 {
@@ -322,28 +817,40 @@ func (h *Hyparview) HandleForwardJoinMessage(sender peer.Peer, msg message.Messa
 		if h.activeView.size() == 1 {
 			h.logger.Infof("Accepting forwardJoin message from %s, h.activeView.size() == 1", fwdJoinMsg.OriginalSender.String())
 		}
-		if h.addPeerToActiveView(fwdJoinMsg.OriginalSender) {
-			h.sendMessageTmpTransport(ForwardJoinMessageReply{}, fwdJoinMsg.OriginalSender)
+		accepted := h.addPeerToActiveView(fwdJoinMsg.OriginalSender)
+		if accepted {
+			h.learnCapabilities(fwdJoinMsg.OriginalSender, fwdJoinMsg.Capabilities)
+			h.sendMessageTmpTransport(ForwardJoinMessageReply{Incarnation: h.incarnation, TraceID: fwdJoinMsg.WalkID, Capabilities: h.localCapabilities()}, fwdJoinMsg.OriginalSender)
 		}
+		h.reportWalkTerminated(fwdJoinMsg, accepted)
 		return
 	}
 
-	if fwdJoinMsg.TTL == uint32(h.conf.PRWL) {
-		h.addPeerToPassiveView(fwdJoinMsg.OriginalSender)
+	if fwdJoinMsg.TTL == uint32(h.conf.PRWL) && !h.passiveOriginFull(sender) && !h.deferForVerification(fwdJoinMsg.OriginalSender, 0, sender) {
+		h.addPeerToPassiveViewWithAge(fwdJoinMsg.OriginalSender, 0, sender)
 	}
 
-	rndSample := h.activeView.getRandomElementsFromView(1, fwdJoinMsg.OriginalSender, sender)
+	exclusions := []peer.Peer{fwdJoinMsg.OriginalSender, sender}
+	if h.conf.StrictPaper {
+		exclusions = exclusions[1:]
+	}
+	rndSample := h.activeView.getRandomElementsFromView(1, exclusions...)
 	if len(rndSample) == 0 { // only know original sender, act as if join message
 		h.logger.Errorf("Cannot forward forwardJoin message, dialing %s", fwdJoinMsg.OriginalSender.String())
-		if h.addPeerToActiveView(fwdJoinMsg.OriginalSender) {
-			h.sendMessageTmpTransport(ForwardJoinMessageReply{}, fwdJoinMsg.OriginalSender)
+		accepted := h.addPeerToActiveView(fwdJoinMsg.OriginalSender)
+		if accepted {
+			h.learnCapabilities(fwdJoinMsg.OriginalSender, fwdJoinMsg.Capabilities)
+			h.sendMessageTmpTransport(ForwardJoinMessageReply{Incarnation: h.incarnation, TraceID: fwdJoinMsg.WalkID, Capabilities: h.localCapabilities()}, fwdJoinMsg.OriginalSender)
 		}
+		h.reportWalkTerminated(fwdJoinMsg, accepted)
 		return
 	}
 
 	toSend := ForwardJoinMessage{
 		TTL:            fwdJoinMsg.TTL - 1,
+		WalkID:         fwdJoinMsg.WalkID,
 		OriginalSender: fwdJoinMsg.OriginalSender,
+		Capabilities:   fwdJoinMsg.Capabilities,
 	}
 	nodeToSendTo := rndSample[0]
 	h.logger.Infof(
@@ -357,17 +864,53 @@ func (h *Hyparview) HandleForwardJoinMessage(sender peer.Peer, msg message.Messa
 
 func (h *Hyparview) HandleForwardJoinMessageReply(sender peer.Peer, msg message.Message) {
 	h.logger.Infof("Received forward join message reply from  %s", sender.String())
-	h.addPeerToActiveView(sender)
+	fwdJoinReplyMsg := msg.(ForwardJoinMessageReply)
+	h.fenceIncarnation(sender, fwdJoinReplyMsg.Incarnation)
+	h.traceReplied(traceJoin, sender, fwdJoinReplyMsg.TraceID)
+	if !h.acceptBootstrapReply(sender) {
+		return
+	}
+	if p, ok := h.activeView.get(sender); ok {
+		p.capabilities = fwdJoinReplyMsg.Capabilities
+		// both sides added each other concurrently, only the lower address dials,
+		// the other side dials back upon receiving its maintenance messages
+		h.logger.Infof("Peer %s which sent forward join reply is already in active view", sender.String())
+		if peerLess(h.babel.SelfPeer(), sender) {
+			h.dialPeer(p)
+		} else {
+			h.deferDial(p)
+		}
+		return
+	}
+	if h.addPeerToActiveView(sender) {
+		h.learnCapabilities(sender, fwdJoinReplyMsg.Capabilities)
+	}
 }
 
 func (h *Hyparview) HandleNeighbourMessage(sender peer.Peer, msg message.Message) {
 	neighborMsg := msg.(NeighbourMessage)
 	h.logger.Infof("Received neighbor message %+v", neighborMsg)
+	if h.decommissioning() || !h.sameOverlay(sender, neighborMsg.OverlayID, "neighbour request") || !h.sameClusterToken(sender, neighborMsg.ClusterToken, "neighbour request") {
+		h.sendMessageTmpTransport(NeighbourMessageReply{Accepted: false, TraceID: neighborMsg.TraceID}, sender)
+		return
+	}
+	h.fenceIncarnation(sender, neighborMsg.Incarnation)
+	h.traceReceived(traceNeighbour, sender, neighborMsg.TraceID)
+	h.setOutboundOnly(sender, neighborMsg.OutboundOnly)
+
+	if h.inDegreeExceeded(sender) {
+		h.redirectNeighbourRequest(sender, neighborMsg)
+		return
+	}
 
 	if neighborMsg.HighPrio {
 		if h.addPeerToActiveView(sender) {
+			h.learnCapabilities(sender, neighborMsg.Capabilities)
 			reply := NeighbourMessageReply{
-				Accepted: true,
+				Accepted:     true,
+				Incarnation:  h.incarnation,
+				TraceID:      neighborMsg.TraceID,
+				Capabilities: h.localCapabilities(),
 			}
 			h.sendMessageTmpTransport(reply, sender)
 		}
@@ -376,37 +919,55 @@ func (h *Hyparview) HandleNeighbourMessage(sender peer.Peer, msg message.Message
 
 	if h.activeView.isFull() {
 		reply := NeighbourMessageReply{
-			Accepted: false,
+			Accepted:    false,
+			Incarnation: h.incarnation,
+			TraceID:     neighborMsg.TraceID,
 		}
 		h.sendMessageTmpTransport(reply, sender)
 		return
 	}
 	if h.addPeerToActiveView(sender) {
+		h.learnCapabilities(sender, neighborMsg.Capabilities)
 		reply := NeighbourMessageReply{
-			Accepted: true,
+			Accepted:     true,
+			Incarnation:  h.incarnation,
+			TraceID:      neighborMsg.TraceID,
+			Capabilities: h.localCapabilities(),
 		}
 		h.sendMessageTmpTransport(reply, sender)
 	}
 }
 
 func (h *Hyparview) HandleNeighbourMaintenanceMessage(sender peer.Peer, msg message.Message) {
+	maintenanceMsg := msg.(NeighbourMaintenanceMessage)
+	h.recordMaintainer(sender)
+	if h.fenceIncarnation(sender, maintenanceMsg.Incarnation) {
+		h.addPeerToActiveView(sender)
+		return
+	}
 	if p, ok := h.activeView.get(sender); ok {
+		p.failureDomain = maintenanceMsg.FailureDomain
+		h.recordTimeHint(p, maintenanceMsg.Time)
+		h.recordVersion(p, maintenanceMsg.Version)
+		h.maintenanceReceived(p)
 		if p.outConnected {
 			delete(h.danglingNeighCounters, sender.String())
 			return
 		} else {
-			h.babel.Dial(h.ID(), sender, sender.ToTCPAddr())
+			p.dialDeferred = time.Time{}
+			h.dialPeer(p)
 			return
 		}
 	}
 	h.logger.Warn("Got maintenance message from not a neigh")
+	h.heardFrom(sender)
 	_, ok := h.danglingNeighCounters[sender.String()]
 	if !ok {
 		h.danglingNeighCounters[sender.String()] = 0
 	}
 	h.danglingNeighCounters[sender.String()]++
 	if h.danglingNeighCounters[sender.String()] >= 3 {
-		h.babel.SendMessageSideStream(DisconnectMessage{}, sender, sender.ToTCPAddr(), h.ID(), h.ID())
+		h.sendSideStream(DisconnectMessage{}, sender)
 		h.logger.Warn("Disconnecting due to maintenance msg")
 	}
 }
@@ -414,45 +975,92 @@ func (h *Hyparview) HandleNeighbourMaintenanceMessage(sender peer.Peer, msg mess
 func (h *Hyparview) HandleNeighbourReplyMessage(sender peer.Peer, msg message.Message) {
 	h.logger.Info("Received neighbor reply message")
 	neighborReplyMsg := msg.(NeighbourMessageReply)
+	h.fenceIncarnation(sender, neighborReplyMsg.Incarnation)
+	h.traceReplied(traceNeighbour, sender, neighborReplyMsg.TraceID)
 	if neighborReplyMsg.Accepted {
-		h.addPeerToActiveView(sender)
+		if h.addPeerToActiveView(sender) {
+			h.learnCapabilities(sender, neighborReplyMsg.Capabilities)
+		}
+		return
+	}
+	if h.conf.NeighbourRetries > 0 {
+		h.retryPromotion(sender)
+		return
+	}
+	if h.conf.StrictPaper && !h.activeView.isFull() {
+		candidates := h.filterPromotable(h.passiveView.getRandomElementsFromView(h.passiveView.size(), sender))
+		if len(candidates) > 0 {
+			h.logger.Infof("Neighbour request rejected by %s, trying %s", sender.String(), candidates[0].String())
+			h.sendNeighbourMessage(candidates[0])
+		}
 	}
 }
 
 func (h *Hyparview) HandleShuffleMessage(sender peer.Peer, msg message.Message) {
 	shuffleMsg := msg.(ShuffleMessage)
+	if !h.sameOverlay(sender, shuffleMsg.OverlayID, "shuffle") {
+		return
+	}
+	if h.dropIfContainsSelf(sender, "shuffle", shuffleMsg.Peers) {
+		return
+	}
+	shuffleMsg.TTL = h.clampTTL(shuffleMsg.TTL, h.conf.MaxShuffleTTL, h.conf.PRWL, sender)
+	if p, ok := h.activeView.get(sender); ok {
+		p.capabilities = shuffleMsg.Capabilities
+	}
+	h.heardFrom(sender)
+	h.traceReceived(traceShuffle, sender, shuffleMsg.ID)
+	if shuffleMsg.ConfigUpdate != nil {
+		h.acceptConfigUpdate(*shuffleMsg.ConfigUpdate, sender)
+	}
 	if shuffleMsg.TTL > 0 {
 		rndSample := h.activeView.getRandomElementsFromView(1, sender)
-		if len(rndSample) != 0 {
+		if len(rndSample) != 0 && !h.shuffleForwardRateExceeded() && !h.shedShuffleForward() {
 			toSend := ShuffleMessage{
 				ID:    shuffleMsg.ID,
 				TTL:   shuffleMsg.TTL - 1,
 				Peers: shuffleMsg.Peers,
 			}
 			h.logger.Debug("Forwarding shuffle message to :", rndSample[0].String())
-			h.sendMessage(toSend, rndSample[0])
+			h.sendShuffleMessage(toSend, rndSample[0])
 			return
 		}
 	}
-	//  TTL is 0 or have no nodes to forward to
+	//  TTL is 0, have no nodes to forward to or forwarding rate is exceeded or shed
 	//  select random nr of hosts from passive view
 	exclusions := append(shuffleMsg.Peers, sender)
-	toSend := h.passiveView.getRandomElementsFromView(len(shuffleMsg.Peers), exclusions...)
-	h.mergeShuffleMsgPeersWithPassiveView(shuffleMsg.Peers, toSend)
+	toSend := h.withoutShuffleExclusions(h.passiveView.getRandomElementsFromView(len(shuffleMsg.Peers), exclusions...))
+	h.mergeShuffleMsgPeersWithPassiveView(shuffleMsg.Peers, toSend, sender)
 	reply := ShuffleReplyMessage{
 		ID:    shuffleMsg.ID,
 		Peers: toSend,
 	}
-	h.sendMessageTmpTransport(reply, sender)
+	h.sendShuffleReplyMessage(reply, sender, shuffleMsg.Capabilities)
+}
+
+// learnablePeer is the filter shared by every merge of received peers into the passive view, Cyclon
+// shuffles included, so that active view members and blacklisted peers never come back through one.
+func (h *Hyparview) learnablePeer(p peer.Peer) bool {
+	return !h.activeView.contains(p) && !h.isBlacklisted(p) && !h.joinOnlyContact(p)
 }
 
-func (h *Hyparview) mergeShuffleMsgPeersWithPassiveView(shuffleMsgPeers, peersToKickFirst []peer.Peer) {
+// mergeShuffleMsgPeersWithPassiveView merges peers relayed by origin into the passive view.
+func (h *Hyparview) mergeShuffleMsgPeersWithPassiveView(shuffleMsgPeers, peersToKickFirst []peer.Peer, origin peer.Peer) {
 	for _, receivedHost := range shuffleMsgPeers {
-		if h.babel.SelfPeer().String() == receivedHost.String() {
+		if h.isSelf(receivedHost) {
+			continue
+		}
+
+		if h.passiveView.contains(receivedHost) {
+			h.heardFrom(receivedHost)
 			continue
 		}
 
-		if h.activeView.contains(receivedHost) || h.passiveView.contains(receivedHost) {
+		if !h.learnablePeer(receivedHost) {
+			continue
+		}
+
+		if h.passiveOriginFull(origin) || h.deferForVerification(receivedHost, 0, origin) {
 			continue
 		}
 
@@ -468,56 +1076,106 @@ func (h *Hyparview) mergeShuffleMsgPeersWithPassiveView(shuffleMsgPeers, peersTo
 				h.passiveView.dropRandom() // drop random element to make space
 			}
 		}
-		h.addPeerToPassiveView(receivedHost)
+		h.addPeerToPassiveViewWithAge(receivedHost, 0, origin)
 	}
 }
 
 func (h *Hyparview) HandleShuffleReplyMessage(sender peer.Peer, m message.Message) {
 	shuffleReplyMsg := m.(ShuffleReplyMessage)
+	h.traceReplied(traceShuffle, sender, shuffleReplyMsg.ID)
+	if h.dropIfContainsSelf(sender, "shuffle reply", shuffleReplyMsg.Peers) {
+		return
+	}
 	h.logger.Infof("Received shuffle reply message %+v", shuffleReplyMsg)
+	h.heardFrom(sender)
+	if shuffleReplyMsg.ConfigUpdate != nil {
+		h.acceptConfigUpdate(*shuffleReplyMsg.ConfigUpdate, sender)
+	}
+	merge, current := h.classifyShuffleReply(shuffleReplyMsg.ID)
+	if !merge {
+		return
+	}
 	peersToDiscardFirst := []peer.Peer{}
-	if h.lastShuffleMsg != nil {
+	if current {
 		peersToDiscardFirst = append(peersToDiscardFirst, h.lastShuffleMsg.Peers...)
+		h.lastShuffleMsg = nil
 	}
-	h.lastShuffleMsg = nil
-	h.mergeShuffleMsgPeersWithPassiveView(shuffleReplyMsg.Peers, peersToDiscardFirst)
+	h.mergeShuffleMsgPeersWithPassiveView(shuffleReplyMsg.Peers, peersToDiscardFirst, sender)
 }
 
 // ---------------- Protocol handlers (timers) ----------------
 
 func (h *Hyparview) HandlePromoteTimer(t timer.Timer) {
 	h.logger.Info("Promote timer trigger")
-	if time.Since(h.timeStart) > time.Duration(h.conf.JoinTimeSeconds)*time.Second {
-		if h.activeView.size() == 0 && h.passiveView.size() == 0 {
-			h.joinOverlay()
+	if !h.shouldRunPeriodic(t) {
+		return
+	}
+	if h.decommissioning() {
+		return
+	}
+	if !h.stabilizing() {
+		if h.needsRecovery() {
+			h.handleIsolation()
+			return
+		}
+		if h.enforceVersionComposition() {
 			return
 		}
 		if !h.activeView.isFull() && h.passiveView.size() > 0 {
-			h.logger.Warn("Promoting node from passive view to active view")
-			newNeighbor := h.passiveView.getRandomElementsFromView(1)
-			h.sendMessageTmpTransport(NeighbourMessage{
-				HighPrio: h.activeView.size() <= 1, // TODO review this
-			}, newNeighbor[0])
+			if candidate := h.pickPromotionCandidate(); candidate != nil {
+				h.logger.Warn("Promoting node from passive view to active view")
+				h.promote(candidate)
+			}
 		}
 	}
 }
 
 func (h *Hyparview) HandleMaintenanceTimer(t timer.Timer) {
+	if !h.shouldRunPeriodic(t) {
+		return
+	}
 	for _, p := range h.activeView.asArr {
 		if !p.outConnected {
-			h.babel.Dial(h.ID(), p, p.ToTCPAddr())
+			h.dialPeer(p)
 		}
-		h.sendMessage(NeighbourMaintenanceMessage{}, p)
+		h.sendMessage(NeighbourMaintenanceMessage{
+			FailureDomain: h.conf.FailureDomain,
+			Incarnation:   h.incarnation,
+			Time:          h.timeHintFor(p),
+			Version:       versionFor(p),
+		}, p)
+		h.sendMetadata(p)
 	}
+	h.markStaleMetadata()
+	h.countMissedMaintenance()
+	h.demoteSlowPeers()
+	h.checkSilentNeighbours()
+	h.adaptPassiveCapacity()
 }
 
 func (h *Hyparview) HandleShuffleTimer(t timer.Timer) {
+	if h.hasLeft() {
+		return
+	}
 	h.logger.Info("Shuffle timer trigger")
 	minShuffleDuration := time.Duration(h.conf.MinShuffleTimerDurationSeconds) * time.Second
 
 	// add jitter to emission of shuffle messages
 	toWait := minShuffleDuration + time.Duration(float32(minShuffleDuration)*rand.Float32())
-	h.babel.RegisterTimer(h.ID(), ShuffleTimer{duration: toWait})
+	if h.conf.StrictPaper {
+		toWait = minShuffleDuration
+	}
+	h.scheduleTimer(ShuffleTimer{duration: toWait})
+
+	if h.shufflesSuppressed() || h.frozen() {
+		h.logger.Warn("Shuffles are suppressed, not shuffling")
+		return
+	}
+
+	if h.conf.CyclonShuffle {
+		h.cyclonShuffle()
+		return
+	}
 
 	if h.activeView.size() == 0 {
 		h.logger.Info("No nodes to send shuffle message message to")
@@ -525,51 +1183,149 @@ func (h *Hyparview) HandleShuffleTimer(t timer.Timer) {
 	}
 
 	rndNode := h.activeView.getRandomElementsFromView(1)
-	passiveViewRandomPeers := h.passiveView.getRandomElementsFromView(h.conf.Kp-1, rndNode...)
-	activeViewRandomPeers := h.activeView.getRandomElementsFromView(h.conf.Ka, rndNode...)
+	toSend := h.newShuffleMessage(uint32(h.conf.PRWL), rndNode[0])
+	h.logger.Info("Sending shuffle message to: ", rndNode[0].String())
+	h.sendShuffleMessage(toSend, rndNode[0])
+}
+
+func (h *Hyparview) newShuffleMessage(ttl uint32, target peer.Peer) ShuffleMessage {
+	nrPassive := h.conf.Kp - 1
+	if h.conf.StrictPaper {
+		nrPassive = h.conf.Kp
+	}
+	passiveViewRandomPeers := h.passiveView.getRandomElementsFromView(nrPassive, target)
+	activeViewRandomPeers := h.dialableOnly(h.activeView.getRandomElementsFromView(h.conf.Ka, target))
 	peers := append(passiveViewRandomPeers, activeViewRandomPeers...)
-	peers = append(peers, h.babel.SelfPeer())
+	if !h.conf.OutboundOnly {
+		peers = append(peers, h.babel.SelfPeer())
+	}
 	toSend := ShuffleMessage{
-		ID:    uint32(getRandInt(math.MaxUint32)),
-		TTL:   uint32(h.conf.PRWL),
-		Peers: peers,
+		ID:    h.nextShuffleID(),
+		TTL:   ttl,
+		Peers: h.withoutShuffleExclusions(peers),
 	}
 	h.lastShuffleMsg = &toSend
-	h.logger.Info("Sending shuffle message to: ", rndNode[0].String())
-	h.sendMessage(toSend, rndNode[0])
+	h.shuffleReplyStats.Sent++
+	h.traceSent(traceShuffle, target, toSend.ID)
+	return toSend
 }
 
 func (h *Hyparview) HandleDisconnectMessage(sender peer.Peer, m message.Message) {
+	disconnectMsg := m.(DisconnectMessage)
+	if h.dropIfContainsSelf(sender, "disconnect", disconnectMsg.Peers) {
+		disconnectMsg.Peers = nil
+	}
+	if h.dropIfContainsSelf(sender, "peer exchange", disconnectMsg.PX) || (!h.conf.PeerExchange && !h.conf.LeaveHandoff) {
+		disconnectMsg.PX = nil
+	}
 	h.logger.Warnf("Got Disconnect message from %s", sender.String())
-	h.handleNodeDown(sender)
+	if disconnectMsg.Nonce != 0 {
+		h.checkMutualEviction(sender, disconnectMsg.Nonce)
+	}
+	h.mergeShuffleMsgPeersWithPassiveView(disconnectMsg.Peers, []peer.Peer{}, sender)
+	h.mergeShuffleMsgPeersWithPassiveView(disconnectMsg.PX, []peer.Peer{}, sender)
+	h.exchangedPeers = disconnectMsg.PX
+	h.handleNodeDown(sender, RemovalDisconnected)
+	h.exchangedPeers = nil
 }
 
 // ---------------- Auxiliary functions ----------------
 
-func (h *Hyparview) logHyparviewState() {
-	h.logger.Info("------------- Hyparview state -------------")
-	var toLog string
-	toLog = "Active view : "
-	for _, p := range h.activeView.asArr {
-		toLog += fmt.Sprintf("%s, ", p.String())
+// viewsChanged is called on every view mutation, building the state dump is only worth it at debug
+// level, otherwise the changes are counted and the state is logged by the debug timer.
+func (h *Hyparview) viewsChanged() {
+	h.viewChanges++
+	if h.logger.IsLevelEnabled(logrus.DebugLevel) {
+		h.logger.Debug(h.formatView("Active view : ", h.activeView))
+		h.logger.Debug(h.formatView("Passive view : ", h.passiveView))
 	}
-	h.logger.Info(toLog)
-	toLog = "Passive view : "
-	for _, p := range h.passiveView.asArr {
-		toLog += fmt.Sprintf("%s, ", p.String())
+}
+
+func (h *Hyparview) logHyparviewState() {
+	h.analytics("viewChanges", "%d", h.viewChanges)
+	if h.viewChanges == 0 {
+		return
 	}
-	h.logger.Info(toLog)
+	h.viewChanges = 0
+	h.logger.Info("------------- Hyparview state -------------")
+	h.logger.Info(h.formatView("Active view : ", h.activeView))
+	h.logger.Info(h.formatView("Passive view : ", h.passiveView))
 	h.logger.Info("-------------------------------------------")
 }
 
+func (h *Hyparview) formatView(prefix string, view *View) string {
+	var toLog strings.Builder
+	toLog.WriteString(prefix)
+	for _, p := range view.asArr {
+		toLog.WriteString(p.String())
+		toLog.WriteString(", ")
+	}
+	return toLog.String()
+}
+
 func (h *Hyparview) sendMessage(msg message.Message, target peer.Peer) {
+	if !h.allowSend(msg, target) {
+		return
+	}
+	h.recordSend(msg, target)
+	h.tapMessage(Outbound, target, msg)
 	h.babel.SendMessage(msg, target, h.ID(), h.ID(), false)
 }
 
+// clampTTL bounds a TTL received from a remote peer to the configured cap, or to localDefault if no cap is set.
+func (h *Hyparview) clampTTL(ttl uint32, maxTTL, localDefault int, sender peer.Peer) uint32 {
+	if maxTTL <= 0 {
+		maxTTL = localDefault
+	}
+	if ttl > uint32(maxTTL) {
+		h.logger.Warnf("Clamping TTL %d received from %s to %d", ttl, sender.String(), maxTTL)
+		return uint32(maxTTL)
+	}
+	return ttl
+}
+
 func (h *Hyparview) sendMessageTmpTransport(msg message.Message, target peer.Peer) {
-	h.babel.SendMessageSideStream(msg, target, target.ToTCPAddr(), h.ID(), h.ID())
+	if !h.isDialable(target) {
+		h.logger.Warnf("Not sending %s to outbound-only peer %s", reflect.TypeOf(msg), target.String())
+		return
+	}
+	if !h.allowSend(msg, target) {
+		return
+	}
+	h.sendSideStream(msg, target)
 }
 
 func (h *Hyparview) HandleDebugTimer(t timer.Timer) {
+	if !h.shouldRunPeriodic(t) {
+		return
+	}
 	h.logInView()
+	h.logHyparviewState()
+	h.logBootstrapStats()
+	h.logActiveViewDomains()
+	h.logActiveViewVersions()
+	h.pruneKnownVersions()
+	h.logHandlerPanics()
+	h.logDeniedForeignConns()
+	h.logClockOffsets()
+	h.logBandwidths()
+	h.logLivenessProbes()
+	h.logMaintenance()
+	h.logActionStats()
+	h.logShadowStats()
+	h.logInDegree()
+	h.logShuffleFragments()
+	h.logCircuitBreakers()
+	h.analytics("selfAddressSeen", "%d", h.selfAddressSeen)
+	h.analytics("shuffleForwardsCapped", "%d", h.shuffleForwardsCapped)
+	h.analytics("sideStreamDropped", "%d", h.sideStreamDropped)
+	h.analytics("overlayMismatches", "%d", h.overlayMismatches)
+	h.analytics("clusterTokenMismatches", "%d", h.tokenMismatches)
+	h.analytics("passiveOriginCapped", "%d", h.passiveOriginCapped)
+	h.analytics("mutualEvictions", "%d", h.mutualEvictions)
+	h.analytics("subscriptionDrops", "%d", h.subscriptionDrops)
+	h.logEventQueue()
+	h.logPeerLifetimes()
+	h.logShuffleReplyStats()
+	h.expireTraces()
 }
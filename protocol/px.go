@@ -0,0 +1,62 @@
+package protocol
+
+import "github.com/nm-morais/go-babel/pkg/peer"
+
+// With PeerExchange set, neighbours disconnected for capacity reasons (dropped to make room for
+// another neighbour, or drained by Decommission) are handed a peer exchange (PX) list along with the
+// disconnect, as GossipSub does on prune: up to PeerExchangeSize (4 by default) connected neighbours
+// of the sender, likely alive and reachable. A receiver with PeerExchange set merges them in its
+// passive view like any relayed peers (so blacklists, origin caps and, with VerifyPassivePeers,
+// address verification apply) and tries them first when replacing the sender, rewiring right away
+// instead of picking a possibly stale passive view member. The list is a trailing field of the
+// disconnect message, which older nodes ignore.
+
+// PeerExchangeConfig enables handing PX lists to disconnected neighbours.
+type PeerExchangeConfig struct {
+	PeerExchange     bool `yaml:"peerExchange"`
+	PeerExchangeSize int  `yaml:"peerExchangeSize"`
+}
+
+const defaultPeerExchangeSize = 4
+
+// peerExchangeList returns the PX list to send along with the disconnect of p.
+func (h *Hyparview) peerExchangeList(p peer.Peer) []peer.Peer {
+	if !h.conf.PeerExchange {
+		return nil
+	}
+	size := h.conf.PeerExchangeSize
+	if size <= 0 {
+		size = defaultPeerExchangeSize
+	}
+	px := []peer.Peer{}
+	for _, neigh := range h.activeView.getRandomStatesFromView(h.activeView.size(), p) {
+		if len(px) == size {
+			break
+		}
+		if neigh.outConnected && !h.excludedFromShuffles(neigh.Peer) {
+			px = append(px, neigh.Peer)
+		}
+	}
+	return px
+}
+
+// sendPrune disconnects from p for capacity reasons, handing it a PX list if enabled and an eviction
+// nonce, see mutualevict.go.
+func (h *Hyparview) sendPrune(p *PeerState) {
+	h.deliverDisconnect(p, DisconnectMessage{
+		Peers: h.passiveView.getRandomElementsFromView(h.conf.Kp, p.Peer),
+		PX:    h.peerExchangeList(p.Peer),
+		Nonce: h.evictionNonce(p.Peer),
+	})
+}
+
+// pickExchangedPeer returns a peer received in the PX list of the disconnect being handled which
+// made it to the passive view and may be promoted, if any.
+func (h *Hyparview) pickExchangedPeer() peer.Peer {
+	for _, p := range h.exchangedPeers {
+		if h.passiveView.contains(p) && h.promotionAllowed(p) && h.subnetAllows(p) {
+			return p
+		}
+	}
+	return nil
+}
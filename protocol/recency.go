@@ -0,0 +1,55 @@
+package protocol
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Passive view members remember when they were last heard of (received in a shuffle or disconnect,
+// or sent us a message). Promotions favour recently heard members, which are more likely to still
+// be alive after churn: PromotionRecencyBias 0 picks uniformly at random, 100 always picks the most
+// recently heard member, values in between weight each member by exp(-k*rank) over the recency ranking.
+
+const maxPromotionRecencyBias = 100
+
+func (h *Hyparview) heardFrom(p peer.Peer) {
+	if state, ok := h.passiveView.get(p); ok {
+		state.lastHeard = h.timeNow()
+	}
+}
+
+// getRecencyWeightedElements returns every view member, in a random order biased towards the most recently heard ones.
+func (v *View) getRecencyWeightedElements(bias int) []peer.Peer {
+	if bias <= 0 {
+		return v.getRandomElementsFromView(v.size())
+	}
+	byRecency := make([]*PeerState, len(v.asArr))
+	copy(byRecency, v.asArr)
+	sort.SliceStable(byRecency, func(i, j int) bool { return byRecency[i].lastHeard.After(byRecency[j].lastHeard) })
+
+	ordered := make([]peer.Peer, 0, len(byRecency))
+	if bias >= maxPromotionRecencyBias {
+		for _, p := range byRecency {
+			ordered = append(ordered, p.Peer)
+		}
+		return ordered
+	}
+
+	// weighted sampling without replacement (Efraimidis-Spirakis): sort by u^(1/w) descending
+	k := float64(bias) / float64(maxPromotionRecencyBias-bias)
+	keys := make([]float64, len(byRecency))
+	perm := make([]int, len(byRecency))
+	for rank := range byRecency {
+		weight := math.Exp(-k * float64(rank))
+		keys[rank] = math.Pow(rand.Float64(), 1/weight)
+		perm[rank] = rank
+	}
+	sort.Slice(perm, func(i, j int) bool { return keys[perm[i]] > keys[perm[j]] })
+	for _, rank := range perm {
+		ordered = append(ordered, byRecency[rank].Peer)
+	}
+	return ordered
+}
@@ -0,0 +1,85 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/go-babel/pkg/request"
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// Every handler is registered through registerMessageHandler/registerTimerHandler/registerRequestHandler,
+// which recover from panics so that a malformed message or an unexpected state does not take the
+// process down. Panics are counted per message/timer/request type and logged by the debug timer.
+
+func (h *Hyparview) registerMessageHandler(msg message.Message, handler func(peer.Peer, message.Message)) {
+	h.babel.RegisterMessageHandler(protoID, msg, func(sender peer.Peer, m message.Message) {
+		defer func() {
+			if r := recover(); r != nil {
+				h.handlerPanicked(fmt.Sprintf("%T", m), r)
+				h.logger.Errorf("Offending message from %s: %+v", sender.String(), m)
+				if h.conf.RemovePeerOnHandlerPanic {
+					h.logger.Warnf("Removing peer %s after handler panic", sender.String())
+					h.passiveView.remove(sender)
+					h.handleNodeDown(sender, RemovalHandlerPanic)
+				}
+			}
+		}()
+		h.eventsHandled++
+		h.handlerRan()
+		h.receivedFrom(sender)
+		h.tapMessage(Inbound, sender, m)
+		if h.hasLeft() || h.shedMessage(m) {
+			return
+		}
+		handler(sender, m)
+	})
+}
+
+func (h *Hyparview) registerTimerHandler(timerID timer.ID, handler func(timer.Timer)) {
+	h.babel.RegisterTimerHandler(protoID, timerID, func(t timer.Timer) {
+		defer func() {
+			if r := recover(); r != nil {
+				handled := fmt.Sprintf("%T", t)
+				if run, ok := t.(RunTimer); ok {
+					handled = run.what
+				}
+				h.handlerPanicked(handled, r)
+			}
+		}()
+		h.eventsHandled++
+		h.handlerRan()
+		h.timerFired(t)
+		handler(t)
+	})
+}
+
+func (h *Hyparview) registerRequestHandler(requestID request.ID, handler func(request.Request) request.Reply) {
+	h.babel.RegisterRequestHandler(protoID, requestID, func(req request.Request) request.Reply {
+		defer func() {
+			if r := recover(); r != nil {
+				h.handlerPanicked(fmt.Sprintf("%T", req), r)
+			}
+		}()
+		h.eventsHandled++
+		h.handlerRan()
+		return handler(req)
+	})
+}
+
+func (h *Hyparview) handlerPanicked(handled string, r interface{}) {
+	h.handlerPanics[handled]++
+	h.logger.Errorf("Recovered from panic handling %s: %v\n%s", handled, r, debug.Stack())
+	h.logger.Error(h.DumpState())
+}
+
+func (h *Hyparview) logHandlerPanics() {
+	res, err := json.Marshal(h.handlerPanics)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("handlerPanics", "%s", string(res))
+}
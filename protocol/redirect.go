@@ -0,0 +1,42 @@
+package protocol
+
+import (
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// JoinFullPolicy chooses how a contact node with a full active view admits a joiner: by dropping a
+// random neighbour (the default, as in the paper), or by keeping its active view intact and
+// redirecting the joiner to a passive view sample while still forwarding the join, trading join
+// latency for topology stability.
+const (
+	JoinFullDropRandom = "dropRandom"
+	JoinFullRedirect   = "redirect"
+)
+
+func (h *Hyparview) redirectJoin(sender peer.Peer, joinMsg JoinMessage) {
+	sample := h.passiveView.getRandomElementsFromView(h.conf.Kp, sender)
+	h.logger.Infof("Redirecting joiner %s to %d passive view members", sender.String(), len(sample))
+	h.sendMessageTmpTransport(RedirectMessage{Peers: sample}, sender)
+	if !joinMsg.OutboundOnly {
+		h.forwardJoin(sender, joinMsg.Capabilities)
+	}
+}
+
+func (h *Hyparview) HandleRedirectMessage(sender peer.Peer, msg message.Message) {
+	redirectMsg := msg.(RedirectMessage)
+	h.logger.Infof("Join redirected by %s to %d peers", sender.String(), len(redirectMsg.Peers))
+	if h.pendingBootstrapJoin != nil && h.pendingBootstrapJoin.contacted[sender.String()] {
+		// the contact node handled the join, do not retry it
+		h.pendingBootstrapJoin.answered = true
+	}
+	if h.dropIfContainsSelf(sender, "redirect", redirectMsg.Peers) {
+		return
+	}
+	h.mergeShuffleMsgPeersWithPassiveView(redirectMsg.Peers, []peer.Peer{}, sender)
+	if h.activeView.size() == 0 && h.passiveView.size() > 0 {
+		if candidate := h.pickPromotionCandidate(); candidate != nil {
+			h.sendNeighbourMessage(candidate)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Package registry lets protocols co-hosted in the same babel process declare the message, timer and
+// notification ID ranges they use, so that overlapping ranges, which make babel silently route events
+// to the wrong handlers, are detected when the protocols are initialized.
+package registry
+
+import (
+	"fmt"
+	"sync"
+)
+
+type Kind int
+
+const (
+	Message Kind = iota
+	Timer
+	Notification
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Message:
+		return "message"
+	case Timer:
+		return "timer"
+	case Notification:
+		return "notification"
+	default:
+		return fmt.Sprintf("kind(%d)", int(k))
+	}
+}
+
+// Range is an inclusive ID range of a given kind.
+type Range struct {
+	Kind Kind
+	From int
+	To   int
+}
+
+func (r Range) overlaps(other Range) bool {
+	return r.Kind == other.Kind && r.From <= other.To && other.From <= r.To
+}
+
+type declaration struct {
+	owner string
+	Range
+}
+
+var (
+	mu           sync.Mutex
+	declarations []declaration
+)
+
+// Declare registers the ranges used by owner, failing if any of them overlaps a range declared by
+// another owner. Declaring again ranges already declared by the same owner is a no-op, so several
+// instances of the same protocol can share a process.
+func Declare(owner string, ranges ...Range) error {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, r := range ranges {
+		if r.From > r.To {
+			return fmt.Errorf("%s declared invalid %s ID range [%d, %d]", owner, r.Kind, r.From, r.To)
+		}
+		for _, d := range declarations {
+			if d.owner != owner && d.overlaps(r) {
+				return fmt.Errorf("%s ID range [%d, %d] of %s collides with range [%d, %d] of %s",
+					r.Kind, r.From, r.To, owner, d.From, d.To, d.owner)
+			}
+		}
+	}
+	for _, r := range ranges {
+		if !isDeclared(owner, r) {
+			declarations = append(declarations, declaration{owner: owner, Range: r})
+		}
+	}
+	return nil
+}
+
+func isDeclared(owner string, r Range) bool {
+	for _, d := range declarations {
+		if d.owner == owner && d.Range == r {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// rejectState holds the join rejectors and the joins admitted in the last second.
+type rejectState struct {
+	joinRejectors []JoinRejector
+	recentJoins   []time.Time
+}
+
+type JoinRejectReason uint8
+
+const (
+	RejectUnspecified JoinRejectReason = iota
+	RejectRateLimited
+	RejectBlacklisted
+	RejectShuttingDown
+	RejectUnreachable
+	RejectOverlayMismatch
+	RejectClusterTokenMismatch
+)
+
+func (r JoinRejectReason) String() string {
+	switch r {
+	case RejectRateLimited:
+		return "rate limited"
+	case RejectBlacklisted:
+		return "blacklisted"
+	case RejectShuttingDown:
+		return "shutting down"
+	case RejectUnreachable:
+		return "advertised address unreachable"
+	case RejectOverlayMismatch:
+		return "different overlay"
+	case RejectClusterTokenMismatch:
+		return "cluster token mismatch"
+	default:
+		return "unspecified"
+	}
+}
+
+// JoinRejector decides whether a join from sender must be rejected and why.
+type JoinRejector func(sender peer.Peer) (JoinRejectReason, bool)
+
+// AddJoinRejector registers a rejector consulted on every join, taking effect on the protocol goroutine.
+func (h *Hyparview) AddJoinRejector(rejector JoinRejector) {
+	h.onProtocol("AddJoinRejector", func() { h.addJoinRejector(rejector) })
+}
+
+func (h *Hyparview) addJoinRejector(rejector JoinRejector) {
+	h.joinRejectors = append(h.joinRejectors, rejector)
+}
+
+func (h *Hyparview) shouldRejectJoin(sender peer.Peer) (JoinRejectReason, bool) {
+	if h.decommissioning() {
+		return RejectShuttingDown, true
+	}
+	for _, rejector := range h.joinRejectors {
+		if reason, rejected := rejector(sender); rejected {
+			return reason, true
+		}
+	}
+	if h.joinRateExceeded() {
+		return RejectRateLimited, true
+	}
+	return RejectUnspecified, false
+}
+
+func (h *Hyparview) joinRateExceeded() bool {
+	if h.conf.MaxJoinsPerSecond <= 0 {
+		return false
+	}
+	recent := h.recentJoins[:0]
+	for _, t := range h.recentJoins {
+		if h.elapsedSince(t) < time.Second {
+			recent = append(recent, t)
+		}
+	}
+	h.recentJoins = recent
+	if len(h.recentJoins) >= h.conf.MaxJoinsPerSecond {
+		return true
+	}
+	h.recentJoins = append(h.recentJoins, h.timeNow())
+	return false
+}
+
+func (h *Hyparview) rejectJoin(sender peer.Peer, reason JoinRejectReason) {
+	h.logger.Warnf("Rejecting join from %s: %s", sender.String(), reason)
+	var alternatives []peer.Peer
+	// nodes of another overlay must not learn about ours
+	if reason != RejectOverlayMismatch && reason != RejectClusterTokenMismatch {
+		alternatives = h.dialableOnly(h.activeView.getRandomElementsFromView(h.conf.Ka, sender))
+		alternatives = append(alternatives, h.passiveView.getRandomElementsFromView(h.conf.Kp, sender)...)
+	}
+	h.sendMessageTmpTransport(JoinRejectMessage{
+		Reason: reason,
+		Peers:  alternatives,
+	}, sender)
+}
+
+func (h *Hyparview) HandleJoinRejectMessage(sender peer.Peer, msg message.Message) {
+	rejectMsg := msg.(JoinRejectMessage)
+	h.logger.Warnf("Join rejected by %s: %s, got %d alternatives", sender.String(), rejectMsg.Reason, len(rejectMsg.Peers))
+	if h.pendingBootstrapJoin != nil && h.pendingBootstrapJoin.contacted[sender.String()] {
+		delete(h.pendingBootstrapJoin.contacted, sender.String())
+	}
+	if !h.dropIfContainsSelf(sender, "join reject", rejectMsg.Peers) {
+		h.mergeShuffleMsgPeersWithPassiveView(rejectMsg.Peers, []peer.Peer{}, sender)
+	}
+	if h.activeView.size() > 0 {
+		return
+	}
+	if h.passiveView.size() > 0 {
+		if candidate := h.pickPromotionCandidate(); candidate != nil {
+			h.sendNeighbourMessage(candidate)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/timer"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// reloadState tracks the watched config file.
+type reloadState struct {
+	debugTimerID    int
+	confFilePath    string
+	confFileModTime time.Time
+	fileConf        *HyparviewConfig
+}
+
+const configReloadTimerDuration = 2 * time.Second
+
+// WatchConfigFile makes the protocol poll the config file at path once started, applying the
+// reloadable fields (timer durations, view sizes, log level and bootstrap peers) whenever it changes.
+// Only fields changed in the file are applied, so values overridden by flags are kept otherwise.
+func (h *Hyparview) WatchConfigFile(path string) {
+	h.confFilePath = path
+	if info, err := os.Stat(path); err == nil {
+		h.confFileModTime = info.ModTime()
+	}
+	fileConf, err := readConfigFile(path)
+	if err != nil {
+		h.logger.Errorf("Could not read config file %s: %s", path, err)
+		fileConf = &HyparviewConfig{}
+	}
+	h.fileConf = fileConf
+}
+
+// ReloadConfig rereads the watched config file as soon as possible, even if it was not modified.
+func (h *Hyparview) ReloadConfig() {
+	if h.confFilePath == "" {
+		h.logger.Warn("Ignoring config reload, no config file being watched")
+		return
+	}
+	h.onProtocol("ReloadConfig", func() { h.checkConfigFile(true) })
+}
+
+func (h *Hyparview) HandleConfigReloadTimer(t timer.Timer) {
+	h.checkConfigFile(false)
+}
+
+// checkConfigFile reloads the config file if it was modified since the last reload, or if force is set.
+func (h *Hyparview) checkConfigFile(force bool) {
+	info, err := os.Stat(h.confFilePath)
+	if err != nil {
+		h.logger.Errorf("Could not stat config file %s: %s", h.confFilePath, err)
+		return
+	}
+	if info.ModTime().Equal(h.confFileModTime) && !force {
+		return
+	}
+	h.confFileModTime = info.ModTime()
+	h.logger.Infof("Config file %s changed, reloading", h.confFilePath)
+	err = h.reloadConfig()
+	if err != nil {
+		h.logger.Errorf("Rejected config reload: %s", err)
+	}
+	h.babel.SendNotification(ConfigReloadedNotification{
+		Applied:     err == nil,
+		Err:         err,
+		ViewVersion: h.CurrentViewVersion(),
+	})
+}
+
+func readConfigFile(path string) (*HyparviewConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	conf := &HyparviewConfig{}
+	if err = yaml.NewDecoder(f).Decode(conf); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+func (h *Hyparview) reloadConfig() error {
+	newConf, err := readConfigFile(h.confFilePath)
+	if err != nil {
+		return err
+	}
+	if err = validateReloadableConfig(newConf); err != nil {
+		return err
+	}
+	// flags may override the file, so the whole running config is checked with the file's changes applied
+	prevConf := *h.conf
+	conf := *h.conf
+	mergeReloadableConfig(&conf, h.fileConf, newConf)
+	if err = ValidateConfig(&conf); err != nil {
+		return err
+	}
+	h.applyReloadableConfig(&prevConf, &conf)
+	h.fileConf = newConf
+	return nil
+}
+
+// mergeReloadableConfig copies into conf the reloadable fields that changed between prevConf and newConf.
+func mergeReloadableConfig(conf, prevConf, newConf *HyparviewConfig) {
+	if newConf.MinShuffleTimerDurationSeconds != prevConf.MinShuffleTimerDurationSeconds {
+		conf.MinShuffleTimerDurationSeconds = newConf.MinShuffleTimerDurationSeconds
+	}
+	if newConf.DebugTimerDurationSeconds != prevConf.DebugTimerDurationSeconds {
+		conf.DebugTimerDurationSeconds = newConf.DebugTimerDurationSeconds
+	}
+	if newConf.ActiveViewSize != prevConf.ActiveViewSize {
+		conf.ActiveViewSize = newConf.ActiveViewSize
+	}
+	if newConf.PassiveViewSize != prevConf.PassiveViewSize {
+		conf.PassiveViewSize = newConf.PassiveViewSize
+	}
+	if newConf.LogLevel != prevConf.LogLevel && newConf.LogLevel != "" {
+		conf.LogLevel = newConf.LogLevel
+	}
+	if !reflect.DeepEqual(newConf.BootstrapPeers, prevConf.BootstrapPeers) {
+		conf.BootstrapPeers = newConf.BootstrapPeers
+	}
+}
+
+func validateReloadableConfig(conf *HyparviewConfig) error {
+	if conf.ActiveViewSize <= 0 {
+		return errors.New("activeViewSize must be positive")
+	}
+	if conf.PassiveViewSize <= 0 {
+		return errors.New("passiveViewSize must be positive")
+	}
+	if conf.MinShuffleTimerDurationSeconds <= 0 {
+		return errors.New("minShuffleTimerDurationSeconds must be positive")
+	}
+	if conf.DebugTimerDurationSeconds <= 0 {
+		return errors.New("debugTimerDurationSeconds must be positive")
+	}
+	if len(conf.BootstrapPeers) == 0 && !usesDiscovery(conf) {
+		return errors.New("no bootstrap peers")
+	}
+	for _, p := range conf.BootstrapPeers {
+		// hosts which are not IPs are resolved through DNS, see bootstrapdns.go, SRV names carry their ports
+		if p.Host == "" {
+			return errors.New("empty bootstrap host")
+		}
+		if !isSRVName(p.Host) && (p.Port <= 0 || p.Port > 65535) {
+			return fmt.Errorf("invalid port %d for bootstrap %s", p.Port, p.Host)
+		}
+	}
+	if conf.LogLevel != "" {
+		if _, err := logrus.ParseLevel(conf.LogLevel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Hyparview) applyReloadableConfig(prevConf, newConf *HyparviewConfig) {
+	if newConf.MinShuffleTimerDurationSeconds != prevConf.MinShuffleTimerDurationSeconds {
+		h.conf.MinShuffleTimerDurationSeconds = newConf.MinShuffleTimerDurationSeconds
+	}
+
+	if newConf.DebugTimerDurationSeconds != prevConf.DebugTimerDurationSeconds {
+		h.conf.DebugTimerDurationSeconds = newConf.DebugTimerDurationSeconds
+		h.cancelPeriodicTimer(h.debugTimerID)
+		debugTimer := DebugTimer{time.Duration(h.conf.DebugTimerDurationSeconds) * time.Second}
+		h.debugTimerID = h.schedulePeriodicTimer(debugTimer, false)
+	}
+
+	if newConf.ActiveViewSize != prevConf.ActiveViewSize {
+		h.conf.ActiveViewSize = newConf.ActiveViewSize
+		h.activeView.capacity = newConf.ActiveViewSize
+		for h.activeView.size() > h.activeView.capacity {
+			h.dropRandomElemFromActiveView()
+		}
+	}
+
+	if newConf.PassiveViewSize != prevConf.PassiveViewSize {
+		h.conf.PassiveViewSize = newConf.PassiveViewSize
+		// adaptive sizing owns the capacity, which only has to stay above the new floor
+		if !h.adaptivePassiveEnabled() || h.passiveView.capacity < newConf.PassiveViewSize {
+			h.passiveView.capacity = newConf.PassiveViewSize
+		}
+		for h.passiveView.size() > h.passiveView.capacity {
+			h.passiveView.dropRandom()
+		}
+	}
+
+	if newConf.LogLevel != prevConf.LogLevel && newConf.LogLevel != "" {
+		h.conf.LogLevel = newConf.LogLevel
+		level, _ := logrus.ParseLevel(newConf.LogLevel)
+		h.logger.SetLevel(level)
+	}
+
+	if !reflect.DeepEqual(newConf.BootstrapPeers, prevConf.BootstrapPeers) {
+		h.conf.BootstrapPeers = newConf.BootstrapPeers
+		h.staticBootstraps, h.dnsBootstraps = splitBootstraps(h.conf)
+		h.resolvedBootstraps = nil
+		h.bootstrapNodes = h.staticBootstraps
+	}
+	h.logger.Infof("Applied reloaded config: %+v", h.conf)
+}
@@ -0,0 +1,16 @@
+package protocol
+
+import "github.com/nm-morais/go-babel/pkg/timer"
+
+// The protocol's state is only touched on the protocol goroutine. Public methods, and the goroutines
+// doing blocking work such as probes and lookups, hand their work over to it through onProtocol.
+
+// onProtocol runs f on the protocol goroutine, through a zero duration timer. what names f in the
+// handler panic counts.
+func (h *Hyparview) onProtocol(what string, f func()) {
+	h.babel.RegisterTimer(h.ID(), RunTimer{what: what, run: f})
+}
+
+func (h *Hyparview) HandleRunTimer(t timer.Timer) {
+	t.(RunTimer).run()
+}
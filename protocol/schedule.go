@@ -0,0 +1,110 @@
+package protocol
+
+import (
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// The protocol's own recurring timers (shuffle, promote, maintenance, debug, ...) are registered
+// through scheduleTimer/schedulePeriodicTimer, which keep track of when they fire next, so that
+// operators can check in the snapshot that work is actually being scheduled. A one-shot timer which
+// fired without being registered again has no next fire time.
+
+// scheduleState tracks the timers the protocol scheduled itself.
+type scheduleState struct {
+	periodicTimers  []int
+	scheduledTimers map[timer.ID]*ScheduledTimer
+}
+
+type ScheduledTimer struct {
+	Name      string        `json:"name"`
+	Periodic  bool          `json:"periodic"`
+	Period    time.Duration `json:"period"`
+	NextFire  time.Time     `json:"nextFire"`
+	LastFired time.Time     `json:"lastFired"`
+	// Lag is how late the timer last fired, the time it spent waiting behind other events
+	Lag time.Duration `json:"lag"`
+}
+
+func (h *Hyparview) scheduleTimer(t timer.Timer) int {
+	h.trackTimer(t, false, false)
+	return h.babel.RegisterTimer(h.ID(), t)
+}
+
+func (h *Hyparview) schedulePeriodicTimer(t timer.Timer, triggerAtTimeZero bool) int {
+	h.trackTimer(t, true, triggerAtTimeZero)
+	id := h.babel.RegisterPeriodicTimer(h.ID(), t, triggerAtTimeZero)
+	h.periodicTimers = append(h.periodicTimers, id)
+	return id
+}
+
+// cancelPeriodicTimer cancels a periodic timer before it is registered again, e.g. with a new period.
+func (h *Hyparview) cancelPeriodicTimer(id int) {
+	h.babel.CancelTimer(id)
+	for i, periodic := range h.periodicTimers {
+		if periodic == id {
+			h.periodicTimers = append(h.periodicTimers[:i], h.periodicTimers[i+1:]...)
+			break
+		}
+	}
+}
+
+// stopPeriodicTimers cancels every periodic timer, once the node left the overlay.
+func (h *Hyparview) stopPeriodicTimers() {
+	for _, id := range h.periodicTimers {
+		h.babel.CancelTimer(id)
+	}
+	h.periodicTimers = nil
+	for id, tracked := range h.scheduledTimers {
+		if tracked.Periodic {
+			delete(h.scheduledTimers, id)
+		}
+	}
+}
+
+func (h *Hyparview) trackTimer(t timer.Timer, periodic, triggerAtTimeZero bool) {
+	tracked := &ScheduledTimer{
+		Name:     reflect.TypeOf(t).Name(),
+		Periodic: periodic,
+		Period:   t.Duration(),
+		NextFire: h.timeNow().Add(t.Duration()),
+	}
+	if triggerAtTimeZero {
+		tracked.NextFire = h.timeNow()
+	}
+	if prev, ok := h.scheduledTimers[t.ID()]; ok {
+		tracked.LastFired = prev.LastFired
+	}
+	h.scheduledTimers[t.ID()] = tracked
+}
+
+func (h *Hyparview) timerFired(t timer.Timer) {
+	tracked, ok := h.scheduledTimers[t.ID()]
+	if !ok {
+		return
+	}
+	now := h.timeNow()
+	if !tracked.NextFire.IsZero() && now.After(tracked.NextFire) {
+		tracked.Lag = now.Sub(tracked.NextFire)
+	} else {
+		tracked.Lag = 0
+	}
+	tracked.LastFired = now
+	if tracked.Periodic {
+		tracked.NextFire = now.Add(tracked.Period)
+	} else {
+		tracked.NextFire = time.Time{}
+	}
+}
+
+func (h *Hyparview) scheduledTimersSnapshot() []ScheduledTimer {
+	timers := make([]ScheduledTimer, 0, len(h.scheduledTimers))
+	for _, tracked := range h.scheduledTimers {
+		timers = append(timers, *tracked)
+	}
+	sort.Slice(timers, func(i, j int) bool { return timers[i].Name < timers[j].Name })
+	return timers
+}
@@ -0,0 +1,48 @@
+package protocol
+
+import (
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// NAT hairpinning or a misconfigured advertise address can make a node receive its own address,
+// either as a ForwardJoin OriginalSender or inside peer lists. Self entries are never added to the
+// views, SelfAddressPolicy only chooses what else happens to the message carrying them. Note that
+// shuffle walks may legitimately end at a node listed in the shuffle, so "drop" also drops some
+// regular shuffles, it is meant for diagnosing deployments.
+const (
+	SelfAddressIgnore = "ignore" // skip the self entry silently, process the rest of the message
+	SelfAddressLog    = "log"    // skip the self entry and log a warning
+	SelfAddressDrop   = "drop"   // log a warning and drop the whole message
+)
+
+func (h *Hyparview) isSelf(p peer.Peer) bool {
+	return peer.PeersEqual(h.babel.SelfPeer(), p)
+}
+
+func (h *Hyparview) containsSelf(peers []peer.Peer) bool {
+	for _, p := range peers {
+		if h.isSelf(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropForSelfAddress applies the SelfAddressPolicy to a message from sender found to carry our own
+// address, returning true if the message must be dropped.
+func (h *Hyparview) dropForSelfAddress(sender peer.Peer, msgName string) bool {
+	h.selfAddressSeen++
+	switch h.conf.SelfAddressPolicy {
+	case SelfAddressDrop:
+		h.logger.Warnf("Dropping %s from %s carrying own address", msgName, sender.String())
+		return true
+	case SelfAddressLog:
+		h.logger.Warnf("Got own address in %s from %s, skipping it", msgName, sender.String())
+	}
+	return false
+}
+
+// dropIfContainsSelf is a shorthand for peer list carrying messages.
+func (h *Hyparview) dropIfContainsSelf(sender peer.Peer, msgName string, peers []peer.Peer) bool {
+	return h.containsSelf(peers) && h.dropForSelfAddress(sender, msgName)
+}
@@ -0,0 +1,25 @@
+package protocol
+
+// Bootstrap nodes listed in their own BootstrapPeers take a distinct startup path: they are the
+// overlay's entry points, so they do not join through the other bootstraps but are considered
+// joined right away, and with BootstrapPreload set to bootstraps (the default) they preload the other
+// bootstraps into their passive view, which the promote timer then connects them to. Passive view
+// members persisted with ExportPeers can be imported on top, as for any node. A bootstrap which later
+// becomes isolated recovers like any other node.
+
+const (
+	PreloadBootstraps = "bootstraps"
+	PreloadNone       = "none"
+)
+
+func (h *Hyparview) startAsBootstrap() {
+	h.logger.Info("Starting as bootstrap node, not joining")
+	if h.conf.BootstrapPreload != PreloadNone {
+		for _, b := range h.bootstrapCandidates() {
+			if !h.isBlacklisted(b) {
+				h.addPeerToPassiveView(b)
+			}
+		}
+	}
+	h.completeJoin(nil)
+}
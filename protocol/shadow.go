@@ -0,0 +1,125 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// With ShadowPolicy set, every promotion and every random active view drop is also decided by an
+// alternative policy, which is never acted upon. Decisions are compared with the real ones and
+// divergence statistics are logged as <shadowPolicy>, so a new policy can be evaluated on production
+// traffic before being enabled: how often both policies agree, and the mean staleness (time since
+// last heard of) of promoted peers and mean tenure (time in the active view) of dropped neighbours
+// under each policy. As policies are randomised, agreement is only meaningful relative to running
+// the shadow with the real policy's own settings.
+
+const (
+	DropRandom = "random"
+	DropOldest = "oldest"
+	DropNewest = "newest"
+)
+
+type ShadowDecisions struct {
+	Decisions  int           `json:"decisions"`
+	Agreed     int           `json:"agreed"`
+	RealMean   time.Duration `json:"realMean"`
+	ShadowMean time.Duration `json:"shadowMean"`
+	realSum    time.Duration
+	shadowSum  time.Duration
+}
+
+type ShadowStats struct {
+	Promotions ShadowDecisions `json:"promotions"`
+	Drops      ShadowDecisions `json:"drops"`
+}
+
+func (d *ShadowDecisions) record(agreed bool, real, shadow time.Duration) {
+	d.Decisions++
+	if agreed {
+		d.Agreed++
+	}
+	d.realSum += real
+	d.shadowSum += shadow
+	d.RealMean = d.realSum / time.Duration(d.Decisions)
+	d.ShadowMean = d.shadowSum / time.Duration(d.Decisions)
+}
+
+func validateShadowPolicy(conf *HyparviewConfig) error {
+	if conf.ShadowPolicy == nil {
+		return nil
+	}
+	bias := conf.ShadowPolicy.PromotionRecencyBias
+	if bias < 0 || bias > maxPromotionRecencyBias {
+		return fmt.Errorf("shadowPolicy.promotionRecencyBias must be between 0 and %d", maxPromotionRecencyBias)
+	}
+	switch conf.ShadowPolicy.DropPolicy {
+	case "", DropRandom, DropOldest, DropNewest:
+		return nil
+	default:
+		return fmt.Errorf("unknown shadowPolicy.dropPolicy %q", conf.ShadowPolicy.DropPolicy)
+	}
+}
+
+// shadowPromotion records the candidate the shadow policy would have promoted instead of chosen.
+func (h *Hyparview) shadowPromotion(excluded map[string]bool, chosen peer.Peer) {
+	if h.conf.ShadowPolicy == nil || chosen == nil {
+		return
+	}
+	shadow := h.pickCandidate(h.conf.ShadowPolicy.PromotionRecencyBias, excluded)
+	if shadow == nil {
+		return
+	}
+	h.shadowStats.Promotions.record(peer.PeersEqual(chosen, shadow), h.staleness(chosen), h.staleness(shadow))
+}
+
+func (h *Hyparview) staleness(p peer.Peer) time.Duration {
+	if state, ok := h.passiveView.get(p); ok {
+		return h.elapsedSince(state.lastHeard)
+	}
+	return 0
+}
+
+// shadowDrop returns the neighbour the shadow policy would drop from the active view, if enabled.
+func (h *Hyparview) shadowDrop() *PeerState {
+	if h.conf.ShadowPolicy == nil || h.activeView.size() == 0 {
+		return nil
+	}
+	var dropped *PeerState
+	for _, p := range h.activeView.asArr {
+		switch h.conf.ShadowPolicy.DropPolicy {
+		case DropOldest:
+			if dropped == nil || p.addedAt.Before(dropped.addedAt) {
+				dropped = p
+			}
+		case DropNewest:
+			if dropped == nil || p.addedAt.After(dropped.addedAt) {
+				dropped = p
+			}
+		}
+	}
+	if dropped == nil {
+		dropped = h.activeView.asArr[getRandInt(h.activeView.size())]
+	}
+	return dropped
+}
+
+func (h *Hyparview) recordShadowDrop(real, shadow *PeerState) {
+	if real == nil || shadow == nil {
+		return
+	}
+	h.shadowStats.Drops.record(peer.PeersEqual(real, shadow), h.elapsedSince(real.addedAt), h.elapsedSince(shadow.addedAt))
+}
+
+func (h *Hyparview) logShadowStats() {
+	if h.conf.ShadowPolicy == nil {
+		return
+	}
+	res, err := json.Marshal(h.shadowStats)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("shadowPolicy", "%s", string(res))
+}
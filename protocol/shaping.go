@@ -0,0 +1,31 @@
+package protocol
+
+import "time"
+
+// shapingState counts the shuffles forwarded in the last second.
+type shapingState struct {
+	recentShuffleForwards []time.Time
+	shuffleForwardsCapped int
+}
+
+// shuffleForwardRateExceeded caps the shuffles forwarded per second, past the cap walks end here
+// and are answered with a passive view sample, so that nodes with small active views do not become
+// hotspots for shuffle traffic.
+func (h *Hyparview) shuffleForwardRateExceeded() bool {
+	if h.conf.MaxShuffleForwardsPerSecond <= 0 {
+		return false
+	}
+	recent := h.recentShuffleForwards[:0]
+	for _, t := range h.recentShuffleForwards {
+		if h.elapsedSince(t) < time.Second {
+			recent = append(recent, t)
+		}
+	}
+	h.recentShuffleForwards = recent
+	if len(h.recentShuffleForwards) >= h.conf.MaxShuffleForwardsPerSecond {
+		h.shuffleForwardsCapped++
+		return true
+	}
+	h.recentShuffleForwards = append(h.recentShuffleForwards, h.timeNow())
+	return false
+}
@@ -0,0 +1,88 @@
+package protocol
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// Shuffle IDs are sequence numbers prefixed by an epoch: the lower shuffleSeqBits count the shuffles
+// sent by the node, Cyclon ones included, and the upper bits hold the node's incarnation, or a random
+// epoch chosen on start if incarnations are not persisted, so IDs are not reused across restarts.
+// Replies can then be told apart: a reply to the last shuffle is merged as usual, a repeated reply is
+// dropped, and a late reply to an earlier shuffle is merged without discarding the peers sent in the
+// last one, whose reply is still expected.
+
+// shuffleSeqState numbers the shuffles sent and remembers the replies already merged.
+type shuffleSeqState struct {
+	shuffleEpoch       uint32
+	shuffleSeq         uint32
+	answeredShuffleIDs []uint32
+	shuffleReplyStats  ShuffleReplyStats
+}
+
+const (
+	shuffleSeqBits = 20
+	shuffleSeqMask = 1<<shuffleSeqBits - 1
+
+	maxAnsweredShuffleIDs = 16
+)
+
+type ShuffleReplyStats struct {
+	Sent       int `json:"sent"`
+	Answered   int `json:"answered"`
+	Duplicate  int `json:"duplicate"`
+	OutOfOrder int `json:"outOfOrder"`
+	Unknown    int `json:"unknown"`
+}
+
+func (h *Hyparview) initShuffleEpoch() {
+	if h.incarnation != 0 {
+		h.shuffleEpoch = uint32(h.incarnation)
+		return
+	}
+	h.shuffleEpoch = uint32(getRandInt(math.MaxUint32))
+}
+
+func (h *Hyparview) nextShuffleID() uint32 {
+	h.shuffleSeq++
+	if h.shuffleSeq > shuffleSeqMask {
+		h.shuffleEpoch++
+		h.shuffleSeq = 1
+	}
+	return h.shuffleEpoch<<shuffleSeqBits | h.shuffleSeq
+}
+
+// classifyShuffleReply returns whether a reply with the given ID must be merged, and whether it answers
+// the last shuffle sent.
+func (h *Hyparview) classifyShuffleReply(id uint32) (merge, current bool) {
+	for _, answered := range h.answeredShuffleIDs {
+		if answered == id {
+			h.shuffleReplyStats.Duplicate++
+			h.logger.Warnf("Dropping duplicate reply to shuffle %d", id)
+			return false, false
+		}
+	}
+	h.answeredShuffleIDs = append(h.answeredShuffleIDs, id)
+	if len(h.answeredShuffleIDs) > maxAnsweredShuffleIDs {
+		h.answeredShuffleIDs = h.answeredShuffleIDs[1:]
+	}
+	if h.lastShuffleMsg != nil && h.lastShuffleMsg.ID == id {
+		h.shuffleReplyStats.Answered++
+		return true, true
+	}
+	epoch, seq := id>>shuffleSeqBits, id&shuffleSeqMask
+	if epoch == h.shuffleEpoch&(math.MaxUint32>>shuffleSeqBits) && seq < h.shuffleSeq {
+		h.shuffleReplyStats.OutOfOrder++
+	} else {
+		h.shuffleReplyStats.Unknown++
+	}
+	return true, false
+}
+
+func (h *Hyparview) logShuffleReplyStats() {
+	toPrint, err := json.Marshal(h.shuffleReplyStats)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("shuffleReplies", "%s", string(toPrint))
+}
@@ -0,0 +1,27 @@
+package protocol
+
+import "github.com/nm-morais/go-babel/pkg/peer"
+
+// DoShuffleWith shuffles with target right away, outside of the shuffle timer. The shuffle is sent
+// with a TTL of 0 so that target itself answers it, which lets operators inspect the passive view
+// content exchanged with a given node, or repair the stale views of a specific node.
+func (h *Hyparview) DoShuffleWith(target peer.Peer) {
+	h.onProtocol("DoShuffleWith", func() { h.shuffleWith(target) })
+}
+
+func (h *Hyparview) shuffleWith(target peer.Peer) {
+	if h.isSelf(target) {
+		h.logger.Warn("Not shuffling with self")
+		return
+	}
+	toSend := h.newShuffleMessage(0, target)
+	h.logger.Infof("Sending requested shuffle message to %s", target.String())
+	if h.activeView.contains(target) {
+		h.sendShuffleMessage(toSend, target)
+		return
+	}
+	toSend.Capabilities = h.localCapabilities()
+	toSend.OverlayID = h.overlayID()
+	toSend.ConfigUpdate = h.configUpdateToGossip()
+	h.sendMessageTmpTransport(toSend, target)
+}
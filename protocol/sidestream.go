@@ -0,0 +1,88 @@
+package protocol
+
+import (
+	"hash/fnv"
+	"reflect"
+	"sync"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Side-stream sends may set up a TCP connection and wait for a dial timeout. With SideStreamWorkers
+// set they are handed to a pool of workers so that unreachable targets do not hold up the protocol
+// goroutine. Each destination is always served by the same worker, preserving the order of the
+// messages sent to it. Sends finding the worker queue full are dropped, as with any lost message.
+// Leaving closes the queues: the workers exit once they sent what was queued before, such as the
+// disconnects of leave, and later sends are dropped.
+
+// SideStreamConfig sizes the side stream worker pool.
+type SideStreamConfig struct {
+	SideStreamDisconnect bool `yaml:"sideStreamDisconnect"` // deprecated, disconnects to unconnected peers always use a side stream
+	SideStreamWorkers    int  `yaml:"sideStreamWorkers"`
+	SideStreamQueueSize  int  `yaml:"sideStreamQueueSize"`
+}
+
+// sideStreamState holds the worker queues, nil without SideStreamWorkers, and the running workers,
+// which Leave waits for.
+type sideStreamState struct {
+	sideStreamQueues  []chan sideStreamSend
+	sideStreamWorkers sync.WaitGroup
+	sideStreamDropped int
+}
+
+const defaultSideStreamQueueSize = 64
+
+type sideStreamSend struct {
+	msg    message.Message
+	target peer.Peer
+}
+
+func (h *Hyparview) startSideStreamWorkers() {
+	if h.conf.SideStreamWorkers <= 0 {
+		return
+	}
+	queueSize := h.conf.SideStreamQueueSize
+	if queueSize <= 0 {
+		queueSize = defaultSideStreamQueueSize
+	}
+	h.sideStreamQueues = make([]chan sideStreamSend, h.conf.SideStreamWorkers)
+	for i := range h.sideStreamQueues {
+		queue := make(chan sideStreamSend, queueSize)
+		h.sideStreamQueues[i] = queue
+		h.sideStreamWorkers.Add(1)
+		go func() {
+			defer h.sideStreamWorkers.Done()
+			for s := range queue {
+				h.babel.SendMessageSideStream(s.msg, s.target, s.target.ToTCPAddr(), h.ID(), h.ID())
+			}
+		}()
+	}
+}
+
+func (h *Hyparview) stopSideStreamWorkers() {
+	for _, queue := range h.sideStreamQueues {
+		close(queue)
+	}
+}
+
+func (h *Hyparview) sendSideStream(msg message.Message, target peer.Peer) {
+	if h.hasLeft() {
+		h.logger.Warnf("Left the overlay, dropping %s to %s", reflect.TypeOf(msg), target.String())
+		return
+	}
+	h.tapMessage(Outbound, target, msg)
+	if len(h.sideStreamQueues) == 0 {
+		h.babel.SendMessageSideStream(msg, target, target.ToTCPAddr(), h.ID(), h.ID())
+		return
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(target.String()))
+	queue := h.sideStreamQueues[hash.Sum32()%uint32(len(h.sideStreamQueues))]
+	select {
+	case queue <- sideStreamSend{msg: msg, target: target}:
+	default:
+		h.sideStreamDropped++
+		h.logger.Warnf("Side stream queue full, dropping %s to %s", reflect.TypeOf(msg), target.String())
+	}
+}
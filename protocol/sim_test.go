@@ -0,0 +1,164 @@
+package protocol_test
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/nm-morais/x-bot/protocol"
+	"github.com/nm-morais/x-bot/testutil"
+	"github.com/nm-morais/x-bot/testutil/babeltest"
+)
+
+func simConfig(t *testing.T) protocol.HyparviewConfig {
+	// the simulation replaces the addresses of the nodes and of the bootstrap
+	conf, err := protocol.NewConfig("127.0.0.1", 1200,
+		protocol.WithBootstrap("127.0.0.1", 1201),
+		protocol.WithTimers(protocol.Timers{
+			Join:        2 * time.Second,
+			MinShuffle:  5 * time.Second,
+			DialTimeout: 2 * time.Second,
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *conf
+}
+
+func newSimulation(t *testing.T, n int, simConf testutil.SimConfig) *testutil.Simulation {
+	sim, err := testutil.NewSimulation(n, simConfig(t), simConf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sim.Close)
+	return sim
+}
+
+func TestSimulationConverges(t *testing.T) {
+	sim := newSimulation(t, 20, testutil.SimConfig{Seed: 1, Latency: 10 * time.Millisecond, Jitter: 5 * time.Millisecond})
+	if err := sim.WaitForConvergence(2 * time.Minute); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimulationConvergesAfterCrash(t *testing.T) {
+	sim := newSimulation(t, 20, testutil.SimConfig{Seed: 2, Latency: 10 * time.Millisecond, Jitter: 5 * time.Millisecond})
+	if err := sim.WaitForConvergence(2 * time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	crashed := map[string]bool{}
+	for _, i := range []int{3, 7, 11} {
+		sim.Crash(i)
+		crashed[sim.Nodes[i].Peer.String()] = true
+	}
+	if err := sim.WaitForConvergence(2 * time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range sim.Nodes {
+		if crashed[n.Peer.String()] {
+			continue
+		}
+		for _, p := range n.Hyparview.Snapshot().Active {
+			if crashed[p.Peer] {
+				t.Fatalf("%s still has crashed node %s as neighbour", n.Peer, p.Peer)
+			}
+		}
+	}
+}
+
+func TestSimulationJoinsUnderLoss(t *testing.T) {
+	sim := newSimulation(t, 20, testutil.SimConfig{Seed: 3, Latency: 10 * time.Millisecond, Jitter: 20 * time.Millisecond, Loss: 0.05})
+	if err := sim.WaitForConvergence(5 * time.Minute); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSimulationConvergesAfterDomainCrash(t *testing.T) {
+	sim := newSimulation(t, 24, testutil.SimConfig{
+		Seed:           4,
+		Latency:        10 * time.Millisecond,
+		Jitter:         5 * time.Millisecond,
+		FailureDomains: []string{"rack-1", "rack-2", "rack-3"},
+	})
+	if err := sim.WaitForConvergence(2 * time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if crashed := sim.CrashDomain("rack-2"); crashed != 8 {
+		t.Fatalf("crashed %d nodes of rack-2, expected 8", crashed)
+	}
+	if crashed := sim.CrashDomain("rack-2"); crashed != 0 {
+		t.Fatalf("crashed %d nodes of rack-2 again", crashed)
+	}
+	if err := sim.WaitForConvergence(2 * time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	crashed := map[string]bool{}
+	for _, n := range sim.Nodes {
+		if n.Conf.FailureDomain == "rack-2" {
+			crashed[n.Peer.String()] = true
+		}
+	}
+	for _, n := range sim.Nodes {
+		if crashed[n.Peer.String()] {
+			continue
+		}
+		for _, p := range n.Hyparview.Snapshot().Active {
+			if crashed[p.Peer] {
+				t.Fatalf("%s still has %s of the crashed domain as neighbour", n.Peer, p.Peer)
+			}
+		}
+	}
+}
+
+func TestSimulationNoMessagesAfterLeave(t *testing.T) {
+	sim := newSimulation(t, 10, testutil.SimConfig{Seed: 5, Latency: 10 * time.Millisecond, Jitter: 5 * time.Millisecond})
+	if err := sim.WaitForConvergence(2 * time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	leaving := sim.Nodes[4]
+	<-leaving.Hyparview.Leave()
+	if active := leaving.Hyparview.Snapshot().Active; len(active) != 0 {
+		t.Fatalf("active view not emptied on leave: %+v", active)
+	}
+	sent := leaving.Babel.Sent()
+	sim.RunFor(2 * time.Minute)
+	if leaving.Babel.Sent() != sent {
+		t.Fatalf("node sent %d messages after leaving", leaving.Babel.Sent()-sent)
+	}
+}
+
+func TestSimulationSideStreamWorkersStopOnLeave(t *testing.T) {
+	conf := simConfig(t)
+	conf.SideStreamWorkers = 2
+	sim, err := testutil.NewSimulation(10, conf, testutil.SimConfig{Seed: 6, Latency: 10 * time.Millisecond, Jitter: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sim.Close)
+	// the workers hand their sends to the network in real time, so the views need not converge
+	sim.RunFor(time.Minute)
+	leaving := sim.Nodes[4]
+	goroutines := runtime.NumGoroutine()
+	<-leaving.Hyparview.Leave()
+	sent := settledSent(leaving.Babel)
+	if runtime.NumGoroutine() != goroutines-conf.SideStreamWorkers {
+		t.Fatalf("side stream workers still running after leave: %d goroutines, %d before", runtime.NumGoroutine(), goroutines)
+	}
+	sim.RunFor(2 * time.Minute)
+	if after := settledSent(leaving.Babel); after != sent {
+		t.Fatalf("node sent %d messages after leaving", after-sent)
+	}
+}
+
+// settledSent waits for the side stream workers to send what they have queued.
+func settledSent(b *babeltest.Babel) int {
+	sent := b.Sent()
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if b.Sent() == sent {
+			return sent
+		}
+		sent = b.Sent()
+	}
+	return sent
+}
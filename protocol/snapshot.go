@@ -0,0 +1,137 @@
+package protocol
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+const maxRecentEvents = 100
+
+// Event is a view change, kept in a bounded list of recent events for inspection.
+type Event struct {
+	Time time.Time `json:"time"`
+	View string    `json:"view"`
+	Kind string    `json:"kind"`
+	Peer string    `json:"peer"`
+}
+
+type SnapshotPeer struct {
+	Peer          string     `json:"peer"`
+	Connected     bool       `json:"connected"`
+	Age           uint16     `json:"age"`
+	FailureDomain string     `json:"failureDomain,omitempty"`
+	Version       uint16     `json:"version,omitempty"`
+	Bandwidth     *Bandwidth `json:"bandwidth,omitempty"`
+	Origin        string     `json:"origin,omitempty"`
+}
+
+type NodeSnapshot struct {
+	Self                  string            `json:"self"`
+	Joined                bool              `json:"joined"`
+	ViewVersion           uint64            `json:"viewVersion"`
+	Uptime                time.Duration     `json:"uptime"`
+	Active                []SnapshotPeer    `json:"active"`
+	Passive               []SnapshotPeer    `json:"passive"`
+	Bootstrap             BootstrapStats    `json:"bootstrap"`
+	HandlerPanics         map[string]int    `json:"handlerPanics"`
+	DeniedForeignConns    map[uint16]int    `json:"deniedForeignConns"`
+	SelfAddressSeen       int               `json:"selfAddressSeen"`
+	ShuffleForwardsCapped int               `json:"shuffleForwardsCapped"`
+	OverlayMismatches     int               `json:"overlayMismatches"`
+	TokenMismatches       int               `json:"clusterTokenMismatches"`
+	OriginCapped          int               `json:"passiveOriginCapped"`
+	EventQueue            EventQueueStats   `json:"eventQueue"`
+	PeerLifetimes         PeerLifetimeStats `json:"peerLifetimes"`
+	ShuffleReplies        ShuffleReplyStats `json:"shuffleReplies"`
+	LivenessProbes        LivenessStats     `json:"livenessProbes"`
+	Maintenance           MaintenanceDump   `json:"maintenance"`
+	Actions               ActionStats       `json:"actions"`
+	InDegree              InDegreeStats     `json:"inDegree"`
+	Blacklisted           int               `json:"blacklisted"`
+	WatchdogStalls        int64             `json:"watchdogStalls"`
+	Events                []Event           `json:"events"`
+	Timers                []ScheduledTimer  `json:"timers"`
+	Lifecycle             PhaseStats        `json:"lifecycle"`
+}
+
+func (h *Hyparview) recordViewEvents() {
+	for _, view := range []ViewID{ActiveView, PassiveView} {
+		h.onAfterAdd(view, func(view ViewID, p peer.Peer) {
+			h.recordEvent(view, "added", p)
+		})
+		h.onBeforeRemove(view, func(view ViewID, p peer.Peer) {
+			h.recordEvent(view, "removed", p)
+		})
+	}
+}
+
+func (h *Hyparview) recordEvent(view ViewID, kind string, p peer.Peer) {
+	h.events = append(h.events, Event{Time: h.timeNow(), View: view.String(), Kind: kind, Peer: p.String()})
+	if len(h.events) > maxRecentEvents {
+		h.events = h.events[1:]
+	}
+}
+
+// Snapshot returns the views, counters and recent events of the node, it blocks until the protocol
+// goroutine takes the snapshot.
+func (h *Hyparview) Snapshot() NodeSnapshot {
+	snapshotCh := make(chan NodeSnapshot, 1)
+	h.onProtocol("Snapshot", func() { snapshotCh <- h.snapshot() })
+	return <-snapshotCh
+}
+
+func (h *Hyparview) snapshot() NodeSnapshot {
+	snapshot := NodeSnapshot{
+		Self:                  h.babel.SelfPeer().String(),
+		Joined:                h.isJoinDone(),
+		ViewVersion:           h.CurrentViewVersion(),
+		Uptime:                h.timeSince(h.timeStart),
+		Active:                snapshotPeers(h.activeView),
+		Passive:               snapshotPeers(h.passiveView),
+		Bootstrap:             *h.bootstrapStats,
+		HandlerPanics:         map[string]int{},
+		DeniedForeignConns:    map[uint16]int{},
+		SelfAddressSeen:       h.selfAddressSeen,
+		ShuffleForwardsCapped: h.shuffleForwardsCapped,
+		OverlayMismatches:     h.overlayMismatches,
+		TokenMismatches:       h.tokenMismatches,
+		OriginCapped:          h.passiveOriginCapped,
+		EventQueue:            h.eventQueueSnapshot(),
+		PeerLifetimes:         h.peerLifetimesSnapshot(),
+		ShuffleReplies:        h.shuffleReplyStats,
+		LivenessProbes:        h.livenessStats,
+		Maintenance:           h.maintenanceDump(),
+		Actions:               h.actionStatsSnapshot(),
+		InDegree:              h.inDegreeStats(),
+		Blacklisted:           len(h.blacklist),
+		WatchdogStalls:        atomic.LoadInt64(&h.watchdogStalls),
+		Events:                append([]Event{}, h.events...),
+		Timers:                h.scheduledTimersSnapshot(),
+		Lifecycle:             h.phaseStats(),
+	}
+	for handled, count := range h.handlerPanics {
+		snapshot.HandlerPanics[handled] = count
+	}
+	for dialerProto, count := range h.deniedForeignConns {
+		snapshot.DeniedForeignConns[uint16(dialerProto)] = count
+	}
+	return snapshot
+}
+
+func snapshotPeers(view *View) []SnapshotPeer {
+	peers := make([]SnapshotPeer, 0, view.size())
+	for _, p := range view.asArr {
+		peers = append(peers, SnapshotPeer{
+			Peer:          p.String(),
+			Connected:     p.outConnected,
+			Age:           p.age,
+			FailureDomain: p.failureDomain,
+			Version:       p.version,
+			Bandwidth:     p.bandwidthEstimate(),
+			Origin:        p.origin,
+		})
+	}
+	return peers
+}
@@ -0,0 +1,59 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// Primary bootstraps periodically mirror a snapshot of their views to the standby bootstraps,
+// so standbys can serve joins with useful peer samples as soon as the primaries fail.
+
+// StandbyConfig lists the standby bootstraps the primaries mirror their views to.
+type StandbyConfig struct {
+	StandbyBootstrapPeers []struct {
+		Port          int    `yaml:"port"`
+		Host          string `yaml:"host"`
+		AnalyticsPort int    `yaml:"analyticsPort"`
+	} `yaml:"standbyBootstrapPeers"`
+	StandbyBootstrap           bool `yaml:"standbyBootstrap"`
+	MirrorTimerDurationSeconds int  `yaml:"mirrorTimerDurationSeconds"`
+}
+
+const defaultMirrorTimerDuration = 10 * time.Second
+
+func (h *Hyparview) mirrorTimerDuration() time.Duration {
+	if h.conf.MirrorTimerDurationSeconds <= 0 {
+		return defaultMirrorTimerDuration
+	}
+	return time.Duration(h.conf.MirrorTimerDurationSeconds) * time.Second
+}
+
+func (h *Hyparview) HandleMirrorTimer(t timer.Timer) {
+	snapshot := h.dialableOnly(h.activeView.getRandomElementsFromView(h.activeView.size()))
+	snapshot = append(snapshot, h.passiveView.getRandomElementsFromView(h.passiveView.size())...)
+	snapshot = append(snapshot, h.babel.SelfPeer())
+	for _, standby := range h.standbyBootstraps {
+		if peer.PeersEqual(standby, h.babel.SelfPeer()) {
+			continue
+		}
+		h.logger.Infof("Mirroring view snapshot with %d peers to standby bootstrap %s", len(snapshot), standby.String())
+		h.sendMessageTmpTransport(ViewSnapshotMessage{Peers: snapshot}, standby)
+	}
+}
+
+func (h *Hyparview) HandleViewSnapshotMessage(sender peer.Peer, msg message.Message) {
+	if !h.conf.StandbyBootstrap {
+		h.logger.Warnf("Got view snapshot from %s but not acting as standby bootstrap", sender.String())
+		return
+	}
+	if !h.isBootstrapNode(sender) {
+		h.logger.Warnf("Discarding view snapshot from %s, not a primary bootstrap", sender.String())
+		return
+	}
+	snapshot := msg.(ViewSnapshotMessage)
+	h.logger.Infof("Got view snapshot with %d peers from %s", len(snapshot.Peers), sender.String())
+	h.mergeShuffleMsgPeersWithPassiveView(snapshot.Peers, []peer.Peer{}, sender)
+}
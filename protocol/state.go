@@ -3,14 +3,17 @@ package protocol
 import (
 	"fmt"
 	"math/rand"
+	"time"
 
 	"github.com/nm-morais/go-babel/pkg/peer"
 )
 
 type View struct {
+	id       ViewID
 	capacity int
 	asArr    []*PeerState
 	asMap    map[string]*PeerState
+	hooks    viewHooks
 }
 
 func (v *View) size() int {
@@ -25,6 +28,7 @@ func (v *View) contains(p fmt.Stringer) bool {
 func (v *View) dropRandom() *PeerState {
 	toDropIdx := getRandInt(len(v.asArr))
 	peerDropped := v.asArr[toDropIdx]
+	v.runBeforeRemove(peerDropped)
 	v.asArr = append(v.asArr[:toDropIdx], v.asArr[toDropIdx+1:]...)
 	delete(v.asMap, peerDropped.String())
 	return peerDropped
@@ -49,6 +53,7 @@ func (v *View) add(p *PeerState, dropIfFull bool) {
 func (v *View) remove(p peer.Peer) *PeerState {
 	removed, existed := v.asMap[p.String()]
 	if existed {
+		v.runBeforeRemove(removed)
 		found := false
 		for idx, curr := range v.asArr {
 			if peer.PeersEqual(curr, p) {
@@ -81,10 +86,28 @@ func (v *View) toArray() []*PeerState {
 	return v.asArr
 }
 
+func (v *View) oldest() *PeerState {
+	var oldest *PeerState
+	for _, p := range v.asArr {
+		if oldest == nil || p.age > oldest.age {
+			oldest = p
+		}
+	}
+	return oldest
+}
+
 func (v *View) getRandomElementsFromView(amount int, exclusions ...peer.Peer) []peer.Peer {
+	rndElements := []peer.Peer{}
+	for _, p := range v.getRandomStatesFromView(amount, exclusions...) {
+		rndElements = append(rndElements, p)
+	}
+	return rndElements
+}
+
+func (v *View) getRandomStatesFromView(amount int, exclusions ...peer.Peer) []*PeerState {
 	viewAsArr := v.toArray()
 	perm := rand.Perm(len(viewAsArr))
-	rndElements := []peer.Peer{}
+	rndElements := []*PeerState{}
 	for i := 0; i < len(viewAsArr) && len(rndElements) < amount; i++ {
 		excluded := false
 		curr := viewAsArr[perm[i]]
@@ -103,7 +126,25 @@ func (v *View) getRandomElementsFromView(amount int, exclusions ...peer.Peer) []
 
 type PeerState struct {
 	peer.Peer
-	outConnected bool
+	outConnected  bool
+	dialing       bool
+	age           uint16
+	failureDomain string
+	capabilities  uint8
+	link          *linkStats
+	lastHeard     time.Time
+	clock         *clockStats
+	incarnation   uint64
+	addedAt       time.Time
+	version       uint16
+	bandwidth     *bandwidthStats
+	dialStartedAt time.Time
+	dialDeferred  time.Time
+	lastInbound   time.Time
+	liveness      *livenessProbe
+	origin        string
+	maintenance   *maintenanceStats
+	metadata      *peerMetadata
 }
 
 type HyparviewState struct {
@@ -112,8 +153,9 @@ type HyparviewState struct {
 }
 
 func (h *Hyparview) addPeerToActiveView(newPeer peer.Peer) bool {
-	if peer.PeersEqual(h.babel.SelfPeer(), newPeer) {
-		h.logger.Panic("Trying to add self to active view")
+	if h.isSelf(newPeer) {
+		h.logger.Error("Trying to add self to active view")
+		return false
 	}
 
 	if h.activeView.contains(newPeer) {
@@ -121,7 +163,21 @@ func (h *Hyparview) addPeerToActiveView(newPeer peer.Peer) bool {
 		return false
 	}
 
+	if h.decommissioning() {
+		h.logger.Warnf("Not adding %s to active view, decommissioning", newPeer.String())
+		return false
+	}
+
+	if !h.activeView.runBeforeAdd(newPeer) {
+		h.logger.Warnf("Addition of peer %s to active view was vetoed", newPeer.String())
+		return false
+	}
+
 	if h.activeView.isFull() {
+		if h.frozen() {
+			h.logger.Warnf("Not adding %s to full active view, membership is frozen", newPeer.String())
+			return false
+		}
 		h.dropRandomElemFromActiveView()
 	}
 
@@ -131,18 +187,27 @@ func (h *Hyparview) addPeerToActiveView(newPeer peer.Peer) bool {
 	}
 
 	h.logger.Warnf("Added peer %s to active view", newPeer.String())
-	h.activeView.add(&PeerState{
+	added := &PeerState{
 		Peer:         newPeer,
 		outConnected: false,
-	}, false)
-	h.babel.Dial(h.ID(), newPeer, newPeer.ToTCPAddr())
-	h.logHyparviewState()
+		addedAt:      h.timeNow(),
+	}
+	h.activeView.add(added, false)
+	h.activeView.runAfterAdd(newPeer)
+	h.dialPeer(added)
+	h.viewsChanged()
 	return true
 }
 
 func (h *Hyparview) addPeerToPassiveView(newPeer peer.Peer) {
-	if peer.PeersEqual(newPeer, h.babel.SelfPeer()) {
-		h.logger.Panic("trying to add self to passive view ")
+	h.addPeerToPassiveViewWithAge(newPeer, 0, nil)
+}
+
+// addPeerToPassiveViewWithAge adds newPeer, learned from origin (nil if learned directly).
+func (h *Hyparview) addPeerToPassiveViewWithAge(newPeer peer.Peer, age uint16, origin peer.Peer) {
+	if h.isSelf(newPeer) {
+		h.logger.Error("trying to add self to passive view ")
+		return
 	}
 
 	if h.activeView.contains(newPeer) {
@@ -150,28 +215,100 @@ func (h *Hyparview) addPeerToPassiveView(newPeer peer.Peer) {
 		return
 	}
 
+	if h.passiveView.contains(newPeer) || h.joinOnlyContact(newPeer) {
+		return
+	}
+
+	if !h.isDialable(newPeer) {
+		return
+	}
+
+	if !h.passiveView.runBeforeAdd(newPeer) {
+		h.logger.Warnf("Addition of peer %s to passive view was vetoed", newPeer.String())
+		return
+	}
+
 	h.passiveView.add(&PeerState{
 		Peer:         newPeer,
 		outConnected: false,
+		age:          age,
+		lastHeard:    h.timeNow(),
+		origin:       originString(origin),
 	}, true)
+	h.passiveView.runAfterAdd(newPeer)
 	h.logger.Warnf("Added peer %s to passive view", newPeer.String())
-	h.logHyparviewState()
+	h.viewsChanged()
 }
 
 func (h *Hyparview) dropRandomElemFromActiveView() {
+	shadow := h.shadowDrop()
+	h.removalReason = RemovalDroppedRandom
 	removed := h.activeView.dropRandom()
+	h.removalReason = ""
+	h.recordShadowDrop(removed, shadow)
 	if removed != nil {
-		h.addPeerToPassiveView(removed)
-		disconnectMsg := DisconnectMessage{}
+		h.addPeerToPassiveView(removed.Peer)
+		h.sendPrune(removed)
 		if removed.outConnected {
-			h.babel.SendMessageAndDisconnect(disconnectMsg, removed, h.ID(), h.ID())
 			h.babel.SendNotification(NeighborDownNotification{
-				PeerDown: removed,
-				View:     h.getView(),
+				PeerDown:    removed,
+				View:        h.getView(),
+				ViewVersion: h.nextViewVersion(),
 			})
-		} else {
-			h.babel.SendMessageSideStream(disconnectMsg, removed, removed.ToTCPAddr(), h.ID(), h.ID())
 		}
-		h.logHyparviewState()
+		h.viewsChanged()
+	}
+}
+
+// sendDisconnect sends the DisconnectMessage over the existing stream and tears it down if the peer
+// is connected, otherwise through a side stream, so that the peer drops us even if our dial to it is
+// still pending or failed. Pending dials are torn down by DialSuccess, as the peer is no longer in the
+// active view.
+func (h *Hyparview) sendDisconnect(p *PeerState) {
+	h.deliverDisconnect(p, DisconnectMessage{
+		Peers: h.passiveView.getRandomElementsFromView(h.conf.Kp, p.Peer),
+	})
+}
+
+func (h *Hyparview) deliverDisconnect(p *PeerState, toSend DisconnectMessage) {
+	if p.outConnected {
+		h.tapMessage(Outbound, p.Peer, toSend)
+		h.babel.SendMessageAndDisconnect(toSend, p.Peer, h.ID(), h.ID())
+		return
+	}
+	h.sendMessageTmpTransport(toSend, p.Peer)
+}
+
+// dialPeer queues a dial to p, see actions.go. Every dial goes through it, so that a dial in flight or
+// deferred by deferDial is not duplicated by the maintenance timer.
+func (h *Hyparview) dialPeer(p *PeerState) {
+	if !h.shouldDial(p) {
+		return
+	}
+	h.queueAction(actionDial, p.Peer, priorityHigh)
+}
+
+func (h *Hyparview) shouldDial(p *PeerState) bool {
+	return !p.outConnected && !p.dialing && !h.timeNow().Before(p.dialDeferred) && h.isDialable(p)
+}
+
+// deferDial holds off dials to p for a dial timeout, while p is expected to dial first. Maintenance
+// messages from p end the wait early, as they arrive once p's connection is up.
+func (h *Hyparview) deferDial(p *PeerState) {
+	if p.outConnected || p.dialing {
+		return
+	}
+	p.dialDeferred = h.timeNow().Add(h.dialTimeout(p.Peer))
+}
+
+func (h *Hyparview) dialNow(p *PeerState) {
+	if !h.shouldDial(p) {
+		return
+	}
+	p.dialing = true
+	p.dialStartedAt = h.timeNow()
+	h.babel.Dial(h.ID(), p.Peer, p.ToTCPAddr())
+	if timeout := h.dialTimeout(p.Peer); timeout < BabelDialTimeout(h.conf) {
+		h.babel.RegisterTimer(h.ID(), DialTimeoutTimer{duration: timeout, peer: p.Peer, startedAt: p.dialStartedAt})
 	}
 }
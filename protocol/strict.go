@@ -0,0 +1,55 @@
+package protocol
+
+// With StrictPaper set the protocol follows the pseudo-code of the HyParView paper (Leitão et al.,
+// DSN 2007), as a baseline for comparisons against the extended behaviour:
+//   - no maintenance messages nor dangling neighbour counters, and no periodic promote timer, so
+//     passive view members are only promoted when a neighbour fails, and isolated nodes do not rejoin
+//   - neighbour requests are high priority only if the active view is empty, and a rejected request
+//     is retried with another passive view member
+//   - joins are only answered by the contact node and forward joins are forwarded to any neighbour
+//     but the sender, without handing out passive view samples nor retrying unanswered joins
+//   - shuffles run with a fixed period and carry Kp passive view members
+//   - every extension driven by config (cyclon shuffles, bootstrap strategies, subnet diversity, rate
+//     limits, slow peer demotion, promotion bias, config gossip, ...) is disabled
+//
+// ForwardJoinMessageReply and NeighbourMessageReply are kept, as babel connections are not symmetric
+// like the TCP links assumed by the paper and both sides must learn about each other.
+
+func applyStrictPaper(conf *HyparviewConfig) {
+	conf.CyclonShuffle = false
+	conf.BootstrapStrategy = BootstrapFirst
+	conf.BootstrapFanout = 0
+	conf.OutboundOnly = false
+	conf.MaxActivePerSubnet = 0
+	conf.CompactPeerLists = false
+	conf.MaxJoinsPerSecond = 0
+	conf.MaxShuffleForwardsPerSecond = 0
+	conf.DialBackJoiners = false
+	conf.VerifyPassivePeers = false
+	conf.StandbyBootstrap = false
+	conf.ConfigAdminPublicKey = ""
+	conf.ConfigAdminPrivateKeyFile = ""
+	conf.RemovePeerOnHandlerPanic = false
+	conf.SlowPeerLatencyMillis = 0
+	conf.SlowPeerFailurePercent = 0
+	conf.PromotionRecencyBias = 0
+	conf.JoinFullPolicy = JoinFullDropRandom
+	conf.TimeSyncHints = false
+	conf.ShedPolicy = ShedNone
+	conf.NeighbourRetries = 0
+	conf.LearnInboundPeers = false
+	conf.MinNeighboursAtVersion = 0
+	conf.BandwidthProbeSeconds = 0
+	conf.PassiveRejoinFanout = 0
+	conf.SilentNeighbourSeconds = 0
+	conf.ShuffleFragmentBytes = 0
+	conf.MaxPassiveOriginPercent = 0
+	conf.MaxActionsPerSecond = 0
+	conf.BootstrapMembership = BootstrapMember
+	conf.PeerExchange = false
+	conf.MaxInDegree = 0
+	conf.CircuitBreakerFailures = 0
+	conf.LeaveHandoff = false
+	conf.Metadata = nil
+	conf.MaxPassiveViewSize = 0
+}
@@ -0,0 +1,165 @@
+package protocol
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// In-process consumers may Subscribe to membership events instead of registering babel notification
+// handlers. Events are delivered on a buffered channel by the protocol goroutine, which never blocks
+// on a slow consumer: when the buffer is full the subscription's overflow policy decides whether the
+// new event or the oldest buffered one is dropped, or whether the subscription is cancelled.
+
+// subscriptionState holds the registered subscriptions.
+type subscriptionState struct {
+	subscriptions     map[*subscription]struct{}
+	subscriptionDrops int
+}
+
+type MembershipEventType uint8
+
+const (
+	EventNeighborUp MembershipEventType = 1 << iota
+	EventNeighborDown
+	EventPassiveAdded
+	EventPassiveRemoved
+)
+
+func (t MembershipEventType) String() string {
+	switch t {
+	case EventNeighborUp:
+		return "neighborUp"
+	case EventNeighborDown:
+		return "neighborDown"
+	case EventPassiveAdded:
+		return "passiveAdded"
+	case EventPassiveRemoved:
+		return "passiveRemoved"
+	default:
+		return "unknown"
+	}
+}
+
+type MembershipEvent struct {
+	Type MembershipEventType
+	Peer peer.Peer
+	Time time.Time
+}
+
+type OverflowPolicy int
+
+const (
+	// OverflowDropNewest drops events arriving while the buffer is full.
+	OverflowDropNewest OverflowPolicy = iota
+	// OverflowDropOldest drops the oldest buffered event to make room for the new one.
+	OverflowDropOldest
+	// OverflowCancel cancels the subscription, closing its channel once the buffered events are read.
+	OverflowCancel
+)
+
+const defaultSubscriptionBuffer = 64
+
+// EventFilter selects the events delivered to a subscription, zero values match everything.
+type EventFilter struct {
+	Types    MembershipEventType // bitmask of Event... types
+	Peer     peer.Peer
+	Subnet   *net.IPNet
+	Buffer   int // channel capacity, defaultSubscriptionBuffer if zero
+	Overflow OverflowPolicy
+}
+
+func (f EventFilter) matches(event MembershipEvent) bool {
+	if f.Types != 0 && f.Types&event.Type == 0 {
+		return false
+	}
+	if f.Peer != nil && !peer.PeersEqual(f.Peer, event.Peer) {
+		return false
+	}
+	return f.Subnet == nil || f.Subnet.Contains(event.Peer.IP())
+}
+
+type subscription struct {
+	filter  EventFilter
+	events  chan MembershipEvent
+	dropped int
+}
+
+// Subscribe returns a channel receiving the membership events matching filter, and a function
+// cancelling the subscription and closing the channel. It blocks until the protocol goroutine
+// registers the subscription, so it must not be called from HyParView hooks.
+func (h *Hyparview) Subscribe(filter EventFilter) (<-chan MembershipEvent, func()) {
+	if filter.Buffer <= 0 {
+		filter.Buffer = defaultSubscriptionBuffer
+	}
+	sub := &subscription{filter: filter, events: make(chan MembershipEvent, filter.Buffer)}
+	registered := make(chan struct{})
+	h.onProtocol("Subscribe", func() {
+		h.subscriptions[sub] = struct{}{}
+		close(registered)
+	})
+	<-registered
+	var once sync.Once
+	return sub.events, func() {
+		once.Do(func() {
+			h.onProtocol("Unsubscribe", func() { h.unsubscribe(sub) })
+		})
+	}
+}
+
+func (h *Hyparview) unsubscribe(sub *subscription) {
+	if _, ok := h.subscriptions[sub]; !ok {
+		return
+	}
+	delete(h.subscriptions, sub)
+	close(sub.events)
+}
+
+func (h *Hyparview) publishEvent(eventType MembershipEventType, p peer.Peer) {
+	if len(h.subscriptions) == 0 {
+		return
+	}
+	event := MembershipEvent{Type: eventType, Peer: p, Time: h.timeNow()}
+	for sub := range h.subscriptions {
+		if !sub.filter.matches(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+			continue
+		default:
+		}
+		sub.dropped++
+		h.subscriptionDrops++
+		switch sub.filter.Overflow {
+		case OverflowDropOldest:
+			select {
+			case <-sub.events:
+			default:
+			}
+			select {
+			case sub.events <- event:
+			default:
+			}
+		case OverflowCancel:
+			h.logger.Warnf("Cancelling membership event subscription, %d events not consumed", len(sub.events))
+			h.unsubscribe(sub)
+		}
+	}
+}
+
+func (h *Hyparview) publishViewEvents() {
+	h.onBeforeRemove(ActiveView, func(_ ViewID, p peer.Peer) {
+		if state, ok := h.activeView.get(p); ok && state.outConnected {
+			h.publishEvent(EventNeighborDown, p)
+		}
+	})
+	h.onAfterAdd(PassiveView, func(_ ViewID, p peer.Peer) {
+		h.publishEvent(EventPassiveAdded, p)
+	})
+	h.onBeforeRemove(PassiveView, func(_ ViewID, p peer.Peer) {
+		h.publishEvent(EventPassiveRemoved, p)
+	})
+}
@@ -0,0 +1,48 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+type MessageDirection int
+
+const (
+	Inbound MessageDirection = iota
+	Outbound
+)
+
+func (d MessageDirection) String() string {
+	switch d {
+	case Inbound:
+		return "inbound"
+	case Outbound:
+		return "outbound"
+	default:
+		return "unknown"
+	}
+}
+
+// MessageTap receives every message the protocol sends or receives, for custom analytics, replay logs
+// or teaching tools. Taps run on the protocol goroutine, so they must return quickly and must not
+// modify the messages, which share their peer lists with the protocol. No taps are installed by default.
+type MessageTap interface {
+	OnMessage(direction MessageDirection, p peer.Peer, at time.Time, msg message.Message)
+}
+
+// AddMessageTap installs a tap, it must be called before the protocol is started.
+func (h *Hyparview) AddMessageTap(tap MessageTap) {
+	h.messageTaps = append(h.messageTaps, tap)
+}
+
+func (h *Hyparview) tapMessage(direction MessageDirection, p peer.Peer, msg message.Message) {
+	if len(h.messageTaps) == 0 {
+		return
+	}
+	now := h.timeNow()
+	for _, tap := range h.messageTaps {
+		tap.OnMessage(direction, p, now, msg)
+	}
+}
@@ -0,0 +1,86 @@
+package protocol
+
+import (
+	"encoding/json"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// TelemetryConfig enables the random walk reports sent to WalkCollector.
+type TelemetryConfig struct {
+	WalkCollector *struct {
+		Port          int    `yaml:"port"`
+		Host          string `yaml:"host"`
+		AnalyticsPort int    `yaml:"analyticsPort"`
+	} `yaml:"walkCollector"`
+	WalkTelemetry bool `yaml:"walkTelemetry"`
+}
+
+func (h *Hyparview) reportWalkTerminated(fwdJoinMsg ForwardJoinMessage, accepted bool) {
+	if !h.conf.WalkTelemetry {
+		return
+	}
+
+	hops := uint32(1)
+	if arwl := uint32(h.conf.ARWL); arwl >= fwdJoinMsg.TTL {
+		hops += arwl - fwdJoinMsg.TTL
+	}
+	report := WalkTerminatedMessage{
+		WalkID:         fwdJoinMsg.WalkID,
+		Hops:           hops,
+		Accepted:       accepted,
+		OriginalSender: fwdJoinMsg.OriginalSender,
+	}
+
+	var target peer.Peer = fwdJoinMsg.OriginalSender
+	if c := h.conf.WalkCollector; c != nil {
+		target = configuredPeer(h.conf, c.Host, c.Port, c.AnalyticsPort)
+	}
+	if peer.PeersEqual(target, h.babel.SelfPeer()) {
+		h.logWalkTerminated(h.babel.SelfPeer(), report)
+		return
+	}
+	h.sendMessageTmpTransport(report, target)
+}
+
+func (h *Hyparview) HandleWalkTerminatedMessage(sender peer.Peer, msg message.Message) {
+	report := msg.(WalkTerminatedMessage)
+	if report.OriginalSender == nil {
+		return
+	}
+	h.logWalkTerminated(sender, report)
+}
+
+func (h *Hyparview) logWalkTerminated(terminatedAt peer.Peer, report WalkTerminatedMessage) {
+	toPrint := struct {
+		WalkID       uint32 `json:"walkID"`
+		Hops         uint32 `json:"hops"`
+		Accepted     bool   `json:"accepted"`
+		Joiner       string `json:"joiner"`
+		TerminatedAt string `json:"terminatedAt"`
+	}{
+		WalkID:       report.WalkID,
+		Hops:         report.Hops,
+		Accepted:     report.Accepted,
+		Joiner:       report.OriginalSender.String(),
+		TerminatedAt: terminatedAt.String(),
+	}
+	res, err := json.Marshal(toPrint)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("walkTerminated", "%s", string(res))
+}
+
+func (h *Hyparview) logActiveViewDomains() {
+	domains := map[string]int{}
+	for _, p := range h.activeView.asArr {
+		domains[p.failureDomain]++
+	}
+	res, err := json.Marshal(domains)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("activeViewDomains", "%s", string(res))
+}
@@ -3,6 +3,7 @@ package protocol
 import (
 	"time"
 
+	"github.com/nm-morais/go-babel/pkg/peer"
 	"github.com/nm-morais/go-babel/pkg/timer"
 )
 
@@ -61,3 +62,220 @@ func (MaintenanceTimer) ID() timer.ID {
 func (s MaintenanceTimer) Duration() time.Duration {
 	return s.duration
 }
+
+const JoinCompletionTimerID = 1505
+
+type JoinCompletionTimer struct {
+	duration time.Duration
+}
+
+func (JoinCompletionTimer) ID() timer.ID {
+	return JoinCompletionTimerID
+}
+
+func (s JoinCompletionTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const MirrorTimerID = 1506
+
+type MirrorTimer struct {
+	duration time.Duration
+}
+
+func (MirrorTimer) ID() timer.ID {
+	return MirrorTimerID
+}
+
+func (s MirrorTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const ConfigReloadTimerID = 1507
+
+type ConfigReloadTimer struct {
+	duration time.Duration
+}
+
+func (ConfigReloadTimer) ID() timer.ID {
+	return ConfigReloadTimerID
+}
+
+func (s ConfigReloadTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const JoinReplyTimerID = 1511
+
+type JoinReplyTimer struct {
+	duration time.Duration
+	attempt  int
+}
+
+func (JoinReplyTimer) ID() timer.ID {
+	return JoinReplyTimerID
+}
+
+func (s JoinReplyTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const LoadProbeTimerID = 1520
+
+type LoadProbeTimer struct {
+	duration time.Duration
+}
+
+func (LoadProbeTimer) ID() timer.ID {
+	return LoadProbeTimerID
+}
+
+func (s LoadProbeTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const BandwidthProbeTimerID = 1525
+
+type BandwidthProbeTimer struct {
+	duration time.Duration
+}
+
+func (BandwidthProbeTimer) ID() timer.ID {
+	return BandwidthProbeTimerID
+}
+
+func (s BandwidthProbeTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const DialTimeoutTimerID = 1527
+
+type DialTimeoutTimer struct {
+	duration  time.Duration
+	peer      peer.Peer
+	startedAt time.Time
+}
+
+func (DialTimeoutTimer) ID() timer.ID {
+	return DialTimeoutTimerID
+}
+
+func (s DialTimeoutTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const ShuffleFragmentTimerID = 1528
+
+type ShuffleFragmentTimer struct {
+	duration  time.Duration
+	key       string
+	startedAt time.Time
+}
+
+func (ShuffleFragmentTimer) ID() timer.ID {
+	return ShuffleFragmentTimerID
+}
+
+func (s ShuffleFragmentTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const DrainTimerID = 1530
+
+type DrainTimer struct {
+	duration time.Duration
+}
+
+func (DrainTimer) ID() timer.ID {
+	return DrainTimerID
+}
+
+func (s DrainTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const ActionTimerID = 1531
+
+type ActionTimer struct {
+	duration time.Duration
+}
+
+func (ActionTimer) ID() timer.ID {
+	return ActionTimerID
+}
+
+func (s ActionTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const ViewHistoryTimerID = 1533
+
+type ViewHistoryTimer struct {
+	duration time.Duration
+}
+
+func (ViewHistoryTimer) ID() timer.ID {
+	return ViewHistoryTimerID
+}
+
+func (s ViewHistoryTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const LatencyProbeTimerID = 1535
+
+type LatencyProbeTimer struct {
+	duration time.Duration
+}
+
+func (LatencyProbeTimer) ID() timer.ID {
+	return LatencyProbeTimerID
+}
+
+func (s LatencyProbeTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const LatencyReportTimerID = 1537
+
+type LatencyReportTimer struct {
+	duration time.Duration
+}
+
+func (LatencyReportTimer) ID() timer.ID {
+	return LatencyReportTimerID
+}
+
+func (s LatencyReportTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const JoinWindowTimerID = 1543
+
+type JoinWindowTimer struct {
+	duration time.Duration
+}
+
+func (JoinWindowTimer) ID() timer.ID {
+	return JoinWindowTimerID
+}
+
+func (s JoinWindowTimer) Duration() time.Duration {
+	return s.duration
+}
+
+const RunTimerID = 1549
+
+type RunTimer struct {
+	duration time.Duration
+	what     string
+	run      func()
+}
+
+func (RunTimer) ID() timer.ID {
+	return RunTimerID
+}
+
+func (s RunTimer) Duration() time.Duration {
+	return s.duration
+}
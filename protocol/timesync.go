@@ -0,0 +1,137 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+)
+
+// With TimeSyncHints set, maintenance messages carry NTP-style timestamps: the send time, and the
+// send time of the last maintenance message received from the target together with how long ago it
+// was received. As neighbours exchange maintenance messages periodically in both directions, every
+// message yields a round trip sample and an estimate of the neighbour's clock offset, which latency
+// analytics built on AnalyticsPort data can use to correct for skew. Shuffles are not used, as their
+// random walks make the path delay asymmetric.
+//
+// Nodes without hint support read the hint as part of the failure domain, so hints are only sent to
+// neighbours advertising CapTimeHints in their join or neighbour handshake, or in their shuffles.
+
+const clockOffsetAlpha = 0.2
+
+type TimeHint struct {
+	SentAt     int64 // unix nanoseconds
+	EchoSentAt int64 // SentAt of the last hint received from the target, 0 if none
+	EchoDelay  int64 // nanoseconds between receiving that hint and sending this one
+}
+
+func (t *TimeHint) encode() []byte {
+	hintBytes := make([]byte, 24)
+	binary.BigEndian.PutUint64(hintBytes[0:8], uint64(t.SentAt))
+	binary.BigEndian.PutUint64(hintBytes[8:16], uint64(t.EchoSentAt))
+	binary.BigEndian.PutUint64(hintBytes[16:24], uint64(t.EchoDelay))
+	return hintBytes
+}
+
+func decodeTimeHint(hintBytes []byte) *TimeHint {
+	if len(hintBytes) < 24 {
+		return nil
+	}
+	return &TimeHint{
+		SentAt:     int64(binary.BigEndian.Uint64(hintBytes[0:8])),
+		EchoSentAt: int64(binary.BigEndian.Uint64(hintBytes[8:16])),
+		EchoDelay:  int64(binary.BigEndian.Uint64(hintBytes[16:24])),
+	}
+}
+
+type clockStats struct {
+	lastSentAt   int64
+	lastReceived time.Time
+	offset       time.Duration
+	rtt          time.Duration
+	samples      int
+}
+
+// ClockOffset is the estimated clock offset of a neighbour (its clock minus ours) and the round trip
+// time of the link, both smoothed over Samples exchanges.
+type ClockOffset struct {
+	Offset  time.Duration `json:"offset"`
+	RTT     time.Duration `json:"rtt"`
+	Samples int           `json:"samples"`
+}
+
+func (h *Hyparview) timeHintFor(p *PeerState) *TimeHint {
+	if !h.conf.TimeSyncHints || p.capabilities&CapTimeHints == 0 {
+		return nil
+	}
+	now := h.timeNow()
+	hint := &TimeHint{SentAt: now.UnixNano()}
+	if p.clock != nil && p.clock.lastSentAt != 0 {
+		hint.EchoSentAt = p.clock.lastSentAt
+		hint.EchoDelay = int64(now.Sub(p.clock.lastReceived))
+	}
+	return hint
+}
+
+func (h *Hyparview) recordTimeHint(p *PeerState, hint *TimeHint) {
+	if !h.conf.TimeSyncHints || hint == nil {
+		return
+	}
+	now := h.timeNow()
+	if p.clock == nil {
+		p.clock = &clockStats{}
+	}
+	clock := p.clock
+	clock.lastSentAt = hint.SentAt
+	clock.lastReceived = now
+	if hint.EchoSentAt == 0 {
+		return
+	}
+	rtt := time.Duration(now.UnixNano() - hint.EchoSentAt - hint.EchoDelay)
+	if rtt < 0 {
+		return
+	}
+	// the neighbour received our hint at SentAt-EchoDelay and we received its hint at now
+	offset := time.Duration((hint.SentAt - hint.EchoDelay - hint.EchoSentAt + hint.SentAt - now.UnixNano()) / 2)
+	if clock.samples == 0 {
+		clock.offset = offset
+		clock.rtt = rtt
+	} else {
+		clock.offset = time.Duration(clockOffsetAlpha*float64(offset) + (1-clockOffsetAlpha)*float64(clock.offset))
+		clock.rtt = time.Duration(clockOffsetAlpha*float64(rtt) + (1-clockOffsetAlpha)*float64(clock.rtt))
+	}
+	clock.samples++
+}
+
+func (h *Hyparview) clockOffsets() map[string]ClockOffset {
+	offsets := map[string]ClockOffset{}
+	for _, p := range h.activeView.asArr {
+		if p.clock == nil || p.clock.samples == 0 {
+			continue
+		}
+		offsets[p.String()] = ClockOffset{
+			Offset:  p.clock.offset,
+			RTT:     p.clock.rtt,
+			Samples: p.clock.samples,
+		}
+	}
+	return offsets
+}
+
+// ClockOffsets returns the clock offset estimates of the active view members, keyed by peer, it
+// blocks until the protocol goroutine takes the snapshot.
+func (h *Hyparview) ClockOffsets() map[string]ClockOffset {
+	offsetsCh := make(chan map[string]ClockOffset, 1)
+	h.onProtocol("ClockOffsets", func() { offsetsCh <- h.clockOffsets() })
+	return <-offsetsCh
+}
+
+func (h *Hyparview) logClockOffsets() {
+	if !h.conf.TimeSyncHints {
+		return
+	}
+	res, err := json.Marshal(h.clockOffsets())
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("clockOffsets", "%s", string(res))
+}
@@ -0,0 +1,94 @@
+package protocol
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// With MessageTracing set, joins and neighbour requests carry a trace ID echoed by their replies
+// (forward join replies at the end of a join's random walks carry the walk ID instead), and shuffles
+// are traced by their ID. Every traced message is logged as <trace>, with the latency of the exchange
+// for replies, and requests left unanswered for traceTimeout are logged as such by the debug timer, so
+// that requests and replies can be matched across nodes when a handshake never completes.
+
+const (
+	traceJoin      = "join"
+	traceNeighbour = "neighbour"
+	traceShuffle   = "shuffle"
+
+	traceTimeout = 30 * time.Second
+)
+
+type pendingTrace struct {
+	exchange string
+	peer     string
+	sent     time.Time
+}
+
+func (h *Hyparview) newTraceID() uint32 {
+	if !h.conf.MessageTracing {
+		return 0
+	}
+	// 0 means untraced
+	return 1 + uint32(getRandInt(math.MaxUint32-1))
+}
+
+func (h *Hyparview) traceSent(exchange string, target peer.Peer, id uint32) {
+	if !h.conf.MessageTracing || id == 0 {
+		return
+	}
+	h.pendingTraces[id] = pendingTrace{exchange: exchange, peer: target.String(), sent: h.timeNow()}
+	h.logTrace(exchange, "sent", target.String(), id, 0)
+}
+
+func (h *Hyparview) traceReceived(exchange string, sender peer.Peer, id uint32) {
+	if !h.conf.MessageTracing || id == 0 {
+		return
+	}
+	h.logTrace(exchange, "received", sender.String(), id, 0)
+}
+
+func (h *Hyparview) traceReplied(exchange string, sender peer.Peer, id uint32) {
+	if !h.conf.MessageTracing || id == 0 {
+		return
+	}
+	var latency time.Duration
+	if pending, ok := h.pendingTraces[id]; ok {
+		latency = h.elapsedSince(pending.sent)
+		delete(h.pendingTraces, id)
+	}
+	h.logTrace(exchange, "replied", sender.String(), id, latency)
+}
+
+func (h *Hyparview) expireTraces() {
+	for id, pending := range h.pendingTraces {
+		if h.elapsedSince(pending.sent) >= traceTimeout {
+			h.logTrace(pending.exchange, "unanswered", pending.peer, id, h.elapsedSince(pending.sent))
+			delete(h.pendingTraces, id)
+		}
+	}
+}
+
+func (h *Hyparview) logTrace(exchange, event, p string, id uint32, latency time.Duration) {
+	toPrint := struct {
+		ID       uint32        `json:"id"`
+		Exchange string        `json:"exchange"`
+		Event    string        `json:"event"`
+		Peer     string        `json:"peer"`
+		Latency  time.Duration `json:"latency,omitempty"`
+	}{
+		ID:       id,
+		Exchange: exchange,
+		Event:    event,
+		Peer:     p,
+		Latency:  latency,
+	}
+	res, err := json.Marshal(toPrint)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("trace", "%s", string(res))
+}
@@ -1,7 +1,19 @@
 package protocol
 
-import "math/rand"
+import (
+	"bytes"
+	"math/rand"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
 
 func getRandInt(roof int) int {
 	return rand.Intn(roof)
 }
+
+func peerLess(p1, p2 peer.Peer) bool {
+	if cmp := bytes.Compare(p1.IP().To16(), p2.IP().To16()); cmp != 0 {
+		return cmp < 0
+	}
+	return p1.ProtosPort() < p2.ProtosPort()
+}
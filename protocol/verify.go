@@ -0,0 +1,98 @@
+package protocol
+
+import (
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// With VerifyPassivePeers set, peers learned from shuffles, forward joins and the like are dialed
+// before entering the passive view, and discarded if unreachable. Verifications run in the background
+// and are rate limited to MaxPeerVerificationsPerSecond, peers arriving past the limit are dropped and
+// will be verified when learned again. Verified peers are not dialed again for verifiedPeerTTL.
+
+// VerifyConfig enables verifying passive view candidates.
+type VerifyConfig struct {
+	VerifyPassivePeers            bool `yaml:"verifyPassivePeers"`
+	MaxPeerVerificationsPerSecond int  `yaml:"maxPeerVerificationsPerSecond"`
+}
+
+// verifyState tracks the verifications running and the peers verified recently.
+type verifyState struct {
+	verifyingPeers      map[string]bool
+	verifiedPeers       map[string]time.Time
+	recentVerifications []time.Time
+}
+
+const (
+	defaultMaxPeerVerificationsPerSecond = 5
+	verifiedPeerTTL                      = 10 * time.Minute
+)
+
+// deferForVerification returns true if p must not be added to the passive view right away, either
+// because its verification was started or because it cannot be verified now.
+func (h *Hyparview) deferForVerification(p peer.Peer, age uint16, origin peer.Peer) bool {
+	if !h.conf.VerifyPassivePeers || !h.isDialable(p) {
+		return false
+	}
+	if verifiedAt, ok := h.verifiedPeers[p.String()]; ok {
+		if h.elapsedSince(verifiedAt) < verifiedPeerTTL {
+			return false
+		}
+		delete(h.verifiedPeers, p.String())
+	}
+	if h.verifyingPeers[p.String()] || h.verificationRateExceeded() {
+		return true
+	}
+	h.verifyingPeers[p.String()] = true
+	addr := p.ToTCPAddr().String()
+	timeout := h.dialBackTimeout()
+	go func() {
+		err := probeTCP(addr, timeout)
+		h.onProtocol("verifyPeer", func() { h.peerVerified(p, age, origin, err) })
+	}()
+	return true
+}
+
+func (h *Hyparview) verificationRateExceeded() bool {
+	limit := h.conf.MaxPeerVerificationsPerSecond
+	if limit <= 0 {
+		limit = defaultMaxPeerVerificationsPerSecond
+	}
+	recent := h.recentVerifications[:0]
+	for _, t := range h.recentVerifications {
+		if h.elapsedSince(t) < time.Second {
+			recent = append(recent, t)
+		}
+	}
+	h.recentVerifications = recent
+	if len(h.recentVerifications) >= limit {
+		return true
+	}
+	h.recentVerifications = append(h.recentVerifications, h.timeNow())
+	return false
+}
+
+func (h *Hyparview) peerVerified(p peer.Peer, age uint16, origin peer.Peer, err error) {
+	delete(h.verifyingPeers, p.String())
+	if err != nil {
+		h.logger.Infof("Discarding unreachable peer %s: %s", p.String(), err)
+		return
+	}
+	for key, verifiedAt := range h.verifiedPeers {
+		if h.elapsedSince(verifiedAt) >= verifiedPeerTTL {
+			delete(h.verifiedPeers, key)
+		}
+	}
+	h.verifiedPeers[p.String()] = h.timeNow()
+	if h.isSelf(p) || h.activeView.contains(p) || h.passiveView.contains(p) || h.isBlacklisted(p) || h.joinOnlyContact(p) {
+		return
+	}
+	if h.passiveOriginFull(origin) {
+		return
+	}
+	if h.passiveView.isFull() {
+		h.passiveView.dropRandom()
+	}
+	h.addPeerToPassiveViewWithAge(p, age, origin)
+}
@@ -0,0 +1,117 @@
+package protocol
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// VersionConfig sets the number of neighbours kept at a minimum version.
+type VersionConfig struct {
+	MinNeighbourVersion    int `yaml:"minNeighbourVersion"`
+	MinNeighboursAtVersion int `yaml:"minNeighboursAtVersion"`
+}
+
+// ProtocolVersion is advertised to neighbours in maintenance messages, it must be increased by
+// releases adding features which need neighbours to run them too.
+const ProtocolVersion uint16 = 1
+
+// With MinNeighboursAtVersion set, the promote timer keeps at least that many active view members at
+// version MinNeighbourVersion or newer, e.g. during a rolling upgrade, so that dissemination features
+// needing the new version have enough compatible neighbours. While short of them, a passive view member
+// known to run a compatible version is promoted, replacing a neighbour known to run an older version if
+// the active view is full. Versions are learned from maintenance messages, so a neighbour which has not
+// sent one yet, has not learned our capabilities yet, or runs a release predating versions, has an
+// unknown version and is never replaced.
+
+// versionFor returns the version to advertise to p. Nodes predating versions read anything following
+// the failure domain of maintenance messages as part of it, so it is only sent to neighbours which
+// advertised CapVersion, and maintenance messages to the others carry no trailer unless they need one.
+func versionFor(p *PeerState) uint16 {
+	if p.capabilities&CapVersion == 0 {
+		return 0
+	}
+	return ProtocolVersion
+}
+
+func (h *Hyparview) recordVersion(p *PeerState, version uint16) {
+	p.version = version
+	if version != 0 {
+		h.knownVersions[p.String()] = version
+	}
+}
+
+func (h *Hyparview) compatibleVersion(version uint16) bool {
+	return version != 0 && int(version) >= h.conf.MinNeighbourVersion
+}
+
+// enforceVersionComposition returns whether it sent a neighbour request.
+func (h *Hyparview) enforceVersionComposition() bool {
+	if h.conf.MinNeighboursAtVersion <= 0 || h.frozen() {
+		return false
+	}
+	compatible := 0
+	var outdated *PeerState
+	for _, p := range h.activeView.asArr {
+		if h.compatibleVersion(p.version) {
+			compatible++
+		} else if p.version != 0 && outdated == nil {
+			outdated = p
+		}
+	}
+	if compatible >= h.conf.MinNeighboursAtVersion {
+		return false
+	}
+	var candidate peer.Peer
+	for _, p := range h.passiveView.asArr {
+		if h.compatibleVersion(h.knownVersions[p.String()]) && h.promotionAllowed(p) {
+			candidate = p.Peer
+			break
+		}
+	}
+	if candidate == nil {
+		h.logger.Warnf("Only %d neighbours at version >= %d, and no compatible passive view member is known", compatible, h.conf.MinNeighbourVersion)
+		return false
+	}
+	if !h.activeView.isFull() {
+		h.logger.Warnf("Only %d neighbours at version >= %d, promoting %s", compatible, h.conf.MinNeighbourVersion, candidate.String())
+		h.sendNeighbourMessage(candidate)
+		return true
+	}
+	if outdated == nil {
+		return false
+	}
+	h.logger.Warnf("Only %d neighbours at version >= %d, replacing %s (version %d) with %s", compatible, h.conf.MinNeighbourVersion, outdated.String(), outdated.version, candidate.String())
+	h.replaceNeighbour(outdated, candidate, RemovalVersionComposition)
+	return true
+}
+
+// pruneKnownVersions forgets the versions of peers which left both views.
+func (h *Hyparview) pruneKnownVersions() {
+	for key := range h.knownVersions {
+		if _, ok := h.activeView.asMap[key]; ok {
+			continue
+		}
+		if _, ok := h.passiveView.asMap[key]; ok {
+			continue
+		}
+		delete(h.knownVersions, key)
+	}
+}
+
+func (h *Hyparview) logActiveViewVersions() {
+	versions := map[string]int{}
+	for _, p := range h.activeView.asArr {
+		version := "unknown"
+		if p.version != 0 {
+			version = strconv.Itoa(int(p.version))
+		}
+		versions[version]++
+	}
+	res, err := json.Marshal(versions)
+	if err != nil {
+		panic(err)
+	}
+	h.analytics("activeViewVersions", "%s", string(res))
+}
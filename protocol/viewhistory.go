@@ -0,0 +1,92 @@
+package protocol
+
+import (
+	"sort"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/timer"
+)
+
+// With ViewHistoryRetentionMinutes set, the active view is sampled every ViewHistorySeconds (10 by
+// default) and kept for the retention period, so that post-incident analysis can ask what the
+// neighbours of a node were at a given time. Samples equal to the previous one are not stored, the
+// view of a time T is the last sample taken at or before T.
+
+// ViewHistoryConfig enables sampling the active view.
+type ViewHistoryConfig struct {
+	ViewHistorySeconds          int `yaml:"viewHistorySeconds"`
+	ViewHistoryRetentionMinutes int `yaml:"viewHistoryRetentionMinutes"`
+}
+
+const defaultViewHistoryPeriod = 10 * time.Second
+
+// ViewHistoryEntry is the active view from At on.
+type ViewHistoryEntry struct {
+	At          time.Time `json:"at"`
+	ViewVersion uint64    `json:"viewVersion"`
+	Active      []string  `json:"active"`
+}
+
+func (h *Hyparview) viewHistoryPeriod() time.Duration {
+	if h.conf.ViewHistorySeconds > 0 {
+		return time.Duration(h.conf.ViewHistorySeconds) * time.Second
+	}
+	return defaultViewHistoryPeriod
+}
+
+func (h *Hyparview) HandleViewHistoryTimer(t timer.Timer) {
+	if !h.shouldRunPeriodic(t) {
+		return
+	}
+	now := h.timeNow()
+	retention := time.Duration(h.conf.ViewHistoryRetentionMinutes) * time.Minute
+	expired := 0
+	// the newest expired sample is kept, it still describes the view at the start of the retention period
+	for expired+1 < len(h.viewHistory) && now.Sub(h.viewHistory[expired+1].At) > retention {
+		expired++
+	}
+	h.viewHistory = h.viewHistory[expired:]
+
+	active := make([]string, 0, h.activeView.size())
+	for _, p := range h.activeView.asArr {
+		active = append(active, p.String())
+	}
+	sort.Strings(active)
+	if len(h.viewHistory) > 0 && sameStrings(h.viewHistory[len(h.viewHistory)-1].Active, active) {
+		return
+	}
+	h.viewHistory = append(h.viewHistory, ViewHistoryEntry{At: now, ViewVersion: h.CurrentViewVersion(), Active: active})
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ViewAt returns the active view of the node at time at, false if at is outside of the history.
+func (h *Hyparview) ViewAt(at time.Time) (ViewHistoryEntry, bool) {
+	entryCh := make(chan *ViewHistoryEntry, 1)
+	h.onProtocol("ViewAt", func() { entryCh <- h.viewAt(at) })
+	entry := <-entryCh
+	if entry == nil {
+		return ViewHistoryEntry{}, false
+	}
+	return *entry, true
+}
+
+func (h *Hyparview) viewAt(at time.Time) *ViewHistoryEntry {
+	idx := sort.Search(len(h.viewHistory), func(i int) bool { return h.viewHistory[i].At.After(at) })
+	if idx == 0 {
+		return nil
+	}
+	entry := h.viewHistory[idx-1]
+	entry.Active = append([]string{}, entry.Active...)
+	return &entry
+}
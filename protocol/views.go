@@ -0,0 +1,80 @@
+package protocol
+
+import (
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/go-babel/pkg/request"
+)
+
+// Other protocols may query the membership at any time instead of tracking NeighborUp and NeighborDown
+// notifications. Protocols on the same babel instance send a ViewsRequest, answered by a ViewsReply
+// delivered to their reply handler, so neither protocol goroutine blocks. Callers outside babel, such
+// as the admin and metrics endpoints, use ActiveView, PassiveView and IsNeighbor, which block until the
+// protocol goroutine answers, so they must not be called from HyParView hooks nor from other protocols.
+
+const ViewsRequestType = 1500
+
+type ViewsRequest struct{}
+
+func (ViewsRequest) ID() request.ID {
+	return ViewsRequestType
+}
+
+// ViewsReply holds the active view members HyParView is connected to and the passive view members.
+type ViewsReply struct {
+	Active  []peer.Peer
+	Passive []peer.Peer
+}
+
+func (ViewsReply) ID() request.ID {
+	return ViewsRequestType
+}
+
+func (h *Hyparview) HandleViewsRequest(r request.Request) request.Reply {
+	res := h.currentViews()
+	return ViewsReply{Active: res.active, Passive: res.passive}
+}
+
+type views struct {
+	active  []peer.Peer
+	passive []peer.Peer
+}
+
+// ActiveView returns the active view members HyParView is connected to, the same neighbours announced
+// by NeighborUp notifications.
+func (h *Hyparview) ActiveView() []peer.Peer {
+	return h.queryViews().active
+}
+
+// PassiveView returns the passive view members.
+func (h *Hyparview) PassiveView() []peer.Peer {
+	return h.queryViews().passive
+}
+
+// IsNeighbor returns whether p is an active view member HyParView is connected to.
+func (h *Hyparview) IsNeighbor(p peer.Peer) bool {
+	for _, neighbour := range h.ActiveView() {
+		if peer.PeersEqual(neighbour, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Hyparview) queryViews() views {
+	reply := make(chan views, 1)
+	h.onProtocol("views", func() { reply <- h.currentViews() })
+	return <-reply
+}
+
+func (h *Hyparview) currentViews() views {
+	res := views{active: []peer.Peer{}, passive: []peer.Peer{}}
+	for _, p := range h.activeView.asArr {
+		if p.outConnected {
+			res.active = append(res.active, p.Peer)
+		}
+	}
+	for _, p := range h.passiveView.asArr {
+		res.passive = append(res.passive, p.Peer)
+	}
+	return res
+}
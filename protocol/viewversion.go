@@ -0,0 +1,14 @@
+package protocol
+
+import "sync/atomic"
+
+// nextViewVersion is called for every neighbour notification emitted, on the protocol goroutine.
+func (h *Hyparview) nextViewVersion() uint64 {
+	return atomic.AddUint64(&h.viewVersion, 1)
+}
+
+// CurrentViewVersion returns the version of the active view, the ViewVersion of the last neighbour
+// notification emitted. It can be called from any goroutine.
+func (h *Hyparview) CurrentViewVersion() uint64 {
+	return atomic.LoadUint64(&h.viewVersion)
+}
@@ -0,0 +1,42 @@
+package protocol
+
+import (
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+)
+
+// Passive view pre-warming: once a node connects its first active neighbour it asks it for a
+// passive view sample, so that a joining (or rejoining) node has promotion candidates within one
+// round trip instead of after several shuffle periods.
+
+func (h *Hyparview) warmPassiveView(neighbour peer.Peer) {
+	if h.conf.StrictPaper || len(h.getView()) != 1 || h.passiveView.isFull() {
+		return
+	}
+	missing := h.conf.Kp - h.passiveView.size()
+	h.logger.Infof("Requesting %d passive view members from first neighbour %s", missing, neighbour.String())
+	h.sendMessage(PassiveViewRequestMessage{Size: uint16(missing)}, neighbour)
+}
+
+func (h *Hyparview) HandlePassiveViewRequestMessage(sender peer.Peer, msg message.Message) {
+	size := int(msg.(PassiveViewRequestMessage).Size)
+	if size > h.conf.Kp {
+		size = h.conf.Kp
+	}
+	sample := h.passiveView.getRandomElementsFromView(size, sender)
+	for _, p := range h.activeView.getRandomElementsFromView(size-len(sample), sender) {
+		if h.isDialable(p) {
+			sample = append(sample, p)
+		}
+	}
+	h.sendMessageTmpTransport(PassiveViewReplyMessage{Peers: sample}, sender)
+}
+
+func (h *Hyparview) HandlePassiveViewReplyMessage(sender peer.Peer, msg message.Message) {
+	replyMsg := msg.(PassiveViewReplyMessage)
+	if h.dropIfContainsSelf(sender, "passive view reply", replyMsg.Peers) {
+		return
+	}
+	h.logger.Infof("Received %d passive view members from %s", len(replyMsg.Peers), sender.String())
+	h.mergeShuffleMsgPeersWithPassiveView(replyMsg.Peers, []peer.Peer{}, sender)
+}
@@ -0,0 +1,79 @@
+package protocol
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The watchdog runs on its own goroutine and alerts when no message or timer handler ran for longer
+// than watchdogSeconds. The load probe timer fires every 100ms, so a quiet period that long means the
+// babel event loop is wedged, e.g. by a deadlock in the embedding application, rather than idle.
+
+// watchdogState is shared with the watchdog goroutine, lastHandlerRun and watchdogStalls are
+// accessed atomically and onStalled under onStalledMu.
+type watchdogState struct {
+	lastHandlerRun int64
+	watchdogStalls int64
+	onStalledMu    sync.Mutex
+	onStalled      []func(since time.Duration)
+}
+
+const defaultWatchdogCheckPeriod = time.Second
+
+// OnStalled registers a callback fired from the watchdog goroutine when handlers stop running, with the
+// time since the last one ran. Callbacks must not wait on the protocol goroutine, it is stalled.
+func (h *Hyparview) OnStalled(callback func(since time.Duration)) {
+	h.onStalledMu.Lock()
+	defer h.onStalledMu.Unlock()
+	h.onStalled = append(h.onStalled, callback)
+}
+
+// handlerRan is called by the handler wrappers, on the protocol goroutine.
+func (h *Hyparview) handlerRan() {
+	atomic.StoreInt64(&h.lastHandlerRun, time.Now().UnixNano())
+}
+
+func (h *Hyparview) startWatchdog() {
+	if h.conf.WatchdogSeconds <= 0 {
+		return
+	}
+	threshold := time.Duration(h.conf.WatchdogSeconds) * time.Second
+	checkPeriod := defaultWatchdogCheckPeriod
+	if threshold < checkPeriod {
+		checkPeriod = threshold
+	}
+	h.handlerRan()
+	go func() {
+		ticker := time.NewTicker(checkPeriod)
+		defer ticker.Stop()
+		stalled := false
+		for {
+			select {
+			case <-h.left:
+				return
+			case <-ticker.C:
+			}
+			since := time.Since(time.Unix(0, atomic.LoadInt64(&h.lastHandlerRun)))
+			if since <= threshold {
+				if stalled {
+					h.analyticsWarn("watchdog", "handlers running again after stall")
+					stalled = false
+				}
+				continue
+			}
+			if stalled {
+				continue
+			}
+			stalled = true
+			stalls := atomic.AddInt64(&h.watchdogStalls, 1)
+			h.analyticsError("watchdog", "no handler ran for %s, event loop stalled (stall #%d)", since, stalls)
+			h.onStalledMu.Lock()
+			callbacks := h.onStalled
+			h.onStalledMu.Unlock()
+			for _, callback := range callbacks {
+				callback(since)
+			}
+		}
+	}()
+}
@@ -0,0 +1,28 @@
+#!/bin/bash
+
+domain=$1
+shift 1
+
+if [ -z $domain ]; then
+  echo "usage <failure_domain> <node_array>"
+  exit
+fi
+
+n_nodes=0
+for var in $@
+do
+  n_nodes=$((n_nodes+1))
+done
+
+if [[ $n_nodes -eq 0 ]]; then
+  echo "usage <failure_domain> <node_array>"
+  exit
+fi
+
+echo "Killing all containers in failure domain $domain..."
+for node in $@
+do
+  cmd="docker ps -q --filter label=failureDomain=$domain | xargs -r docker kill"
+  echo "running command: $cmd on: $node"
+  ssh -n $node "$cmd"
+done
@@ -46,7 +46,7 @@ fi
 echo "number of nodes: $n_nodes"
 i=0
 echo "Lauching containers..."
-while read -r ip name bw
+while read -r ip name bw domain
 do
   if [[ $i -eq $nContainers ]]; then
     break
@@ -57,12 +57,17 @@ do
   idx=$((idx+1))
   node=${!idx}
 
+  if [ -z $domain ]; then
+    domain="default"
+  fi
+
   cmd="docker run -e config='/config/exampleConfig.yml' -v $SWARM_VOL_DIR:/tmp/logs -v /lib/modules:/lib/modules -d -t --privileged --cap-add=ALL \
    --net $SWARM_NET \
    --ip $ip \
    --name $name \
+   --label failureDomain=$domain \
    -h $name \
-    $DOCKER_IMAGE $i $nContainers $bw -bootstraps='$BOOTSTRAPS' -listenIP=$ip"
+    $DOCKER_IMAGE $i $nContainers $bw -bootstraps='$BOOTSTRAPS' -listenIP=$ip -failureDomain=$domain"
 
   echo "running command: $cmd"
 
@@ -0,0 +1,378 @@
+// Package babeltest provides an in-memory implementation of babel's protocol manager, whose nodes
+// exchange messages over a simulated network running in virtual time.
+package babeltest
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/nm-morais/go-babel/pkg/errors"
+	"github.com/nm-morais/go-babel/pkg/handlers"
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/notification"
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/go-babel/pkg/protocol"
+	"github.com/nm-morais/go-babel/pkg/request"
+	"github.com/nm-morais/go-babel/pkg/timer"
+	"github.com/sirupsen/logrus"
+)
+
+// Babel is an in-memory protocol manager for one node of a Network. Messages, dials, timers,
+// notifications and requests become events of the network, run one at a time in virtual time.
+// Messages are serialized and deserialized on their way, like over a real transport. As with babel,
+// messages need an outbound connection established by Dial, except side stream ones, and only the
+// dialer learns when a connection breaks, once the other node crashed.
+type Babel struct {
+	net                  *Network
+	self                 peer.Peer
+	logger               *logrus.Logger
+	protocols            []*fakeProtocol
+	byID                 map[protocol.ID]*fakeProtocol
+	notificationHandlers map[notification.ID][]handlers.NotificationHandler
+	connections          map[string]bool
+	down                 bool
+	sent                 int
+}
+
+type fakeProtocol struct {
+	proto           protocol.Protocol
+	messages        map[message.ID]message.Message
+	messageHandlers map[message.ID]handlers.MessageHandler
+	timerHandlers   map[timer.ID]handlers.TimerHandler
+	requestHandlers map[request.ID]handlers.RequestHandler
+	replyHandlers   map[request.ID]handlers.ReplyHandler
+}
+
+// fakeError is reported to the protocols as the cause of failed deliveries.
+type fakeError struct {
+	reason string
+}
+
+func (e fakeError) Reason() string   { return e.reason }
+func (e fakeError) Code() int        { return 500 }
+func (e fakeError) Caller() string   { return "babeltest" }
+func (e fakeError) ToString() string { return e.reason }
+func (e fakeError) Error() string    { return e.reason }
+
+func newBabel(net *Network, self peer.Peer, logger *logrus.Logger) *Babel {
+	return &Babel{
+		net:                  net,
+		self:                 self,
+		logger:               logger,
+		byID:                 map[protocol.ID]*fakeProtocol{},
+		notificationHandlers: map[notification.ID][]handlers.NotificationHandler{},
+		connections:          map[string]bool{},
+	}
+}
+
+func (b *Babel) RegisterProtocol(proto protocol.Protocol) errors.Error {
+	if _, ok := b.byID[proto.ID()]; ok {
+		return fakeError{fmt.Sprintf("protocol %d already registered", proto.ID())}
+	}
+	p := &fakeProtocol{
+		proto:           proto,
+		messages:        map[message.ID]message.Message{},
+		messageHandlers: map[message.ID]handlers.MessageHandler{},
+		timerHandlers:   map[timer.ID]handlers.TimerHandler{},
+		requestHandlers: map[request.ID]handlers.RequestHandler{},
+		replyHandlers:   map[request.ID]handlers.ReplyHandler{},
+	}
+	b.protocols = append(b.protocols, p)
+	b.byID[proto.ID()] = p
+	return nil
+}
+
+func (b *Babel) protocol(protoID protocol.ID) (*fakeProtocol, errors.Error) {
+	p, ok := b.byID[protoID]
+	if !ok {
+		return nil, fakeError{fmt.Sprintf("protocol %d not registered", protoID)}
+	}
+	return p, nil
+}
+
+func (b *Babel) RegisterNotificationHandler(protoID protocol.ID, n notification.Notification, handler handlers.NotificationHandler) errors.Error {
+	if _, err := b.protocol(protoID); err != nil {
+		return err
+	}
+	b.notificationHandlers[n.ID()] = append(b.notificationHandlers[n.ID()], handler)
+	return nil
+}
+
+func (b *Babel) RegisterTimerHandler(protoID protocol.ID, t timer.ID, handler handlers.TimerHandler) errors.Error {
+	p, err := b.protocol(protoID)
+	if err != nil {
+		return err
+	}
+	p.timerHandlers[t] = handler
+	return nil
+}
+
+func (b *Babel) RegisterRequestHandler(protoID protocol.ID, r request.ID, handler handlers.RequestHandler) errors.Error {
+	p, err := b.protocol(protoID)
+	if err != nil {
+		return err
+	}
+	p.requestHandlers[r] = handler
+	return nil
+}
+
+func (b *Babel) RegisterRequestReplyHandler(protoID protocol.ID, r request.ID, handler handlers.ReplyHandler) errors.Error {
+	p, err := b.protocol(protoID)
+	if err != nil {
+		return err
+	}
+	p.replyHandlers[r] = handler
+	return nil
+}
+
+func (b *Babel) RegisterMessageHandler(protoID protocol.ID, msg message.Message, handler handlers.MessageHandler) errors.Error {
+	p, err := b.protocol(protoID)
+	if err != nil {
+		return err
+	}
+	p.messages[msg.Type()] = msg
+	p.messageHandlers[msg.Type()] = handler
+	return nil
+}
+
+func (b *Babel) RegisterListenAddr(net.Addr) {}
+
+func (b *Babel) RegisterPeriodicTimer(origin protocol.ID, t timer.Timer, triggerAtTimeZero bool) int {
+	id := b.net.newTimerID()
+	first := t.Duration()
+	if triggerAtTimeZero {
+		first = 0
+	}
+	var fire func()
+	fire = func() {
+		b.fireTimer(origin, t)
+		if !b.net.cancelled[id] {
+			b.net.scheduleTimer(b, id, t.Duration(), fire)
+		}
+	}
+	b.net.scheduleTimer(b, id, first, fire)
+	return id
+}
+
+// RegisterTimer runs zero duration timers right away when registered outside of the network's
+// events, so that queries such as Snapshot, which wait for a timer to reply, work between steps.
+func (b *Babel) RegisterTimer(origin protocol.ID, t timer.Timer) int {
+	id := b.net.newTimerID()
+	if t.Duration() == 0 && !b.net.dispatching {
+		b.net.dispatch(b, func() { b.fireTimer(origin, t) })
+		return id
+	}
+	b.net.scheduleTimer(b, id, t.Duration(), func() { b.fireTimer(origin, t) })
+	return id
+}
+
+func (b *Babel) fireTimer(origin protocol.ID, t timer.Timer) {
+	p, err := b.protocol(origin)
+	if err != nil {
+		b.logger.Error(err.Reason())
+		return
+	}
+	handler, ok := p.timerHandlers[t.ID()]
+	if !ok {
+		b.logger.Errorf("No handler for timer %d of protocol %d", t.ID(), origin)
+		return
+	}
+	handler(t)
+}
+
+func (b *Babel) CancelTimer(timerID int) errors.Error {
+	b.net.cancelled[timerID] = true
+	return nil
+}
+
+func (b *Babel) SendMessage(toSend message.Message, destPeer peer.Peer, origin protocol.ID, destination protocol.ID, batch bool) {
+	p, err := b.protocol(origin)
+	if err != nil {
+		b.logger.Error(err.Reason())
+		return
+	}
+	if !b.connections[destPeer.String()] {
+		b.net.schedule(b, 0, func() {
+			p.proto.MessageDeliveryErr(toSend, destPeer, fakeError{"not connected to " + destPeer.String()})
+		})
+		return
+	}
+	b.net.transmit(b, destPeer, destination, toSend)
+	b.net.schedule(b, 0, func() { p.proto.MessageDelivered(toSend, destPeer) })
+}
+
+func (b *Babel) SendMessageSideStream(toSend message.Message, destPeer peer.Peer, _ net.Addr, _ protocol.ID, destProto protocol.ID) {
+	b.net.transmit(b, destPeer, destProto, toSend)
+}
+
+func (b *Babel) SendMessageAndDisconnect(toSend message.Message, destPeer peer.Peer, origin protocol.ID, destination protocol.ID) {
+	b.SendMessage(toSend, destPeer, origin, destination, false)
+	b.Disconnect(origin, destPeer)
+}
+
+// receive runs on the receiving node.
+func (b *Babel) receive(sender peer.Peer, destProto protocol.ID, msgType message.ID, data []byte) {
+	p, err := b.protocol(destProto)
+	if err != nil {
+		return
+	}
+	prototype, ok := p.messages[msgType]
+	if !ok {
+		b.logger.Errorf("No handler for message %d of protocol %d", msgType, destProto)
+		return
+	}
+	p.messageHandlers[msgType](sender, prototype.Deserializer().Deserialize(data))
+}
+
+func (b *Babel) SendNotification(n notification.Notification) errors.Error {
+	for _, handler := range b.notificationHandlers[n.ID()] {
+		handler := handler
+		b.net.schedule(b, 0, func() { handler(n) })
+	}
+	return nil
+}
+
+func (b *Babel) SendRequest(r request.Request, origin protocol.ID, destination protocol.ID) errors.Error {
+	p, err := b.protocol(destination)
+	if err != nil {
+		return err
+	}
+	handler, ok := p.requestHandlers[r.ID()]
+	if !ok {
+		return fakeError{fmt.Sprintf("no handler for request %d of protocol %d", r.ID(), destination)}
+	}
+	b.net.schedule(b, 0, func() {
+		if reply := handler(r); reply != nil {
+			b.SendRequestReply(reply, destination, origin)
+		}
+	})
+	return nil
+}
+
+func (b *Babel) SendRequestReply(reply request.Reply, origin protocol.ID, destination protocol.ID) errors.Error {
+	p, err := b.protocol(destination)
+	if err != nil {
+		return err
+	}
+	handler, ok := p.replyHandlers[reply.ID()]
+	if !ok {
+		return fakeError{fmt.Sprintf("no handler for reply %d of protocol %d", reply.ID(), destination)}
+	}
+	b.net.schedule(b, 0, func() { handler(reply) })
+	return nil
+}
+
+// Dial takes a round trip: the dialed node's protocols are asked whether they accept the connection,
+// and the answer reaches the dialer, which keeps the connection if one of its protocols wants it.
+func (b *Babel) Dial(dialingProto protocol.ID, toDial peer.Peer, _ net.Addr) errors.Error {
+	if b.connections[toDial.String()] {
+		b.net.schedule(b, 0, func() { b.dialSucceeded(dialingProto, toDial) })
+		return nil
+	}
+	target := b.net.nodeOf(toDial)
+	// the event is the dialer's, so that it still hears back if the target crashes meanwhile
+	b.net.schedule(b, b.net.delay(), func() {
+		accepted := false
+		if target != nil && !target.down {
+			for _, p := range target.protocols {
+				if p.proto.InConnRequested(dialingProto, b.self) {
+					accepted = true
+				}
+			}
+		}
+		b.net.schedule(b, b.net.delay(), func() {
+			if !accepted {
+				b.dialFailed(dialingProto, toDial)
+				return
+			}
+			b.connections[toDial.String()] = true
+			b.dialSucceeded(dialingProto, toDial)
+		})
+	})
+	return nil
+}
+
+func (b *Babel) dialSucceeded(dialingProto protocol.ID, dialed peer.Peer) {
+	wanted := false
+	for _, p := range b.protocols {
+		if p.proto.DialSuccess(dialingProto, dialed) {
+			wanted = true
+		}
+	}
+	if !wanted {
+		delete(b.connections, dialed.String())
+	}
+}
+
+func (b *Babel) dialFailed(dialingProto protocol.ID, toDial peer.Peer) {
+	if p, err := b.protocol(dialingProto); err == nil {
+		p.proto.DialFailed(toDial)
+	}
+}
+
+// connectionDown breaks the connection to p once p crashed.
+func (b *Babel) connectionDown(p peer.Peer) {
+	if !b.connections[p.String()] {
+		return
+	}
+	delete(b.connections, p.String())
+	for _, proto := range b.protocols {
+		proto.proto.OutConnDown(p)
+	}
+}
+
+func (b *Babel) Disconnect(_ protocol.ID, toDc peer.Peer) {
+	delete(b.connections, toDc.String())
+}
+
+// Crash stops the node without its protocols leaving: its pending events are dropped, and the nodes
+// connected to it see their connection go down one network leg later.
+func (b *Babel) Crash() {
+	if b.down {
+		return
+	}
+	b.down = true
+	for _, other := range b.net.nodes {
+		other := other
+		if other.down || !other.connections[b.self.String()] {
+			continue
+		}
+		b.net.schedule(other, b.net.delay(), func() { other.connectionDown(b.self) })
+	}
+}
+
+// Down returns whether the node crashed.
+func (b *Babel) Down() bool {
+	return b.down
+}
+
+// Sent returns how many messages the node sent so far, including lost ones.
+func (b *Babel) Sent() int {
+	b.net.mu.Lock()
+	defer b.net.mu.Unlock()
+	return b.sent
+}
+
+func (b *Babel) SelfPeer() peer.Peer {
+	return b.self
+}
+
+func (b *Babel) Logger() *logrus.Logger {
+	return b.logger
+}
+
+func (b *Babel) StartSync() {
+	b.StartAsync()
+}
+
+// StartAsync initializes every registered protocol, then starts them.
+func (b *Babel) StartAsync() {
+	b.net.dispatch(b, func() {
+		for _, p := range b.protocols {
+			p.proto.Init()
+		}
+		for _, p := range b.protocols {
+			p.proto.Start()
+		}
+	})
+}
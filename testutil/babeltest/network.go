@@ -0,0 +1,199 @@
+package babeltest
+
+import (
+	"container/heap"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/message"
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/go-babel/pkg/protocol"
+	"github.com/sirupsen/logrus"
+)
+
+// A Network connects Babel nodes in one process, in virtual time: events run one at a time, in time
+// order, and time jumps from one event to the next. It delays messages and dials by a configurable
+// latency and jitter, and loses a configurable fraction of the messages. Nothing runs until the
+// network is stepped, so a network which never is keeps its nodes' messages and timers from going
+// anywhere.
+//
+// Events run on the goroutine stepping the network, but messages may be sent from any goroutine, such
+// as the protocol's side stream workers. The order of the events then depends on the scheduling of
+// those goroutines.
+
+type Config struct {
+	Seed int64
+	// messages and each leg of a dial take Latency plus up to Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+	// fraction of the messages lost, between 0 and 1
+	Loss float64
+}
+
+type Network struct {
+	// mu guards the event queue, the random source and the send counters against concurrent sends
+	mu          sync.Mutex
+	conf        Config
+	rand        *rand.Rand
+	now         time.Time
+	seq         uint64
+	queue       eventQueue
+	nextTimerID int
+	cancelled   map[int]bool
+	nodes       []*Babel
+	byPeer      map[string]*Babel
+	dispatching bool
+}
+
+type event struct {
+	at      time.Time
+	seq     uint64
+	node    *Babel
+	timerID int
+	run     func()
+}
+
+type eventQueue []*event
+
+func (q eventQueue) Len() int { return len(q) }
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+func (q eventQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *eventQueue) Push(x interface{}) { *q = append(*q, x.(*event)) }
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	ev := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return ev
+}
+
+// NewNetwork returns an empty network whose virtual time starts at start.
+func NewNetwork(conf Config, start time.Time) *Network {
+	return &Network{
+		conf:      conf,
+		rand:      rand.New(rand.NewSource(conf.Seed)),
+		now:       start,
+		cancelled: map[int]bool{},
+		byPeer:    map[string]*Babel{},
+	}
+}
+
+// NewNode adds a node listening at self to the network.
+func (n *Network) NewNode(self peer.Peer, logger *logrus.Logger) *Babel {
+	b := newBabel(n, self, logger)
+	n.nodes = append(n.nodes, b)
+	n.byPeer[self.String()] = b
+	return b
+}
+
+// Now returns the virtual time.
+func (n *Network) Now() time.Time {
+	return n.now
+}
+
+func (n *Network) newTimerID() int {
+	n.nextTimerID++
+	return n.nextTimerID
+}
+
+func (n *Network) nodeOf(p peer.Peer) *Babel {
+	return n.byPeer[p.String()]
+}
+
+func (n *Network) schedule(node *Babel, after time.Duration, run func()) {
+	n.scheduleTimer(node, 0, after, run)
+}
+
+func (n *Network) scheduleTimer(node *Babel, timerID int, after time.Duration, run func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.push(node, timerID, after, run)
+}
+
+func (n *Network) push(node *Babel, timerID int, after time.Duration, run func()) {
+	n.seq++
+	heap.Push(&n.queue, &event{at: n.now.Add(after), seq: n.seq, node: node, timerID: timerID, run: run})
+}
+
+// delay draws the duration of one network leg.
+func (n *Network) delay() time.Duration {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.leg()
+}
+
+func (n *Network) leg() time.Duration {
+	d := n.conf.Latency
+	if n.conf.Jitter > 0 {
+		d += time.Duration(n.rand.Int63n(int64(n.conf.Jitter) + 1))
+	}
+	return d
+}
+
+// transmit carries msg to dest, unless it is lost or dest is down when it arrives.
+func (n *Network) transmit(from *Babel, dest peer.Peer, destProto protocol.ID, msg message.Message) {
+	data := msg.Serializer().Serialize(msg)
+	msgType := msg.Type()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	from.sent++
+	target := n.nodeOf(dest)
+	if target == nil || n.rand.Float64() < n.conf.Loss {
+		return
+	}
+	n.push(target, 0, n.leg(), func() { target.receive(from.self, destProto, msgType, data) })
+}
+
+func (n *Network) dispatch(node *Babel, run func()) {
+	if node.down {
+		return
+	}
+	n.dispatching = true
+	defer func() { n.dispatching = false }()
+	run()
+}
+
+// Step runs the next event, advancing the virtual time to it. It returns false if no event is left.
+func (n *Network) Step() bool {
+	ev := n.next(time.Time{})
+	if ev == nil {
+		return false
+	}
+	n.dispatch(ev.node, ev.run)
+	return true
+}
+
+// RunFor runs every event due within d, then advances the virtual time by d.
+func (n *Network) RunFor(d time.Duration) {
+	end := n.now.Add(d)
+	for ev := n.next(end); ev != nil; ev = n.next(end) {
+		n.dispatch(ev.node, ev.run)
+	}
+	n.mu.Lock()
+	n.now = end
+	n.mu.Unlock()
+}
+
+// next pops the next event not cancelled and due by end, unless end is zero, advancing the virtual
+// time to it.
+func (n *Network) next(end time.Time) *event {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for n.queue.Len() > 0 {
+		if !end.IsZero() && n.queue[0].at.After(end) {
+			return nil
+		}
+		ev := heap.Pop(&n.queue).(*event)
+		if ev.timerID != 0 && n.cancelled[ev.timerID] {
+			continue
+		}
+		n.now = ev.at
+		return ev
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Package testutil runs clusters of Hyparview nodes on the loopback interface within one process,
+// over real babel transports, for testing code built on top of the membership layer. It also runs
+// simulations of many nodes over an in-memory protocol manager in virtual time, see sim.go.
+package testutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	babel "github.com/nm-morais/go-babel/pkg"
+	"github.com/nm-morais/go-babel/pkg/peer"
+	"github.com/nm-morais/go-babel/pkg/protocolManager"
+	"github.com/nm-morais/x-bot/protocol"
+)
+
+const (
+	loopbackHost         = "127.0.0.1"
+	convergencePollDelay = 100 * time.Millisecond
+	leaveTimeout         = 5 * time.Second
+)
+
+type Node struct {
+	Peer      peer.Peer
+	Conf      *protocol.HyparviewConfig
+	Babel     protocolManager.ProtocolManager
+	Hyparview *protocol.Hyparview
+}
+
+type Cluster struct {
+	Nodes  []*Node
+	logDir string
+}
+
+// NewTestCluster starts n nodes listening on ephemeral loopback ports, using conf as template for
+// their configs. The first node is the bootstrap of every other node.
+func NewTestCluster(n int, conf protocol.HyparviewConfig) (*Cluster, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("cluster needs at least one node, got %d", n)
+	}
+	logDir, err := ioutil.TempDir("", "hyparview-cluster")
+	if err != nil {
+		return nil, err
+	}
+	c := &Cluster{logDir: logDir}
+	for i := 0; i < n; i++ {
+		port, err := freePort()
+		if err != nil {
+			os.RemoveAll(logDir)
+			return nil, err
+		}
+		nodeConf := conf
+		nodeConf.SelfPeer.Host = loopbackHost
+		nodeConf.SelfPeer.Port = port
+		nodeConf.LogFolder = filepath.Join(logDir, fmt.Sprintf("%s:%d", loopbackHost, port)) + "/"
+		// the first node lists itself, so that it starts as a bootstrap instead of joining
+		bootstrap := nodeConf.SelfPeer
+		if i > 0 {
+			bootstrap = c.Nodes[0].Conf.SelfPeer
+		}
+		nodeConf.BootstrapPeers = append(nodeConf.BootstrapPeers[:0:0], struct {
+			Port          int    `yaml:"port"`
+			Host          string `yaml:"host"`
+			AnalyticsPort int    `yaml:"analyticsPort"`
+		}{Port: bootstrap.Port, Host: bootstrap.Host, AnalyticsPort: bootstrap.AnalyticsPort})
+		c.Nodes = append(c.Nodes, startNode(&nodeConf))
+	}
+	return c, nil
+}
+
+func startNode(conf *protocol.HyparviewConfig) *Node {
+	self := peer.NewPeer(net.ParseIP(conf.SelfPeer.Host), uint16(conf.SelfPeer.Port), uint16(conf.SelfPeer.AnalyticsPort))
+	p := babel.NewProtoManager(babel.Config{
+		Silent:    true,
+		LogFolder: conf.LogFolder,
+		SmConf: babel.StreamManagerConf{
+			BatchMaxSizeBytes: 20000,
+			BatchTimeout:      time.Second,
+			DialTimeout:       protocol.BabelDialTimeout(conf),
+		},
+		Peer: self,
+	})
+	p.RegisterListenAddr(&net.TCPAddr{IP: self.IP(), Port: int(self.ProtosPort())})
+	p.RegisterListenAddr(&net.UDPAddr{IP: self.IP(), Port: int(self.ProtosPort())})
+	hyparview := protocol.NewHyparviewProtocol(p, conf).(*protocol.Hyparview)
+	p.RegisterProtocol(hyparview)
+	p.StartAsync()
+	return &Node{Peer: self, Conf: conf, Babel: p, Hyparview: hyparview}
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", loopbackHost+":0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// WaitForConvergence waits until every node joined, active views are symmetric and they connect all
+// nodes, returning an error describing the last state seen if that does not happen within timeout.
+func (c *Cluster) WaitForConvergence(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := c.converged()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("cluster did not converge within %s: %s", timeout, err)
+		}
+		time.Sleep(convergencePollDelay)
+	}
+}
+
+func (c *Cluster) converged() error {
+	snapshots := make([]protocol.NodeSnapshot, 0, len(c.Nodes))
+	for _, n := range c.Nodes {
+		snapshots = append(snapshots, n.Hyparview.Snapshot())
+	}
+	return checkConvergence(snapshots)
+}
+
+// checkConvergence checks that every node joined, active views are symmetric and they connect all nodes.
+func checkConvergence(snapshots []protocol.NodeSnapshot) error {
+	if len(snapshots) == 1 {
+		// a lone node never gets a neighbour to complete its join with
+		return nil
+	}
+	neighbours := map[string]map[string]bool{}
+	for _, snapshot := range snapshots {
+		if !snapshot.Joined {
+			return fmt.Errorf("%s has not joined", snapshot.Self)
+		}
+		neighbours[snapshot.Self] = map[string]bool{}
+		for _, p := range snapshot.Active {
+			if !p.Connected {
+				return fmt.Errorf("%s is not connected to %s", snapshot.Self, p.Peer)
+			}
+			neighbours[snapshot.Self][p.Peer] = true
+		}
+	}
+	for self, active := range neighbours {
+		for p := range active {
+			if !neighbours[p][self] {
+				return fmt.Errorf("%s has %s as neighbour but not the other way around", self, p)
+			}
+		}
+	}
+	start := snapshots[0].Self
+	reached := map[string]bool{start: true}
+	toVisit := []string{start}
+	for len(toVisit) > 0 {
+		curr := toVisit[0]
+		toVisit = toVisit[1:]
+		for p := range neighbours[curr] {
+			if !reached[p] {
+				reached[p] = true
+				toVisit = append(toVisit, p)
+			}
+		}
+	}
+	if len(reached) != len(snapshots) {
+		return fmt.Errorf("only %d of %d nodes are connected through active views", len(reached), len(snapshots))
+	}
+	return nil
+}
+
+// Close makes every node leave the overlay and removes their log folders.
+func (c *Cluster) Close() {
+	for _, n := range c.Nodes {
+		select {
+		case <-n.Hyparview.Leave():
+		case <-time.After(leaveTimeout):
+		}
+	}
+	os.RemoveAll(c.logDir)
+}
@@ -0,0 +1,48 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/nm-morais/x-bot/protocol"
+)
+
+func snapshot(self string, joined bool, active ...string) protocol.NodeSnapshot {
+	s := protocol.NodeSnapshot{Self: self, Joined: joined}
+	for _, p := range active {
+		s.Active = append(s.Active, protocol.SnapshotPeer{Peer: p, Connected: true})
+	}
+	return s
+}
+
+func TestCheckConvergence(t *testing.T) {
+	unconnected := snapshot("a", true, "b")
+	unconnected.Active[0].Connected = false
+	tests := []struct {
+		name      string
+		snapshots []protocol.NodeSnapshot
+		converged bool
+	}{
+		{"single node", []protocol.NodeSnapshot{snapshot("a", false)}, true},
+		{"line", []protocol.NodeSnapshot{snapshot("a", true, "b"), snapshot("b", true, "a", "c"), snapshot("c", true, "b")}, true},
+		{"not joined", []protocol.NodeSnapshot{snapshot("a", true, "b"), snapshot("b", false, "a")}, false},
+		{"not connected", []protocol.NodeSnapshot{unconnected, snapshot("b", true, "a")}, false},
+		{"asymmetric", []protocol.NodeSnapshot{snapshot("a", true, "b"), snapshot("b", true)}, false},
+		{"neighbour not running", []protocol.NodeSnapshot{snapshot("a", true, "b", "c"), snapshot("b", true, "a")}, false},
+		{"partitioned", []protocol.NodeSnapshot{
+			snapshot("a", true, "b"), snapshot("b", true, "a"),
+			snapshot("c", true, "d"), snapshot("d", true, "c"),
+		}, false},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			err := checkConvergence(test.snapshots)
+			if test.converged && err != nil {
+				t.Fatalf("expected convergence, got %s", err)
+			}
+			if !test.converged && err == nil {
+				t.Fatal("expected no convergence")
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package testutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nm-morais/go-babel/pkg/peer"
+	hyparview "github.com/nm-morais/x-bot/protocol"
+	"github.com/nm-morais/x-bot/testutil/babeltest"
+	"github.com/sirupsen/logrus"
+)
+
+// A Simulation runs Hyparview nodes in one process over a babeltest network, in virtual time: events
+// run one at a time, in time order, and time jumps from one event to the next, so minutes of protocol
+// time take milliseconds. The network delays messages and dials by a configurable latency and jitter,
+// and loses a configurable fraction of the messages. Every node is handed the simulation's clock
+// through its config.
+//
+// Runs are reproducible for a given seed as far as the protocol is: it draws from the seeded global
+// math/rand source, but it also iterates over maps in places, whose order Go randomizes. Side stream
+// workers hand their sends to the network in real time, so runs using them are not reproducible.
+// Other features running their own goroutines (DNS bootstraps, discovery, dial-backs, peer
+// verification, latency probes and the watchdog) must stay disabled.
+
+const (
+	simPort          = 1200
+	simAnalyticsPort = 1300
+	simStepInterval  = time.Second
+)
+
+// simEpoch is the virtual time at which simulations start.
+var simEpoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type SimConfig struct {
+	Seed int64
+	// messages and each leg of a dial take Latency plus up to Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+	// fraction of the messages lost, between 0 and 1
+	Loss float64
+	// logs of the nodes are discarded unless Verbose is set
+	Verbose bool
+	// failure domains assigned to the nodes round robin, the template's is kept if empty
+	FailureDomains []string
+}
+
+type SimNode struct {
+	Peer      peer.Peer
+	Conf      *hyparview.HyparviewConfig
+	Babel     *babeltest.Babel
+	Hyparview *hyparview.Hyparview
+}
+
+type Simulation struct {
+	*babeltest.Network
+	Nodes  []*SimNode
+	conf   SimConfig
+	logDir string
+}
+
+// NewSimulation starts n nodes using conf as template for their configs, the first node being the
+// bootstrap of every other node. Nodes start at the same virtual time, in order.
+func NewSimulation(n int, conf hyparview.HyparviewConfig, simConf SimConfig) (*Simulation, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("simulation needs at least one node, got %d", n)
+	}
+	if simConf.Loss < 0 || simConf.Loss > 1 || simConf.Latency < 0 || simConf.Jitter < 0 {
+		return nil, fmt.Errorf("invalid network settings %+v", simConf)
+	}
+	logDir, err := ioutil.TempDir("", "hyparview-sim")
+	if err != nil {
+		return nil, err
+	}
+	s := &Simulation{
+		Network: babeltest.NewNetwork(babeltest.Config{
+			Seed:    simConf.Seed,
+			Latency: simConf.Latency,
+			Jitter:  simConf.Jitter,
+			Loss:    simConf.Loss,
+		}, simEpoch),
+		conf:   simConf,
+		logDir: logDir,
+	}
+	rand.Seed(simConf.Seed)
+	for i := 1; i <= n; i++ {
+		ip := net.IPv4(10, byte(i>>16), byte(i>>8), byte(i))
+		nodeConf := conf
+		nodeConf.SelfPeer.Host = ip.String()
+		nodeConf.SelfPeer.Port = simPort
+		nodeConf.SelfPeer.AnalyticsPort = simAnalyticsPort
+		nodeConf.LogFolder = filepath.Join(logDir, fmt.Sprintf("%s:%d", ip, simPort)) + "/"
+		nodeConf.Clock = s.Now
+		if len(simConf.FailureDomains) > 0 {
+			nodeConf.FailureDomain = simConf.FailureDomains[(i-1)%len(simConf.FailureDomains)]
+		}
+		// the first node lists itself, so that it starts as a bootstrap instead of joining
+		bootstrap := nodeConf.SelfPeer
+		if i > 1 {
+			bootstrap = s.Nodes[0].Conf.SelfPeer
+		}
+		nodeConf.BootstrapPeers = append(nodeConf.BootstrapPeers[:0:0], struct {
+			Port          int    `yaml:"port"`
+			Host          string `yaml:"host"`
+			AnalyticsPort int    `yaml:"analyticsPort"`
+		}{Port: bootstrap.Port, Host: bootstrap.Host, AnalyticsPort: bootstrap.AnalyticsPort})
+		s.Nodes = append(s.Nodes, s.startNode(&nodeConf))
+	}
+	return s, nil
+}
+
+func (s *Simulation) startNode(conf *hyparview.HyparviewConfig) *SimNode {
+	self := peer.NewPeer(net.ParseIP(conf.SelfPeer.Host), uint16(conf.SelfPeer.Port), uint16(conf.SelfPeer.AnalyticsPort))
+	logger := logrus.New()
+	if !s.conf.Verbose {
+		logger.SetOutput(ioutil.Discard)
+	}
+	b := s.NewNode(self, logger)
+	node := hyparview.NewHyparviewProtocol(b, conf).(*hyparview.Hyparview)
+	if !s.conf.Verbose {
+		node.Logger().SetOutput(ioutil.Discard)
+	}
+	b.RegisterProtocol(node)
+	b.StartAsync()
+	return &SimNode{Peer: self, Conf: conf, Babel: b, Hyparview: node}
+}
+
+// Crash stops the i-th node without it leaving the overlay: its pending events are dropped, and the
+// nodes connected to it see their connection go down one network leg later.
+func (s *Simulation) Crash(i int) {
+	s.Nodes[i].Babel.Crash()
+}
+
+// CrashDomain crashes every running node of a failure domain at once, as Crash does, and returns how
+// many nodes it crashed.
+func (s *Simulation) CrashDomain(domain string) int {
+	crashed := 0
+	for i, n := range s.Nodes {
+		if n.Conf.FailureDomain == domain && !n.Babel.Down() {
+			s.Crash(i)
+			crashed++
+		}
+	}
+	return crashed
+}
+
+// Converged checks that every running node joined, active views are symmetric and they connect all
+// running nodes.
+func (s *Simulation) Converged() error {
+	snapshots := []hyparview.NodeSnapshot{}
+	for _, n := range s.Nodes {
+		if !n.Babel.Down() {
+			snapshots = append(snapshots, n.Hyparview.Snapshot())
+		}
+	}
+	return checkConvergence(snapshots)
+}
+
+// WaitForConvergence runs the simulation until it converges, returning an error describing the last
+// state seen if that does not happen within timeout of virtual time.
+func (s *Simulation) WaitForConvergence(timeout time.Duration) error {
+	deadline := s.Now().Add(timeout)
+	for {
+		err := s.Converged()
+		if err == nil {
+			return nil
+		}
+		if !s.Now().Before(deadline) {
+			return fmt.Errorf("simulation did not converge within %s: %s", timeout, err)
+		}
+		s.RunFor(simStepInterval)
+	}
+}
+
+// Close removes the log folders of the nodes.
+func (s *Simulation) Close() {
+	os.RemoveAll(s.logDir)
+}